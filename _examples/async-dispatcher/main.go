@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/pchchv/wh/dispatch"
+	"github.com/pchchv/wh/github"
+)
+
+const path = "/webhooks"
+
+func main() {
+	hook, _ := github.New(github.Options.Secret("MyGitHubSuperSecretSecrect...?"))
+	dispatcher, _ := dispatch.New(
+		func(r *http.Request) (interface{}, error) {
+			return hook.Parse(r, github.ReleaseEvent, github.PullRequestEvent)
+		},
+		func(ctx context.Context, payload interface{}) error {
+			switch payload := payload.(type) {
+			case github.ReleasePayload:
+				// do whatever you want from here...
+				fmt.Printf("%+v", payload)
+			case github.PullRequestPayload:
+				// do whatever you want from here...
+				fmt.Printf("%+v", payload)
+			}
+			return nil
+		},
+		dispatch.Options.Workers(8),
+		dispatch.Options.QueueSize(128),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle(path, dispatcher)
+	server := &http.Server{Addr: ":3000", Handler: mux}
+	go func() {
+		_ = server.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_ = server.Shutdown(ctx)
+	_ = dispatcher.Shutdown(ctx)
+}
@@ -1,4 +1,18 @@
 // The `azure` package accepts Azure DevOps Server webhooks.
+//
+// The payload holds the event type and the resource version selected by the subscription, e.g. "1.0" or "2.0"
+// of pull requests. Parse decodes the resource of each supported version and returns an error wrapping
+// ErrUnsupportedResourceVersion for the others. The supported versions are:
+//
+//   - git.push: 1.0, 2.0
+//   - git.pullrequest.created, git.pullrequest.merged, git.pullrequest.updated: 1.0, 2.0 (GitPullRequestEventV2)
+//   - git.pullrequest.comment-event, ms.vss-code.git-pullrequest-comment-event: 2.0
+//   - build.complete: 1.0, 2.0 (BuildCompleteEventV2)
+//   - workitem.created, workitem.updated, workitem.deleted, workitem.restored, workitem.commented: 1.0, 2.0, 3.0
+//   - ms.vss-release.deployment-completed-event: 3.0-preview
+//   - ms.vss-pipelines.run-state-changed-event, ms.vss-pipelines.stage-state-changed-event: 5.1-preview
+//
+// A payload without a resource version is decoded like the first version listed for its event.
 package azure
 
 import (
@@ -6,7 +20,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
 )
 
 const (
@@ -16,6 +37,18 @@ const (
 	GitPullRequestMergedEventType  Event = "git.pullrequest.merged"
 	GitPullRequestCreatedEventType Event = "git.pullrequest.created"
 	GitPullRequestUpdatedEventType Event = "git.pullrequest.updated"
+	GitPullRequestCommentEventType Event = "git.pullrequest.comment-event"
+	// Work item hook types.
+	WorkItemCreatedEventType   Event = "workitem.created"
+	WorkItemUpdatedEventType   Event = "workitem.updated"
+	WorkItemDeletedEventType   Event = "workitem.deleted"
+	WorkItemRestoredEventType  Event = "workitem.restored"
+	WorkItemCommentedEventType Event = "workitem.commented"
+	// Extension hook types, named by their publisher.
+	CodeGitPullRequestCommentEventType  Event = "ms.vss-code.git-pullrequest-comment-event"
+	ReleaseDeploymentCompletedEventType Event = "ms.vss-release.deployment-completed-event"
+	PipelinesRunStateChangedEventType   Event = "ms.vss-pipelines.run-state-changed-event"
+	PipelinesStageStateChangedEventType Event = "ms.vss-pipelines.stage-state-changed-event"
 )
 
 var (
@@ -24,26 +57,53 @@ var (
 	// Parse errors.
 	ErrParsingPayload              = errors.New("error parsing payload")
 	ErrBasicAuthVerificationFailed = errors.New("basic auth verification failed")
+	// ErrUnsupportedResourceVersion is returned when the resource version of a subscription is not decoded,
+	// as its resource would not match the payload of the event.
+	ErrUnsupportedResourceVersion = errors.New("unsupported resource version")
 )
 
 // Event defines an Azure DevOps server hook event type.
 type Event string
 
+// provider describes the deliveries of Azure DevOps to the instrumentation.
+var provider = &observe.Provider{
+	Name: "azure",
+}
+
 // Webhook instance contains all methods needed to process events.
 type Webhook struct {
 	username string
 	password string
+	observer observe.Observer
 }
 
 // Parse verifies and parses the events specified and returns the payload object or an error.
-func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error) {
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
 	defer func() {
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
 	}()
 
-	if !hook.verifyBasicAuth(r) {
-		return nil, ErrBasicAuthVerificationFailed
+	err := d.Verify(hook.username != "" || hook.password != "", func() error {
+		if !hook.verifyBasicAuth(r) {
+			return ErrBasicAuthVerificationFailed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if r.Method != http.MethodPost {
@@ -55,28 +115,26 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 		return nil, ErrParsingPayload
 	}
 
+	d.Decoding()
 	var pl BasicEvent
 	err = json.Unmarshal([]byte(payload), &pl)
 	if err != nil {
 		return nil, ErrParsingPayload
 	}
 
-	switch pl.EventType {
-	case GitPushEventType:
-		var fpl GitPushEvent
-		err = json.Unmarshal([]byte(payload), &fpl)
-		return fpl, err
-	case GitPullRequestCreatedEventType, GitPullRequestMergedEventType, GitPullRequestUpdatedEventType:
-		var fpl GitPullRequestEvent
-		err = json.Unmarshal([]byte(payload), &fpl)
-		return fpl, err
-	case BuildCompleteEventType:
-		var fpl BuildCompleteEvent
-		err = json.Unmarshal([]byte(payload), &fpl)
-		return fpl, err
-	default:
+	// Azure DevOps sends the event type and ID in the payload
+	d.Event, d.ID = string(pl.EventType), pl.ID
+
+	versions, ok := resources[pl.EventType]
+	if !ok {
 		return nil, fmt.Errorf("unknown event %s", pl.EventType)
 	}
+
+	decode, ok := versions[resourceVersion(pl.ResourceVersion)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q of %s", ErrUnsupportedResourceVersion, pl.ResourceVersion, pl.EventType)
+	}
+	return decode(payload)
 }
 
 func (hook Webhook) verifyBasicAuth(r *http.Request) bool {
@@ -89,6 +147,46 @@ func (hook Webhook) verifyBasicAuth(r *http.Request) bool {
 	return ok && username == hook.username && password == hook.password
 }
 
+// resources maps the events to the decoders of the payloads of each supported resource version,
+// "" decodes a payload without a version like Parse did before it read the version.
+// git.push 2.0 and work items 2.0 and 3.0 share the resource of 1.0.
+var resources = map[Event]map[string]func([]byte) (interface{}, error){
+	GitPushEventType:                    {"": decode[GitPushEvent], "1.0": decode[GitPushEvent], "2.0": decode[GitPushEvent]},
+	GitPullRequestCreatedEventType:      {"": decode[GitPullRequestEvent], "1.0": decode[GitPullRequestEvent], "2.0": decode[GitPullRequestEventV2]},
+	GitPullRequestMergedEventType:       {"": decode[GitPullRequestEvent], "1.0": decode[GitPullRequestEvent], "2.0": decode[GitPullRequestEventV2]},
+	GitPullRequestUpdatedEventType:      {"": decode[GitPullRequestEvent], "1.0": decode[GitPullRequestEvent], "2.0": decode[GitPullRequestEventV2]},
+	GitPullRequestCommentEventType:      {"": decode[GitPullRequestCommentEvent], "2.0": decode[GitPullRequestCommentEvent]},
+	CodeGitPullRequestCommentEventType:  {"": decode[GitPullRequestCommentEvent], "2.0": decode[GitPullRequestCommentEvent]},
+	BuildCompleteEventType:              {"": decode[BuildCompleteEvent], "1.0": decode[BuildCompleteEvent], "2.0": decode[BuildCompleteEventV2]},
+	WorkItemCreatedEventType:            workItemVersions(decode[WorkItemEvent]),
+	WorkItemUpdatedEventType:            workItemVersions(decode[WorkItemUpdatedEvent]),
+	WorkItemDeletedEventType:            workItemVersions(decode[WorkItemEvent]),
+	WorkItemRestoredEventType:           workItemVersions(decode[WorkItemEvent]),
+	WorkItemCommentedEventType:          workItemVersions(decode[WorkItemEvent]),
+	ReleaseDeploymentCompletedEventType: {"": decode[DeploymentCompletedEvent], "3.0-preview": decode[DeploymentCompletedEvent]},
+	PipelinesRunStateChangedEventType:   {"": decode[RunStateChangedEvent], "5.1-preview": decode[RunStateChangedEvent]},
+	PipelinesStageStateChangedEventType: {"": decode[StageStateChangedEvent], "5.1-preview": decode[StageStateChangedEvent]},
+}
+
+func workItemVersions(decode func([]byte) (interface{}, error)) map[string]func([]byte) (interface{}, error) {
+	return map[string]func([]byte) (interface{}, error){"": decode, "1.0": decode, "2.0": decode, "3.0": decode}
+}
+
+func decode[T any](payload []byte) (interface{}, error) {
+	var pl T
+	err := json.Unmarshal(payload, &pl)
+	return pl, err
+}
+
+// resourceVersion returns the version without the revision of a preview,
+// e.g. "5.1-preview" of "5.1-preview.1", as subscriptions select a preview regardless of its revision.
+func resourceVersion(version string) string {
+	if i := strings.Index(version, "-preview"); i >= 0 {
+		return version[:i+len("-preview")]
+	}
+	return version
+}
+
 // Option is a configuration option for the webhook.
 type Option func(*Webhook) error
 
@@ -114,3 +212,38 @@ func (WebhookOptions) BasicAuth(username, password string) Option {
 		return nil
 	}
 }
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
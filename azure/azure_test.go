@@ -2,14 +2,19 @@ package azure
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"testing"
+	"time"
 
+	"github.com/pchchv/wh/whtest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -45,6 +50,12 @@ func TestWebhooks(t *testing.T) {
 			typ:      BuildCompleteEvent{},
 			filename: "./testdata/build.complete.json",
 		},
+		{
+			name:     "build.complete 2.0",
+			event:    BuildCompleteEventType,
+			typ:      BuildCompleteEventV2{},
+			filename: "./testdata/build.complete.v2.json",
+		},
 		{
 			name:     "git.pullrequest.created",
 			event:    GitPullRequestCreatedEventType,
@@ -63,12 +74,78 @@ func TestWebhooks(t *testing.T) {
 			typ:      GitPullRequestEvent{},
 			filename: "./testdata/git.pullrequest.updated.json",
 		},
+		{
+			name:     "git.pullrequest.updated 2.0",
+			event:    GitPullRequestUpdatedEventType,
+			typ:      GitPullRequestEventV2{},
+			filename: "./testdata/git.pullrequest.updated.v2.json",
+		},
 		{
 			name:     "git.push",
 			event:    GitPushEventType,
 			typ:      GitPushEvent{},
 			filename: "./testdata/git.push.json",
 		},
+		{
+			name:     "git.pullrequest.comment-event",
+			event:    GitPullRequestCommentEventType,
+			typ:      GitPullRequestCommentEvent{},
+			filename: "./testdata/git.pullrequest.comment-event.json",
+		},
+		{
+			name:     "ms.vss-code.git-pullrequest-comment-event",
+			event:    CodeGitPullRequestCommentEventType,
+			typ:      GitPullRequestCommentEvent{},
+			filename: "./testdata/ms.vss-code.git-pullrequest-comment-event.json",
+		},
+		{
+			name:     "ms.vss-pipelines.run-state-changed-event",
+			event:    PipelinesRunStateChangedEventType,
+			typ:      RunStateChangedEvent{},
+			filename: "./testdata/ms.vss-pipelines.run-state-changed-event.json",
+		},
+		{
+			name:     "ms.vss-pipelines.stage-state-changed-event",
+			event:    PipelinesStageStateChangedEventType,
+			typ:      StageStateChangedEvent{},
+			filename: "./testdata/ms.vss-pipelines.stage-state-changed-event.json",
+		},
+		{
+			name:     "ms.vss-release.deployment-completed-event",
+			event:    ReleaseDeploymentCompletedEventType,
+			typ:      DeploymentCompletedEvent{},
+			filename: "./testdata/ms.vss-release.deployment-completed-event.json",
+		},
+		{
+			name:     "workitem.commented",
+			event:    WorkItemCommentedEventType,
+			typ:      WorkItemEvent{},
+			filename: "./testdata/workitem.commented.json",
+		},
+		{
+			name:     "workitem.created",
+			event:    WorkItemCreatedEventType,
+			typ:      WorkItemEvent{},
+			filename: "./testdata/workitem.created.json",
+		},
+		{
+			name:     "workitem.deleted",
+			event:    WorkItemDeletedEventType,
+			typ:      WorkItemEvent{},
+			filename: "./testdata/workitem.deleted.json",
+		},
+		{
+			name:     "workitem.restored",
+			event:    WorkItemRestoredEventType,
+			typ:      WorkItemEvent{},
+			filename: "./testdata/workitem.restored.json",
+		},
+		{
+			name:     "workitem.updated",
+			event:    WorkItemUpdatedEventType,
+			typ:      WorkItemUpdatedEvent{},
+			filename: "./testdata/workitem.updated.json",
+		},
 	}
 
 	for _, tt := range tests {
@@ -101,6 +178,148 @@ func TestWebhooks(t *testing.T) {
 	}
 }
 
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	parse := func(event Event, filename string) interface{} {
+		pl, err := hook.Parse(whtest.Azure(whtest.Fixture(t, filename)).Request(), event)
+		assert.NoError(err)
+		return pl
+	}
+
+	push := parse(GitPushEventType, "./testdata/git.push.json").(GitPushEvent)
+	assert.Equal(GitPushEventType, push.EventType)
+	assert.Equal("c12d0eb8-e382-443b-9f9c-c52cba5014c2", push.ResourceContainers.Collection.ID)
+	assert.Equal("be9b3917-87e6-42a4-a549-2bc06a7a878f", push.ResourceContainers.Project.ID)
+	assert.Nil(push.ResourceContainers.Server)
+	assert.Equal(time.Date(2016, 9, 19, 13, 3, 27, 37915300, time.UTC), time.Time(push.CreatedDate))
+
+	pr1 := parse(GitPullRequestCreatedEventType, "./testdata/git.pullrequest.created.json").(GitPullRequestEvent)
+	assert.Equal(push.ResourceContainers, pr1.ResourceContainers)
+
+	created := parse(WorkItemCreatedEventType, "./testdata/workitem.created.json").(WorkItemEvent)
+	assert.Equal(WorkItemCreatedEventType, created.EventType)
+	assert.Equal(5, created.Resource.ID)
+	assert.Equal("Bug", created.Resource.Fields["System.WorkItemType"])
+	assert.Equal("be9b3917-87e6-42a4-a549-2bc06a7a878f", created.ResourceContainers.Project.ID)
+
+	updated := parse(WorkItemUpdatedEventType, "./testdata/workitem.updated.json").(WorkItemUpdatedEvent)
+	assert.Equal(5, updated.Resource.WorkItemID)
+	assert.Equal("New", updated.Resource.Fields["System.State"].OldValue)
+	assert.Equal("Approved", updated.Resource.Fields["System.State"].NewValue)
+	assert.Equal("Approved", updated.Resource.Revision.Fields["System.State"])
+	assert.Equal("Jamal Hartnett", updated.Resource.RevisedBy.DisplayName)
+
+	comment := parse(CodeGitPullRequestCommentEventType, "./testdata/ms.vss-code.git-pullrequest-comment-event.json").(GitPullRequestCommentEvent)
+	assert.Equal("This is my comment.", comment.Resource.Comment.Content)
+	assert.Equal(1, comment.Resource.Comment.ParentCommentID)
+	assert.Equal(1, comment.Resource.PullRequest.PullRequestID)
+
+	deployment := parse(ReleaseDeploymentCompletedEventType, "./testdata/ms.vss-release.deployment-completed-event.json").(DeploymentCompletedEvent)
+	assert.Equal("Dev", deployment.Resource.Environment.Name)
+	assert.Equal("succeeded", deployment.Resource.Environment.Status)
+	assert.Equal("Release-5", deployment.Resource.Environment.Release.Name)
+
+	run := parse(PipelinesRunStateChangedEventType, "./testdata/ms.vss-pipelines.run-state-changed-event.json").(RunStateChangedEvent)
+	assert.Equal("succeeded", run.Resource.Run.Result)
+	assert.Equal("FabrikamFiber.CI", run.Resource.Pipeline.Name)
+	assert.True(time.Time(run.Resource.Run.FinishedDate).After(time.Time(run.Resource.Run.CreatedDate)))
+
+	stage := parse(PipelinesStageStateChangedEventType, "./testdata/ms.vss-pipelines.stage-state-changed-event.json").(StageStateChangedEvent)
+	assert.Equal("Build", stage.Resource.Stage.Name)
+	assert.Equal(run.Resource.Run.ID, stage.Resource.Run.ID)
+
+	pr := parse(GitPullRequestUpdatedEventType, "./testdata/git.pullrequest.updated.v2.json").(GitPullRequestEventV2)
+	assert.Equal("2.0", pr.ResourceVersion)
+	assert.Equal("needs-review", pr.Resource.Labels[0].Name)
+	assert.True(pr.Resource.Reviewers[0].IsRequired)
+	assert.Equal("squash", pr.Resource.CompletionOptions.MergeStrategy)
+	assert.Equal("Jamal Hartnett", pr.Resource.CreatedBy.DisplayName)
+
+	build := parse(BuildCompleteEventType, "./testdata/build.complete.v2.json").(BuildCompleteEventV2)
+	assert.Equal("https://tfs.fabrikam.com/DefaultCollection/", build.ResourceContainers.Collection.BaseURL)
+	assert.Equal("8a0d3b7c-0c25-4dd8-b9cc-2f1a0f1e4d5b", build.ResourceContainers.Server.ID)
+	assert.Equal("succeeded", build.Resource.Result)
+	assert.Equal("ConsumerAddressModule", build.Resource.Definition.Name)
+	assert.Equal("Hosted", build.Resource.Queue.Pool.Name)
+	assert.Equal("Jamal Hartnett", build.Resource.RequestedFor.DisplayName)
+}
+
+func TestResourceVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		filename string
+		version  string
+		typ      interface{}
+	}{
+		{
+			name:     "PreviewRevision",
+			event:    PipelinesRunStateChangedEventType,
+			filename: "./testdata/ms.vss-pipelines.run-state-changed-event.json",
+			version:  "5.1-preview.2",
+			typ:      RunStateChangedEvent{},
+		},
+		{
+			name:     "UnsupportedPreview",
+			event:    PipelinesRunStateChangedEventType,
+			filename: "./testdata/ms.vss-pipelines.run-state-changed-event.json",
+			version:  "7.1-preview.1",
+		},
+		{
+			name:     "UnsupportedVersion",
+			event:    GitPullRequestCreatedEventType,
+			filename: "./testdata/git.pullrequest.created.json",
+			version:  "3.0",
+		},
+		{
+			name:     "MissingVersion",
+			event:    GitPushEventType,
+			filename: "./testdata/git.push.json",
+			typ:      GitPushEvent{},
+		},
+		{
+			name:     "MissingPullRequestVersion",
+			event:    GitPullRequestCreatedEventType,
+			filename: "./testdata/git.pullrequest.created.json",
+			typ:      GitPullRequestEvent{},
+		},
+		{
+			name:     "GitPushV2",
+			event:    GitPushEventType,
+			filename: "./testdata/git.push.json",
+			version:  "2.0",
+			typ:      GitPushEvent{},
+		},
+		{
+			name:     "WorkItemV3",
+			event:    WorkItemUpdatedEventType,
+			filename: "./testdata/workitem.updated.json",
+			version:  "3.0",
+			typ:      WorkItemUpdatedEvent{},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			var pl map[string]interface{}
+			assert.NoError(json.Unmarshal(whtest.Fixture(t, tc.filename), &pl))
+			pl["resourceVersion"] = tc.version
+
+			results, err := hook.Parse(whtest.Azure(pl).Request(), tc.event)
+			if tc.typ == nil {
+				assert.ErrorIs(err, ErrUnsupportedResourceVersion)
+				assert.Nil(results)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
 func TestBasicAuth(t *testing.T) {
 	const user = "user"
 	const pass = "pass123"
@@ -167,3 +386,165 @@ func newServer(handler http.HandlerFunc) *httptest.Server {
 	mux.HandleFunc(virtualDir, handler)
 	return httptest.NewServer(mux)
 }
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("fabrikam/Fabrikam-Fiber-Git").User("Normal Paulk")
+	push := faker.GitPushEvent("main", 3)
+	update := push.Resource.RefUpdates[0]
+	assert.Equal("refs/heads/main", update.Name)
+	assert.Len(push.Resource.Commits, 3)
+	// newest first
+	assert.Equal(update.NewObjectID, push.Resource.Commits[0].CommitID)
+	assert.Equal("Fabrikam-Fiber-Git", push.Resource.Repository.Name)
+	assert.Equal("Normal Paulk", push.Resource.PushedBy.DisplayName)
+	assert.Equal(push, NewFaker("fabrikam/Fabrikam-Fiber-Git").User("Normal Paulk").GitPushEvent("main", 3))
+
+	merged := faker.GitPullRequestEvent(GitPullRequestMergedEventType, "feature", "main")
+	assert.Equal("completed", merged.Resource.Status)
+	assert.Equal("refs/heads/feature", merged.Resource.SourceRefName)
+	assert.Equal("refs/heads/main", merged.Resource.TargetRefName)
+	assert.Equal(push.Resource.Repository, merged.Resource.Repository)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: GitPushEventType, payload: push},
+		{event: GitPullRequestMergedEventType, payload: merged},
+		{event: GitPullRequestCreatedEventType, payload: faker.GitPullRequestEvent(GitPullRequestCreatedEventType, "feature", "main")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Azure(tc.payload).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{BuildCompleteEventType, "./testdata/build.complete.json"},
+		{BuildCompleteEventType, "./testdata/build.complete.v2.json"},
+		{GitPullRequestCreatedEventType, "./testdata/git.pullrequest.created.json"},
+		{GitPullRequestMergedEventType, "./testdata/git.pullrequest.merged.json"},
+		{GitPullRequestUpdatedEventType, "./testdata/git.pullrequest.updated.json"},
+		{GitPullRequestUpdatedEventType, "./testdata/git.pullrequest.updated.v2.json"},
+		{GitPushEventType, "./testdata/git.push.json"},
+		{GitPullRequestCommentEventType, "./testdata/git.pullrequest.comment-event.json"},
+		{CodeGitPullRequestCommentEventType, "./testdata/ms.vss-code.git-pullrequest-comment-event.json"},
+		{PipelinesRunStateChangedEventType, "./testdata/ms.vss-pipelines.run-state-changed-event.json"},
+		{PipelinesStageStateChangedEventType, "./testdata/ms.vss-pipelines.stage-state-changed-event.json"},
+		{ReleaseDeploymentCompletedEventType, "./testdata/ms.vss-release.deployment-completed-event.json"},
+		{WorkItemCommentedEventType, "./testdata/workitem.commented.json"},
+		{WorkItemCreatedEventType, "./testdata/workitem.created.json"},
+		{WorkItemDeletedEventType, "./testdata/workitem.deleted.json"},
+		{WorkItemRestoredEventType, "./testdata/workitem.restored.json"},
+		{WorkItemUpdatedEventType, "./testdata/workitem.updated.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), payload)
+	}
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event string, payload []byte) {
+		r := whtest.Azure(payload).Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
+
+func FuzzDate(f *testing.F) {
+	files, err := filepath.Glob("./testdata/*.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	dates := regexp.MustCompile(`"\d{4}-\d{2}-\d{2}[ T][^"]*"`)
+	seen := map[string]bool{}
+	for _, file := range files {
+		payload, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		for _, date := range dates.FindAll(payload, -1) {
+			if !seen[string(date)] {
+				seen[string(date)] = true
+				f.Add(date)
+			}
+		}
+	}
+	f.Add([]byte("null"))
+	f.Add([]byte(`"0001-01-01T00:00:00"`))
+	f.Add([]byte(`"2024-06-03T08:44:01.123"`))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var d Date
+		if err := d.UnmarshalJSON(b); err != nil {
+			return
+		}
+
+		// every accepted date must survive the round trip
+		encoded, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded Date
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("decoding %s encoded from %q: %v", encoded, b, err)
+		}
+
+		if !time.Time(decoded).Equal(time.Time(d)) {
+			t.Fatalf("%q decoded to %v, encoded as %s decoded to %v", b, time.Time(d), encoded, time.Time(decoded))
+		}
+
+		if reencoded, err := json.Marshal(decoded); err != nil || !bytes.Equal(reencoded, encoded) {
+			t.Fatalf("%q encoded as %s, then as %s: %v", b, encoded, reencoded, err)
+		}
+	})
+}
+
+func TestDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "UTC", json: `"2016-09-19T13:03:27.0379153Z"`, want: time.Date(2016, 9, 19, 13, 3, 27, 37915300, time.UTC)},
+		{name: "Offset", json: `"2016-09-19T15:03:27+02:00"`, want: time.Date(2016, 9, 19, 13, 3, 27, 0, time.UTC)},
+		{name: "Null", json: `null`},
+		{name: "ZoneLess", json: `"0001-01-01T00:00:00"`},
+		{name: "ZoneLessFraction", json: `"2024-06-03T08:44:01.123"`, want: time.Date(2024, 6, 3, 8, 44, 1, 123000000, time.UTC)},
+		{name: "Escaped", json: `"2024-06-03T08:44:01\u005a"`, want: time.Date(2024, 6, 3, 8, 44, 1, 0, time.UTC)},
+		{name: "Unquoted", json: `2024`, wantErr: true},
+		{name: "QuotesInside", json: `"2024-06-03T08:"44:01Z"`, wantErr: true},
+		{name: "Invalid", json: `"yesterday"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			var d struct {
+				Date Date `json:"date"`
+			}
+			err := json.Unmarshal([]byte(`{"date":`+tc.json+`}`), &d)
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.True(tc.want.Equal(time.Time(d.Date)), "%s decoded to %v", tc.json, time.Time(d.Date))
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package azure
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic events of a repository to unit test handlers.
+// The events of a faker agree with each other on the project, repository, users, refs and commits,
+// and encode to the JSON Azure DevOps sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same events.
+type Faker struct {
+	organization string
+	project      string
+	projectID    string
+	repositoryID string
+	collectionID string
+	user         User
+	src          *fake.Source
+}
+
+// NewFaker returns a faker of the Git repository of the project with the given full name, e.g. "fabrikam/Fabrikam-Fiber-Git".
+// Events are triggered by the user "Jamal Hartnett".
+func NewFaker(fullName string) *Faker {
+	organization, project, ok := strings.Cut(fullName, "/")
+	if !ok {
+		organization, project = "fabrikam", fullName
+	}
+
+	src := fake.New("azure/" + fullName)
+	f := &Faker{
+		organization: organization,
+		project:      project,
+		projectID:    src.UUID(),
+		repositoryID: src.UUID(),
+		collectionID: fake.New("azure/" + organization).UUID(),
+		src:          src,
+	}
+	return f.User("Jamal Hartnett")
+}
+
+// User sets the display name of the user triggering the events.
+func (f *Faker) User(displayName string) *Faker {
+	id := fake.New("azure/user/" + displayName).UUID()
+	f.user = User{
+		ID:          id,
+		DisplayName: displayName,
+		UniqueName:  strings.ReplaceAll(strings.ToLower(displayName), " ", ".") + "@example.com",
+		URL:         f.baseURL() + "/_apis/Identities/" + id,
+		ImageURL:    f.baseURL() + "/_api/_common/identityImage?id=" + id,
+	}
+	return f
+}
+
+// SHA returns a new commit ID.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// GitPushEvent returns the push of commits to ref, a branch name or a full ref such as "refs/tags/v1.0.0".
+// The commits follow each other, the first one listed being the new object of the ref, as Azure DevOps lists them newest first.
+func (f *Faker) GitPushEvent(ref string, commits int) GitPushEvent {
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	created := f.src.Time()
+	update := RefUpdate{Name: ref, OldObjectID: f.SHA()}
+	update.NewObjectID = update.OldObjectID
+	pushed := make([]Commit, commits)
+	for i := range pushed {
+		update.NewObjectID = f.SHA()
+		pushed[len(pushed)-1-i] = Commit{CommitID: update.NewObjectID, URL: f.repositoryURL() + "/commit/" + update.NewObjectID}
+	}
+
+	branch := strings.TrimPrefix(ref, "refs/heads/")
+	text := fmt.Sprintf("%s pushed updates to %s of repository %s.", f.user.DisplayName, branch, f.project)
+	pushID := int(f.src.ID() % 100)
+	return GitPushEvent{
+		ID:              f.src.UUID(),
+		Scope:           "all",
+		EventType:       GitPushEventType,
+		CreatedDate:     Date(created),
+		PublisherID:     "tfs",
+		ResourceVersion: "1.0",
+		Message:         Message{Text: text, HTML: text, Markdown: text},
+		DetailedMessage: Message{
+			Text:     fmt.Sprintf("%s pushed %d commits to %s of repository %s.", f.user.DisplayName, commits, branch, f.project),
+			HTML:     text,
+			Markdown: text,
+		},
+		ResourceContainers: f.resourceContainers(),
+		Resource: Resource{
+			PushID:     pushID,
+			URL:        fmt.Sprintf("%s/pushes/%d", f.repositoryAPIURL(), pushID),
+			Date:       created.Format("2006-01-02T15:04:05Z"),
+			Commits:    pushed,
+			PushedBy:   PushedBy{ID: f.user.ID, UniqueName: f.user.UniqueName, DisplayName: f.user.DisplayName},
+			Repository: f.repository(),
+			RefUpdates: []RefUpdate{update},
+		},
+	}
+}
+
+// GitPullRequestEvent returns a pull request event merging the source branch into the target branch.
+// Merged events get a completed pull request with a merge commit.
+func (f *Faker) GitPullRequestEvent(event Event, source, target string) GitPullRequestEvent {
+	id := int(f.src.ID()%1000 + 1)
+	created := f.src.Time()
+	head := Commit{CommitID: f.SHA()}
+	head.URL = f.repositoryURL() + "/commit/" + head.CommitID
+	base := Commit{CommitID: f.SHA()}
+	base.URL = f.repositoryURL() + "/commit/" + base.CommitID
+	pr := PullRequest{
+		PullRequestID:         id,
+		URL:                   fmt.Sprintf("%s/pullRequests/%d", f.repositoryAPIURL(), id),
+		Title:                 "Merge " + source + " into " + target,
+		Description:           "Changes of " + source + ".",
+		MergeID:               f.src.UUID(),
+		MergeStatus:           "succeeded",
+		SourceRefName:         "refs/heads/" + source,
+		TargetRefName:         "refs/heads/" + target,
+		Status:                "active",
+		Commits:               []Commit{head},
+		Reviewers:             []Reviewer{},
+		CreatedBy:             f.user,
+		Repository:            f.repository(),
+		CreationDate:          Date(created),
+		LastMergeSourceCommit: head,
+		LastMergeTargetCommit: base,
+	}
+
+	verb := "updated"
+	switch event {
+	case GitPullRequestCreatedEventType:
+		verb = "created"
+	case GitPullRequestMergedEventType:
+		verb = "completed"
+		merge := Commit{CommitID: f.SHA()}
+		merge.URL = f.repositoryURL() + "/commit/" + merge.CommitID
+		pr.Status = "completed"
+		pr.ClosedDate = Date(f.src.Time())
+		pr.LastMergeCommit = merge
+	}
+
+	text := fmt.Sprintf("%s %s pull request %d (%s) in %s", f.user.DisplayName, verb, id, pr.Title, f.project)
+	return GitPullRequestEvent{
+		ID:                 f.src.UUID(),
+		Scope:              "all",
+		PublisherID:        "tfs",
+		ResourceVersion:    "1.0",
+		CreatedDate:        Date(f.src.Time()),
+		EventType:          event,
+		Message:            Message{Text: text, HTML: text, Markdown: text},
+		DetailedMessage:    Message{Text: text, HTML: text, Markdown: text},
+		Resource:           pr,
+		ResourceContainers: f.resourceContainers(),
+	}
+}
+
+func (f *Faker) resourceContainers() ResourceContainers {
+	return ResourceContainers{
+		Collection: ResourceContainer{ID: f.collectionID},
+		Account:    ResourceContainer{ID: f.collectionID},
+		Project:    ResourceContainer{ID: f.projectID},
+	}
+}
+
+func (f *Faker) repository() Repository {
+	return Repository{
+		ID:   f.repositoryID,
+		URL:  f.repositoryAPIURL(),
+		Name: f.project,
+		Project: Project{
+			ID:    f.projectID,
+			URL:   f.baseURL() + "/_apis/projects/" + f.projectID,
+			Name:  f.project,
+			State: "wellFormed",
+		},
+		RemoteURL:     f.repositoryURL(),
+		DefaultBranch: "refs/heads/main",
+	}
+}
+
+func (f *Faker) baseURL() string {
+	return "https://dev.azure.com/" + f.organization
+}
+
+func (f *Faker) repositoryURL() string {
+	return f.baseURL() + "/" + f.project + "/_git/" + f.project
+}
+
+func (f *Faker) repositoryAPIURL() string {
+	return f.baseURL() + "/_apis/git/repositories/" + f.repositoryID
+}
@@ -2,7 +2,7 @@ package azure
 
 import (
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 )
 
@@ -14,10 +14,17 @@ type User struct {
 	DisplayName string `json:"displayName"`
 }
 
-type Account struct {
-	ID string `json:"id"`
+// ResourceContainer identifies a container of the resource of an event, BaseURL is set for collections.
+type ResourceContainer struct {
+	ID      string `json:"id"`
+	BaseURL string `json:"baseUrl,omitempty"`
 }
 
+// Account identifies a container of the resource of an event.
+//
+// Deprecated: use ResourceContainer.
+type Account = ResourceContainer
+
 type Commit struct {
 	URL      string `json:"url"`
 	CommitID string `json:"commitId"`
@@ -113,10 +120,13 @@ type BuildDefinition struct {
 	URL            string `json:"url"`
 }
 
+// ResourceContainers contains the containers of the resource of an event,
+// Server is only sent by Azure DevOps Server.
 type ResourceContainers struct {
-	Account    Account `json:"account"`
-	Project    Account `json:"project"`
-	Collection Account `json:"collection"`
+	Collection ResourceContainer  `json:"collection"`
+	Account    ResourceContainer  `json:"account"`
+	Project    ResourceContainer  `json:"project"`
+	Server     *ResourceContainer `json:"server,omitempty"`
 }
 
 type Build struct {
@@ -161,6 +171,251 @@ type PullRequest struct {
 	LastMergeTargetCommit Commit     `json:"lastMergeTargetCommit"`
 }
 
+type Link struct {
+	Href string `json:"href"`
+}
+
+type Links map[string]Link
+
+// Identity is a user of resources of version 2.0 and above.
+type Identity struct {
+	User
+	Descriptor string `json:"descriptor"`
+	Links      Links  `json:"_links"`
+}
+
+type ReviewerV2 struct {
+	Identity
+	Vote        int    `json:"vote"`
+	HasDeclined bool   `json:"hasDeclined"`
+	IsFlagged   bool   `json:"isFlagged"`
+	IsRequired  bool   `json:"isRequired"`
+	IsContainer bool   `json:"isContainer"`
+	ReviewerURL string `json:"reviewerUrl"`
+}
+
+type Label struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+type CompletionOptions struct {
+	MergeStrategy       string `json:"mergeStrategy"`
+	MergeCommitMessage  string `json:"mergeCommitMessage"`
+	DeleteSourceBranch  bool   `json:"deleteSourceBranch"`
+	TransitionWorkItems bool   `json:"transitionWorkItems"`
+	BypassPolicy        bool   `json:"bypassPolicy"`
+}
+
+// PullRequestV2 is the pull request of resource version 2.0.
+type PullRequestV2 struct {
+	PullRequestID         int                `json:"pullRequestId"`
+	CodeReviewID          int                `json:"codeReviewId"`
+	ArtifactID            string             `json:"artifactId"`
+	URL                   string             `json:"url"`
+	Title                 string             `json:"title"`
+	MergeID               string             `json:"mergeId"`
+	Description           string             `json:"description"`
+	MergeStatus           string             `json:"mergeStatus"`
+	SourceRefName         string             `json:"sourceRefName"`
+	TargetRefName         string             `json:"targetRefName"`
+	Status                string             `json:"status"`
+	IsDraft               bool               `json:"isDraft"`
+	SupportsIterations    bool               `json:"supportsIterations"`
+	Commits               []Commit           `json:"commits"`
+	Reviewers             []ReviewerV2       `json:"reviewers"`
+	Labels                []Label            `json:"labels"`
+	CreatedBy             Identity           `json:"createdBy"`
+	ClosedBy              *Identity          `json:"closedBy,omitempty"`
+	AutoCompleteSetBy     *Identity          `json:"autoCompleteSetBy,omitempty"`
+	CompletionOptions     *CompletionOptions `json:"completionOptions,omitempty"`
+	ClosedDate            Date               `json:"closedDate"`
+	Repository            Repository         `json:"repository"`
+	CreationDate          Date               `json:"creationDate"`
+	LastMergeCommit       Commit             `json:"lastMergeCommit"`
+	LastMergeSourceCommit Commit             `json:"lastMergeSourceCommit"`
+	LastMergeTargetCommit Commit             `json:"lastMergeTargetCommit"`
+	Links                 Links              `json:"_links"`
+}
+
+type AgentPool struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	IsHosted bool   `json:"isHosted"`
+}
+
+type AgentQueue struct {
+	ID   int       `json:"id"`
+	Name string    `json:"name"`
+	Pool AgentPool `json:"pool"`
+}
+
+type BuildDefinitionReference struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	URL         string  `json:"url"`
+	URI         string  `json:"uri"`
+	Path        string  `json:"path"`
+	Type        string  `json:"type"`
+	QueueStatus string  `json:"queueStatus"`
+	Revision    int     `json:"revision"`
+	Project     Project `json:"project"`
+}
+
+type BuildLogs struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type BuildRepository struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Name               string `json:"name"`
+	URL                string `json:"url"`
+	Clean              string `json:"clean"`
+	CheckoutSubmodules bool   `json:"checkoutSubmodules"`
+}
+
+// BuildV2 is the build of resource version 2.0, the build of the Build REST API.
+type BuildV2 struct {
+	ID                int                      `json:"id"`
+	BuildNumber       string                   `json:"buildNumber"`
+	Status            string                   `json:"status"`
+	Result            string                   `json:"result"`
+	Reason            string                   `json:"reason"`
+	Priority          string                   `json:"priority"`
+	URI               string                   `json:"uri"`
+	URL               string                   `json:"url"`
+	SourceBranch      string                   `json:"sourceBranch"`
+	SourceVersion     string                   `json:"sourceVersion"`
+	QueueTime         Date                     `json:"queueTime"`
+	StartTime         Date                     `json:"startTime"`
+	FinishTime        Date                     `json:"finishTime"`
+	LastChangedDate   Date                     `json:"lastChangedDate"`
+	Definition        BuildDefinitionReference `json:"definition"`
+	Project           Project                  `json:"project"`
+	Queue             AgentQueue               `json:"queue"`
+	RequestedFor      Identity                 `json:"requestedFor"`
+	RequestedBy       Identity                 `json:"requestedBy"`
+	LastChangedBy     Identity                 `json:"lastChangedBy"`
+	Logs              BuildLogs                `json:"logs"`
+	Repository        BuildRepository          `json:"repository"`
+	KeepForever       bool                     `json:"keepForever"`
+	RetainedByRelease bool                     `json:"retainedByRelease"`
+	Tags              []string                 `json:"tags"`
+	Links             Links                    `json:"_links"`
+}
+
+type WorkItem struct {
+	ID     int                    `json:"id"`
+	Rev    int                    `json:"rev"`
+	Fields map[string]interface{} `json:"fields"`
+	Links  Links                  `json:"_links"`
+	URL    string                 `json:"url"`
+}
+
+type FieldChange struct {
+	OldValue interface{} `json:"oldValue,omitempty"`
+	NewValue interface{} `json:"newValue,omitempty"`
+}
+
+type WorkItemUpdate struct {
+	ID          int                    `json:"id"`
+	WorkItemID  int                    `json:"workItemId"`
+	Rev         int                    `json:"rev"`
+	RevisedBy   User                   `json:"revisedBy"`
+	RevisedDate Date                   `json:"revisedDate"`
+	Fields      map[string]FieldChange `json:"fields"`
+	Links       Links                  `json:"_links"`
+	URL         string                 `json:"url"`
+	Revision    WorkItem               `json:"revision"`
+}
+
+type Comment struct {
+	ID                     int    `json:"id"`
+	ParentCommentID        int    `json:"parentCommentId"`
+	Author                 User   `json:"author"`
+	Content                string `json:"content"`
+	PublishedDate          Date   `json:"publishedDate"`
+	LastUpdatedDate        Date   `json:"lastUpdatedDate"`
+	LastContentUpdatedDate Date   `json:"lastContentUpdatedDate"`
+	CommentType            string `json:"commentType"`
+	Links                  Links  `json:"_links"`
+}
+
+type PullRequestComment struct {
+	Comment     Comment       `json:"comment"`
+	PullRequest PullRequestV2 `json:"pullRequest"`
+}
+
+type ReleaseReference struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Links Links  `json:"_links"`
+}
+
+type ReleaseEnvironment struct {
+	ID                      int              `json:"id"`
+	ReleaseID               int              `json:"releaseId"`
+	Name                    string           `json:"name"`
+	Status                  string           `json:"status"`
+	Rank                    int              `json:"rank"`
+	DefinitionEnvironmentID int              `json:"definitionEnvironmentId"`
+	QueueID                 int              `json:"queueId"`
+	ModifiedOn              Date             `json:"modifiedOn"`
+	Owner                   User             `json:"owner"`
+	Release                 ReleaseReference `json:"release"`
+	ReleaseDefinition       ReleaseReference `json:"releaseDefinition"`
+}
+
+type Deployment struct {
+	Environment ReleaseEnvironment `json:"environment"`
+	Project     Project            `json:"project"`
+}
+
+type Pipeline struct {
+	ID       int    `json:"id"`
+	Revision int    `json:"revision"`
+	Name     string `json:"name"`
+	Folder   string `json:"folder"`
+	URL      string `json:"url"`
+}
+
+type PipelineRun struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	State        string   `json:"state"`
+	Result       string   `json:"result,omitempty"`
+	CreatedDate  Date     `json:"createdDate"`
+	FinishedDate Date     `json:"finishedDate"`
+	URL          string   `json:"url"`
+	Pipeline     Pipeline `json:"pipeline"`
+	Links        Links    `json:"_links"`
+}
+
+type PipelineStage struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	State       string `json:"state"`
+	Result      string `json:"result,omitempty"`
+	Links       Links  `json:"_links"`
+}
+
+type RunStateChanged struct {
+	Run      PipelineRun `json:"run"`
+	Pipeline Pipeline    `json:"pipeline"`
+}
+
+type StageStateChanged struct {
+	Stage    PipelineStage `json:"stage"`
+	Run      PipelineRun   `json:"run"`
+	Pipeline Pipeline      `json:"pipeline"`
+}
+
 // Azure DevOps does not send an event header, this BasicEvent is provided to get the EventType.
 type BasicEvent struct {
 	ID          string `json:"id"`
@@ -168,52 +423,66 @@ type BasicEvent struct {
 	PublisherID string `json:"publisherId"`
 	CreatedDate Date   `json:"createdDate"`
 	EventType   Event  `json:"eventType"`
+	// ResourceVersion selects the shape of the resource, chosen by the subscription.
+	ResourceVersion string `json:"resourceVersion"`
 }
 
-// git.push
-type GitPushEvent struct {
+// Envelope contains the fields Azure DevOps sends with every event, Resource is the resource of the event,
+// its shape given by the EventType and ResourceVersion. ResourceContainers identify where the event happened
+// to route it by collection or project.
+type Envelope[R any] struct {
 	ID                 string             `json:"id"`
-	Scope              string             `json:"scope"`
-	EventType          string             `json:"eventType"`
-	CreatedDate        string             `json:"createdDate"`
+	EventType          Event              `json:"eventType"`
 	PublisherID        string             `json:"publisherId"`
-	ResourceVersion    string             `json:"resourceVersion"`
+	Scope              string             `json:"scope"`
 	Message            Message            `json:"message"`
 	DetailedMessage    Message            `json:"detailedMessage"`
+	Resource           R                  `json:"resource"`
+	ResourceVersion    string             `json:"resourceVersion"`
 	ResourceContainers ResourceContainers `json:"resourceContainers"`
-	Resource           Resource           `json:"resource"`
+	CreatedDate        Date               `json:"createdDate"`
 }
 
+// git.push
+type GitPushEvent = Envelope[Resource]
+
 // git.pullrequest.*
 // git.pullrequest.merged
 // git.pullrequest.created
 // git.pullrequest.updated
-type GitPullRequestEvent struct {
-	ID                 string      `json:"id"`
-	Scope              string      `json:"scope"`
-	PublisherID        string      `json:"publisherId"`
-	ResourceVersion    string      `json:"resourceVersion"`
-	CreatedDate        Date        `json:"createdDate"`
-	EventType          Event       `json:"eventType"`
-	Message            Message     `json:"message"`
-	Resource           PullRequest `json:"resource"`
-	DetailedMessage    Message     `json:"detailedMessage"`
-	ResourceContainers interface{} `json:"resourceContainers"`
-}
+type GitPullRequestEvent = Envelope[PullRequest]
+
+// git.pullrequest.* of resource version 2.0
+type GitPullRequestEventV2 = Envelope[PullRequestV2]
 
 // build.complete
-type BuildCompleteEvent struct {
-	ID                 string      `json:"id"`
-	Scope              string      `json:"scope"`
-	PublisherID        string      `json:"publisherId"`
-	ResourceVersion    string      `json:"resourceVersion"`
-	CreatedDate        Date        `json:"createdDate"`
-	EventType          Event       `json:"eventType"`
-	Message            Message     `json:"message"`
-	Resource           Build       `json:"resource"`
-	DetailedMessage    Message     `json:"detailedMessage"`
-	ResourceContainers interface{} `json:"resourceContainers"`
-}
+type BuildCompleteEvent = Envelope[Build]
+
+// build.complete of resource version 2.0
+type BuildCompleteEventV2 = Envelope[BuildV2]
+
+// workitem.*
+// workitem.created
+// workitem.deleted
+// workitem.restored
+// workitem.commented
+type WorkItemEvent = Envelope[WorkItem]
+
+// workitem.updated
+type WorkItemUpdatedEvent = Envelope[WorkItemUpdate]
+
+// git.pullrequest.comment-event
+// ms.vss-code.git-pullrequest-comment-event
+type GitPullRequestCommentEvent = Envelope[PullRequestComment]
+
+// ms.vss-release.deployment-completed-event
+type DeploymentCompletedEvent = Envelope[Deployment]
+
+// ms.vss-pipelines.run-state-changed-event
+type RunStateChangedEvent = Envelope[RunStateChanged]
+
+// ms.vss-pipelines.stage-state-changed-event
+type StageStateChangedEvent = Envelope[StageStateChanged]
 
 type Date time.Time
 
@@ -221,12 +490,30 @@ func (b Date) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("\"%s\"", time.Time(b).Format(time.RFC3339Nano))), nil
 }
 
+// UnmarshalJSON decodes an RFC 3339 date, or one without zone such as "0001-01-01T00:00:00" as UTC.
+// null leaves the zero value.
 func (b *Date) UnmarshalJSON(p []byte) error {
-	if t, err := time.Parse(time.RFC3339Nano, strings.Replace(string(p), "\"", "", -1)); err != nil {
-		return err
-	} else {
-		*b = Date(t)
+	if string(p) == "null" {
+		return nil
+	}
+
+	if len(p) < 2 || p[0] != '"' {
+		return fmt.Errorf("invalid date %s", p)
+	}
+
+	s, err := strconv.Unquote(string(p))
+	if err != nil {
+		return fmt.Errorf("invalid date %s", p)
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		// dates of unset fields, e.g. the lastModifiedDate of a new pull request, are sent without zone
+		if t, err = time.Parse("2006-01-02T15:04:05.999999999", s); err != nil {
+			return err
+		}
 	}
 
+	*b = Date(t)
 	return nil
 }
@@ -8,7 +8,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
 )
 
 const (
@@ -34,6 +41,9 @@ const (
 	PullRequestFromReferenceUpdatedEvent Event = "pr:from_ref_updated"
 )
 
+// ErrParsingPayload is returned when a payload cannot be read or decoded.
+var ErrParsingPayload = errors.New("error parsing payload")
+
 // Options is a namespace var for configuration options.
 var Options = WebhookOptions{}
 
@@ -53,12 +63,34 @@ func New(options ...Option) (*Webhook, error) {
 	return hook, nil
 }
 
+// provider describes the deliveries of Bitbucket Server to the instrumentation.
+var provider = &observe.Provider{
+	Name:           "bitbucket-server",
+	EventHeader:    "X-Event-Key",
+	DeliveryHeader: "X-Request-Id",
+	SecretHeaders:  []string{"X-Hub-Signature"},
+}
+
 // Webhook instance contains all methods needed to process events.
 type Webhook struct {
-	secret string
+	secret   string
+	observer observe.Observer
+}
+
+// Parse verifies and parses the events specified and returns the payload object or an error.
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook *Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
 }
 
-func (hook *Webhook) Parse(r *http.Request, events ...Event) (interface{}, error) {
+func (hook *Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
 	defer func() {
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
@@ -97,23 +129,33 @@ func (hook *Webhook) Parse(r *http.Request, events ...Event) (interface{}, error
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil || len(payload) == 0 {
-		return nil, errors.New("error parsing payload")
+		return nil, ErrParsingPayload
 	}
 
-	if len(hook.secret) > 0 {
+	err = d.Verify(len(hook.secret) > 0, func() error {
 		signature := r.Header.Get("X-Hub-Signature")
 		if len(signature) == 0 {
-			return nil, errors.New("missing X-Hub-Signature Header")
+			return errors.New("missing X-Hub-Signature Header")
+		}
+
+		signature, ok := strings.CutPrefix(signature, "sha256=")
+		if !ok {
+			return errors.New("HMAC verification failed")
 		}
 
 		mac := hmac.New(sha256.New, []byte(hook.secret))
 		_, _ = mac.Write(payload)
 		expectedMAC := hex.EncodeToString(mac.Sum(nil))
-		if !hmac.Equal([]byte(signature[7:]), []byte(expectedMAC)) {
-			return nil, errors.New("HMAC verification failed")
+		if !hmac.Equal([]byte(signature), []byte(expectedMAC)) {
+			return errors.New("HMAC verification failed")
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	d.Decoding()
 	switch bitbucketEvent {
 	case RepositoryReferenceChangedEvent:
 		var pl RepositoryReferenceChangedPayload
@@ -206,3 +248,38 @@ func (WebhookOptions) Secret(secret string) Option {
 		return nil
 	}
 }
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
@@ -2,14 +2,19 @@ package bitbucket_server
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"testing"
+	"time"
 
+	"github.com/pchchv/wh/whtest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -52,6 +57,15 @@ func TestBadRequests(t *testing.T) {
 				"X-Hub-Signature": []string{""},
 			},
 		},
+		{
+			name:    "BadSignatureShort",
+			event:   RepositoryReferenceChangedEvent,
+			payload: bytes.NewBuffer([]byte("{}")),
+			headers: http.Header{
+				"X-Event-Key":     []string{"repo:refs_changed"},
+				"X-Hub-Signature": []string{"sha"},
+			},
+		},
 		{
 			name:    "BadSignatureMatch",
 			event:   RepositoryReferenceChangedEvent,
@@ -316,3 +330,130 @@ func newServer(handler http.HandlerFunc) *httptest.Server {
 	mux.HandleFunc(path, handler)
 	return httptest.NewServer(mux)
 }
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("PROJ/webhook-test").Actor("gopher")
+	refs := faker.RepositoryReferenceChangedPayload("main")
+	assert.Equal("refs/heads/main", refs.Changes[0].ReferenceID)
+	assert.NotEqual(refs.Changes[0].FromHash, refs.Changes[0].ToHash)
+	assert.Equal("webhook-test", refs.Repository.Slug)
+	assert.Equal("gopher", refs.Actor.Slug)
+	assert.Equal(refs, NewFaker("PROJ/webhook-test").Actor("gopher").RepositoryReferenceChangedPayload("main"))
+
+	merged := faker.PullRequestMergedPayload("feature", "main")
+	assert.Equal("MERGED", merged.PullRequest.State)
+	assert.Equal("refs/heads/feature", merged.PullRequest.FromRef.ID)
+	assert.Equal("refs/heads/main", merged.PullRequest.ToRef.ID)
+	assert.Equal(refs.Repository, merged.PullRequest.ToRef.Repository)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: RepositoryReferenceChangedEvent, payload: refs},
+		{event: PullRequestMergedEvent, payload: merged},
+		{event: PullRequestOpenedEvent, payload: faker.PullRequestOpenedPayload("feature", "main")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.BitbucketServer(tc.event, tc.payload).Secret(hook.secret).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{RepositoryReferenceChangedEvent, "./testdata/repo-refs-changed.json"},
+		{RepositoryModifiedEvent, "./testdata/repo-modified.json"},
+		{RepositoryForkedEvent, "./testdata/repo-forked.json"},
+		{RepositoryCommentEditedEvent, "./testdata/repo-comment-edited.json"},
+		{RepositoryCommentDeletedEvent, "./testdata/repo-comment-deleted.json"},
+		{RepositoryCommentAddedEvent, "./testdata/repo-comment-added.json"},
+		{PullRequestReviewerUnapprovedEvent, "./testdata/pr-reviewer-unapproved.json"},
+		{PullRequestReviewerUpdatedEvent, "./testdata/pr-reviewer-updated.json"},
+		{PullRequestOpenedEvent, "./testdata/pr-opened.json"},
+		{PullRequestModifiedEvent, "./testdata/pr-modified.json"},
+		{PullRequestMergedEvent, "./testdata/pr-merged.json"},
+		{PullRequestReviewerNeedsWorkEvent, "./testdata/pr-reviewer-needs-work.json"},
+		{PullRequestDeletedEvent, "./testdata/pr-deleted.json"},
+		{PullRequestDeclinedEvent, "./testdata/pr-declined.json"},
+		{PullRequestCommentEditedEvent, "./testdata/pr-comment-edited.json"},
+		{PullRequestCommentDeletedEvent, "./testdata/pr-comment-deleted.json"},
+		{PullRequestReviewerApprovedEvent, "./testdata/pr-reviewer-approved.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), "", payload)
+	}
+	f.Add(string(seeds[0].event), "sha", []byte("{}"))
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event, signature string, payload []byte) {
+		d := whtest.BitbucketServer(event, payload).Secret(hook.secret)
+		if signature != "" {
+			d.Header("X-Hub-Signature", signature)
+		}
+
+		r := d.Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
+
+func FuzzDate(f *testing.F) {
+	files, err := filepath.Glob("./testdata/*.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	dates := regexp.MustCompile(`"\d{4}-\d{2}-\d{2}[ T][^"]*"`)
+	seen := map[string]bool{}
+	for _, file := range files {
+		payload, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		for _, date := range dates.FindAll(payload, -1) {
+			if !seen[string(date)] {
+				seen[string(date)] = true
+				f.Add(date)
+			}
+		}
+	}
+	f.Add([]byte("null"))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var d Date
+		if err := d.UnmarshalJSON(b); err != nil {
+			return
+		}
+
+		// years outside of four digits cannot be encoded by the layout
+		if year := time.Time(d).Year(); year < 0 || year > 9999 {
+			return
+		}
+
+		encoded, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded Date
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("decoding %s encoded from %q: %v", encoded, b, err)
+		}
+
+		if !time.Time(decoded).Equal(time.Time(d).Truncate(time.Second)) {
+			t.Fatalf("%q decoded to %v, encoded as %s decoded to %v", b, time.Time(d), encoded, time.Time(decoded))
+		}
+	})
+}
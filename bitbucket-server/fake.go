@@ -0,0 +1,196 @@
+package bitbucket_server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic payloads of a repository to unit test handlers.
+// The payloads of a faker agree with each other on the repository, users, refs and hashes,
+// and encode to the JSON Bitbucket Server sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same payloads.
+type Faker struct {
+	host    string
+	project string
+	slug    string
+	id      uint64
+	actor   string
+	src     *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given project key and slug, e.g. "PROJ/webhook-test".
+// Events are triggered by the user "admin".
+func NewFaker(fullName string) *Faker {
+	project, slug, ok := strings.Cut(fullName, "/")
+	if !ok {
+		project, slug = "PROJ", fullName
+	}
+
+	src := fake.New("bitbucket-server/" + fullName)
+	return &Faker{
+		host:    "https://bitbucket.example.com",
+		project: project,
+		slug:    slug,
+		id:      uint64(src.ID() % 1000),
+		actor:   "admin",
+		src:     src,
+	}
+}
+
+// Actor sets the slug of the user triggering the events.
+func (f *Faker) Actor(slug string) *Faker {
+	f.actor = slug
+	return f
+}
+
+// SHA returns a new commit hash.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// RepositoryReferenceChangedPayload returns the push of commits to a branch,
+// changing it from one new hash to another.
+func (f *Faker) RepositoryReferenceChangedPayload(branch string) RepositoryReferenceChangedPayload {
+	ref := f.ref(branch, "")
+	return RepositoryReferenceChangedPayload{
+		Date:       Date(f.src.Time()),
+		Actor:      f.user(f.actor),
+		EventKey:   RepositoryReferenceChangedEvent,
+		Repository: f.repository(),
+		Changes: []RepositoryChange{{
+			Type:        "UPDATE",
+			FromHash:    f.SHA(),
+			ToHash:      f.SHA(),
+			ReferenceID: ref.ID,
+			Reference:   RepositoryReference{ID: ref.ID, DisplayID: ref.DisplayID, Type: ref.Type},
+		}},
+	}
+}
+
+// PullRequestOpenedPayload returns the opening of a pull request merging the from branch into the to branch.
+func (f *Faker) PullRequestOpenedPayload(from, to string) PullRequestOpenedPayload {
+	pr := f.pullRequest(from, to)
+	return PullRequestOpenedPayload{
+		Date:        Date(f.src.Time()),
+		Actor:       f.user(f.actor),
+		EventKey:    PullRequestOpenedEvent,
+		PullRequest: pr,
+	}
+}
+
+// PullRequestMergedPayload returns the merge of a pull request of the from branch into the to branch.
+func (f *Faker) PullRequestMergedPayload(from, to string) PullRequestMergedPayload {
+	pr := f.pullRequest(from, to)
+	merged := f.src.Time()
+	pr.Version++
+	pr.State = "MERGED"
+	pr.Open = false
+	pr.Closed = true
+	pr.UpdatedDate = uint64(merged.UnixMilli())
+	pr.ClosedDate = pr.UpdatedDate
+	pr.Properties = map[string]interface{}{
+		"mergeCommit": map[string]interface{}{
+			"id":        f.SHA(),
+			"displayId": f.SHA()[:11],
+		},
+	}
+	return PullRequestMergedPayload{
+		Date:        Date(merged),
+		Actor:       f.user(f.actor),
+		EventKey:    PullRequestMergedEvent,
+		PullRequest: pr,
+	}
+}
+
+func (f *Faker) pullRequest(from, to string) PullRequest {
+	id := uint64(f.src.ID()%1000 + 1)
+	created := uint64(f.src.Time().UnixMilli())
+	return PullRequest{
+		ID:          id,
+		Title:       "Merge " + from + " into " + to,
+		Description: "Changes of " + from + ".",
+		State:       "OPEN",
+		Open:        true,
+		CreatedDate: created,
+		UpdatedDate: created,
+		FromRef:     f.ref(from, f.SHA()),
+		ToRef:       f.ref(to, f.SHA()),
+		Author: PullRequestParticipant{
+			Role:   "AUTHOR",
+			Status: "UNAPPROVED",
+			User:   f.user(f.actor),
+		},
+		Reviewers:    []PullRequestParticipant{},
+		Participants: []PullRequestParticipant{},
+		Links:        links("self", fmt.Sprintf("%s/pull-requests/%d", f.repositoryURL(), id)),
+	}
+}
+
+func (f *Faker) ref(branch, latest string) RepositoryReference {
+	return RepositoryReference{
+		ID:           "refs/heads/" + branch,
+		Type:         "BRANCH",
+		DisplayID:    branch,
+		LatestCommit: latest,
+		Repository:   f.repository(),
+	}
+}
+
+func (f *Faker) repository() Repository {
+	clone := []interface{}{
+		map[string]interface{}{
+			"href": fmt.Sprintf("ssh://git@%s:7999/%s/%s.git", strings.TrimPrefix(f.host, "https://"), strings.ToLower(f.project), f.slug),
+			"name": "ssh",
+		},
+		map[string]interface{}{
+			"href": fmt.Sprintf("%s/scm/%s/%s.git", f.host, strings.ToLower(f.project), f.slug),
+			"name": "http",
+		},
+	}
+
+	l := links("self", f.repositoryURL()+"/browse")
+	l["clone"] = clone
+	return Repository{
+		ID:            f.id,
+		Slug:          f.slug,
+		Name:          f.slug,
+		ScmID:         "git",
+		State:         "AVAILABLE",
+		StatusMessage: "Available",
+		Forkable:      true,
+		Project: Project{
+			ID:    uint64(fake.NameID(f.project) % 1000),
+			Key:   f.project,
+			Name:  f.project,
+			Type:  "NORMAL",
+			Links: links("self", f.host+"/projects/"+f.project),
+		},
+		Links: l,
+	}
+}
+
+func (f *Faker) user(slug string) User {
+	return User{
+		ID:           uint64(fake.NameID(slug) % 10000),
+		Active:       true,
+		Name:         slug,
+		Slug:         slug,
+		Type:         "NORMAL",
+		DisplayName:  slug,
+		EmailAddress: slug + "@example.com",
+		Links:        links("self", f.host+"/users/"+slug),
+	}
+}
+
+func (f *Faker) repositoryURL() string {
+	return f.host + "/projects/" + f.project + "/repos/" + f.slug
+}
+
+// links returns links in the form JSON decodes them to, so fake payloads survive a round trip.
+func links(name, href string) map[string]interface{} {
+	return map[string]interface{}{
+		name: []interface{}{map[string]interface{}{"href": href}},
+	}
+}
@@ -5,7 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
 )
 
 const (
@@ -30,6 +36,9 @@ const (
 	PullRequestCommentDeletedEvent Event = "pullrequest:comment_deleted"
 )
 
+// ErrParsingPayload is returned when a payload cannot be read or decoded.
+var ErrParsingPayload = errors.New("error parsing payload")
+
 // Options is a namespace var for configuration options.
 var Options = WebhookOptions{}
 
@@ -50,13 +59,34 @@ func New(options ...Option) (*Webhook, error) {
 	return hook, nil
 }
 
+// provider describes the deliveries of Bitbucket to the instrumentation.
+var provider = &observe.Provider{
+	Name:           "bitbucket",
+	EventHeader:    "X-Event-Key",
+	DeliveryHeader: "X-Request-UUID",
+	SecretHeaders:  []string{"X-Hook-UUID"},
+}
+
 // Webhook instance contains all methods needed to process events.
 type Webhook struct {
-	uuid string
+	uuid     string
+	observer observe.Observer
 }
 
 // Parse verifies and parses the events specified and returns the payload object or an error.
-func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error) {
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
 	defer func() {
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
@@ -70,18 +100,24 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 		return nil, errors.New("invalid HTTP Method")
 	}
 
-	uuid := r.Header.Get("X-Hook-UUID")
-	if hook.uuid != "" && uuid == "" {
-		return nil, errors.New("missing X-Hook-UUID Header")
-	}
-
 	event := r.Header.Get("X-Event-Key")
 	if event == "" {
 		return nil, errors.New("missing X-Event-Key Header")
 	}
 
-	if len(hook.uuid) > 0 && uuid != hook.uuid {
-		return nil, errors.New("UUID verification failed")
+	err := d.Verify(len(hook.uuid) > 0, func() error {
+		uuid := r.Header.Get("X-Hook-UUID")
+		if uuid == "" {
+			return errors.New("missing X-Hook-UUID Header")
+		}
+
+		if uuid != hook.uuid {
+			return errors.New("UUID verification failed")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	var found bool
@@ -100,9 +136,10 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil || len(payload) == 0 {
-		return nil, errors.New("error parsing payload")
+		return nil, ErrParsingPayload
 	}
 
+	d.Decoding()
 	switch bitbucketEvent {
 	case RepoPushEvent:
 		var pl RepoPushPayload
@@ -191,3 +228,38 @@ func (WebhookOptions) UUID(uuid string) Option {
 		return nil
 	}
 }
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
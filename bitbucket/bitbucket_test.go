@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/pchchv/wh/whtest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -342,3 +343,85 @@ func newServer(handler http.HandlerFunc) *httptest.Server {
 	mux.HandleFunc(path, handler)
 	return httptest.NewServer(mux)
 }
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("atlassian/stash-example-plugin").Actor("emmap1")
+	push := faker.RepoPushPayload("main", 3)
+	change := push.Push.Changes[0]
+	assert.Equal("main", change.New.Name)
+	assert.Len(change.Commits, 3)
+	// newest first
+	assert.Equal(change.New.Target.Hash, change.Commits[0].Hash)
+	assert.Equal(change.Commits[1].Hash, change.New.Target.Parents[0].Hash)
+	assert.Equal("atlassian/stash-example-plugin", push.Repository.FullName)
+	assert.Equal("emmap1", push.Actor.NickName)
+	assert.Equal(push, NewFaker("atlassian/stash-example-plugin").Actor("emmap1").RepoPushPayload("main", 3))
+
+	merged := faker.PullRequestMergedPayload("feature", "main")
+	assert.Equal("MERGED", merged.PullRequest.State)
+	assert.Equal("feature", merged.PullRequest.Source.Branch.Name)
+	assert.Equal("main", merged.PullRequest.Destination.Branch.Name)
+	assert.Equal(push.Repository, merged.Repository)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: RepoPushEvent, payload: push},
+		{event: PullRequestMergedEvent, payload: merged},
+		{event: PullRequestCreatedEvent, payload: faker.PullRequestCreatedPayload("feature", "main")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Bitbucket(tc.event, tc.payload).Secret(hook.uuid).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{RepoPushEvent, "./testdata/repo-push.json"},
+		{RepoForkEvent, "./testdata/repo-fork.json"},
+		{RepoUpdatedEvent, "./testdata/repo-updated.json"},
+		{RepoCommitCommentCreatedEvent, "./testdata/commit-comment-created.json"},
+		{RepoCommitStatusCreatedEvent, "./testdata/repo-commit-status-created.json"},
+		{RepoCommitStatusUpdatedEvent, "./testdata/repo-commit-status-updated.json"},
+		{IssueCreatedEvent, "./testdata/issue-created.json"},
+		{IssueUpdatedEvent, "./testdata/issue-updated.json"},
+		{IssueCommentCreatedEvent, "./testdata/issue-comment-created.json"},
+		{PullRequestCreatedEvent, "./testdata/pull-request-created.json"},
+		{PullRequestUpdatedEvent, "./testdata/pull-request-updated.json"},
+		{PullRequestApprovedEvent, "./testdata/pull-request-approved.json"},
+		{PullRequestUnapprovedEvent, "./testdata/pull-request-approval-removed.json"},
+		{PullRequestMergedEvent, "./testdata/pull-request-merged.json"},
+		{PullRequestDeclinedEvent, "./testdata/pull-request-declined.json"},
+		{PullRequestCommentCreatedEvent, "./testdata/pull-request-comment-created.json"},
+		{PullRequestCommentUpdatedEvent, "./testdata/pull-request-comment-updated.json"},
+		{PullRequestCommentDeletedEvent, "./testdata/pull-request-comment-deleted.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), "", payload)
+	}
+	f.Add(string(seeds[0].event), "MY", []byte("{}"))
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event, signature string, payload []byte) {
+		d := whtest.Bitbucket(event, payload).Secret(hook.uuid)
+		if signature != "" {
+			d.Header("X-Hook-UUID", signature)
+		}
+
+		r := d.Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
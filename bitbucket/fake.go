@@ -0,0 +1,248 @@
+package bitbucket
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic payloads of a repository to unit test handlers.
+// The payloads of a faker agree with each other on the repository, users, branches and hashes,
+// and encode to the JSON Bitbucket Cloud sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same payloads.
+type Faker struct {
+	workspace string
+	slug      string
+	uuid      string
+	actor     string
+	src       *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given full name, e.g. "atlassian/stash-example-plugin".
+// Events are triggered by the workspace owner.
+func NewFaker(fullName string) *Faker {
+	workspace, slug, ok := strings.Cut(fullName, "/")
+	if !ok {
+		workspace, slug = "atlassian", fullName
+	}
+
+	src := fake.New("bitbucket/" + fullName)
+	return &Faker{workspace: workspace, slug: slug, uuid: "{" + src.UUID() + "}", actor: workspace, src: src}
+}
+
+// Actor sets the nickname of the user triggering the events.
+func (f *Faker) Actor(nickname string) *Faker {
+	f.actor = nickname
+	return f
+}
+
+// SHA returns a new commit hash.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// RepoPushPayload returns the push of commits to a branch.
+// The commits follow each other, the last one being the new target of the branch,
+// and are listed newest first as Bitbucket does.
+func (f *Faker) RepoPushPayload(branch string, commits int) RepoPushPayload {
+	var pl RepoPushPayload
+	pl.Actor = f.owner(f.actor)
+	pl.Repository = f.repository()
+	pl.Push.Changes = fake.Make(pl.Push.Changes, 1)
+	change := &pl.Push.Changes[0]
+
+	before := f.SHA()
+	old := f.commit(before, "Initial commit")
+	f.ref(&change.Old, branch, old, "")
+	after, parent := before, ""
+	change.Commits = fake.Make(change.Commits, commits)
+	for i := range change.Commits {
+		parent, after = after, f.SHA()
+		values := f.commit(after, fmt.Sprintf("Change %d", i+1))
+		// newest first
+		c := &change.Commits[len(change.Commits)-1-i]
+		fake.Set(c, values)
+		c.Author = f.owner(f.actor)
+		if i == len(change.Commits)-1 {
+			f.ref(&change.New, branch, values, parent)
+		}
+	}
+
+	if commits == 0 {
+		f.ref(&change.New, branch, old, "")
+	}
+	fake.Set(change, map[string]interface{}{
+		"links.html.href":    fmt.Sprintf("%s/branches/compare/%s..%s", f.htmlURL(), after, before),
+		"links.diff.href":    fmt.Sprintf("%s/diff/%s..%s", f.apiURL(), after, before),
+		"links.commits.href": fmt.Sprintf("%s/commits?include=%s&exclude=%s", f.apiURL(), after, before),
+	})
+	return pl
+}
+
+// PullRequestCreatedPayload returns the opening of a pull request merging the source branch into the destination branch.
+func (f *Faker) PullRequestCreatedPayload(source, destination string) PullRequestCreatedPayload {
+	return PullRequestCreatedPayload{
+		Actor:       f.owner(f.actor),
+		Repository:  f.repository(),
+		PullRequest: f.pullRequest(source, destination, false),
+	}
+}
+
+// PullRequestMergedPayload returns the merge of a pull request of the source branch into the destination branch.
+func (f *Faker) PullRequestMergedPayload(source, destination string) PullRequestMergedPayload {
+	return PullRequestMergedPayload{
+		Actor:       f.owner(f.actor),
+		Repository:  f.repository(),
+		PullRequest: f.pullRequest(source, destination, true),
+	}
+}
+
+func (f *Faker) pullRequest(source, destination string, merged bool) PullRequest {
+	id := f.src.ID()%1000 + 1
+	created := f.src.Time()
+	values := map[string]interface{}{
+		"id":                      id,
+		"title":                   "Merge " + source + " into " + destination,
+		"description":             "Changes of " + source + ".",
+		"state":                   "OPEN",
+		"created_on":              created,
+		"updated_on":              created,
+		"source.branch.name":      source,
+		"source.commit.hash":      f.SHA()[:12],
+		"destination.branch.name": destination,
+		"destination.commit.hash": f.SHA()[:12],
+		"links.self.href":         fmt.Sprintf("%s/pullrequests/%d", f.apiURL(), id),
+		"links.html.href":         fmt.Sprintf("%s/pull-requests/%d", f.htmlURL(), id),
+		"close_source_branch":     false,
+		"participants":            []Owner{},
+		"reviewers":               []Owner{},
+	}
+
+	if merged {
+		updated := f.src.Time()
+		values["state"] = "MERGED"
+		values["updated_on"] = updated
+		values["merge_commit.hash"] = f.SHA()[:12]
+		values["reason"] = ""
+	}
+
+	var pr PullRequest
+	fake.Set(&pr, values)
+	pr.Author = f.owner(f.actor)
+	pr.Source.Repository = f.repository()
+	pr.Destination.Repository = f.repository()
+	if merged {
+		pr.ClosedBy = f.owner(f.actor)
+	}
+	return pr
+}
+
+// ref fills the old or new state of a pushed branch pointing to commit.
+func (f *Faker) ref(v interface{}, branch string, commit map[string]interface{}, parent string) {
+	values := make(map[string]interface{}, len(commit)+10)
+	for k, value := range commit {
+		values["target."+k] = value
+	}
+	values["type"] = "branch"
+	values["target.date"] = f.src.Time()
+	values["name"] = branch
+	values["links.self.href"] = f.apiURL() + "/refs/branches/" + branch
+	values["links.commits.href"] = f.apiURL() + "/commits/" + branch
+	values["links.html.href"] = f.htmlURL() + "/branch/" + branch
+	values["target.author.type"] = "user"
+	values["target.author.nickname"] = f.actor
+	values["target.author.display_name"] = f.actor
+	values["target.author.uuid"] = f.userUUID(f.actor)
+	values["target.author.account_id"] = f.accountID(f.actor)
+	fake.Set(v, values)
+
+	if parent == "" {
+		return
+	}
+
+	// the parents are a slice of anonymous structs Set cannot fill
+	var parents []struct {
+		Type  string `json:"type"`
+		Hash  string `json:"hash"`
+		Links struct {
+			Self struct {
+				Href string `json:"href"`
+			} `json:"self"`
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+	}
+	parents = fake.Make(parents, 1)
+	fake.Set(&parents[0], map[string]interface{}{
+		"type":            "commit",
+		"hash":            parent,
+		"links.self.href": f.apiURL() + "/commit/" + parent,
+		"links.html.href": f.htmlURL() + "/commits/" + parent,
+	})
+	fake.Set(v, map[string]interface{}{"target.parents": parents})
+}
+
+func (f *Faker) commit(hash, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":            "commit",
+		"hash":            hash,
+		"message":         message + "\n",
+		"links.self.href": f.apiURL() + "/commit/" + hash,
+		"links.html.href": f.htmlURL() + "/commits/" + hash,
+	}
+}
+
+func (f *Faker) repository() Repository {
+	var r Repository
+	fake.Set(&r, map[string]interface{}{
+		"type":              "repository",
+		"scm":               "git",
+		"uuid":              f.uuid,
+		"name":              f.slug,
+		"full_name":         f.workspace + "/" + f.slug,
+		"website":           "",
+		"is_private":        true,
+		"links.self.href":   f.apiURL(),
+		"links.html.href":   f.htmlURL(),
+		"links.avatar.href": "https://bytebucket.org/ravatar/" + f.uuid + "?ts=default",
+		"project.type":      "project",
+		"project.project":   "Untitled project",
+		"project.uuid":      "{" + fake.New("bitbucket/"+f.workspace).UUID() + "}",
+		"project.key":       "PROJ",
+	})
+	r.Owner = f.owner(f.workspace)
+	return r
+}
+
+func (f *Faker) owner(nickname string) Owner {
+	var o Owner
+	fake.Set(&o, map[string]interface{}{
+		"type":              "user",
+		"uuid":              f.userUUID(nickname),
+		"nickname":          nickname,
+		"account_id":        f.accountID(nickname),
+		"display_name":      nickname,
+		"links.self.href":   "https://api.bitbucket.org/2.0/users/" + f.userUUID(nickname),
+		"links.html.href":   "https://bitbucket.org/" + f.userUUID(nickname) + "/",
+		"links.avatar.href": fmt.Sprintf("https://avatar-management--avatars.us-west-2.prod.public.atl-paas.net/initials/%s-0.png", nickname[:min(2, len(nickname))]),
+	})
+	return o
+}
+
+func (f *Faker) userUUID(nickname string) string {
+	return "{" + fake.New("bitbucket/user/"+nickname).UUID() + "}"
+}
+
+func (f *Faker) accountID(nickname string) string {
+	return fmt.Sprintf("557058:%s", fake.New("bitbucket/account/"+nickname).UUID())
+}
+
+func (f *Faker) htmlURL() string {
+	return "https://bitbucket.org/" + f.workspace + "/" + f.slug
+}
+
+func (f *Faker) apiURL() string {
+	return "https://api.bitbucket.org/2.0/repositories/" + f.workspace + "/" + f.slug
+}
@@ -0,0 +1,77 @@
+// Command wh helps testing webhook receivers built with the wh packages.
+//
+// Usage:
+//
+//	wh <command> [flags]
+//
+// The commands are:
+//
+//	send    sign a delivery like the provider does and POST it to a receiver
+//	serve   receive, verify, print and save deliveries of any provider
+//	verify  check the signatures of a captured delivery and explain mismatches
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "send", summary: "sign a delivery like the provider does and POST it to a receiver", run: runSend},
+		{name: "serve", summary: "receive, verify, print and save deliveries of any provider", run: runServe},
+		{name: "verify", summary: "check the signatures of a captured delivery and explain mismatches", run: runVerify},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		return 2
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+
+		if err := c.run(args[1:], stdout, stderr); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 2
+			}
+			fmt.Fprintf(stderr, "wh %s: %v\n", c.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "wh: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: wh <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'wh <command> -h' for the flags of a command.")
+}
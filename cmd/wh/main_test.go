@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	secret   = "IsWishesWereHorsesWedAllBeEatingSteak!"
+	topicARN = "arn:aws:sns:us-east-1:123456789012:MyDemoTopic"
+)
+
+var (
+	sourcehutKey       = ed25519.NewKeyFromSeed([]byte(secret[:ed25519.SeedSize]))
+	sourcehutSecret    = base64.StdEncoding.EncodeToString(sourcehutKey)
+	sourcehutPublicKey = base64.StdEncoding.EncodeToString(sourcehutKey.Public().(ed25519.PublicKey))
+)
+
+func secretFor(provider string) string {
+	switch provider {
+	case "azure", "gerrit", "jenkins", "quay":
+		return "user:" + secret
+	case "sourcehut":
+		return sourcehutSecret
+	case "codecommit":
+		return topicARN
+	default:
+		return secret
+	}
+}
+
+func mustProvider(t *testing.T, name string) provider {
+	p, err := lookupProvider(name)
+	require.NoError(t, err)
+	return p
+}
+
+func TestSendFixtures(t *testing.T) {
+	for _, p := range providers {
+		for _, event := range p.events() {
+			p, event := p, event
+			t.Run(p.name+"/"+event, func(t *testing.T) {
+				t.Parallel()
+				assert := require.New(t)
+				var parseError error
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, parseError = p.parse(r, event, secretFor(p.name))
+				}))
+				defer server.Close()
+
+				var stdout, stderr bytes.Buffer
+				code := run([]string{"send", "-provider", p.name, "-event", event, "-secret", secretFor(p.name), "-url", server.URL}, &stdout, &stderr)
+				assert.Equal(0, code, stderr.String())
+				assert.NoError(parseError)
+				assert.Contains(stdout.String(), "200 OK")
+			})
+		}
+	}
+}
+
+func TestEmbeddedFixtures(t *testing.T) {
+	assert := require.New(t)
+	for _, p := range providers {
+		for _, event := range p.events() {
+			onDisk, err := readFixture(p, event, "../..")
+			assert.NoError(err)
+			embedded, err := readFixture(p, event, "")
+			assert.NoError(err)
+			assert.Equal(onDisk, embedded, p.name+"/"+event)
+		}
+	}
+
+	// the fixtures are built into wh, no checkout is needed
+	t.Chdir(t.TempDir())
+	_, err := readFixture(mustProvider(t, "github"), "push", "")
+	assert.NoError(err)
+}
+
+func TestSendFile(t *testing.T) {
+	assert := require.New(t)
+	file := filepath.Join(t.TempDir(), "payload.json")
+	assert.NoError(os.WriteFile(file, []byte(`{"zen":"Keep it logically awesome."}`), 0o644))
+
+	var parseError error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, parseError = mustProvider(t, "github").parse(r, "ping", secret)
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"send", "-event", "ping", "-secret", secret, "-url", server.URL, file}, &stdout, &stderr)
+	assert.Equal(0, code, stderr.String())
+	assert.NoError(parseError)
+}
+
+func TestSendWrongSecret(t *testing.T) {
+	assert := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := mustProvider(t, "gitea").parse(r, "push", secret); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"send", "-provider", "gitea", "-event", "push", "-secret", "wrong", "-url", server.URL}, &stdout, &stderr)
+	assert.Equal(1, code)
+	assert.Contains(stdout.String(), "HMAC verification failed")
+	assert.Contains(stderr.String(), "401 Unauthorized")
+}
+
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "UnknownCommand", args: []string{"nope"}, want: "unknown command"},
+		{name: "UnknownProvider", args: []string{"send", "-provider", "svn", "-event", "push"}, want: "unknown provider"},
+		{name: "MissingEvent", args: []string{"send"}, want: "missing -event"},
+		{name: "UnknownFixture", args: []string{"send", "-event", "nope"}, want: "no github fixture"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			require.NotEqual(t, 0, run(tc.args, &stdout, &stderr))
+			require.Contains(t, stderr.String(), tc.want)
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	var out bytes.Buffer
+	rc := &receiver{secrets: secrets{}, dir: dir, out: &out, now: time.Now}
+	assert.NoError(rc.secrets.Set(secret))
+	assert.NoError(rc.secrets.Set("azure=user:" + secret))
+	assert.NoError(rc.secrets.Set("gerrit=user:" + secret))
+	assert.NoError(rc.secrets.Set("jenkins=user:" + secret))
+	assert.NoError(rc.secrets.Set("quay=user:" + secret))
+	assert.NoError(rc.secrets.Set("sourcehut=" + sourcehutPublicKey))
+	assert.NoError(rc.secrets.Set("codecommit=" + topicARN))
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	for _, p := range providers {
+		event := p.events()[0]
+		out.Reset()
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"send", "-provider", p.name, "-event", event, "-secret", secretFor(p.name), "-url", server.URL}, &stdout, &stderr)
+		assert.Equal(0, code, stderr.String()+out.String())
+		assert.Contains(out.String(), p.name+" event=", p.name)
+		if p.authenticated {
+			assert.Contains(out.String(), "verification=ok", p.name)
+		} else {
+			assert.Contains(out.String(), "verification=unsupported (provider has no authentication)", p.name)
+		}
+
+		saved, err := filepath.Glob(filepath.Join(dir, p.name, "*.json"))
+		assert.NoError(err)
+		assert.Len(saved, 1, p.name)
+		fixture, err := readFixture(p, event, "")
+		assert.NoError(err)
+		body, err := os.ReadFile(saved[0])
+		assert.NoError(err)
+		assert.Equal(fixture, body, p.name)
+
+		headers, err := os.ReadFile(strings.TrimSuffix(saved[0], ".json") + ".headers")
+		assert.NoError(err)
+		if p.eventHeader != "" || p.eventParam != "" {
+			assert.Contains(string(headers), event)
+		}
+
+		// the saved delivery can be replayed
+		code = run([]string{"send", "-provider", p.name, "-event", event, "-secret", secretFor(p.name), "-url", server.URL, saved[0]}, &stdout, &stderr)
+		assert.Equal(0, code, stderr.String())
+	}
+}
+
+func TestServeDescribe(t *testing.T) {
+	assert := require.New(t)
+	var out bytes.Buffer
+	rc := &receiver{secrets: secrets{"github": secret}, out: &out, now: time.Now}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(0, run([]string{"send", "-event", "pull_request", "-secret", secret, "-url", server.URL}, &stdout, &stderr))
+	assert.Contains(out.String(), `github event="pull_request" type=github.PullRequestPayload action="opened" repository="baxterthehacker/public-repo" verification=ok`)
+
+	out.Reset()
+	assert.Equal(1, run([]string{"send", "-event", "push", "-secret", "wrong", "-url", server.URL}, &stdout, &stderr))
+	assert.Contains(out.String(), `verification=failed error="HMAC verification failed"`)
+
+	out.Reset()
+	assert.Equal(0, run([]string{"send", "-provider", "gitea", "-event", "push", "-url", server.URL}, &stdout, &stderr))
+	assert.Contains(out.String(), "verification=skipped (no secret)")
+
+	// a secret does not make the unauthenticated Docker Hub deliveries verified
+	out.Reset()
+	rc.secrets["docker"] = secret
+	assert.Equal(0, run([]string{"send", "-provider", "docker", "-event", "build", "-url", server.URL}, &stdout, &stderr))
+	assert.Contains(out.String(), `docker event="build" type=docker.BuildPayload`)
+	assert.Contains(out.String(), "verification=unsupported (provider has no authentication)")
+	assert.NotContains(out.String(), "verification=ok")
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader("{}"))
+	assert.NoError(err)
+	_ = resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(out.String(), "unable to detect the provider")
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte("{\n  \"ref\": \"refs/heads/main\",\n  \"before\": \"<none>\"\n}\n")
+	var compact bytes.Buffer
+	require.NoError(t, json.Compact(&compact, body))
+
+	tests := []struct {
+		name     string
+		provider string
+		// detect leaves the provider to be detected from the delivery
+		detect bool
+		secret string
+		// change alters the signed delivery like a proxy would
+		change func(h http.Header, body []byte) []byte
+		code   int
+		want   string
+	}{
+		{name: "GitHub", provider: "github", code: 0, want: "X-Hub-Signature-256  hmac-sha256  ok"},
+		{name: "GitLab", provider: "gitlab", code: 0, want: "X-Gitlab-Token  token  ok"},
+		{name: "Azure", provider: "azure", code: 0, want: "username and password match"},
+		{name: "Docker", provider: "docker", code: 0, want: "docker does not sign its deliveries"},
+		{name: "SourceHut", provider: "sourcehut", secret: sourcehutPublicKey, code: 0, want: "X-Payload-Signature  ed25519  ok"},
+		{name: "DetectSourceHut", provider: "sourcehut", detect: true, secret: sourcehutPublicKey, code: 0, want: "X-Payload-Signature  ed25519  ok"},
+		{name: "CodeCommit", provider: "codecommit", code: 0, want: "SNS signs the message in the body with an AWS certificate"},
+		{name: "DetectCodeCommit", provider: "codecommit", detect: true, code: 0, want: "the secret is the topic ARN"},
+		{name: "Drone", provider: "drone", code: 0, want: "Signature  http-signature  ok"},
+		{name: "DetectDrone", provider: "drone", detect: true, code: 0, want: "Digest     sha256-digest   ok"},
+		{
+			name: "DroneBodyChanged", provider: "drone", code: 1,
+			change: func(h http.Header, body []byte) []byte { return bytes.TrimSpace(body) },
+			want:   "digest matches the body with a trailing newline",
+		},
+		{
+			name: "DroneDateChanged", provider: "drone", code: 1,
+			change: func(h http.Header, body []byte) []byte {
+				h.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+				return body
+			},
+			want: "the request target /webhooks or a signed header was changed",
+		},
+		{name: "GiteeSigned", provider: "gitee-signed", detect: true, code: 0, want: "X-Gitee-Token  gitee-signature  ok"},
+		{
+			name: "GiteeStale", provider: "gitee-signed", code: 1,
+			change: func(h http.Header, body []byte) []byte {
+				h.Set("X-Gitee-Timestamp", strconv.FormatInt(time.Now().Add(-time.Hour).UnixMilli(), 10))
+				return body
+			},
+			want: "the signing key differs or the X-Gitee-Timestamp was changed",
+		},
+		{
+			name: "GiteePassword", provider: "gitee-signed", code: 1,
+			change: func(h http.Header, body []byte) []byte { h.Set("X-Gitee-Token", secret); return body },
+			want:   "token is the secret itself",
+		},
+		{name: "Quay", provider: "quay", code: 0, want: "username and password match"},
+		{name: "DetectQuay", provider: "quay", detect: true, code: 0, want: "Authorization  basic-auth  ok"},
+		{
+			name: "OtherTopic", provider: "codecommit", secret: "arn:aws:sns:us-east-1:123456789012:OtherTopic", code: 1,
+			want: "SNS topic not allowed",
+		},
+		{name: "WrongSecret", provider: "github", secret: "wrong", code: 1, want: "signature does not match: the secret differs"},
+		{
+			name: "TrailingNewlineRemoved", provider: "gitea", code: 1,
+			change: func(h http.Header, body []byte) []byte { return bytes.TrimSpace(body) },
+			want:   "signature matches the body with a trailing newline",
+		},
+		{
+			name: "Reindented", provider: "bitbucket-server", code: 1,
+			change: func(h http.Header, body []byte) []byte { return compact.Bytes() },
+			want:   "signature matches the body as indented JSON followed by a newline",
+		},
+		{
+			name: "MissingHeader", provider: "gogs", code: 1,
+			change: func(h http.Header, body []byte) []byte { h.Del("X-Gogs-Signature"); return body },
+			want:   "X-Gogs-Signature header is missing",
+		},
+		{
+			name: "MissingPrefix", provider: "bitbucket-server", code: 1,
+			change: func(h http.Header, body []byte) []byte {
+				h.Set("X-Hub-Signature", strings.TrimPrefix(h.Get("X-Hub-Signature"), "sha256="))
+				return body
+			},
+			want: `signature matches but lacks the "sha256=" prefix`,
+		},
+		{
+			name: "WrongAlgorithm", provider: "github", code: 1,
+			change: func(h http.Header, body []byte) []byte {
+				h.Set("X-Hub-Signature-256", "sha256="+strings.TrimPrefix(h.Get("X-Hub-Signature"), "sha1="))
+				return body
+			},
+			want: "signature is a valid hmac-sha1 of the body, the provider signs with hmac-sha256",
+		},
+		{
+			name: "OptionalSignature", provider: "github", code: 0,
+			change: func(h http.Header, body []byte) []byte { h.Del("X-Hub-Signature"); return body },
+			want:   "(not verified by the github package)",
+		},
+		{
+			name: "TokenWhitespace", provider: "gitlab", secret: secret + "\n", code: 1,
+			want: "token only differs from the secret by surrounding whitespace",
+		},
+		{
+			name: "WrongPassword", provider: "azure", secret: "user:wrong", code: 1,
+			want: "password does not match",
+		},
+		{
+			name: "ChangedNonce", provider: "sourcehut", secret: sourcehutPublicKey, code: 1,
+			change: func(h http.Header, body []byte) []byte { h.Set("X-Payload-Nonce", "replayed"); return body },
+			want:   "signature does not match: the key differs, or the body or the nonce was changed",
+		},
+		{
+			name: "MissingNonce", provider: "sourcehut", code: 1,
+			change: func(h http.Header, body []byte) []byte { h.Del("X-Payload-Nonce"); return body },
+			want:   "X-Payload-Nonce header is missing",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+			p := mustProvider(t, tc.provider)
+			event := p.events()[0]
+			signed := body
+			switch p.name {
+			case "azure":
+				signed = []byte(`{"eventType":"git.push"}`)
+			case "codecommit", "quay":
+				// the message type is in the body too, quay is detected by its body
+				var err error
+				signed, err = readFixture(p, event, "")
+				assert.NoError(err)
+			}
+			target, err := p.target(whtest.DefaultTarget, event)
+			assert.NoError(err)
+			h, err := p.headers(target, event, signed, secretFor(p.name))
+			assert.NoError(err)
+			sent := signed
+			if tc.change != nil {
+				sent = tc.change(h, signed)
+			}
+
+			dir := t.TempDir()
+			var headers bytes.Buffer
+			fmt.Fprintf(&headers, "POST %s HTTP/1.1\r\n", target)
+			assert.NoError(h.Write(&headers))
+			assert.NoError(os.WriteFile(filepath.Join(dir, "delivery.headers"), headers.Bytes(), 0o644))
+			assert.NoError(os.WriteFile(filepath.Join(dir, "delivery.json"), sent, 0o644))
+
+			s := tc.secret
+			if s == "" {
+				s = secretFor(p.name)
+			}
+			args := []string{"verify", "-secret", s, "-headers", filepath.Join(dir, "delivery.headers"), "-body", filepath.Join(dir, "delivery.json")}
+			if !tc.detect {
+				args = append(args, "-provider", p.name)
+			}
+			var stdout, stderr bytes.Buffer
+			assert.Equal(tc.code, run(args, &stdout, &stderr), stdout.String()+stderr.String())
+			assert.Contains(stdout.String(), tc.want)
+			if tc.detect {
+				assert.Regexp(`provider +`+p.name+`\n`, stdout.String())
+			}
+		})
+	}
+}
+
+func TestVerifyRawRequest(t *testing.T) {
+	assert := require.New(t)
+	p := mustProvider(t, "github")
+	body, err := readFixture(p, "push", "")
+	assert.NoError(err)
+
+	r, err := http.NewRequest(http.MethodPost, "http://localhost:3000/webhooks", bytes.NewReader(body))
+	assert.NoError(err)
+	r.Header, err = p.headers(r.URL.String(), "push", body, secret)
+	assert.NoError(err)
+	var raw bytes.Buffer
+	assert.NoError(r.Write(&raw))
+
+	file := filepath.Join(t.TempDir(), "request.http")
+	assert.NoError(os.WriteFile(file, raw.Bytes(), 0o644))
+	var stdout, stderr bytes.Buffer
+	assert.Equal(0, run([]string{"verify", "-secret", secret, file}, &stdout, &stderr), stdout.String()+stderr.String())
+	assert.Regexp(`provider +github\n`, stdout.String())
+	assert.Regexp(`parse +ok\n`, stdout.String())
+
+	// a proxy appending to the body without updating Content-Length
+	raw.WriteString("\nextra")
+	assert.NoError(os.WriteFile(file, raw.Bytes(), 0o644))
+	stdout.Reset()
+	assert.Equal(0, run([]string{"verify", "-secret", secret, file}, &stdout, &stderr))
+	assert.Contains(stdout.String(), "6 bytes follow the body declared by the request")
+
+	stderr.Reset()
+	assert.Equal(1, run([]string{"verify", file}, &stdout, &stderr))
+	assert.Contains(stderr.String(), "missing -secret")
+}
@@ -0,0 +1,852 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pchchv/wh/azure"
+	"github.com/pchchv/wh/bitbucket"
+	bitbucketserver "github.com/pchchv/wh/bitbucket-server"
+	"github.com/pchchv/wh/codecommit"
+	"github.com/pchchv/wh/docker"
+	"github.com/pchchv/wh/drone"
+	"github.com/pchchv/wh/forgejo"
+	"github.com/pchchv/wh/gerrit"
+	"github.com/pchchv/wh/gitea"
+	"github.com/pchchv/wh/gitee"
+	"github.com/pchchv/wh/github"
+	"github.com/pchchv/wh/gitlab"
+	"github.com/pchchv/wh/gogs"
+	"github.com/pchchv/wh/harbor"
+	"github.com/pchchv/wh/jenkins"
+	"github.com/pchchv/wh/quay"
+	"github.com/pchchv/wh/sourcehut"
+	"github.com/pchchv/wh/whtest"
+	"github.com/pchchv/wh/woodpecker"
+)
+
+// provider describes how a webhook provider identifies, signs and labels its deliveries.
+type provider struct {
+	name           string
+	dir            string
+	eventHeader    string
+	deliveryHeader string
+	// eventParam is the query parameter of the receiver URL holding the event, for providers sending no event header
+	eventParam string
+	signatures []signature
+	// note explains how a provider without signature headers authenticates its deliveries
+	note string
+	// authenticated reports whether the provider authenticates its deliveries at all, Docker Hub does not
+	authenticated bool
+	delivery      func(event string, payload interface{}) *whtest.Delivery
+	parse         func(r *http.Request, event, secret string) (interface{}, error)
+	fixtures      map[string]string
+}
+
+// signature schemes providers authenticate their deliveries with.
+const (
+	hmacSHA1   = "hmac-sha1"
+	hmacSHA256 = "hmac-sha256"
+	token      = "token"
+	basicAuth  = "basic-auth"
+	ed25519Sig = "ed25519"
+	digest     = "sha256-digest"
+	httpSig    = "http-signature"
+	// giteeSig is the HMAC-SHA256 of the X-Gitee-Timestamp and the signing key of Gitee
+	giteeSig = "gitee-signature"
+)
+
+// signature describes a header a provider authenticates its deliveries with.
+type signature struct {
+	header string
+	scheme string
+	prefix string
+	// checked reports whether the provider package verifies the header, others are only sent by the provider.
+	checked bool
+}
+
+// value returns the header value the provider sends for body signed with secret.
+func (s signature) value(secret string, body []byte) string {
+	switch s.scheme {
+	case hmacSHA1:
+		return s.prefix + hexMAC(sha1.New, secret, body)
+	case hmacSHA256:
+		return s.prefix + hexMAC(sha256.New, secret, body)
+	case basicAuth:
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(secret))
+	case digest:
+		sum := sha256.Sum256(body)
+		return s.prefix + base64.StdEncoding.EncodeToString(sum[:])
+	default:
+		return s.prefix + secret
+	}
+}
+
+var providers = []provider{
+	{
+		name:           "github",
+		dir:            "github",
+		authenticated:  true,
+		eventHeader:    "X-GitHub-Event",
+		deliveryHeader: "X-GitHub-Delivery",
+		signatures: []signature{
+			{header: "X-Hub-Signature-256", scheme: hmacSHA256, prefix: "sha256=", checked: true},
+			{header: "X-Hub-Signature", scheme: hmacSHA1, prefix: "sha1="},
+		},
+		delivery: whtest.GitHub[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := github.New(github.Options.Secret(secret))
+			return hook.Parse(r, github.Event(event))
+		},
+		fixtures: map[string]string{
+			"check_run":                             "check-run.json",
+			"check_suite":                           "check-suite.json",
+			"code_scanning_alert":                   "code_scanning_alert.json",
+			"commit_comment":                        "commit-comment.json",
+			"create":                                "create.json",
+			"delete":                                "delete.json",
+			"dependabot_alert":                      "dependabot_alert.json",
+			"deploy_key":                            "deploy_key.json",
+			"deployment":                            "deployment.json",
+			"deployment_status":                     "deployment-status.json",
+			"fork":                                  "fork.json",
+			"github_app_authorization":              "github-app-authorization.json",
+			"gollum":                                "gollum.json",
+			"installation":                          "installation.json",
+			"installation_repositories":             "installation-repositories.json",
+			"integration_installation":              "integration-installation.json",
+			"integration_installation_repositories": "integration-installation-repositories.json",
+			"issue_comment":                         "issue-comment.json",
+			"issues":                                "issues.json",
+			"label":                                 "label.json",
+			"member":                                "member.json",
+			"membership":                            "membership.json",
+			"milestone":                             "milestone.json",
+			"org_block":                             "org-block.json",
+			"organization":                          "organization.json",
+			"page_build":                            "page-build.json",
+			"ping":                                  "ping.json",
+			"project":                               "project.json",
+			"project_card":                          "project-card.json",
+			"project_column":                        "project-column.json",
+			"public":                                "public.json",
+			"pull_request":                          "pull-request.json",
+			"pull_request_review":                   "pull-request-review.json",
+			"pull_request_review_comment":           "pull-request-review-comment.json",
+			"push":                                  "push.json",
+			"release":                               "release.json",
+			"repository":                            "repository.json",
+			"repository_vulnerability_alert":        "repository-vulnerability-alert.json",
+			"security_advisory":                     "security-advisory.json",
+			"status":                                "status.json",
+			"team":                                  "team.json",
+			"team_add":                              "team-add.json",
+			"watch":                                 "watch.json",
+			"workflow_dispatch":                     "workflow_dispatch.json",
+			"workflow_job":                          "workflow_job.json",
+			"workflow_run":                          "workflow_run.json",
+		},
+	},
+	{
+		name:           "gitlab",
+		dir:            "gitlab",
+		authenticated:  true,
+		eventHeader:    "X-Gitlab-Event",
+		deliveryHeader: "X-Gitlab-Event-UUID",
+		signatures: []signature{
+			{header: "X-Gitlab-Token", scheme: token, checked: true},
+		},
+		delivery: whtest.GitLab[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := gitlab.New()
+			if secret != "" {
+				hook, _ = gitlab.New(gitlab.Options.Secret(secret))
+			}
+			// system and job hooks are forwarded to the parsers of the events they carry
+			return hook.Parse(r, gitlab.Event(event), gitlab.PushEvents, gitlab.TagEvents, gitlab.MergeRequestEvents, gitlab.BuildEvents)
+		},
+		fixtures: map[string]string{
+			"Build Hook":              "build-event.json",
+			"Confidential Issue Hook": "confidential-issue-event.json",
+			"Confidential Note Hook":  "confidential-comment-event.json",
+			"Deployment Hook":         "deployment-event.json",
+			"Issue Hook":              "issue-event.json",
+			"Job Hook":                "job-event.json",
+			"Merge Request Hook":      "merge-request-event.json",
+			"Note Hook":               "comment-merge-request-event.json",
+			"Pipeline Hook":           "pipeline-event.json",
+			"Push Hook":               "push-event.json",
+			"Release Hook":            "release-event.json",
+			"System Hook":             "system-push-event.json",
+			"Tag Push Hook":           "tag-event.json",
+			"Wiki Page Hook":          "wikipage-event.json",
+		},
+	},
+	{
+		name:          "gitee",
+		dir:           "gitee",
+		authenticated: true,
+		eventHeader:   "X-Gitee-Event",
+		// the secret is the WebHook password, WebHooks with a signing key are sent as gitee-signed
+		signatures: []signature{
+			{header: "X-Gitee-Token", scheme: token, checked: true},
+		},
+		delivery: whtest.Gitee[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := gitee.New()
+			if secret != "" {
+				hook, _ = gitee.New(gitee.Options.Secret(secret))
+			}
+			return hook.Parse(r, gitee.Event(event))
+		},
+		fixtures: giteeFixtures,
+	},
+	{
+		name:          "gitee-signed",
+		dir:           "gitee",
+		authenticated: true,
+		eventHeader:   "X-Gitee-Event",
+		// the secret is the signing key of the WebHook
+		signatures: []signature{
+			{header: "X-Gitee-Token", scheme: giteeSig, checked: true},
+		},
+		delivery: whtest.GiteeSigned[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := gitee.New()
+			if secret != "" {
+				hook, _ = gitee.New(gitee.Options.SigningKey(secret))
+			}
+			return hook.Parse(r, gitee.Event(event))
+		},
+		fixtures: giteeFixtures,
+	},
+	{
+		name:           "gitea",
+		dir:            "gitea",
+		authenticated:  true,
+		eventHeader:    "X-Gitea-Event",
+		deliveryHeader: "X-Gitea-Delivery",
+		signatures: []signature{
+			{header: "X-Gitea-Signature", scheme: hmacSHA256, checked: true},
+		},
+		delivery: whtest.Gitea[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := gitea.New(gitea.Options.Secret(secret))
+			return hook.Parse(r, gitea.Event(event))
+		},
+		fixtures: map[string]string{
+			"create":                 "create-event.json",
+			"delete":                 "delete-event.json",
+			"fork":                   "fork-event.json",
+			"issue_assign":           "issue-assign-event.json",
+			"issue_comment":          "issue-comment-event.json",
+			"issue_label":            "issue-label-event.json",
+			"issue_milestone":        "issue-milestone-event.json",
+			"issues":                 "issues-event.json",
+			"pull_request":           "pull-request-event.json",
+			"pull_request_assign":    "pull-request-assign-event.json",
+			"pull_request_comment":   "pull-request-comment-event.json",
+			"pull_request_label":     "pull-request-label-event.json",
+			"pull_request_milestone": "pull-request-milestone-event.json",
+			"pull_request_review":    "pull-request-review-event.json",
+			"push":                   "push-event.json",
+			"release":                "release-event.json",
+			"repository":             "repository-event.json",
+		},
+	},
+	{
+		name:           "forgejo",
+		dir:            "forgejo",
+		authenticated:  true,
+		eventHeader:    "X-Forgejo-Event",
+		deliveryHeader: "X-Forgejo-Delivery",
+		signatures: []signature{
+			{header: "X-Forgejo-Signature", scheme: hmacSHA256, checked: true},
+		},
+		delivery: whtest.Forgejo[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := forgejo.New(forgejo.Options.Secret(secret))
+			return hook.Parse(r, forgejo.Event(event))
+		},
+		fixtures: map[string]string{
+			"create":                       "create-event.json",
+			"delete":                       "delete-event.json",
+			"fork":                         "fork-event.json",
+			"issue_assign":                 "issue-assign-event.json",
+			"issue_comment":                "issue-comment-event.json",
+			"issue_label":                  "issue-label-event.json",
+			"issue_milestone":              "issue-milestone-event.json",
+			"issues":                       "issues-event.json",
+			"package":                      "package-event.json",
+			"pull_request":                 "pull-request-event.json",
+			"pull_request_assign":          "pull-request-assign-event.json",
+			"pull_request_comment":         "pull-request-comment-event.json",
+			"pull_request_label":           "pull-request-label-event.json",
+			"pull_request_milestone":       "pull-request-milestone-event.json",
+			"pull_request_review":          "pull-request-review-event.json",
+			"pull_request_review_approved": "pull-request-review-approved-event.json",
+			"pull_request_review_request":  "pull-request-review-request-event.json",
+			"push":                         "push-event.json",
+			"release":                      "release-event.json",
+			"repository":                   "repository-event.json",
+			"wiki":                         "wiki-event.json",
+		},
+	},
+	{
+		name:           "gogs",
+		dir:            "gogs",
+		authenticated:  true,
+		eventHeader:    "X-Gogs-Event",
+		deliveryHeader: "X-Gogs-Delivery",
+		signatures: []signature{
+			{header: "X-Gogs-Signature", scheme: hmacSHA256, checked: true},
+		},
+		delivery: whtest.Gogs[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := gogs.New(gogs.Options.Secret(secret))
+			return hook.Parse(r, gogs.Event(event))
+		},
+		fixtures: map[string]string{
+			"create":        "create-event.json",
+			"delete":        "delete-event.json",
+			"fork":          "fork-event.json",
+			"issue_comment": "issue-comment-event.json",
+			"issues":        "issues-event.json",
+			"pull_request":  "pull-request-event.json",
+			"push":          "push-event.json",
+			"release":       "release-event.json",
+		},
+	},
+	{
+		name:           "bitbucket",
+		dir:            "bitbucket",
+		authenticated:  true,
+		eventHeader:    "X-Event-Key",
+		deliveryHeader: "X-Request-UUID",
+		signatures: []signature{
+			{header: "X-Hook-UUID", scheme: token, checked: true},
+		},
+		delivery: whtest.Bitbucket[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := bitbucket.New(bitbucket.Options.UUID(secret))
+			return hook.Parse(r, bitbucket.Event(event))
+		},
+		fixtures: map[string]string{
+			"issue:comment_created":       "issue-comment-created.json",
+			"issue:created":               "issue-created.json",
+			"issue:updated":               "issue-updated.json",
+			"pullrequest:approved":        "pull-request-approved.json",
+			"pullrequest:comment_created": "pull-request-comment-created.json",
+			"pullrequest:comment_deleted": "pull-request-comment-deleted.json",
+			"pullrequest:comment_updated": "pull-request-comment-updated.json",
+			"pullrequest:created":         "pull-request-created.json",
+			"pullrequest:fulfilled":       "pull-request-merged.json",
+			"pullrequest:rejected":        "pull-request-declined.json",
+			"pullrequest:unapproved":      "pull-request-approval-removed.json",
+			"pullrequest:updated":         "pull-request-updated.json",
+			"repo:commit_comment_created": "commit-comment-created.json",
+			"repo:commit_status_created":  "repo-commit-status-created.json",
+			"repo:commit_status_updated":  "repo-commit-status-updated.json",
+			"repo:fork":                   "repo-fork.json",
+			"repo:push":                   "repo-push.json",
+			"repo:updated":                "repo-updated.json",
+		},
+	},
+	{
+		name:           "bitbucket-server",
+		dir:            "bitbucket-server",
+		authenticated:  true,
+		eventHeader:    "X-Event-Key",
+		deliveryHeader: "X-Request-Id",
+		signatures: []signature{
+			{header: "X-Hub-Signature", scheme: hmacSHA256, prefix: "sha256=", checked: true},
+		},
+		delivery: whtest.BitbucketServer[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := bitbucketserver.New(bitbucketserver.Options.Secret(secret))
+			return hook.Parse(r, bitbucketserver.Event(event))
+		},
+		fixtures: map[string]string{
+			"pr:comment:added":       "pr-comment-added.json",
+			"pr:comment:deleted":     "pr-comment-deleted.json",
+			"pr:comment:edited":      "pr-comment-edited.json",
+			"pr:declined":            "pr-declined.json",
+			"pr:deleted":             "pr-deleted.json",
+			"pr:merged":              "pr-merged.json",
+			"pr:modified":            "pr-modified.json",
+			"pr:opened":              "pr-opened.json",
+			"pr:reviewer:approved":   "pr-reviewer-approved.json",
+			"pr:reviewer:needs_work": "pr-reviewer-needs-work.json",
+			"pr:reviewer:unapproved": "pr-reviewer-unapproved.json",
+			"pr:reviewer:updated":    "pr-reviewer-updated.json",
+			"repo:comment:added":     "repo-comment-added.json",
+			"repo:comment:deleted":   "repo-comment-deleted.json",
+			"repo:comment:edited":    "repo-comment-edited.json",
+			"repo:forked":            "repo-forked.json",
+			"repo:modified":          "repo-modified.json",
+			"repo:refs_changed":      "repo-refs-changed.json",
+		},
+	},
+	{
+		name:          "azure",
+		dir:           "azure",
+		authenticated: true,
+		// the secret is "username:password" for basic auth
+		signatures: []signature{
+			{header: "Authorization", scheme: basicAuth, checked: true},
+		},
+		delivery: func(event string, payload interface{}) *whtest.Delivery {
+			return whtest.Azure(payload)
+		},
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			username, password, _ := strings.Cut(secret, ":")
+			hook, _ := azure.New(azure.Options.BasicAuth(username, password))
+			return hook.Parse(r, azure.Event(event))
+		},
+		fixtures: map[string]string{
+			"build.complete":                             "build.complete.json",
+			"git.pullrequest.comment-event":              "git.pullrequest.comment-event.json",
+			"git.pullrequest.created":                    "git.pullrequest.created.json",
+			"git.pullrequest.merged":                     "git.pullrequest.merged.json",
+			"git.pullrequest.updated":                    "git.pullrequest.updated.json",
+			"git.push":                                   "git.push.json",
+			"ms.vss-code.git-pullrequest-comment-event":  "ms.vss-code.git-pullrequest-comment-event.json",
+			"ms.vss-pipelines.run-state-changed-event":   "ms.vss-pipelines.run-state-changed-event.json",
+			"ms.vss-pipelines.stage-state-changed-event": "ms.vss-pipelines.stage-state-changed-event.json",
+			"ms.vss-release.deployment-completed-event":  "ms.vss-release.deployment-completed-event.json",
+			"workitem.commented":                         "workitem.commented.json",
+			"workitem.created":                           "workitem.created.json",
+			"workitem.deleted":                           "workitem.deleted.json",
+			"workitem.restored":                          "workitem.restored.json",
+			"workitem.updated":                           "workitem.updated.json",
+		},
+	},
+	{
+		name:          "gerrit",
+		dir:           "gerrit",
+		authenticated: true,
+		// the secret is "username:password" for basic auth
+		signatures: []signature{
+			{header: "Authorization", scheme: basicAuth, checked: true},
+		},
+		delivery: func(event string, payload interface{}) *whtest.Delivery {
+			return whtest.Gerrit(payload)
+		},
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			username, password, _ := strings.Cut(secret, ":")
+			hook, _ := gerrit.New(gerrit.Options.BasicAuth(username, password))
+			return hook.Parse(r, gerrit.Event(event))
+		},
+		fixtures: map[string]string{
+			"change-abandoned": "change-abandoned.json",
+			"change-merged":    "change-merged.json",
+			"comment-added":    "comment-added.json",
+			"patchset-created": "patchset-created.json",
+			"ref-updated":      "ref-updated.json",
+		},
+	},
+	{
+		name:           "sourcehut",
+		dir:            "sourcehut",
+		authenticated:  true,
+		eventHeader:    "X-Webhook-Event",
+		deliveryHeader: "X-Webhook-Delivery",
+		// the secret is the base64 encoded Ed25519 private key signing deliveries, or the public key verifying them
+		signatures: []signature{
+			{header: "X-Payload-Signature", scheme: ed25519Sig, checked: true},
+		},
+		delivery: whtest.SourceHut[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := sourcehut.New()
+			if secret != "" {
+				key, err := ed25519PublicKey(secret)
+				if err != nil {
+					return nil, err
+				}
+				hook, _ = sourcehut.New(sourcehut.Options.PublicKey(base64.StdEncoding.EncodeToString(key)))
+			}
+			return hook.Parse(r, sourcehut.Event(event))
+		},
+		fixtures: map[string]string{
+			"event:create":     "event-create.json",
+			"job:create":       "job-create.json",
+			"repo:create":      "repo-create.json",
+			"repo:post-update": "repo-post-update.json",
+			"ticket:create":    "ticket-create.json",
+			"ticket:update":    "ticket-update.json",
+		},
+	},
+	{
+		name:           "codecommit",
+		dir:            "codecommit",
+		authenticated:  true,
+		eventHeader:    "X-Amz-Sns-Message-Type",
+		deliveryHeader: "X-Amz-Sns-Message-Id",
+		// the secret is the ARN of the SNS topic the receiver accepts messages of
+		note:     "SNS signs the message in the body with an AWS certificate, which wh does not fetch, the secret is the topic ARN checked by parse",
+		delivery: whtest.CodeCommit[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			var options []codecommit.Option
+			if secret != "" {
+				options = append(options, codecommit.Options.TopicARNs(secret))
+			}
+			hook, err := codecommit.New(options...)
+			if err != nil {
+				return nil, fmt.Errorf("the codecommit secret is the ARN of the SNS topic: %w", err)
+			}
+			return hook.Parse(r, codecommit.Event(event))
+		},
+		fixtures: map[string]string{
+			"Notification":             "notification.json",
+			"SubscriptionConfirmation": "subscription-confirmation.json",
+			"UnsubscribeConfirmation":  "unsubscribe-confirmation.json",
+		},
+	},
+	{
+		name:          "quay",
+		dir:           "quay",
+		authenticated: true,
+		eventParam:    "event",
+		signatures: []signature{
+			{header: "Authorization", scheme: basicAuth, checked: true},
+		},
+		delivery: whtest.Quay[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := quay.New()
+			if secret != "" {
+				username, password, _ := strings.Cut(secret, ":")
+				hook, _ = quay.New(quay.Options.BasicAuth(username, password))
+			}
+			return hook.Parse(r, quay.Event(event))
+		},
+		fixtures: map[string]string{
+			"build_failure":       "build-failure.json",
+			"build_success":       "build-success.json",
+			"repo_push":           "repo-push.json",
+			"vulnerability_found": "vulnerability-found.json",
+		},
+	},
+	{
+		name:          "drone",
+		dir:           "drone",
+		authenticated: true,
+		eventHeader:   "X-Drone-Event",
+		signatures: []signature{
+			{header: "Digest", scheme: digest, prefix: "SHA-256=", checked: true},
+			{header: "Signature", scheme: httpSig, checked: true},
+		},
+		delivery: whtest.Drone[string],
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := drone.New()
+			if secret != "" {
+				hook, _ = drone.New(drone.Options.Secret(secret))
+			}
+			return hook.Parse(r, drone.Event(event))
+		},
+		fixtures: map[string]string{
+			"build": "build-created.json",
+			"repo":  "repo-enabled.json",
+			"user":  "user-created.json",
+		},
+	},
+	{
+		name:          "harbor",
+		dir:           "harbor",
+		authenticated: true,
+		// the secret is the Auth Header of the webhook policy, sent as is
+		signatures: []signature{
+			{header: "Authorization", scheme: token, checked: true},
+		},
+		delivery: func(event string, payload interface{}) *whtest.Delivery {
+			return whtest.Harbor(payload)
+		},
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := harbor.New()
+			if secret != "" {
+				hook, _ = harbor.New(harbor.Options.AuthHeader(secret))
+			}
+			return hook.Parse(r, harbor.Event(event))
+		},
+		fixtures: map[string]string{
+			"DELETE_ARTIFACT":    "delete-artifact.json",
+			"PUSH_ARTIFACT":      "push-artifact.json",
+			"SCANNING_COMPLETED": "scanning-completed.json",
+		},
+	},
+	{
+		name:          "jenkins",
+		dir:           "jenkins",
+		authenticated: true,
+		// the secret is "username:password" for basic auth
+		signatures: []signature{
+			{header: "Authorization", scheme: basicAuth, checked: true},
+		},
+		delivery: func(event string, payload interface{}) *whtest.Delivery {
+			return whtest.Jenkins(payload)
+		},
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			username, password, _ := strings.Cut(secret, ":")
+			hook, _ := jenkins.New(jenkins.Options.BasicAuth(username, password))
+			return hook.Parse(r, jenkins.Event(event))
+		},
+		fixtures: map[string]string{
+			"COMPLETED": "completed.json",
+			"FINALIZED": "finalized.json",
+			"STARTED":   "started.json",
+		},
+	},
+	{
+		name:          "woodpecker",
+		dir:           "woodpecker",
+		authenticated: true,
+		signatures: []signature{
+			{header: "Authorization", scheme: token, prefix: "Bearer ", checked: true},
+		},
+		delivery: func(event string, payload interface{}) *whtest.Delivery {
+			return whtest.Woodpecker(payload)
+		},
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := woodpecker.New()
+			if secret != "" {
+				hook, _ = woodpecker.New(woodpecker.Options.Token(secret))
+			}
+			return hook.Parse(r, woodpecker.Event(event))
+		},
+		fixtures: map[string]string{
+			"failure": "failure.json",
+			"success": "success.json",
+		},
+	},
+	{
+		name: "docker",
+		dir:  "docker",
+		delivery: func(event string, payload interface{}) *whtest.Delivery {
+			return whtest.Docker(payload)
+		},
+		parse: func(r *http.Request, event, secret string) (interface{}, error) {
+			hook, _ := docker.New()
+			return hook.Parse(r, docker.Event(event))
+		},
+		fixtures: map[string]string{
+			"build": "docker_hub_build_notice.json",
+		},
+	},
+}
+
+// giteeFixtures are the fixtures of the Gitee WebHooks, with a password or a signing key.
+var giteeFixtures = map[string]string{
+	"Issue Hook":         "issue-event.json",
+	"Merge Request Hook": "merge-request-event.json",
+	"Note Hook":          "note-event.json",
+	"Push Hook":          "push-event.json",
+	"Tag Push Hook":      "tag-push-event.json",
+}
+
+func lookupProvider(name string) (provider, error) {
+	for _, p := range providers {
+		if p.name == name {
+			return p, nil
+		}
+	}
+	return provider{}, fmt.Errorf("unknown provider %q, expected one of %s", name, strings.Join(providerNames(), ", "))
+}
+
+// detectProvider guesses the provider and event of a delivery from its headers,
+// falling back to the body for the providers which send no event header.
+func detectProvider(target string, h http.Header, body []byte) (provider, string, error) {
+	name := ""
+	switch {
+	// Forgejo also sends the Gitea headers
+	case h.Get("X-Forgejo-Event") != "":
+		name = "forgejo"
+	case h.Get("X-Gitea-Event") != "":
+		name = "gitea"
+	case h.Get("X-Gogs-Event") != "":
+		name = "gogs"
+	case h.Get("X-GitHub-Event") != "":
+		name = "github"
+	// only WebHooks with a signing key send a timestamp
+	case h.Get("X-Gitee-Event") != "" && h.Get("X-Gitee-Timestamp") != "":
+		name = "gitee-signed"
+	case h.Get("X-Gitee-Event") != "":
+		name = "gitee"
+	case h.Get("X-Gitlab-Event") != "":
+		name = "gitlab"
+	case h.Get("X-Event-Key") != "" && (h.Get("X-Hook-UUID") != "" || h.Get("X-Request-UUID") != ""):
+		name = "bitbucket"
+	case h.Get("X-Event-Key") != "":
+		name = "bitbucket-server"
+	case h.Get("X-Webhook-Event") != "":
+		name = "sourcehut"
+	case h.Get("X-Drone-Event") != "":
+		name = "drone"
+	case h.Get("X-Amz-Sns-Message-Type") != "":
+		name = "codecommit"
+	case bytes.Contains(body, []byte(`"eventType"`)):
+		name = "azure"
+	case bytes.Contains(body, []byte(`"eventCreatedOn"`)):
+		name = "gerrit"
+	case bytes.Contains(body, []byte(`"full_url"`)):
+		name = "jenkins"
+	case bytes.Contains(body, []byte(`"curr"`)):
+		name = "woodpecker"
+	case bytes.Contains(body, []byte(`"event_data"`)):
+		name = "harbor"
+	case bytes.Contains(body, []byte(`"push_data"`)):
+		name = "docker"
+	case bytes.Contains(body, []byte(`"docker_url"`)):
+		name = "quay"
+	default:
+		return provider{}, "", errors.New("unable to detect the provider from the delivery headers")
+	}
+
+	p, err := lookupProvider(name)
+	if err != nil {
+		return p, "", err
+	}
+	return p, p.event(target, h, body), nil
+}
+
+// event returns the event of a delivery of the provider to the request target.
+func (p provider) event(target string, h http.Header, body []byte) string {
+	if p.eventParam != "" {
+		if u, err := url.Parse(target); err == nil && u.Query().Get(p.eventParam) != "" {
+			return u.Query().Get(p.eventParam)
+		}
+	}
+
+	switch p.name {
+	case "azure":
+		var basic struct {
+			EventType string `json:"eventType"`
+		}
+		_ = json.Unmarshal(body, &basic)
+		return basic.EventType
+	case "gerrit", "harbor":
+		var basic struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal(body, &basic)
+		return basic.Type
+	case "jenkins":
+		var basic jenkins.BasicEvent
+		_ = json.Unmarshal(body, &basic)
+		return string(basic.Build.Phase)
+	case "woodpecker":
+		var basic woodpecker.BasicEvent
+		_ = json.Unmarshal(body, &basic)
+		return string(basic.Curr.Status)
+	case "docker":
+		return string(docker.BuildEvent)
+	case "quay":
+		// builds are only told apart by the event parameter
+		var basic struct {
+			UpdatedTags   json.RawMessage `json:"updated_tags"`
+			Vulnerability json.RawMessage `json:"vulnerability"`
+		}
+		_ = json.Unmarshal(body, &basic)
+		switch {
+		case basic.UpdatedTags != nil:
+			return string(quay.RepoPushEvent)
+		case basic.Vulnerability != nil:
+			return string(quay.VulnerabilityFoundEvent)
+		}
+		return ""
+	default:
+		return h.Get(p.eventHeader)
+	}
+}
+
+func providerNames() []string {
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+func (p provider) events() []string {
+	events := make([]string, 0, len(p.fixtures))
+	for e := range p.fixtures {
+		events = append(events, e)
+	}
+	sort.Strings(events)
+	return events
+}
+
+// headers returns the headers the provider sends with a delivery of the event to the target, signed with secret.
+func (p provider) headers(target, event string, body []byte, secret string) (http.Header, error) {
+	d := p.delivery(event, body).Target(target)
+	switch {
+	case secret == "":
+	case p.basicAuth():
+		username, password, _ := strings.Cut(secret, ":")
+		d.BasicAuth(username, password)
+	case p.name == "sourcehut":
+		if key, err := base64.StdEncoding.DecodeString(secret); err != nil || len(key) != ed25519.PrivateKeySize {
+			return nil, errors.New("sourcehut signs deliveries with a base64 encoded Ed25519 private key")
+		}
+		d.Secret(secret)
+	default:
+		d.Secret(secret)
+	}
+	return d.Request().Header, nil
+}
+
+// basicAuth reports whether the secret of the provider is a username:password pair.
+func (p provider) basicAuth() bool {
+	for _, s := range p.signatures {
+		if s.scheme == basicAuth {
+			return true
+		}
+	}
+	return false
+}
+
+// target returns the request target of a delivery of the event to the URL, adding the event parameter.
+func (p provider) target(rawURL, event string) (string, error) {
+	if p.eventParam == "" {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(p.eventParam, event)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// ed25519PublicKey returns the public key of secret, a base64 encoded Ed25519 public key or private key.
+func ed25519PublicKey(secret string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	switch {
+	case err == nil && len(key) == ed25519.PublicKeySize:
+		return ed25519.PublicKey(key), nil
+	case err == nil && len(key) == ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key).Public().(ed25519.PublicKey), nil
+	default:
+		return nil, errors.New("the secret is not a base64 encoded Ed25519 public or private key")
+	}
+}
+
+func hexMAC(fn func() hash.Hash, secret string, body []byte) string {
+	mac := hmac.New(fn, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pchchv/wh"
+)
+
+func runSend(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	providerName := fs.String("provider", "github", "webhook provider: "+strings.Join(providerNames(), ", "))
+	event := fs.String("event", "", "event to send, e.g. push or \"Merge Request Hook\" (required)")
+	secret := fs.String("secret", "", "secret to sign the delivery with (azure, gerrit, jenkins, quay: username:password, bitbucket: hook UUID, sourcehut: Ed25519 private key, drone: HMAC secret, gitee: WebHook password, gitee-signed: signing key)")
+	url := fs.String("url", "http://localhost:3000/webhooks", "receiver URL")
+	testdata := fs.String("testdata", "", "root of a wh checkout to read the provider fixtures from (default: the fixtures built into wh)")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: wh send -provider <provider> -event <event> [flags] [payload.json]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Without a payload file, the provider fixture of the event is sent.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	p, err := lookupProvider(*providerName)
+	if err != nil {
+		return err
+	}
+
+	if *event == "" {
+		fs.Usage()
+		return errors.New("missing -event")
+	}
+
+	var body []byte
+	switch fs.NArg() {
+	case 0:
+		body, err = readFixture(p, *event, *testdata)
+	case 1:
+		body, err = os.ReadFile(fs.Arg(0))
+	default:
+		return errors.New("too many arguments")
+	}
+	if err != nil {
+		return err
+	}
+
+	target, err := p.target(*url, *event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if req.Header, err = p.headers(target, *event, body, *secret); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	fmt.Fprintf(stdout, "%s %s %s -> %s\n", p.name, *event, target, resp.Status)
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if len(bytes.TrimSpace(respBody)) > 0 {
+		fmt.Fprintf(stdout, "%s\n", bytes.TrimSpace(respBody))
+	}
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return nil
+}
+
+// readFixture reads the testdata fixture of the provider event,
+// from the checkout of the module at root if not empty, built into wh otherwise.
+func readFixture(p provider, event, root string) ([]byte, error) {
+	name, ok := p.fixtures[event]
+	if !ok {
+		return nil, fmt.Errorf("no %s fixture for event %q, expected one of: %s", p.name, event, strings.Join(p.events(), ", "))
+	}
+
+	if root != "" {
+		return os.ReadFile(filepath.Join(root, p.dir, "testdata", name))
+	}
+	return wh.Fixtures.ReadFile(path.Join(p.dir, "testdata", name))
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pchchv/wh/dispatch"
+	"github.com/pchchv/wh/internal/observe"
+)
+
+// secrets maps provider names to their secret, the empty name holds the secret used for every other provider.
+type secrets map[string]string
+
+func (s secrets) String() string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name+"=***")
+	}
+	return strings.Join(names, ",")
+}
+
+func (s secrets) Set(value string) error {
+	if name, secret, ok := strings.Cut(value, "="); ok {
+		if _, err := lookupProvider(name); err == nil {
+			s[name] = secret
+			return nil
+		}
+	}
+	s[""] = value
+	return nil
+}
+
+func (s secrets) get(provider string) (string, bool) {
+	if secret, ok := s[provider]; ok {
+		return secret, true
+	}
+	secret, ok := s[""]
+	return secret, ok
+}
+
+// receiver verifies, describes and records every delivery it receives.
+type receiver struct {
+	secrets secrets
+	dir     string
+	out     io.Writer
+	now     func() time.Time
+	mu      sync.Mutex
+	seq     int
+}
+
+func runServe(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "localhost:3000", "address to listen on")
+	dir := fs.String("dir", "deliveries", "directory to save raw deliveries to, empty to disable")
+	s := secrets{}
+	fs.Var(s, "secret", "secret to verify deliveries with, as provider=secret or a single secret for every provider (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: wh serve [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Receives deliveries of any provider on every path, prints them and saves their raw body and headers.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           &receiver{secrets: s, dir: *dir, out: stdout, now: time.Now},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(stdout, "listening on http://%s\n", *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading payload", http.StatusBadRequest)
+		return
+	}
+
+	p, event, err := detectProvider(r.RequestURI, r.Header, body)
+	if err != nil {
+		fmt.Fprintf(rc.out, "%s %s %s: %v\n", rc.now().Format(time.RFC3339), r.Method, r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved := ""
+	if rc.dir != "" {
+		if saved, err = rc.save(p, event, r, body); err != nil {
+			fmt.Fprintf(rc.out, "error saving delivery: %v\n", err)
+		}
+	}
+
+	secret, hasSecret := rc.secrets.get(p.name)
+	payload, parseErr := p.parse(requestWithBody(r, body), event, secret)
+	verification := "skipped (no secret)"
+	switch {
+	case !p.authenticated:
+		verification = "unsupported (provider has no authentication)"
+	case parseErr != nil && hasSecret:
+		// tell verification failures apart from decoding failures
+		if _, err := p.parse(requestWithBody(r, body), event, ""); err == nil {
+			verification = "failed"
+		} else {
+			verification = "unknown"
+		}
+	case parseErr != nil:
+		verification = "unknown"
+	case hasSecret:
+		verification = "ok"
+	}
+
+	fmt.Fprintf(rc.out, "%s %s event=%q", rc.now().Format(time.RFC3339), p.name, event)
+	if payload != nil {
+		fmt.Fprintf(rc.out, " type=%T", payload)
+		if action := observe.Action(payload); action != "" {
+			fmt.Fprintf(rc.out, " action=%q", action)
+		}
+		if repo := dispatch.RepositoryKey(payload); repo != "" {
+			fmt.Fprintf(rc.out, " repository=%q", repo)
+		}
+	}
+	fmt.Fprintf(rc.out, " verification=%s", verification)
+	if parseErr != nil {
+		fmt.Fprintf(rc.out, " error=%q", parseErr.Error())
+	}
+	fmt.Fprintln(rc.out)
+	if saved != "" {
+		fmt.Fprintf(rc.out, "  saved %s (replay: wh send -provider %s -event %q %s)\n", saved, p.name, event, saved)
+	}
+
+	if parseErr != nil {
+		http.Error(w, parseErr.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// save writes the raw body to <dir>/<provider>/<time>-<event>.json, a fixture wh send and the package tests can read,
+// and the request line and headers next to it in <name>.headers using the HTTP wire format.
+func (rc *receiver) save(p provider, event string, r *http.Request, body []byte) (string, error) {
+	dir := filepath.Join(rc.dir, p.name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	rc.mu.Lock()
+	rc.seq++
+	seq := rc.seq
+	rc.mu.Unlock()
+
+	name := fmt.Sprintf("%s-%03d-%s", rc.now().UTC().Format("20060102T150405"), seq, fileSafe(event))
+	path := filepath.Join(dir, name+".json")
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return "", err
+	}
+
+	var headers bytes.Buffer
+	fmt.Fprintf(&headers, "%s %s %s\r\n", r.Method, r.RequestURI, r.Proto)
+	if err := r.Header.Write(&headers); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(filepath.Join(dir, name+".headers"), headers.Bytes(), 0o644)
+}
+
+func requestWithBody(r *http.Request, body []byte) *http.Request {
+	r2 := r.Clone(r.Context())
+	r2.Body = io.NopCloser(bytes.NewReader(body))
+	return r2
+}
+
+func fileSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_':
+			return r
+		default:
+			return '-'
+		}
+	}, s)
+}
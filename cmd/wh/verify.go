@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pchchv/wh/drone"
+	"github.com/pchchv/wh/gitee"
+)
+
+// verify checks the signature header of a delivery to the request target and explains why it does not match.
+func (s signature) verify(h http.Header, target string, body []byte, secret string, bodies []variant) (bool, string) {
+	values := h.Values(s.header)
+	if len(values) == 0 {
+		return false, fmt.Sprintf("%s header is missing: the webhook has no secret configured or a proxy dropped the header", s.header)
+	}
+
+	var note string
+	if len(values) > 1 {
+		note = fmt.Sprintf(" (the header was sent %d times, only the first value is used)", len(values))
+	}
+
+	got := values[0]
+	switch s.scheme {
+	case hmacSHA1, hmacSHA256:
+		ok, why := s.verifyHMAC(got, body, secret, bodies)
+		return ok, why + note
+	case basicAuth:
+		ok, why := verifyBasicAuth(got, secret)
+		return ok, why + note
+	case ed25519Sig:
+		ok, why := verifyEd25519(h, got, body, secret, bodies)
+		return ok, why + note
+	case digest:
+		ok, why := s.verifyDigest(got, body, bodies)
+		return ok, why + note
+	case httpSig:
+		ok, why := verifyHTTPSignature(h, target, got, secret)
+		return ok, why + note
+	case giteeSig:
+		ok, why := verifyGiteeSignature(h, got, secret)
+		return ok, why + note
+	default:
+		switch {
+		case got == s.value(secret, body):
+			return true, "token matches the secret" + note
+		case strings.TrimSpace(got) == strings.TrimSpace(s.value(secret, body)):
+			return false, "token only differs from the secret by surrounding whitespace" + note
+		default:
+			return false, fmt.Sprintf("token does not match the secret (got %d characters, want %d)", len(got), len(s.value(secret, body))) + note
+		}
+	}
+}
+
+func (s signature) verifyHMAC(got string, body []byte, secret string, bodies []variant) (bool, string) {
+	want := s.value(secret, body)
+	if got == want {
+		return true, "signature matches"
+	}
+
+	if strings.EqualFold(got, want) {
+		return false, "signature only differs by letter case, signatures are compared as lower-case hex"
+	}
+
+	sig := strings.TrimPrefix(got, s.prefix)
+	switch {
+	case s.prefix != "" && !strings.HasPrefix(got, s.prefix):
+		if i := strings.Index(got, "="); i > 0 && i < 10 {
+			return false, fmt.Sprintf("signature has the %q prefix, want %q", got[:i+1], s.prefix)
+		}
+		if sig == strings.TrimPrefix(want, s.prefix) {
+			return false, fmt.Sprintf("signature matches but lacks the %q prefix", s.prefix)
+		}
+		return false, fmt.Sprintf("signature lacks the %q prefix and does not match", s.prefix)
+	case s.prefix == "" && strings.Contains(got, "="):
+		prefix, rest, _ := strings.Cut(got, "=")
+		if rest == want {
+			return false, fmt.Sprintf("signature matches but has an unexpected %q prefix", prefix+"=")
+		}
+	}
+
+	if _, err := hex.DecodeString(sig); err != nil {
+		return false, "signature is not hex encoded"
+	}
+
+	for _, alg := range []struct {
+		name string
+		fn   func() hash.Hash
+	}{{hmacSHA1, sha1.New}, {hmacSHA256, sha256.New}, {"hmac-sha512", sha512.New}} {
+		if alg.name != s.scheme && sig == hexMAC(alg.fn, secret, body) {
+			return false, fmt.Sprintf("signature is a valid %s of the body, the provider signs with %s", alg.name, s.scheme)
+		}
+	}
+
+	mac := func(secret string, body []byte) string {
+		return strings.TrimPrefix(s.value(secret, body), s.prefix)
+	}
+
+	for _, v := range bodies {
+		if sig == mac(secret, v.body) {
+			return false, fmt.Sprintf("signature matches the body %s: the body was changed after it was signed, e.g. by a proxy, a middleware or the capture", v.desc)
+		}
+	}
+
+	if trimmed := strings.TrimSpace(secret); trimmed != secret && sig == mac(trimmed, body) {
+		return false, "signature matches the secret without its surrounding whitespace"
+	}
+
+	if sig == mac(secret+"\n", body) {
+		return false, "signature matches the secret followed by a newline, the secret configured at the provider ends with a newline"
+	}
+
+	if len(sig) != len(mac(secret, body)) {
+		return false, fmt.Sprintf("signature has %d hex characters, a %s has %d", len(sig), s.scheme, len(mac(secret, body)))
+	}
+
+	return false, "signature does not match: the secret differs or the body was changed beyond whitespace and encoding"
+}
+
+func verifyBasicAuth(got, secret string) (bool, string) {
+	scheme, credentials, _ := strings.Cut(got, " ")
+	if !strings.EqualFold(scheme, "Basic") {
+		return false, fmt.Sprintf("Authorization header uses the %q scheme, want Basic", scheme)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return false, "Authorization header credentials are not base64 encoded"
+	}
+
+	username, password, _ := strings.Cut(string(decoded), ":")
+	wantUsername, wantPassword, _ := strings.Cut(secret, ":")
+	switch {
+	case username != wantUsername:
+		return false, fmt.Sprintf("username %q does not match %q", username, wantUsername)
+	case password != wantPassword:
+		return false, fmt.Sprintf("password does not match (got %d characters, want %d)", len(password), len(wantPassword))
+	default:
+		return true, "username and password match"
+	}
+}
+
+// verifyEd25519 checks a SourceHut signature, of the body followed by the X-Payload-Nonce header.
+func verifyEd25519(h http.Header, got string, body []byte, secret string, bodies []variant) (bool, string) {
+	key, err := ed25519PublicKey(secret)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	nonce := h.Get("X-Payload-Nonce")
+	if nonce == "" {
+		return false, "X-Payload-Nonce header is missing, the signature covers the body followed by the nonce"
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(got)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false, "signature is not a base64 encoded Ed25519 signature"
+	}
+
+	signed := func(body []byte) []byte {
+		return append(bytes.Clone(body), nonce...)
+	}
+	if ed25519.Verify(key, signed(body), sig) {
+		return true, "signature matches"
+	}
+
+	for _, v := range bodies {
+		if ed25519.Verify(key, signed(v.body), sig) {
+			return false, fmt.Sprintf("signature matches the body %s: the body was changed after it was signed, e.g. by a proxy, a middleware or the capture", v.desc)
+		}
+	}
+	return false, "signature does not match: the key differs, or the body or the nonce was changed"
+}
+
+func (s signature) verifyDigest(got string, body []byte, bodies []variant) (bool, string) {
+	if got == s.value("", body) {
+		return true, "digest matches the body"
+	}
+
+	for _, v := range bodies {
+		if got == s.value("", v.body) {
+			return false, fmt.Sprintf("digest matches the body %s: the body was changed after it was signed, e.g. by a proxy, a middleware or the capture", v.desc)
+		}
+	}
+
+	if !strings.HasPrefix(got, s.prefix) {
+		return false, fmt.Sprintf("digest lacks the %q prefix", s.prefix)
+	}
+	return false, "digest does not match: the body was changed beyond whitespace and encoding"
+}
+
+// verifyHTTPSignature checks a Drone HTTP signature, an HMAC-SHA256 of the request target and the headers it names.
+func verifyHTTPSignature(h http.Header, target, got, secret string) (bool, string) {
+	params := map[string]string{}
+	for rest := strings.TrimSpace(got); rest != ""; {
+		name, value, ok := strings.Cut(rest, `="`)
+		if !ok {
+			return false, "signature is not a list of name=\"value\" parameters"
+		}
+		if value, rest, ok = strings.Cut(value, `"`); !ok {
+			return false, "signature is not a list of name=\"value\" parameters"
+		}
+		params[strings.TrimSpace(name)] = value
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+	}
+
+	if !strings.EqualFold(params["algorithm"], "hmac-sha256") {
+		return false, fmt.Sprintf("signature uses the %q algorithm, the drone package verifies hmac-sha256", params["algorithm"])
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		if name == "(request-target)" {
+			lines = append(lines, name+": post "+target)
+			continue
+		}
+		if len(h.Values(name)) == 0 {
+			return false, fmt.Sprintf("signed %s header is missing", http.CanonicalHeaderKey(name))
+		}
+		lines = append(lines, name+": "+strings.Join(h.Values(name), ", "))
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(strings.Join(lines, "\n")))
+	if params["signature"] != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		return false, fmt.Sprintf("signature does not match: the secret differs, or the request target %s or a signed header was changed, e.g. by a proxy", target)
+	}
+
+	for _, name := range []string{"digest", "date"} {
+		if !slices.Contains(headers, name) {
+			return false, fmt.Sprintf("signature matches but does not cover the %s header, which the drone package requires", http.CanonicalHeaderKey(name))
+		}
+	}
+
+	date, err := http.ParseTime(h.Get("Date"))
+	if err != nil {
+		return false, "signature matches but the Date header is not an HTTP date"
+	}
+	if age := time.Since(date); age > drone.DefaultTolerance || age < -drone.DefaultTolerance {
+		return false, fmt.Sprintf("signature matches but the Date is %s from now, beyond the %s tolerance of the drone package", age.Round(time.Second), drone.DefaultTolerance)
+	}
+	return true, "signature matches"
+}
+
+// verifyGiteeSignature checks the X-Gitee-Token of a WebHook with a signing key,
+// the HMAC-SHA256 of the X-Gitee-Timestamp and the key, and the freshness of the timestamp.
+func verifyGiteeSignature(h http.Header, got, secret string) (bool, string) {
+	timestamp := h.Get("X-Gitee-Timestamp")
+	if timestamp == "" {
+		return false, "X-Gitee-Timestamp header is missing, the signature covers the timestamp and the signing key"
+	}
+
+	if got == secret {
+		return false, "token is the secret itself: the WebHook uses a password, send it as the gitee provider"
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = io.WriteString(mac, timestamp+"\n"+secret)
+	if !hmac.Equal([]byte(got), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))) {
+		return false, "signature does not match: the signing key differs or the X-Gitee-Timestamp was changed"
+	}
+
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false, "signature matches but the X-Gitee-Timestamp is not in milliseconds"
+	}
+	if age := time.Since(time.UnixMilli(ms)); age > gitee.DefaultTolerance || age < -gitee.DefaultTolerance {
+		return false, fmt.Sprintf("signature matches but the X-Gitee-Timestamp is %s from now, beyond the %s tolerance of the gitee package", age.Round(time.Second), gitee.DefaultTolerance)
+	}
+	return true, "signature matches"
+}
+
+// variant is a body a proxy, a middleware or a capture tool may have turned the signed body into.
+type variant struct {
+	desc string
+	body []byte
+}
+
+// bodyVariants returns the bodies the signed body may have been before it was changed into body.
+func bodyVariants(body []byte) []variant {
+	var variants []variant
+	add := func(desc string, b []byte) {
+		if !bytes.Equal(b, body) {
+			variants = append(variants, variant{desc: desc, body: b})
+		}
+	}
+
+	add("without its trailing newline", bytes.TrimRight(body, "\r\n"))
+	add("with a trailing newline", append(bytes.Clone(body), '\n'))
+	add("without surrounding whitespace", bytes.TrimSpace(body))
+	add("with CRLF line endings replaced by LF", bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n")))
+	add("with LF line endings replaced by CRLF", bytes.ReplaceAll(bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n")))
+
+	// JSON encoders often end their output with a newline
+	addJSON := func(desc string, b []byte) {
+		add(desc, b)
+		add(desc+" followed by a newline", append(bytes.Clone(b), '\n'))
+	}
+
+	var compact bytes.Buffer
+	if json.Compact(&compact, body) == nil {
+		addJSON("as compact JSON", compact.Bytes())
+	}
+
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") == nil {
+		addJSON("as indented JSON", indented.Bytes())
+	}
+
+	var v interface{}
+	if json.Unmarshal(body, &v) == nil {
+		if b, err := json.Marshal(v); err == nil {
+			addJSON("decoded and encoded again as JSON (keys sorted, HTML characters escaped)", b)
+		}
+	}
+	return variants
+}
+
+func runVerify(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	providerName := fs.String("provider", "", "webhook provider (default: detected from the delivery)")
+	secret := fs.String("secret", "", "secret the receiver verifies deliveries with (azure, gerrit, jenkins, quay: username:password, bitbucket: hook UUID, sourcehut: Ed25519 public key, codecommit: SNS topic ARN, drone: HMAC secret)")
+	headersFile := fs.String("headers", "", "file holding the request headers, one \"Name: value\" per line, e.g. saved by wh serve")
+	bodyFile := fs.String("body", "", "file holding the exact request body, used with -headers")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: wh verify -secret <secret> [flags] <request.http>")
+		fmt.Fprintln(stderr, "       wh verify -secret <secret> [flags] -headers <file> -body <file>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Checks the signatures of a captured delivery and explains why they do not match.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var header http.Header
+	var body []byte
+	var notes []string
+	target := "/"
+	var err error
+	switch {
+	case *headersFile != "" || *bodyFile != "":
+		if *headersFile == "" || *bodyFile == "" || fs.NArg() > 0 {
+			fs.Usage()
+			return errors.New("-headers and -body go together and replace the request file")
+		}
+		if header, target, err = readHeaders(*headersFile); err != nil {
+			return err
+		}
+		if body, err = os.ReadFile(*bodyFile); err != nil {
+			return err
+		}
+	case fs.NArg() == 1:
+		if header, target, body, notes, err = readRequest(fs.Arg(0)); err != nil {
+			return err
+		}
+	default:
+		fs.Usage()
+		return errors.New("missing the captured request")
+	}
+
+	var p provider
+	var event string
+	if *providerName != "" {
+		if p, err = lookupProvider(*providerName); err != nil {
+			return err
+		}
+		event = p.event(target, header, body)
+	} else if p, event, err = detectProvider(target, header, body); err != nil {
+		return fmt.Errorf("%w, pass -provider", err)
+	}
+
+	if *secret == "" && len(p.signatures) > 0 {
+		fs.Usage()
+		return errors.New("missing -secret")
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "provider\t%s\n", p.name)
+	fmt.Fprintf(w, "event\t%q\n", event)
+	fmt.Fprintf(w, "body\t%d bytes, sha256 %x\n", len(body), sha256.Sum256(body))
+	for _, note := range notes {
+		fmt.Fprintf(w, "note\t%s\n", note)
+	}
+
+	failed := false
+	bodies := bodyVariants(body)
+	switch {
+	case len(p.signatures) > 0:
+	case p.note != "":
+		fmt.Fprintf(w, "signature\t%s\n", p.note)
+	case !p.authenticated:
+		fmt.Fprintf(w, "signature\t%s does not sign its deliveries, nothing to verify\n", p.name)
+	}
+
+	for _, s := range p.signatures {
+		ok, why := s.verify(header, target, body, *secret, bodies)
+		status := "ok"
+		switch {
+		case !ok && s.checked:
+			status = "FAIL"
+			failed = true
+		case !ok:
+			status = "fail"
+			why += fmt.Sprintf(" (not verified by the %s package)", p.name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.header, s.scheme, status, why)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header = header
+	if _, err := p.parse(r, event, *secret); err != nil {
+		fmt.Fprintf(w, "parse\t%v\n", err)
+		failed = true
+	} else {
+		fmt.Fprintf(w, "parse\tok\n")
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed {
+		return errors.New("verification failed")
+	}
+	return nil
+}
+
+// readRequest reads a raw HTTP/1.x request as captured on the wire, e.g. with tcpdump, netcat or a proxy,
+// returning its headers, request target and body.
+func readRequest(path string) (http.Header, string, []byte, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+
+	br := bufio.NewReader(bytes.NewReader(data))
+	r, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, "", nil, nil, fmt.Errorf("read request %s: %w", path, err)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, "", nil, nil, fmt.Errorf("read request body %s: %w", path, err)
+	}
+
+	var notes []string
+	if r.TransferEncoding != nil {
+		notes = append(notes, fmt.Sprintf("the body was sent with %s transfer encoding and is verified decoded", strings.Join(r.TransferEncoding, ", ")))
+	}
+
+	if trailing, _ := io.ReadAll(br); len(bytes.TrimRight(trailing, "\r\n")) > 0 {
+		notes = append(notes, fmt.Sprintf("%d bytes follow the body declared by the request and are ignored, check Content-Length", len(trailing)))
+	}
+
+	if r.Header.Get("Content-Encoding") != "" {
+		notes = append(notes, fmt.Sprintf("the body is %s encoded, providers sign the decoded body", r.Header.Get("Content-Encoding")))
+	}
+	return r.Header, r.RequestURI, body, notes, nil
+}
+
+// readHeaders reads "Name: value" header lines and the target of a leading request line, "/" without one.
+func readHeaders(path string) (http.Header, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	target := "/"
+	if line, rest, ok := bytes.Cut(data, []byte("\n")); ok && bytes.Contains(line, []byte(" HTTP/")) {
+		if fields := strings.Fields(string(line)); len(fields) == 3 {
+			target = fields[1]
+		}
+		data = rest
+	}
+
+	tp := textproto.NewReader(bufio.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader("\r\n\r\n"))))
+	h, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, "", fmt.Errorf("read headers %s: %w", path, err)
+	}
+	return http.Header(h), target, nil
+}
@@ -0,0 +1,99 @@
+package codecommit
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// snsHost matches the hosts of the SNS endpoints, including the regions of China.
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// CertificateFunc returns the certificate a message was signed with by its SigningCertURL.
+type CertificateFunc func(ctx context.Context, url string) (*x509.Certificate, error)
+
+// certificateCache downloads the certificates of SNS once.
+type certificateCache struct {
+	client *http.Client
+	mu     sync.Mutex
+	certs  map[string]*x509.Certificate
+}
+
+func newCertificateCache(client *http.Client) *certificateCache {
+	return &certificateCache{client: client, certs: map[string]*x509.Certificate{}}
+}
+
+func (c *certificateCache) get(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	if err := checkSNSURL(certURL); err != nil {
+		return nil, fmt.Errorf("SigningCertURL %w", err)
+	}
+
+	if !strings.HasSuffix(certURL, ".pem") {
+		return nil, errors.New("SigningCertURL is not a PEM certificate")
+	}
+
+	c.mu.Lock()
+	cert, ok := c.certs[certURL]
+	c.mu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", certURL, resp.Status)
+	}
+
+	// certificates are a few kilobytes
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s is not a PEM certificate", certURL)
+	}
+
+	if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.certs[certURL] = cert
+	c.mu.Unlock()
+	return cert, nil
+}
+
+// checkSNSURL checks that u is an HTTPS URL of SNS, so messages cannot make the receiver request other hosts.
+func checkSNSURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("is invalid: %w", err)
+	}
+
+	if parsed.Scheme != "https" || parsed.User != nil || parsed.Port() != "" || !snsHost.MatchString(parsed.Hostname()) {
+		return fmt.Errorf("%q is not an HTTPS URL of SNS", u)
+	}
+	return nil
+}
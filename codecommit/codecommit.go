@@ -0,0 +1,350 @@
+// The `codecommit` package accepts the AWS CodeCommit triggers delivered by SNS HTTP and HTTPS subscriptions.
+//
+// SNS posts a JSON message signed with the key of an AWS certificate and the x-amz-sns-message-type header.
+// A subscription is confirmed by fetching the SubscribeURL of its SubscriptionConfirmation message,
+// see SubscriptionConfirmationPayload.Confirm. Notifications wrap the records of a CodeCommit trigger in their Message.
+//
+// Signatures are only verified with one of the Certificate, FetchCertificates or CertificateFunc options:
+// without them unsigned messages are accepted, TopicARNs alone only checks the topic named by the message.
+// The Timestamp of a verified message must be within the tolerance of the current time, against replays.
+package codecommit
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+const (
+	// SNS message types.
+	SubscriptionConfirmationEvent Event = "SubscriptionConfirmation"
+	NotificationEvent             Event = "Notification"
+	UnsubscribeConfirmationEvent  Event = "UnsubscribeConfirmation"
+)
+
+// DefaultTolerance is how far the Timestamp of a signed message may be from the current time.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = WebhookOptions{}
+	// Parse errors.
+	ErrParsingPayload              = errors.New("error parsing payload")
+	ErrSignatureVerificationFailed = errors.New("SNS signature verification failed")
+	ErrTopicNotAllowed             = errors.New("SNS topic not allowed")
+	// ErrTimestampExpired is returned when the Timestamp of a signed message
+	// is further from the current time than the tolerance, e.g. of a replayed message.
+	ErrTimestampExpired = errors.New("SNS Timestamp out of tolerance")
+)
+
+// Event defines an SNS message type by the x-amz-sns-message-type Header.
+type Event string
+
+// provider describes the deliveries of SNS to the instrumentation.
+var provider = &observe.Provider{
+	Name:           "codecommit",
+	EventHeader:    "X-Amz-Sns-Message-Type",
+	DeliveryHeader: "X-Amz-Sns-Message-Id",
+}
+
+// Webhook instance contains all methods needed to process events.
+type Webhook struct {
+	certificate CertificateFunc
+	topics      map[string]bool
+	tolerance   time.Duration
+	now         func() time.Time
+	observer    observe.Observer
+}
+
+// Parse verifies and parses the events specified and returns the payload object or an error.
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
+	defer func() {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}()
+
+	if len(events) == 0 {
+		return nil, errors.New("no Event specified to parse")
+	}
+
+	if r.Method != http.MethodPost {
+		return nil, errors.New("invalid HTTP Method")
+	}
+
+	event := r.Header.Get("X-Amz-Sns-Message-Type")
+	if len(event) == 0 {
+		return nil, errors.New("missing x-amz-sns-message-type Header")
+	}
+
+	var found bool
+	snsEvent := Event(event)
+	for _, evt := range events {
+		if evt == snsEvent {
+			found = true
+			break
+		}
+	}
+
+	// event not defined to be parsed
+	if !found {
+		return nil, errors.New("event not defined to be parsed")
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil || len(payload) == 0 {
+		return nil, ErrParsingPayload
+	}
+
+	// the signature is part of the message, which is decoded to be verified
+	var msg Message
+	if err = json.Unmarshal(payload, &msg); err != nil {
+		return nil, ErrParsingPayload
+	}
+
+	if Event(msg.Type) != snsEvent {
+		return nil, fmt.Errorf("message type %q does not match the x-amz-sns-message-type Header", msg.Type)
+	}
+
+	err = d.Verify(hook.certificate != nil || len(hook.topics) > 0, func() error {
+		if len(hook.topics) > 0 && !hook.topics[msg.TopicARN] {
+			return ErrTopicNotAllowed
+		}
+
+		if hook.certificate != nil {
+			if err := hook.verifySignature(r, msg); err != nil {
+				return err
+			}
+			return hook.verifyTimestamp(msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.Decoding()
+	switch snsEvent {
+	case SubscriptionConfirmationEvent:
+		return SubscriptionConfirmationPayload(msg), nil
+	case UnsubscribeConfirmationEvent:
+		return UnsubscribeConfirmationPayload(msg), nil
+	case NotificationEvent:
+		var pl NotificationPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	default:
+		return nil, fmt.Errorf("unknown event %s", snsEvent)
+	}
+}
+
+// verifySignature checks the signature of the message with the certificate of its SigningCertURL.
+func (hook Webhook) verifySignature(r *http.Request, msg Message) error {
+	var hash crypto.Hash
+	switch msg.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("%w: unsupported SignatureVersion %q", ErrSignatureVerificationFailed, msg.SignatureVersion)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: invalid Signature", ErrSignatureVerificationFailed)
+	}
+
+	cert, err := hook.certificate(r.Context(), msg.SigningCertURL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSignatureVerificationFailed, err)
+	}
+
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: the signing certificate has no RSA key", ErrSignatureVerificationFailed)
+	}
+
+	if err = rsa.VerifyPKCS1v15(key, hash, digest(hash, msg.StringToSign()), signature); err != nil {
+		return ErrSignatureVerificationFailed
+	}
+	return nil
+}
+
+// verifyTimestamp checks the signed Timestamp of the message is within the tolerance of the current time.
+func (hook Webhook) verifyTimestamp(msg Message) error {
+	published, err := msg.Time()
+	if err != nil {
+		return fmt.Errorf("%w: invalid Timestamp", ErrTimestampExpired)
+	}
+
+	age := hook.now().Sub(published)
+	if age > hook.tolerance || age < -hook.tolerance {
+		return ErrTimestampExpired
+	}
+	return nil
+}
+
+func digest(hash crypto.Hash, s string) []byte {
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(s))
+		return sum[:]
+	}
+	sum := sha256.Sum256([]byte(s))
+	return sum[:]
+}
+
+// Option is a configuration option for the webhook.
+type Option func(*Webhook) error
+
+// New creates and returns a WebHook instance.
+func New(options ...Option) (*Webhook, error) {
+	hook := &Webhook{tolerance: DefaultTolerance, now: time.Now}
+	for _, opt := range options {
+		if err := opt(hook); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+	return hook, nil
+}
+
+// WebhookOptions is a namespace for configuration option methods.
+type WebhookOptions struct{}
+
+// Certificate verifies every message with the PEM encoded certificate, whatever its SigningCertURL,
+// e.g. the certificate of the region downloaded ahead of time or a test certificate.
+func (WebhookOptions) Certificate(certPEM []byte) Option {
+	return func(hook *Webhook) error {
+		block, _ := pem.Decode(certPEM)
+		if block == nil || block.Type != "CERTIFICATE" {
+			return errors.New("invalid PEM certificate")
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+
+		hook.certificate = func(ctx context.Context, url string) (*x509.Certificate, error) {
+			return cert, nil
+		}
+		return nil
+	}
+}
+
+// FetchCertificates verifies messages with the certificate downloaded from their SigningCertURL with client,
+// or a client with a 10 seconds timeout if nil. Only certificates of SNS, served over HTTPS from
+// sns.<region>.amazonaws.com, are downloaded and they are cached by URL.
+func (WebhookOptions) FetchCertificates(client *http.Client) Option {
+	return func(hook *Webhook) error {
+		if client == nil {
+			client = &http.Client{Timeout: 10 * time.Second}
+		}
+		hook.certificate = newCertificateCache(client).get
+		return nil
+	}
+}
+
+// CertificateFunc verifies messages with the certificate returned for their SigningCertURL.
+func (WebhookOptions) CertificateFunc(fn CertificateFunc) Option {
+	return func(hook *Webhook) error {
+		if fn == nil {
+			return errors.New("certificate func must not be nil")
+		}
+		hook.certificate = fn
+		return nil
+	}
+}
+
+// TopicARNs only accepts the messages of the topics, e.g. "arn:aws:sns:us-east-1:123456789012:MyDemoTopic".
+// A signature only proves a message was sent by SNS, for any topic of any AWS account.
+func (WebhookOptions) TopicARNs(arns ...string) Option {
+	return func(hook *Webhook) error {
+		if hook.topics == nil {
+			hook.topics = make(map[string]bool, len(arns))
+		}
+		for _, arn := range arns {
+			if !strings.HasPrefix(arn, "arn:") {
+				return fmt.Errorf("invalid topic ARN %q", arn)
+			}
+			hook.topics[arn] = true
+		}
+		return nil
+	}
+}
+
+// Tolerance sets how far the Timestamp of a signed message may be from the current time, DefaultTolerance by default.
+// SNS keeps the Timestamp of the publication when it retries a delivery,
+// so the tolerance must exceed the duration of the delivery policy of the subscription for retries to be accepted.
+func (WebhookOptions) Tolerance(tolerance time.Duration) Option {
+	return func(hook *Webhook) error {
+		if tolerance <= 0 {
+			return errors.New("tolerance must be positive")
+		}
+		hook.tolerance = tolerance
+		return nil
+	}
+}
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
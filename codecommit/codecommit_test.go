@@ -0,0 +1,549 @@
+package codecommit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	path  = "/webhooks"
+	topic = "arn:aws:sns:us-east-1:123456789012:MyDemoTopic"
+)
+
+var (
+	hook *Webhook
+	// key signs the test messages in place of the key of the SNS certificate
+	key     *rsa.PrivateKey
+	certPEM []byte
+)
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	if key, certPEM, err = newCertificate(); err != nil {
+		log.Fatal(err)
+	}
+
+	hook, err = New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func newCertificate() (*rsa.PrivateKey, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// signed returns the message of the fixture signed with key, changed by change if not nil.
+func signed(t *testing.T, filename string, version string, change func(*Message)) []byte {
+	t.Helper()
+	var msg Message
+	require.NoError(t, json.Unmarshal(whtest.Fixture(t, filename), &msg))
+	msg.SignatureVersion = version
+
+	var sig []byte
+	var err error
+	if version == "1" {
+		sum := sha1.Sum([]byte(msg.StringToSign()))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, sum[:])
+	} else {
+		sum := sha256.Sum256([]byte(msg.StringToSign()))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	}
+	require.NoError(t, err)
+	msg.Signature = base64.StdEncoding.EncodeToString(sig)
+
+	if change != nil {
+		change(&msg)
+	}
+
+	body, err := json.Marshal(msg)
+	require.NoError(t, err)
+	return body
+}
+
+func TestWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+	}{
+		{
+			name:     "SubscriptionConfirmationEvent",
+			event:    SubscriptionConfirmationEvent,
+			typ:      SubscriptionConfirmationPayload{},
+			filename: "./testdata/subscription-confirmation.json",
+		},
+		{
+			name:     "NotificationEvent",
+			event:    NotificationEvent,
+			typ:      NotificationPayload{},
+			filename: "./testdata/notification.json",
+		},
+		{
+			name:     "UnsubscribeConfirmationEvent",
+			event:    UnsubscribeConfirmationEvent,
+			typ:      UnsubscribeConfirmationPayload{},
+			filename: "./testdata/unsubscribe-confirmation.json",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, bytes.NewReader(whtest.Fixture(t, tc.filename)))
+			assert.NoError(err)
+			req.Header.Set("Content-Type", "text/plain; charset=UTF-8")
+			req.Header.Set("x-amz-sns-message-type", string(tc.event))
+			req.Header.Set("x-amz-sns-topic-arn", topic)
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	pl, err := hook.Parse(whtest.CodeCommit(NotificationEvent, whtest.Fixture(t, "./testdata/notification.json")).Request(), NotificationEvent)
+	assert.NoError(err)
+	notification := pl.(NotificationPayload)
+	assert.Equal(topic, notification.SNS.TopicARN)
+	assert.Equal("MyFirstTrigger", notification.SNS.Subject)
+	published, err := notification.SNS.Time()
+	assert.NoError(err)
+	assert.Equal(time.Date(2024, 6, 3, 8, 50, 12, 12000000, time.UTC), published)
+
+	assert.Len(notification.Records, 1)
+	record := notification.Records[0]
+	assert.Equal("ReferenceChanges", record.EventName)
+	assert.Equal("MyDemoRepo", record.RepositoryName())
+	assert.Equal([]Reference{
+		{Commit: "5c4ef1049f1d27deadbeeff3d6b3b7b1e8a2c5d3", Ref: "refs/heads/main"},
+		{Commit: "317f8570fe2f1c1b2f8e3e8a2d9b4c5a6e7f8091", Ref: "refs/heads/feature/login", Created: true},
+		{Commit: "0000000000000000000000000000000000000000", Ref: "refs/tags/v0.9.0", Deleted: true},
+	}, record.CodeCommit.References)
+
+	pl, err = hook.Parse(whtest.CodeCommit(SubscriptionConfirmationEvent, whtest.Fixture(t, "./testdata/subscription-confirmation.json")).Request(), SubscriptionConfirmationEvent)
+	assert.NoError(err)
+	confirmation := pl.(SubscriptionConfirmationPayload)
+	assert.True(strings.HasSuffix(confirmation.SubscribeURL, "&Token="+confirmation.Token))
+}
+
+func TestBadRequests(t *testing.T) {
+	notification := whtest.Fixture(t, "./testdata/notification.json")
+	tests := []struct {
+		name string
+		r    *http.Request
+	}{
+		{name: "BadNoEventHeader", r: whtest.CodeCommit("", notification).Request()},
+		{name: "UnsubscribedEvent", r: whtest.CodeCommit(UnsubscribeConfirmationEvent, notification).Request()},
+		{name: "TypeMismatch", r: whtest.CodeCommit(NotificationEvent, whtest.Fixture(t, "./testdata/subscription-confirmation.json")).Request()},
+		{name: "BadMethod", r: whtest.CodeCommit(NotificationEvent, notification).Method(http.MethodGet).Request()},
+		{name: "BadBody", r: whtest.CodeCommit(NotificationEvent, "").Request()},
+		{name: "BadJSON", r: whtest.CodeCommit(NotificationEvent, "{").Request()},
+		{name: "NotCodeCommit", r: whtest.CodeCommit(NotificationEvent, Message{Type: "Notification", Message: "Hello from SNS"}).Request()},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := hook.Parse(tc.r, NotificationEvent, SubscriptionConfirmationEvent)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSignature(t *testing.T) {
+	_, otherPEM, err := newCertificate()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		options []Option
+		event   Event
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:    "SignatureVersion1",
+			options: []Option{Options.Certificate(certPEM)},
+			event:   NotificationEvent,
+			body:    signed(t, "./testdata/notification.json", "1", nil),
+		},
+		{
+			name:    "SignatureVersion2",
+			options: []Option{Options.Certificate(certPEM)},
+			event:   NotificationEvent,
+			body:    signed(t, "./testdata/notification.json", "2", nil),
+		},
+		{
+			name:    "SubscriptionConfirmation",
+			options: []Option{Options.Certificate(certPEM), Options.TopicARNs(topic)},
+			event:   SubscriptionConfirmationEvent,
+			body:    signed(t, "./testdata/subscription-confirmation.json", "2", nil),
+		},
+		{
+			name:    "WithoutSubject",
+			options: []Option{Options.Certificate(certPEM)},
+			event:   NotificationEvent,
+			body: signed(t, "./testdata/notification.json", "1", func(m *Message) {
+				// the subject is only signed when present
+				m.Subject = ""
+			}),
+			wantErr: ErrSignatureVerificationFailed,
+		},
+		{
+			name:    "TamperedMessage",
+			options: []Option{Options.Certificate(certPEM)},
+			event:   NotificationEvent,
+			body: signed(t, "./testdata/notification.json", "1", func(m *Message) {
+				m.Message = strings.Replace(m.Message, "refs/heads/main", "refs/heads/prod", 1)
+			}),
+			wantErr: ErrSignatureVerificationFailed,
+		},
+		{
+			name:    "TamperedToken",
+			options: []Option{Options.Certificate(certPEM)},
+			event:   SubscriptionConfirmationEvent,
+			body:    signed(t, "./testdata/subscription-confirmation.json", "1", func(m *Message) { m.Token += "0" }),
+			wantErr: ErrSignatureVerificationFailed,
+		},
+		{
+			name:    "OtherCertificate",
+			options: []Option{Options.Certificate(otherPEM)},
+			event:   NotificationEvent,
+			body:    signed(t, "./testdata/notification.json", "2", nil),
+			wantErr: ErrSignatureVerificationFailed,
+		},
+		{
+			name:    "UnsupportedVersion",
+			options: []Option{Options.Certificate(certPEM)},
+			event:   NotificationEvent,
+			body:    signed(t, "./testdata/notification.json", "2", func(m *Message) { m.SignatureVersion = "3" }),
+			wantErr: ErrSignatureVerificationFailed,
+		},
+		{
+			name:    "BadSignatureEncoding",
+			options: []Option{Options.Certificate(certPEM)},
+			event:   NotificationEvent,
+			body:    signed(t, "./testdata/notification.json", "2", func(m *Message) { m.Signature = "not base64!" }),
+			wantErr: ErrSignatureVerificationFailed,
+		},
+		{
+			name:    "Unsigned",
+			options: []Option{Options.Certificate(certPEM)},
+			event:   NotificationEvent,
+			body:    whtest.Fixture(t, "./testdata/notification.json"),
+			wantErr: ErrSignatureVerificationFailed,
+		},
+		{
+			name:    "OtherTopic",
+			options: []Option{Options.Certificate(certPEM), Options.TopicARNs("arn:aws:sns:us-east-1:123456789012:OtherTopic")},
+			event:   NotificationEvent,
+			body:    signed(t, "./testdata/notification.json", "2", nil),
+			wantErr: ErrTopicNotAllowed,
+		},
+		{
+			name:    "TopicOnly",
+			options: []Option{Options.TopicARNs(topic)},
+			event:   NotificationEvent,
+			body:    whtest.Fixture(t, "./testdata/notification.json"),
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			h, err := New(tc.options...)
+			assert.NoError(err)
+			h.now = func() time.Time { return published(t, tc.body) }
+			_, err = h.Parse(whtest.CodeCommit(tc.event, tc.body).Request(), tc.event)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+// published returns the Timestamp of the message in body.
+func published(t *testing.T, body []byte) time.Time {
+	t.Helper()
+	var msg Message
+	require.NoError(t, json.Unmarshal(body, &msg))
+	ts, err := msg.Time()
+	require.NoError(t, err)
+	return ts
+}
+
+func TestTimestamp(t *testing.T) {
+	body := signed(t, "./testdata/notification.json", "2", nil)
+	tests := []struct {
+		name    string
+		options []Option
+		age     time.Duration
+		wantErr error
+	}{
+		{name: "Fresh", age: time.Minute},
+		{name: "Stale", age: DefaultTolerance + time.Second, wantErr: ErrTimestampExpired},
+		{name: "Future", age: -DefaultTolerance - time.Second, wantErr: ErrTimestampExpired},
+		{name: "Tolerance", options: []Option{Options.Tolerance(time.Hour)}, age: 30 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			h, err := New(append([]Option{Options.Certificate(certPEM)}, tc.options...)...)
+			assert.NoError(err)
+			h.now = func() time.Time { return published(t, body).Add(tc.age) }
+			_, err = h.Parse(whtest.CodeCommit(NotificationEvent, body).Request(), NotificationEvent)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+
+	// unsigned messages are accepted whatever their Timestamp without a certificate option
+	h, err := New()
+	require.NoError(t, err)
+	h.now = func() time.Time { return time.Now().AddDate(1, 0, 0) }
+	_, err = h.Parse(whtest.CodeCommit(NotificationEvent, whtest.Fixture(t, "./testdata/notification.json")).Request(), NotificationEvent)
+	require.NoError(t, err)
+
+	_, err = New(Options.Tolerance(0))
+	require.Error(t, err)
+}
+
+func TestStringToSign(t *testing.T) {
+	assert := require.New(t)
+	msg := Message{
+		Type:      "Notification",
+		MessageID: "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		TopicARN:  topic,
+		Message:   "hello",
+		Timestamp: "2024-06-03T08:50:12.012Z",
+		Signature: "ignored",
+	}
+	assert.Equal("Message\nhello\nMessageId\n22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324\n"+
+		"Timestamp\n2024-06-03T08:50:12.012Z\nTopicArn\n"+topic+"\nType\nNotification\n", msg.StringToSign())
+
+	msg.Type, msg.Token, msg.SubscribeURL = "SubscriptionConfirmation", "t0k3n", "https://sns.us-east-1.amazonaws.com/"
+	assert.Equal("Message\nhello\nMessageId\n22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324\nSubscribeURL\nhttps://sns.us-east-1.amazonaws.com/\n"+
+		"Timestamp\n2024-06-03T08:50:12.012Z\nToken\nt0k3n\nTopicArn\n"+topic+"\nType\nSubscriptionConfirmation\n", msg.StringToSign())
+}
+
+// roundTripper serves the requests of a client without a network.
+type roundTripper func(r *http.Request) (*http.Response, error)
+
+func (fn roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+func respond(status int, body []byte) *http.Response {
+	return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(bytes.NewReader(body)), Header: http.Header{}}
+}
+
+func TestFetchCertificates(t *testing.T) {
+	assert := require.New(t)
+	var fetched []string
+	client := &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		fetched = append(fetched, r.URL.String())
+		return respond(http.StatusOK, certPEM), nil
+	})}
+
+	h, err := New(Options.FetchCertificates(client))
+	assert.NoError(err)
+	body := signed(t, "./testdata/notification.json", "1", nil)
+	h.now = func() time.Time { return published(t, body) }
+	for i := 0; i < 2; i++ {
+		_, err = h.Parse(whtest.CodeCommit(NotificationEvent, body).Request(), NotificationEvent)
+		assert.NoError(err)
+	}
+	// certificates are cached
+	assert.Equal([]string{"https://sns.us-east-1.amazonaws.com/SimpleNotificationService-9c6465fa7f48f5cacd23014631ec1136.pem"}, fetched)
+
+	for _, certURL := range []string{
+		"http://sns.us-east-1.amazonaws.com/SimpleNotificationService.pem",
+		"https://sns.us-east-1.amazonaws.com.example.com/SimpleNotificationService.pem",
+		"https://sns.us-east-1.amazonaws.com@example.com/SimpleNotificationService.pem",
+		"https://example.com/SimpleNotificationService.pem",
+		"https://sns.us-east-1.amazonaws.com/SimpleNotificationService.txt",
+	} {
+		body := signed(t, "./testdata/notification.json", "1", func(m *Message) { m.SigningCertURL = certURL })
+		_, err = h.Parse(whtest.CodeCommit(NotificationEvent, body).Request(), NotificationEvent)
+		assert.ErrorIs(err, ErrSignatureVerificationFailed, certURL)
+	}
+	assert.Len(fetched, 1)
+
+	failing := &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		return respond(http.StatusNotFound, nil), nil
+	})}
+	h, err = New(Options.FetchCertificates(failing))
+	assert.NoError(err)
+	_, err = h.Parse(whtest.CodeCommit(NotificationEvent, body).Request(), NotificationEvent)
+	assert.ErrorIs(err, ErrSignatureVerificationFailed)
+}
+
+func TestConfirm(t *testing.T) {
+	assert := require.New(t)
+	var confirmed string
+	client := &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		confirmed = r.URL.String()
+		return respond(http.StatusOK, []byte("<ConfirmSubscriptionResponse/>")), nil
+	})}
+
+	pl := NewFaker("MyDemoRepo").SubscriptionConfirmationPayload()
+	assert.NoError(pl.Confirm(context.Background(), client))
+	assert.Equal(pl.SubscribeURL, confirmed)
+
+	confirmed = ""
+	for _, subscribeURL := range []string{
+		"http://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription",
+		"https://sns.us-east-1.amazonaws.com.example.com/?Action=ConfirmSubscription",
+		"https://sns.us-east-1.amazonaws.com@example.com/?Action=ConfirmSubscription",
+		"https://user@sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription",
+		"https://sns.us-east-1.amazonaws.com:8443/?Action=ConfirmSubscription",
+		"https://example.com/?Action=ConfirmSubscription",
+	} {
+		pl.SubscribeURL = subscribeURL
+		assert.Error(pl.Confirm(context.Background(), client), subscribeURL)
+	}
+	assert.Empty(confirmed)
+}
+
+func TestOptions(t *testing.T) {
+	assert := require.New(t)
+	_, err := New(Options.Certificate([]byte("not a certificate")))
+	assert.Error(err)
+	_, err = New(Options.CertificateFunc(nil))
+	assert.Error(err)
+	_, err = New(Options.TopicARNs("MyDemoTopic"))
+	assert.Error(err)
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("MyDemoRepo").User("john")
+	notification := faker.NotificationPayload("main", "refs/tags/v1.0.0")
+	assert.Len(notification.Records, 1)
+	record := notification.Records[0]
+	assert.Equal("MyDemoRepo", record.RepositoryName())
+	assert.Equal("arn:aws:iam::123456789012:user/john", record.UserIdentityARN)
+	assert.Equal("refs/heads/main", record.CodeCommit.References[0].Ref)
+	assert.Equal("refs/tags/v1.0.0", record.CodeCommit.References[1].Ref)
+	assert.Equal(faker.TopicARN(), notification.SNS.TopicARN)
+	assert.Equal(notification, NewFaker("MyDemoRepo").User("john").NotificationPayload("main", "refs/tags/v1.0.0"))
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: NotificationEvent, payload: notification},
+		{event: SubscriptionConfirmationEvent, payload: faker.SubscriptionConfirmationPayload()},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.CodeCommit(tc.event, tc.payload).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{SubscriptionConfirmationEvent, "./testdata/subscription-confirmation.json"},
+		{NotificationEvent, "./testdata/notification.json"},
+		{UnsubscribeConfirmationEvent, "./testdata/unsubscribe-confirmation.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), payload)
+	}
+
+	signed, err := New(Options.Certificate(certPEM))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event string, payload []byte) {
+		for _, h := range []*Webhook{hook, signed} {
+			r := whtest.CodeCommit(event, payload).Request()
+			if pl, err := h.parse(r, h.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+				t.Fatal("no payload and no error")
+			}
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package codecommit
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic messages of a repository trigger to unit test handlers.
+// The messages of a faker agree with each other on the topic, repository and user,
+// and encode to the JSON SNS posts, e.g. to deliver them with the whtest package.
+// They are not signed, so they only pass a Webhook verifying no signatures.
+// The same repository always yields the same messages.
+type Faker struct {
+	region     string
+	account    string
+	repository string
+	trigger    string
+	user       string
+	src        *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given name, e.g. "MyDemoRepo",
+// in the us-east-1 region of the account 123456789012. Pushes are made by the IAM user "jane".
+func NewFaker(repository string) *Faker {
+	return &Faker{
+		region:     "us-east-1",
+		account:    "123456789012",
+		repository: repository,
+		trigger:    repository + "Trigger",
+		user:       "jane",
+		src:        fake.New("codecommit/" + repository),
+	}
+}
+
+// User sets the name of the IAM user pushing.
+func (f *Faker) User(name string) *Faker {
+	f.user = name
+	return f
+}
+
+// SHA returns a new commit ID.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// TopicARN returns the ARN of the topic of the trigger.
+func (f *Faker) TopicARN() string {
+	return "arn:aws:sns:" + f.region + ":" + f.account + ":" + f.trigger
+}
+
+// NotificationPayload returns the notification of a push updating refs, branch names or full refs such as "refs/tags/v1.0.0",
+// to new commits.
+func (f *Faker) NotificationPayload(refs ...string) NotificationPayload {
+	references := make([]Reference, 0, len(refs))
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref, "refs/") {
+			ref = "refs/heads/" + ref
+		}
+		references = append(references, Reference{Commit: f.SHA(), Ref: ref})
+	}
+
+	id := f.src.UUID()
+	record := Record{
+		AWSRegion:            f.region,
+		CodeCommit:           CodeCommit{References: references},
+		EventID:              id,
+		EventName:            "ReferenceChanges",
+		EventPartNumber:      1,
+		EventSource:          "aws:codecommit",
+		EventSourceARN:       "arn:aws:codecommit:" + f.region + ":" + f.account + ":" + f.repository,
+		EventTime:            f.src.Time().Format("2006-01-02T15:04:05.000-0700"),
+		EventTotalParts:      1,
+		EventTriggerConfigID: f.src.UUID(),
+		EventTriggerName:     f.trigger,
+		EventVersion:         "1.0",
+		UserIdentityARN:      "arn:aws:iam::" + f.account + ":user/" + f.user,
+	}
+
+	message, err := json.Marshal(records{Records: []Record{record}})
+	if err != nil {
+		panic(err)
+	}
+
+	msg := f.message(NotificationEvent, string(message))
+	msg.Subject = f.trigger
+	msg.UnsubscribeURL = f.endpoint() + "/?Action=Unsubscribe&SubscriptionArn=" + f.TopicARN() + ":" + id
+	return NotificationPayload{SNS: msg, Records: []Record{record}}
+}
+
+// SubscriptionConfirmationPayload returns the confirmation SNS sends to a new subscription to the topic.
+func (f *Faker) SubscriptionConfirmationPayload() SubscriptionConfirmationPayload {
+	msg := f.message(SubscriptionConfirmationEvent, "You have chosen to subscribe to the topic "+f.TopicARN()+
+		".\nTo confirm the subscription, visit the SubscribeURL included in this message.")
+	msg.Token = f.src.Hex(64)
+	msg.SubscribeURL = f.endpoint() + "/?Action=ConfirmSubscription&TopicArn=" + f.TopicARN() + "&Token=" + msg.Token
+	return SubscriptionConfirmationPayload(msg)
+}
+
+func (f *Faker) message(typ Event, message string) Message {
+	return Message{
+		Type:             string(typ),
+		MessageID:        f.src.UUID(),
+		TopicARN:         f.TopicARN(),
+		Message:          message,
+		Timestamp:        f.src.Time().Format("2006-01-02T15:04:05.000Z"),
+		SignatureVersion: "1",
+		SigningCertURL:   f.endpoint() + "/SimpleNotificationService-" + f.src.Hex(16) + ".pem",
+	}
+}
+
+func (f *Faker) endpoint() string {
+	return "https://sns." + f.region + ".amazonaws.com"
+}
@@ -0,0 +1,170 @@
+package codecommit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Message contains an SNS message as posted to the subscription.
+// Timestamp is kept as sent since the signature covers it, see Time.
+type Message struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token,omitempty"`
+	TopicARN         string `json:"TopicArn"`
+	Subject          string `json:"Subject,omitempty"`
+	Message          string `json:"Message"`
+	SubscribeURL     string `json:"SubscribeURL,omitempty"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	UnsubscribeURL   string `json:"UnsubscribeURL,omitempty"`
+}
+
+// Time returns the time the message was published.
+func (m Message) Time() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, m.Timestamp)
+}
+
+// StringToSign returns the fields of the message covered by its signature, in the canonical format of SNS.
+func (m Message) StringToSign() string {
+	var b strings.Builder
+	field := func(name, value string) {
+		b.WriteString(name + "\n" + value + "\n")
+	}
+
+	field("Message", m.Message)
+	field("MessageId", m.MessageID)
+	if m.Type == string(NotificationEvent) {
+		if m.Subject != "" {
+			field("Subject", m.Subject)
+		}
+	} else {
+		field("SubscribeURL", m.SubscribeURL)
+	}
+	field("Timestamp", m.Timestamp)
+	if m.Type != string(NotificationEvent) {
+		field("Token", m.Token)
+	}
+	field("TopicArn", m.TopicARN)
+	field("Type", m.Type)
+	return b.String()
+}
+
+// SubscriptionConfirmationPayload contains the message SNS sends to a new subscription,
+// which is pending until its SubscribeURL is fetched.
+type SubscriptionConfirmationPayload Message
+
+// Confirm confirms the subscription by fetching its SubscribeURL with client, or a client with a 10 seconds timeout
+// if nil. Only HTTPS URLs of SNS, on sns.<region>.amazonaws.com, are fetched: confirm verified messages,
+// as anyone can post an unverified one.
+func (pl SubscriptionConfirmationPayload) Confirm(ctx context.Context, client *http.Client) error {
+	if err := checkSNSURL(pl.SubscribeURL); err != nil {
+		return fmt.Errorf("SubscribeURL %w", err)
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pl.SubscribeURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("confirming the subscription to %s: %s", pl.TopicARN, resp.Status)
+	}
+	return nil
+}
+
+// UnsubscribeConfirmationPayload contains the message SNS sends once a subscription is deleted,
+// its SubscribeURL subscribes again.
+type UnsubscribeConfirmationPayload Message
+
+// Reference contains a ref updated by a push, Commit is the commit it points to
+// and Created or Deleted are set for created and deleted refs.
+type Reference struct {
+	Commit  string `json:"commit"`
+	Ref     string `json:"ref"`
+	Created bool   `json:"created,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// CodeCommit contains the refs a trigger fired for.
+type CodeCommit struct {
+	References []Reference `json:"references"`
+}
+
+// Record contains a CodeCommit trigger event, EventName is "ReferenceChanges" for the pushes to a repository.
+// EventTime is kept as sent, e.g. "2016-02-09T00:08:11.743+0000".
+type Record struct {
+	AWSRegion            string     `json:"awsRegion"`
+	CodeCommit           CodeCommit `json:"codecommit"`
+	CustomData           string     `json:"customData,omitempty"`
+	EventID              string     `json:"eventId"`
+	EventName            string     `json:"eventName"`
+	EventPartNumber      int        `json:"eventPartNumber"`
+	EventSource          string     `json:"eventSource"`
+	EventSourceARN       string     `json:"eventSourceARN"`
+	EventTime            string     `json:"eventTime"`
+	EventTotalParts      int        `json:"eventTotalParts"`
+	EventTriggerConfigID string     `json:"eventTriggerConfigId"`
+	EventTriggerName     string     `json:"eventTriggerName"`
+	EventVersion         string     `json:"eventVersion"`
+	UserIdentityARN      string     `json:"userIdentityARN"`
+}
+
+// RepositoryName returns the name of the repository of the record, the last part of its EventSourceARN.
+func (r Record) RepositoryName() string {
+	return r.EventSourceARN[strings.LastIndexByte(r.EventSourceARN, ':')+1:]
+}
+
+// NotificationPayload contains an SNS notification of a CodeCommit trigger,
+// SNS is the message as posted and Records the trigger events decoded from its Message.
+// It is encoded as the message, whose Message encodes the records.
+type NotificationPayload struct {
+	SNS     Message
+	Records []Record
+}
+
+// records is the CodeCommit trigger event a notification message carries.
+type records struct {
+	Records []Record `json:"Records"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, decoding the records of the message.
+func (pl *NotificationPayload) UnmarshalJSON(b []byte) error {
+	var msg Message
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return err
+	}
+
+	var r records
+	if err := json.Unmarshal([]byte(msg.Message), &r); err != nil {
+		return fmt.Errorf("the notification is not a CodeCommit trigger: %w", err)
+	}
+
+	pl.SNS, pl.Records = msg, r.Records
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, encoding the message as posted.
+func (pl NotificationPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pl.SNS)
+}
@@ -0,0 +1,542 @@
+// The `dispatch` package acknowledges webhook deliveries first and processes them asynchronously.
+//
+// A Dispatcher verifies and parses every delivery synchronously using the provider's Parse,
+// immediately answers 202 Accepted and hands the typed payload to a bounded pool of workers.
+// Deliveries sharing a key (by default the repository) are always handled in order by the same worker.
+// When the queue of that worker is full the delivery is rejected with 503 Service Unavailable
+// so the provider retries it later.
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = DispatcherOptions{}
+	// ErrQueueFull is reported when a delivery is rejected because the worker queue is full.
+	ErrQueueFull = errors.New("dispatch queue is full")
+	// ErrShutdown is reported when a delivery arrives after Shutdown was called.
+	ErrShutdown = errors.New("dispatcher is shut down")
+	// ErrPanic is wrapped by the errors reported for panics recovered from the parse func or the handler.
+	ErrPanic = errors.New("panic recovered")
+)
+
+// ParseFunc verifies and parses a delivery, usually wrapping a provider's Parse:
+//
+//	func(r *http.Request) (interface{}, error) {
+//		return hook.Parse(r, github.PushEvent, github.PullRequestEvent)
+//	}
+type ParseFunc func(r *http.Request) (interface{}, error)
+
+// Handler processes a parsed payload.
+type Handler func(ctx context.Context, payload interface{}) error
+
+// KeyFunc returns the ordering key of a parsed payload.
+// Payloads with the same key are handled sequentially in arrival order,
+// an empty key means the payload can be handled by any worker.
+type KeyFunc func(payload interface{}) string
+
+// Journal durably records verified deliveries before they are dispatched,
+// see the journal package for the on-disk implementation.
+type Journal interface {
+	// Record stores the raw delivery and returns its ID.
+	Record(header http.Header, body []byte) (string, error)
+	// Done stores the outcome of handling the delivery with the given ID.
+	Done(id string, err error) error
+}
+
+// Option is a configuration option for the dispatcher.
+type Option func(*Dispatcher) error
+
+// Delivery is the raw verified delivery a payload was parsed from.
+// Handlers called by the Dispatcher can retrieve it with DeliveryFromContext.
+type Delivery struct {
+	// ID is the journal ID of the delivery, empty when no Journal is configured.
+	ID       string
+	Header   http.Header
+	Body     []byte
+	Received time.Time
+}
+
+// Metadata describes a delivery as parsed by the provider's Parse.
+// Handlers called by the Dispatcher can retrieve it with MetadataFromContext
+// when the parse func calls the Parse of a provider package.
+type Metadata struct {
+	Provider string
+	Event    string
+	// DeliveryID is the ID the provider sent the delivery with, empty if it does not send one.
+	DeliveryID string
+}
+
+type deliveryKey struct{}
+
+type metadataKey struct{}
+
+type job struct {
+	delivery Delivery
+	payload  interface{}
+	// ctx carries the values of the request context, e.g. the delivery span, without its cancellation
+	ctx  context.Context
+	span tracing.Span
+}
+
+// ContextWithDelivery returns a copy of ctx carrying the raw delivery,
+// e.g. to call a handler outside of a Dispatcher.
+func ContextWithDelivery(ctx context.Context, delivery Delivery) context.Context {
+	return context.WithValue(ctx, deliveryKey{}, delivery)
+}
+
+// DeliveryFromContext returns the raw delivery of the payload being handled.
+func DeliveryFromContext(ctx context.Context) (Delivery, bool) {
+	delivery, ok := ctx.Value(deliveryKey{}).(Delivery)
+	return delivery, ok
+}
+
+// ContextWithMetadata returns a copy of ctx carrying the metadata of a delivery,
+// e.g. to call a handler outside of a Dispatcher.
+func ContextWithMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFromContext returns the metadata of the delivery of the payload being handled.
+func MetadataFromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(metadataKey{}).(Metadata)
+	return md, ok
+}
+
+// Dispatcher is an http.Handler that acknowledges deliveries and processes them asynchronously.
+type Dispatcher struct {
+	parse       ParseFunc
+	handler     Handler
+	key         KeyFunc
+	onError     func(payload interface{}, err error)
+	journal     Journal
+	metrics     metrics.Recorder
+	tracer      tracing.Tracer
+	readTimeout time.Duration
+	workers     int
+	queueSize   int
+	queues      []chan job
+	next        uint32
+	mu          sync.RWMutex
+	closed      bool
+	wg          sync.WaitGroup
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+// New creates a Dispatcher and starts its workers.
+func New(parse ParseFunc, handler Handler, options ...Option) (*Dispatcher, error) {
+	if parse == nil || handler == nil {
+		return nil, errors.New("parse func and handler are required")
+	}
+
+	d := &Dispatcher{
+		parse:     parse,
+		handler:   handler,
+		key:       RepositoryKey,
+		workers:   4,
+		queueSize: 64,
+	}
+	for _, opt := range options {
+		if err := opt(d); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	d.queues = make([]chan job, d.workers)
+	for i := range d.queues {
+		d.queues[i] = make(chan job, d.queueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+
+	return d, nil
+}
+
+// ServeHTTP verifies and parses the delivery and queues it for processing.
+// It answers 202 Accepted once the payload is queued, 400 Bad Request when parsing fails,
+// 408 Request Timeout when reading the body takes longer than the read timeout,
+// 500 Internal Server Error when the parse func panics
+// and 503 Service Unavailable when the queue is full or the dispatcher is shut down.
+//
+// When a Journal is configured the verified delivery is recorded before it is queued
+// and the request fails with 503 Service Unavailable if it cannot be recorded.
+func (d *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := d.readBody(w, r)
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "timeout reading payload", http.StatusRequestTimeout)
+		return
+	} else if err != nil {
+		http.Error(w, "error reading payload", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r, payload, span, err := d.parseRequest(r)
+	if err != nil {
+		if errors.Is(err, ErrPanic) {
+			http.Error(w, "error parsing payload", http.StatusInternalServerError)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	delivery := Delivery{Header: r.Header.Clone(), Body: body, Received: time.Now()}
+	if d.journal != nil {
+		if delivery.ID, err = d.journal.Record(r.Header, body); err != nil {
+			endSpan(span, err)
+			http.Error(w, "error recording delivery", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	j := job{delivery: delivery, payload: payload, ctx: context.WithoutCancel(r.Context()), span: span}
+	if err := d.enqueue(j); err != nil {
+		endSpan(span, err)
+		if d.journal != nil {
+			_ = d.journal.Done(delivery.ID, err)
+		}
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Enqueue queues an already parsed payload without blocking.
+// It returns ErrQueueFull or ErrShutdown when the payload cannot be accepted.
+func (d *Dispatcher) Enqueue(payload interface{}) error {
+	return d.enqueue(job{payload: payload})
+}
+
+// Redeliver verifies, parses and handles a raw delivery synchronously,
+// e.g. to replay deliveries recorded in a Journal after an outage.
+// The delivery is not recorded again.
+func (d *Dispatcher) Redeliver(ctx context.Context, header http.Header, body []byte) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	r.Header = header.Clone()
+	r, payload, span, err := d.parseRequest(r)
+	if err != nil {
+		return err
+	}
+
+	delivery := Delivery{Header: r.Header.Clone(), Body: body, Received: time.Now()}
+	return d.process(ContextWithDelivery(r.Context(), delivery), span, payload)
+}
+
+// readBody reads the body of r, aborting when the request is cancelled or the read timeout expires.
+// The timeout is a read deadline of the connection when the ResponseWriter supports it,
+// otherwise reads are aborted by its context.
+func (d *Dispatcher) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if d.readTimeout <= 0 {
+		body := observe.NewBody(r.Context(), r.Body)
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Now().Add(d.readTimeout)); err == nil {
+		defer func() {
+			_ = rc.SetReadDeadline(time.Time{})
+		}()
+
+		body := observe.NewBody(r.Context(), r.Body)
+		defer body.Close()
+		payload, err := io.ReadAll(body)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			err = context.DeadlineExceeded
+		}
+		return payload, err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), d.readTimeout)
+	defer cancel()
+	body := observe.NewAbortableBody(ctx, r.Body)
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// parseRequest parses r with the parse func, in the delivery span when a tracer is configured,
+// the provider's Parse annotates it rather than starting its own.
+// The context of the returned request carries the span and the Metadata of the delivery.
+// Deliveries whose request is cancelled while they are parsed are not handled.
+func (d *Dispatcher) parseRequest(r *http.Request) (*http.Request, interface{}, tracing.Span, error) {
+	scope := &observe.Scope{Tracer: d.tracer}
+	ctx := r.Context()
+	if d.tracer != nil {
+		ctx, scope.Span = d.tracer.Start(ctx, tracing.DeliverySpan)
+	}
+
+	r = r.WithContext(observe.ContextWithScope(ctx, scope))
+	payload, err := d.safeParse(r)
+	if err == nil {
+		err = r.Context().Err()
+	}
+
+	if err != nil {
+		endSpan(scope.Span, err)
+		return r, nil, nil, err
+	}
+
+	if pd := scope.Delivery; pd != nil {
+		md := Metadata{Provider: pd.Provider.Name, Event: pd.Event, DeliveryID: pd.ID}
+		r = r.WithContext(ContextWithMetadata(r.Context(), md))
+	}
+	return r, payload, scope.Span, nil
+}
+
+func endSpan(span tracing.Span, err error) {
+	if span == nil {
+		return
+	}
+
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// process handles a payload in a child span of the delivery span, if any, and ends the delivery span.
+func (d *Dispatcher) process(ctx context.Context, span tracing.Span, payload interface{}) error {
+	if span == nil {
+		return d.handle(ctx, payload)
+	}
+
+	ctx, handleSpan := d.tracer.Start(ctx, tracing.HandleSpan)
+	err := d.handle(ctx, payload)
+	endSpan(handleSpan, err)
+	endSpan(span, err)
+	return err
+}
+
+// safeParse calls the parse func, returning a panic as an error wrapping ErrPanic.
+func (d *Dispatcher) safeParse(r *http.Request) (payload interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: parsing: %v", ErrPanic, p)
+		}
+	}()
+	return d.parse(r)
+}
+
+// handle calls the handler, returning a panic as an error wrapping ErrPanic
+// so a failing payload does not take the worker and the process down.
+func (d *Dispatcher) handle(ctx context.Context, payload interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w: handling: %v", ErrPanic, p)
+		}
+
+		if d.metrics != nil {
+			d.metrics.Handled(outcome(err), time.Since(start))
+		}
+	}()
+	return d.handler(ctx, payload)
+}
+
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeOK
+	case errors.Is(err, ErrPanic):
+		return metrics.OutcomePanic
+	default:
+		return metrics.OutcomeError
+	}
+}
+
+func (d *Dispatcher) enqueue(j job) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrShutdown
+	}
+
+	select {
+	case d.queues[d.shard(j.payload)] <- j:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Shutdown stops accepting deliveries and waits until all queued and in-flight payloads are handled.
+// If ctx is done first the context passed to running handlers is cancelled and ctx.Err() is returned.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, q := range d.queues {
+			close(q)
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) shard(payload interface{}) int {
+	key := d.key(payload)
+	if key == "" {
+		return int(atomic.AddUint32(&d.next, 1) % uint32(len(d.queues)))
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+func (d *Dispatcher) work(queue <-chan job) {
+	defer d.wg.Done()
+	for j := range queue {
+		ctx, cancel := d.jobContext(j)
+		err := d.process(ContextWithDelivery(ctx, j.delivery), j.span, j.payload)
+		cancel()
+		if d.journal != nil && j.delivery.ID != "" {
+			_ = d.journal.Done(j.delivery.ID, err)
+		}
+
+		if err != nil && d.onError != nil {
+			d.onError(j.payload, err)
+		}
+	}
+}
+
+// jobContext returns the context of the handler of j,
+// carrying the values of the request context and cancelled when the dispatcher is shut down.
+func (d *Dispatcher) jobContext(j job) (context.Context, context.CancelFunc) {
+	if j.ctx == nil {
+		return d.ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(j.ctx)
+	stop := context.AfterFunc(d.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// RepositoryKey is the default KeyFunc.
+// It returns the full name of the repository or project the payload refers to,
+// looking for the Repository, Repo, Project and Resource fields used by the provider payloads.
+func RepositoryKey(payload interface{}) string {
+	return observe.Repository(payload)
+}
+
+// DispatcherOptions is a namespace for configuration option methods.
+type DispatcherOptions struct{}
+
+// Workers sets the number of concurrent workers, 4 by default.
+func (DispatcherOptions) Workers(n int) Option {
+	return func(d *Dispatcher) error {
+		if n < 1 {
+			return errors.New("workers must be positive")
+		}
+		d.workers = n
+		return nil
+	}
+}
+
+// QueueSize sets the number of payloads each worker can hold before deliveries are rejected, 64 by default.
+func (DispatcherOptions) QueueSize(n int) Option {
+	return func(d *Dispatcher) error {
+		if n < 0 {
+			return errors.New("queue size must not be negative")
+		}
+		d.queueSize = n
+		return nil
+	}
+}
+
+// Key sets the function used to order payloads, RepositoryKey by default.
+func (DispatcherOptions) Key(key KeyFunc) Option {
+	return func(d *Dispatcher) error {
+		if key == nil {
+			return errors.New("key func is nil")
+		}
+		d.key = key
+		return nil
+	}
+}
+
+// Journal records every verified delivery before it is dispatched and tracks its processing status.
+func (DispatcherOptions) Journal(j Journal) Option {
+	return func(d *Dispatcher) error {
+		d.journal = j
+		return nil
+	}
+}
+
+// OnError registers a callback for errors returned by the handler.
+func (DispatcherOptions) OnError(fn func(payload interface{}, err error)) Option {
+	return func(d *Dispatcher) error {
+		d.onError = fn
+		return nil
+	}
+}
+
+// Metrics reports the time taken by the handler and its outcome to m, e.g. a metrics.Prometheus.
+func (DispatcherOptions) Metrics(m metrics.Recorder) Option {
+	return func(d *Dispatcher) error {
+		d.metrics = m
+		return nil
+	}
+}
+
+// Tracer traces every delivery with a span, annotated by the provider's Parse, ended once the payload is handled
+// and with a child span for the handler. The context of the handler carries the handler span.
+func (DispatcherOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(d *Dispatcher) error {
+		d.tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout answers 408 Request Timeout to deliveries whose body takes longer than timeout to read,
+// e.g. of a client sending it too slowly, independently of the server timeouts.
+func (DispatcherOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		d.readTimeout = timeout
+		return nil
+	}
+}
@@ -0,0 +1,397 @@
+package dispatch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/github"
+	"github.com/pchchv/wh/journal"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+type repository struct {
+	FullName string `json:"full_name"`
+}
+
+type payload struct {
+	Seq        int        `json:"seq"`
+	Repository repository `json:"repository"`
+}
+
+func parse(r *http.Request) (interface{}, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		return nil, errors.New("error parsing payload")
+	}
+
+	var pl payload
+	err = json.Unmarshal(body, &pl)
+	return pl, err
+}
+
+func post(d *Dispatcher, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+	return w
+}
+
+func TestAcceptAndHandle(t *testing.T) {
+	assert := require.New(t)
+	var mu sync.Mutex
+	var handled []payload
+	var deliveries []Delivery
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, pl.(payload))
+		delivery, _ := DeliveryFromContext(ctx)
+		deliveries = append(deliveries, delivery)
+		return nil
+	})
+	assert.NoError(err)
+
+	body := `{"seq":1,"repository":{"full_name":"octo/repo"}}`
+	w := post(d, body)
+	assert.Equal(http.StatusAccepted, w.Code)
+	assert.NoError(d.Shutdown(context.Background()))
+	assert.Len(handled, 1)
+	assert.Equal("octo/repo", handled[0].Repository.FullName)
+	assert.Equal(body, string(deliveries[0].Body))
+	assert.False(deliveries[0].Received.IsZero())
+}
+
+func TestBadPayload(t *testing.T) {
+	assert := require.New(t)
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		t.Fatal("handler must not be called")
+		return nil
+	})
+	assert.NoError(err)
+	defer func() {
+		_ = d.Shutdown(context.Background())
+	}()
+
+	w := post(d, "")
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestPerKeyOrdering(t *testing.T) {
+	assert := require.New(t)
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		p := pl.(payload)
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		seen[p.Repository.FullName] = append(seen[p.Repository.FullName], p.Seq)
+		return nil
+	}, Options.Workers(4), Options.QueueSize(100))
+	assert.NoError(err)
+
+	repos := []string{"a/one", "b/two", "c/three"}
+	for i := 0; i < 30; i++ {
+		body, _ := json.Marshal(payload{Seq: i, Repository: repository{FullName: repos[i%len(repos)]}})
+		assert.Equal(http.StatusAccepted, post(d, string(body)).Code)
+	}
+
+	assert.NoError(d.Shutdown(context.Background()))
+	for i, repo := range repos {
+		assert.Len(seen[repo], 10)
+		for j, seq := range seen[repo] {
+			assert.Equal(i+j*len(repos), seq)
+		}
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	assert := require.New(t)
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}, Options.Workers(1), Options.QueueSize(1))
+	assert.NoError(err)
+
+	body := `{"repository":{"full_name":"octo/repo"}}`
+	assert.Equal(http.StatusAccepted, post(d, body).Code)
+	<-started
+	assert.Equal(http.StatusAccepted, post(d, body).Code)
+	assert.Equal(http.StatusServiceUnavailable, post(d, body).Code)
+
+	close(release)
+	assert.NoError(d.Shutdown(context.Background()))
+	assert.Equal(http.StatusServiceUnavailable, post(d, body).Code)
+	assert.ErrorIs(d.Enqueue(payload{}), ErrShutdown)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	assert := require.New(t)
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.NoError(err)
+	assert.Equal(http.StatusAccepted, post(d, `{}`).Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(d.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestOnError(t *testing.T) {
+	assert := require.New(t)
+	errs := make(chan error, 1)
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		return errors.New("downstream unavailable")
+	}, Options.OnError(func(pl interface{}, err error) {
+		errs <- err
+	}))
+	assert.NoError(err)
+	assert.Equal(http.StatusAccepted, post(d, `{}`).Code)
+	assert.NoError(d.Shutdown(context.Background()))
+	assert.EqualError(<-errs, "downstream unavailable")
+}
+
+func TestPanics(t *testing.T) {
+	assert := require.New(t)
+	errs := make(chan error, 1)
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		var repo *repository
+		_ = repo.FullName
+		return nil
+	}, Options.OnError(func(pl interface{}, err error) {
+		errs <- err
+	}))
+	assert.NoError(err)
+
+	// the worker survives and keeps handling payloads
+	assert.Equal(http.StatusAccepted, post(d, `{"seq":1}`).Code)
+	assert.ErrorIs(<-errs, ErrPanic)
+	assert.Equal(http.StatusAccepted, post(d, `{"seq":2}`).Code)
+	assert.ErrorIs(<-errs, ErrPanic)
+	assert.NoError(d.Shutdown(context.Background()))
+
+	d, err = New(func(r *http.Request) (interface{}, error) {
+		panic("malformed payload")
+	}, func(ctx context.Context, pl interface{}) error {
+		return nil
+	})
+	assert.NoError(err)
+	w := post(d, `{}`)
+	assert.Equal(http.StatusInternalServerError, w.Code)
+	assert.NotContains(w.Body.String(), "malformed")
+	assert.ErrorIs(d.Redeliver(context.Background(), http.Header{}, []byte(`{}`)), ErrPanic)
+	assert.NoError(d.Shutdown(context.Background()))
+}
+
+func TestMetrics(t *testing.T) {
+	assert := require.New(t)
+	prom := metrics.NewPrometheus()
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		switch pl.(payload).Seq {
+		case 2:
+			return errors.New("downstream unavailable")
+		case 3:
+			panic("malformed payload")
+		}
+		return nil
+	}, Options.Metrics(prom), Options.Workers(1))
+	assert.NoError(err)
+
+	for _, body := range []string{`{"seq":1}`, `{"seq":2}`, `{"seq":3}`, `{"seq":1}`} {
+		assert.Equal(http.StatusAccepted, post(d, body).Code)
+	}
+	assert.NoError(d.Shutdown(context.Background()))
+
+	var b strings.Builder
+	_, err = prom.WriteTo(&b)
+	assert.NoError(err)
+	assert.Contains(b.String(), `wh_handler_duration_seconds_count{outcome="ok"} 2`)
+	assert.Contains(b.String(), `wh_handler_duration_seconds_count{outcome="error"} 1`)
+	assert.Contains(b.String(), `wh_handler_duration_seconds_count{outcome="panic"} 1`)
+}
+
+func TestMetadata(t *testing.T) {
+	assert := require.New(t)
+	hook, err := github.New(github.Options.Secret("secret"))
+	assert.NoError(err)
+
+	type handledCtx struct {
+		md  Metadata
+		err error
+	}
+	handled := make(chan handledCtx, 1)
+	d, err := New(func(r *http.Request) (interface{}, error) {
+		return hook.Parse(r, github.PushEvent)
+	}, func(ctx context.Context, pl interface{}) error {
+		md, _ := MetadataFromContext(ctx)
+		handled <- handledCtx{md: md, err: ctx.Err()}
+		return nil
+	})
+	assert.NoError(err)
+
+	r := whtest.GitHub(github.PushEvent, whtest.Fixture(t, "../github/testdata/push.json")).
+		Secret("secret").
+		ID("72d3162e-cc78-11e3-81ab-4c9367dc0958").
+		Request()
+	ctx, cancel := context.WithCancel(context.Background())
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, r.WithContext(ctx))
+	assert.Equal(http.StatusAccepted, w.Code)
+	// the handler context does not end with the request
+	cancel()
+	h := <-handled
+	assert.NoError(h.err)
+	assert.Equal(Metadata{Provider: "github", Event: "push", DeliveryID: "72d3162e-cc78-11e3-81ab-4c9367dc0958"}, h.md)
+
+	// deliveries of cancelled requests are not handled
+	r = whtest.GitHub(github.PushEvent, whtest.Fixture(t, "../github/testdata/push.json")).Secret("secret").Request()
+	w = httptest.NewRecorder()
+	d.ServeHTTP(w, r.WithContext(ctx))
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.NoError(d.Redeliver(context.Background(), r.Header, whtest.GitHub(github.PushEvent, whtest.Fixture(t, "../github/testdata/push.json")).Body()))
+	assert.Equal("push", (<-handled).md.Event)
+	assert.NoError(d.Shutdown(context.Background()))
+	assert.Empty(handled)
+}
+
+// slowBody sends its first byte and then nothing until it is released.
+type slowBody struct {
+	sent    bool
+	release chan struct{}
+}
+
+func (s *slowBody) Read(p []byte) (int, error) {
+	if !s.sent {
+		s.sent = true
+		return copy(p, "{"), nil
+	}
+	<-s.release
+	return 0, io.EOF
+}
+
+func TestReadTimeout(t *testing.T) {
+	assert := require.New(t)
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		return nil
+	}, Options.ReadTimeout(20*time.Millisecond))
+	assert.NoError(err)
+
+	body := &slowBody{release: make(chan struct{})}
+	defer close(body.release)
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhooks", body))
+	assert.Equal(http.StatusRequestTimeout, w.Code)
+	assert.Equal(http.StatusAccepted, post(d, `{"seq":1}`).Code)
+	assert.NoError(d.Shutdown(context.Background()))
+
+	_, err = New(parse, func(ctx context.Context, pl interface{}) error {
+		return nil
+	}, Options.ReadTimeout(-time.Second))
+	assert.Error(err)
+}
+
+func TestReadDeadline(t *testing.T) {
+	assert := require.New(t)
+	d, err := New(parse, func(ctx context.Context, pl interface{}) error {
+		return nil
+	}, Options.ReadTimeout(50*time.Millisecond))
+	assert.NoError(err)
+	server := httptest.NewServer(d)
+	defer server.Close()
+
+	// a client sending the headers and part of the body, then stalling
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.NoError(err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "POST /webhooks HTTP/1.1\r\nHost: example.com\r\nContent-Length: 9\r\n\r\n{")
+	assert.NoError(err)
+
+	assert.NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.NoError(err)
+	assert.Equal(http.StatusRequestTimeout, resp.StatusCode)
+	assert.NoError(d.Shutdown(context.Background()))
+}
+
+func TestJournal(t *testing.T) {
+	assert := require.New(t)
+	j, err := journal.Open(t.TempDir())
+	assert.NoError(err)
+	defer j.Close()
+
+	var mu sync.Mutex
+	down := true
+	var handled []int
+	handler := func(ctx context.Context, pl interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			return errors.New("downstream unavailable")
+		}
+		handled = append(handled, pl.(payload).Seq)
+		return nil
+	}
+	d, err := New(parse, handler, Options.Journal(j))
+	assert.NoError(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{"seq":7}`))
+	req.Header.Set("X-GitHub-Delivery", "delivery-7")
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, req)
+	assert.Equal(http.StatusAccepted, w.Code)
+	assert.Equal(http.StatusBadRequest, post(d, "").Code)
+	assert.NoError(d.Shutdown(context.Background()))
+
+	pending, err := j.Pending()
+	assert.NoError(err)
+	assert.Len(pending, 1)
+	assert.Equal("delivery-7", pending[0].DeliveryID)
+	assert.Equal(journal.StatusFailed, pending[0].Status)
+
+	down = false
+	assert.NoError(j.ReplayID(pending[0].ID, func(e journal.Entry) error {
+		return d.Redeliver(context.Background(), e.Header, e.Body)
+	}))
+	assert.Equal([]int{7}, handled)
+
+	pending, err = j.Pending()
+	assert.NoError(err)
+	assert.Empty(pending)
+}
+
+func TestRepositoryKey(t *testing.T) {
+	assert := require.New(t)
+	type project struct {
+		PathWithNamespace string
+	}
+	type resource struct {
+		Repository struct {
+			Name string
+		}
+	}
+
+	assert.Equal("octo/repo", RepositoryKey(payload{Repository: repository{FullName: "octo/repo"}}))
+	assert.Equal("group/project", RepositoryKey(struct{ Project project }{project{"group/project"}}))
+	assert.Equal("", RepositoryKey(struct{ Repo *repository }{}))
+
+	var r resource
+	r.Repository.Name = "azure-repo"
+	assert.Equal("azure-repo", RepositoryKey(struct{ Resource resource }{r}))
+	assert.Equal("", RepositoryKey(nil))
+}
@@ -3,19 +3,33 @@ package docker
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
 )
 
 // Docker hook types (only one for now).
 const BuildEvent Event = "build"
 
-// parse error
-var ErrParsingPayload = errors.New("error parsing payload")
+var (
+	// Options is a namespace var for configuration options.
+	Options = WebhookOptions{}
+	// ErrParsingPayload is returned when a payload cannot be read or decoded.
+	ErrParsingPayload = errors.New("error parsing payload")
+)
 
 // Event defines a Docker hook event type.
 type Event string
 
+// Option is a configuration option for the webhook.
+type Option func(*Webhook) error
+
 // BuildPayload a docker hub build notice.
 //
 // https://docs.docker.com/docker-hub/webhooks/
@@ -46,16 +60,41 @@ type BuildPayload struct {
 	} `json:"repository"`
 }
 
+// provider describes the deliveries of Docker Hub to the instrumentation.
+var provider = &observe.Provider{
+	Name: "docker",
+}
+
 // Webhook instance contains all methods needed to process events.
-type Webhook struct{}
+type Webhook struct {
+	observer observe.Observer
+}
 
 // New creates and returns a WebHook instance.
-func New() (*Webhook, error) {
-	return new(Webhook), nil
+func New(options ...Option) (*Webhook, error) {
+	hook := new(Webhook)
+	for _, opt := range options {
+		if err := opt(hook); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+	return hook, nil
 }
 
 // Parse verifies and parses the events specified and returns the payload object or an error.
-func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error) {
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
 	defer func() {
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
@@ -70,6 +109,10 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 		return nil, ErrParsingPayload
 	}
 
+	// Docker Hub does not sign its deliveries
+	_ = d.Verify(false, nil)
+	d.Event = string(BuildEvent)
+	d.Decoding()
 	var pl BuildPayload
 	if err = json.Unmarshal([]byte(payload), &pl); err != nil {
 		return nil, ErrParsingPayload
@@ -77,3 +120,41 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 
 	return pl, nil
 }
+
+// WebhookOptions is a namespace for configuration option methods.
+type WebhookOptions struct{}
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
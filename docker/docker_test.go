@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/pchchv/wh/whtest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -79,3 +80,33 @@ func newServer(handler http.HandlerFunc) *httptest.Server {
 	mux.HandleFunc(path, handler)
 	return httptest.NewServer(mux)
 }
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("svendowideit/testhook").Pusher("trustedbuilder")
+	build := faker.BuildPayload("latest")
+	assert.Equal("latest", build.PushData.Tag)
+	assert.Equal("trustedbuilder", build.PushData.Pusher)
+	assert.Equal("svendowideit/testhook", build.Repository.RepoName)
+	assert.Equal(build, NewFaker("svendowideit/testhook").Pusher("trustedbuilder").BuildPayload("latest"))
+
+	pl, err := hook.Parse(whtest.Docker(build).Request(), BuildEvent)
+	assert.NoError(err)
+	assert.Equal(build, pl)
+}
+
+func FuzzParse(f *testing.F) {
+	payload, err := os.ReadFile("./testdata/docker_hub_build_notice.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(payload)
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		r := whtest.Docker(payload).Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), BuildEvent); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
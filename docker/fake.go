@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic build notices of a repository to unit test handlers.
+// The notices of a faker agree with each other on the repository and pusher,
+// and encode to the JSON Docker Hub sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same notices.
+type Faker struct {
+	namespace string
+	name      string
+	pusher    string
+	src       *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given name, e.g. "svendowideit/testhook".
+// Images are pushed by the namespace owner.
+func NewFaker(repoName string) *Faker {
+	namespace, name, ok := strings.Cut(repoName, "/")
+	if !ok {
+		namespace, name = "library", repoName
+	}
+	return &Faker{namespace: namespace, name: name, pusher: namespace, src: fake.New("docker/" + repoName)}
+}
+
+// Pusher sets the user pushing the images.
+func (f *Faker) Pusher(username string) *Faker {
+	f.pusher = username
+	return f
+}
+
+// BuildPayload returns the notice of an image pushed with the tag.
+func (f *Faker) BuildPayload(tag string) BuildPayload {
+	var pl BuildPayload
+	repoName := f.namespace + "/" + f.name
+	pl.CallbackURL = "https://registry.hub.docker.com/u/" + repoName + "/hook/" + f.src.Hex(16) + "/"
+	pl.PushData.Tag = tag
+	pl.PushData.Pusher = f.pusher
+	pl.PushData.Images = []string{}
+	pl.PushData.PushedAt = float32(f.src.Time().Unix())
+	fake.Set(&pl.Repository, map[string]interface{}{
+		"namespace":        f.namespace,
+		"name":             f.name,
+		"repo_name":        repoName,
+		"repo_url":         "https://registry.hub.docker.com/u/" + repoName + "/",
+		"owner":            f.namespace,
+		"description":      "The " + f.name + " image",
+		"full_description": "The " + f.name + " image, built automatically.",
+		"dockerfile":       "FROM alpine\n",
+		"status":           "Active",
+		"is_trusted":       true,
+		"star_count":       int(fake.NameID(repoName) % 100),
+		"comment_count":    0,
+	})
+	pl.Repository.DateCreated = float32(fake.Epoch.Unix())
+	return pl
+}
@@ -0,0 +1,208 @@
+// The `drone` package accepts the global webhooks of a Drone server, configured with DRONE_WEBHOOK_ENDPOINT.
+//
+// Drone sends the event in the X-Drone-Event header and signs its deliveries with the webhook secret,
+// DRONE_WEBHOOK_SECRET, using HTTP Signatures: the Signature header is an HMAC-SHA256 of the request target,
+// the Date header and the Digest header, the SHA-256 of the body. Deliveries whose Date is further from the current
+// time than the tolerance are rejected, so a captured delivery can only be replayed within it.
+package drone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+const (
+	// Drone webhook events.
+	UserEvent  Event = "user"
+	RepoEvent  Event = "repo"
+	BuildEvent Event = "build"
+)
+
+// DefaultTolerance is how far the Date of a signed delivery may be from the current time.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = WebhookOptions{}
+	// ErrParsingPayload is returned when a payload cannot be read or decoded.
+	ErrParsingPayload = errors.New("error parsing payload")
+	// ErrTimestampExpired is returned when the Date of a signed delivery
+	// is further from the current time than the tolerance, e.g. of a replayed delivery.
+	ErrTimestampExpired = errors.New("Date out of tolerance")
+)
+
+// Event defines a Drone webhook event by the X-Drone-Event Header.
+type Event string
+
+// provider describes the deliveries of Drone to the instrumentation.
+var provider = &observe.Provider{
+	Name:          "drone",
+	EventHeader:   "X-Drone-Event",
+	SecretHeaders: []string{"Signature"},
+}
+
+// Webhook instance contains all methods needed to process events.
+type Webhook struct {
+	secret    string
+	tolerance time.Duration
+	now       func() time.Time
+	observer  observe.Observer
+}
+
+// Parse verifies and parses the events specified and returns the payload object or an error.
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
+	defer func() {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}()
+
+	if len(events) == 0 {
+		return nil, errors.New("no Event specified to parse")
+	}
+
+	if r.Method != http.MethodPost {
+		return nil, errors.New("invalid HTTP Method")
+	}
+
+	event := r.Header.Get("X-Drone-Event")
+	if len(event) == 0 {
+		return nil, errors.New("missing X-Drone-Event Header")
+	}
+
+	var found bool
+	droneEvent := Event(event)
+	for _, evt := range events {
+		if evt == droneEvent {
+			found = true
+			break
+		}
+	}
+
+	// event not defined to be parsed
+	if !found {
+		return nil, errors.New("event not defined to be parsed")
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil || len(payload) == 0 {
+		return nil, ErrParsingPayload
+	}
+
+	// if a secret is set, the digest, the signature and the date must be checked
+	err = d.Verify(len(hook.secret) > 0, func() error {
+		return hook.verify(r, payload)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.Decoding()
+	switch droneEvent {
+	case UserEvent:
+		var pl UserPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case RepoEvent:
+		var pl RepoPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case BuildEvent:
+		var pl BuildPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	default:
+		return nil, fmt.Errorf("unknown event %s", droneEvent)
+	}
+}
+
+// Option is a configuration option for the webhook.
+type Option func(*Webhook) error
+
+// New creates and returns a WebHook instance.
+func New(options ...Option) (*Webhook, error) {
+	hook := &Webhook{tolerance: DefaultTolerance, now: time.Now}
+	for _, opt := range options {
+		if err := opt(hook); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+	return hook, nil
+}
+
+// WebhookOptions is a namespace for configuration option methods.
+type WebhookOptions struct{}
+
+// Secret registers the webhook secret of the Drone server, DRONE_WEBHOOK_SECRET.
+func (WebhookOptions) Secret(secret string) Option {
+	return func(hook *Webhook) error {
+		hook.secret = secret
+		return nil
+	}
+}
+
+// Tolerance sets how far the Date of a signed delivery may be from the current time, DefaultTolerance by default.
+func (WebhookOptions) Tolerance(tolerance time.Duration) Option {
+	return func(hook *Webhook) error {
+		if tolerance <= 0 {
+			return errors.New("tolerance must be positive")
+		}
+		hook.tolerance = tolerance
+		return nil
+	}
+}
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
@@ -0,0 +1,348 @@
+package drone
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	path   = "/webhooks"
+	secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+)
+
+var hook *Webhook
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	hook, err = New(Options.Secret(secret))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func TestWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+	}{
+		{
+			name:     "BuildCreated",
+			event:    BuildEvent,
+			typ:      BuildPayload{},
+			filename: "./testdata/build-created.json",
+		},
+		{
+			name:     "BuildUpdated",
+			event:    BuildEvent,
+			typ:      BuildPayload{},
+			filename: "./testdata/build-updated.json",
+		},
+		{
+			name:     "RepoEnabled",
+			event:    RepoEvent,
+			typ:      RepoPayload{},
+			filename: "./testdata/repo-enabled.json",
+		},
+		{
+			name:     "UserCreated",
+			event:    UserEvent,
+			typ:      UserPayload{},
+			filename: "./testdata/user-created.json",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+
+			r := whtest.Drone(tc.event, whtest.Fixture(t, tc.filename)).Secret(secret).Target(server.URL + path).Request()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, r.Body)
+			assert.NoError(err)
+			req.Header = r.Header
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	parse := func(event Event, filename string) interface{} {
+		pl, err := hook.Parse(whtest.Drone(event, whtest.Fixture(t, filename)).Secret(secret).Request(), event)
+		assert.NoError(err)
+		return pl
+	}
+
+	created := parse(BuildEvent, "./testdata/build-created.json").(BuildPayload)
+	assert.Equal(BuildEvent, created.Event)
+	assert.Equal("created", created.Action)
+	assert.Equal("octocat/hello-world", created.Repo.Slug)
+	assert.Equal(int64(101), created.Build.Number)
+	assert.Equal("pending", created.Build.Status)
+	assert.Zero(created.Build.Duration())
+	assert.Equal("https://drone.example.com", created.System.Link)
+
+	updated := parse(BuildEvent, "./testdata/build-updated.json").(BuildPayload)
+	assert.Equal("updated", updated.Action)
+	assert.Equal(created.Build.After, updated.Build.After)
+	assert.Equal("success", updated.Build.Status)
+	assert.Equal(183*time.Second, updated.Build.Duration())
+	assert.Len(updated.Build.Stages[0].Steps, 3)
+	assert.Equal("golang:1.24", updated.Build.Stages[0].Steps[1].Image)
+
+	repo := parse(RepoEvent, "./testdata/repo-enabled.json").(RepoPayload)
+	assert.Equal("enabled", repo.Action)
+	assert.True(repo.Repo.Active)
+
+	user := parse(UserEvent, "./testdata/user-created.json").(UserPayload)
+	assert.Equal("jdoe", user.User.Login)
+}
+
+func TestBadRequests(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/build-created.json")
+	delivery := func() *whtest.Delivery {
+		return whtest.Drone(BuildEvent, body).Secret(secret)
+	}
+
+	tests := []struct {
+		name string
+		r    *http.Request
+	}{
+		{name: "BadNoEventHeader", r: delivery().Header("X-Drone-Event", "").Request()},
+		{name: "UnsubscribedEvent", r: delivery().Header("X-Drone-Event", "repo").Request()},
+		{name: "BadBody", r: whtest.Drone(BuildEvent, "").Secret(secret).Request()},
+		{name: "MissingSignature", r: delivery().Unsigned()},
+		{name: "MissingDigest", r: delivery().Header("Digest", "").Request()},
+		{name: "OtherDigest", r: delivery().Header("Digest", "SHA-256=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=").Request()},
+		{name: "OtherDate", r: delivery().Header("Date", "Mon, 03 Jun 2024 08:44:01 GMT").Request()},
+		{name: "OtherTarget", r: delivery().Header("Signature", delivery().Target("/other").Request().Header.Get("Signature")).Request()},
+		{name: "OtherSecret", r: whtest.Drone(BuildEvent, body).Secret("other").Request()},
+		{name: "TamperedBody", r: delivery().Tampered()},
+		{name: "BadMethod", r: delivery().Method(http.MethodGet).Request()},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := hook.Parse(tc.r, BuildEvent)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSignature(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/user-created.json")
+	sign := func(params, signing string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		_, _ = mac.Write([]byte(signing))
+		return params + `,signature="` + base64.StdEncoding.EncodeToString(mac.Sum(nil)) + `"`
+	}
+	digest := whtest.Drone(UserEvent, body).Secret(secret).Request().Header.Get("Digest")
+	now := time.Date(2024, time.June, 3, 8, 44, 1, 0, time.UTC)
+	signed, err := New(Options.Secret(secret), Options.Tolerance(time.Minute))
+	require.NoError(t, err)
+	signed.now = func() time.Time { return now }
+
+	date := now.Format(http.TimeFormat)
+	expired, future, skewed := now.Add(-2*time.Minute).Format(http.TimeFormat), now.Add(2*time.Minute).Format(http.TimeFormat), now.Add(30*time.Second).Format(http.TimeFormat)
+	tests := []struct {
+		name      string
+		date      string
+		signature string
+		valid     bool
+		wantErr   error
+	}{
+		{
+			name:      "Valid",
+			date:      date,
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha256",headers="(request-target) date digest"`, "(request-target): post /webhooks\ndate: "+date+"\ndigest: "+digest),
+			valid:     true,
+		},
+		{
+			name:      "SpacedParameters",
+			date:      date,
+			signature: sign(`keyId="hmac-key", algorithm="hmac-sha256", headers="Date Digest"`, "date: "+date+"\ndigest: "+digest),
+			valid:     true,
+		},
+		{
+			name:      "ClockSkew",
+			date:      skewed,
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha256",headers="date digest"`, "date: "+skewed+"\ndigest: "+digest),
+			valid:     true,
+		},
+		{
+			name:      "Expired",
+			date:      expired,
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha256",headers="date digest"`, "date: "+expired+"\ndigest: "+digest),
+			wantErr:   ErrTimestampExpired,
+		},
+		{
+			name:      "Future",
+			date:      future,
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha256",headers="date digest"`, "date: "+future+"\ndigest: "+digest),
+			wantErr:   ErrTimestampExpired,
+		},
+		{
+			name:      "DigestOnly",
+			date:      date,
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha256",headers="digest"`, "digest: "+digest),
+		},
+		{
+			name:      "NotCoveringDigest",
+			date:      date,
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha256",headers="(request-target) date"`, "(request-target): post /webhooks\ndate: "+date),
+		},
+		{
+			name:      "DefaultHeaders",
+			date:      date,
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha256"`, "date: "+date),
+		},
+		{
+			name:      "MissingSignedHeader",
+			date:      date,
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha256",headers="x-drone-delivery date digest"`, "x-drone-delivery: \ndate: "+date+"\ndigest: "+digest),
+		},
+		{
+			name:      "InvalidDate",
+			date:      "yesterday",
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha256",headers="date digest"`, "date: yesterday\ndigest: "+digest),
+		},
+		{
+			name:      "UnsupportedAlgorithm",
+			date:      date,
+			signature: sign(`keyId="hmac-key",algorithm="hmac-sha1",headers="date digest"`, "date: "+date+"\ndigest: "+digest),
+		},
+		{
+			name:      "Malformed",
+			date:      date,
+			signature: `keyId=hmac-key,algorithm="hmac-sha256"`,
+		},
+		{
+			name:      "NoSignature",
+			date:      date,
+			signature: `keyId="hmac-key",algorithm="hmac-sha256",headers="date digest"`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			r := whtest.Drone(UserEvent, body).Secret(secret).Header("Date", tc.date).Header("Signature", tc.signature).Request()
+			_, err := signed.Parse(r, UserEvent)
+			switch {
+			case tc.valid:
+				assert.NoError(err)
+			case tc.wantErr != nil:
+				assert.ErrorIs(err, tc.wantErr)
+			default:
+				assert.Error(err)
+			}
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	_, err := New(Options.Tolerance(0))
+	require.Error(t, err)
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("octocat/hello-world").User("jdoe")
+	created := faker.BuildPayload("pending")
+	assert.Equal("created", created.Action)
+	assert.Equal("octocat/hello-world", created.Repo.Slug)
+	assert.Equal(int64(1), created.Build.Number)
+	assert.Equal("jdoe", created.Build.AuthorLogin)
+
+	running := faker.BuildPayload("running")
+	success := faker.BuildPayload("success")
+	assert.Equal("updated", success.Action)
+	assert.Equal(created.Build.After, success.Build.After)
+	assert.Equal(running.Build.Started, success.Build.Started)
+	assert.Positive(success.Build.Duration())
+
+	next := faker.BuildPayload("pending")
+	assert.Equal(int64(2), next.Build.Number)
+	assert.Equal(created.Build.After, next.Build.Before)
+	assert.Equal(created, NewFaker("octocat/hello-world").User("jdoe").BuildPayload("pending"))
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: BuildEvent, payload: created},
+		{event: BuildEvent, payload: running},
+		{event: BuildEvent, payload: success},
+		{event: RepoEvent, payload: faker.RepoPayload("enabled")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Drone(tc.event, tc.payload).Secret(secret).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{BuildEvent, "./testdata/build-created.json"},
+		{BuildEvent, "./testdata/build-updated.json"},
+		{RepoEvent, "./testdata/repo-enabled.json"},
+		{UserEvent, "./testdata/user-created.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), payload)
+	}
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event string, payload []byte) {
+		r := whtest.Drone(event, payload).Secret(secret).Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package drone
+
+import (
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic webhooks of a repository to unit test handlers.
+// The webhooks of a faker agree with each other on the repository, the author and the number, commits and start
+// of the current build, and encode to the JSON Drone sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same webhooks.
+type Faker struct {
+	repo   Repository
+	author string
+	build  Build
+	system System
+	src    *fake.Source
+}
+
+// NewFaker returns a faker of the GitHub repository with the given slug, e.g. "octocat/hello-world",
+// built by drone.example.com. Commits are pushed by the user "octocat".
+func NewFaker(slug string) *Faker {
+	namespace, name, ok := strings.Cut(slug, "/")
+	if !ok {
+		namespace, name = "octocat", slug
+	}
+
+	src := fake.New("drone/" + slug)
+	created := fake.Epoch.Unix()
+	f := &Faker{
+		repo: Repository{
+			ID:            fake.NameID(slug),
+			UID:           src.Hex(4),
+			UserID:        1,
+			Namespace:     namespace,
+			Name:          name,
+			Slug:          namespace + "/" + name,
+			HTTPURL:       "https://github.com/" + namespace + "/" + name + ".git",
+			SSHURL:        "git@github.com:" + namespace + "/" + name + ".git",
+			Link:          "https://github.com/" + namespace + "/" + name,
+			DefaultBranch: "main",
+			Visibility:    "public",
+			Active:        true,
+			ConfigPath:    ".drone.yml",
+			Timeout:       60,
+			Synced:        created,
+			Created:       created,
+			Updated:       created,
+			Version:       1,
+		},
+		system: System{Proto: "https", Host: "drone.example.com", Link: "https://drone.example.com", Version: "2.24.0"},
+		src:    src,
+	}
+	return f.User("octocat")
+}
+
+// User sets the login of the user pushing the commits built.
+func (f *Faker) User(login string) *Faker {
+	f.author = login
+	return f
+}
+
+// RepoPayload returns the repository with the action, e.g. "enabled" or "disabled".
+func (f *Faker) RepoPayload(action string) RepoPayload {
+	repo := f.repo
+	return RepoPayload{BasicEvent: f.event(RepoEvent, action), Repo: &repo}
+}
+
+// BuildPayload returns the build of the status: "pending" creates a new build of a new commit pushed to the
+// default branch, the other statuses update the current build, e.g. "running", then "success" or "failure".
+func (f *Faker) BuildPayload(status string) BuildPayload {
+	action := "updated"
+	if status == "pending" || f.build.Number == 0 {
+		action = "created"
+		f.next()
+	}
+
+	now := f.src.Time().Unix()
+	f.build.Status = status
+	f.build.Updated = now
+	f.build.Version++
+	switch status {
+	case "pending":
+	case "running":
+		f.build.Started = now
+	default:
+		if f.build.Started == 0 {
+			f.build.Started = now
+		}
+		f.build.Finished = now
+	}
+
+	repo, build := f.repo, f.build
+	return BuildPayload{BasicEvent: f.event(BuildEvent, action), Repo: &repo, Build: &build}
+}
+
+// next creates the next build of the repository.
+func (f *Faker) next() {
+	f.repo.Counter++
+	f.repo.Version++
+	before, after := f.build.After, f.src.SHA()
+	if before == "" {
+		before = f.src.SHA()
+	}
+
+	now := f.src.Time().Unix()
+	f.build = Build{
+		ID:           f.src.ID(),
+		RepoID:       f.repo.ID,
+		Trigger:      "@hook",
+		Number:       f.repo.Counter,
+		Event:        "push",
+		Link:         f.repo.Link + "/compare/" + before[:12] + "..." + after[:12],
+		Message:      "Update " + f.repo.DefaultBranch + "\n",
+		Before:       before,
+		After:        after,
+		Ref:          "refs/heads/" + f.repo.DefaultBranch,
+		Source:       f.repo.DefaultBranch,
+		Target:       f.repo.DefaultBranch,
+		AuthorLogin:  f.author,
+		AuthorName:   f.author,
+		AuthorEmail:  f.author + "@example.com",
+		AuthorAvatar: "https://avatars.githubusercontent.com/" + f.author,
+		Sender:       f.author,
+		Created:      now,
+	}
+	f.repo.Updated = now
+}
+
+func (f *Faker) event(event Event, action string) BasicEvent {
+	system := f.system
+	return BasicEvent{Event: event, Action: action, System: &system}
+}
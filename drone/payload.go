@@ -0,0 +1,177 @@
+package drone
+
+import "time"
+
+// System contains the Drone server sending a webhook, Link is its URL.
+type System struct {
+	Proto   string `json:"proto,omitempty"`
+	Host    string `json:"host,omitempty"`
+	Link    string `json:"link,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// BasicEvent contains the event, action and server common to all Drone webhooks,
+// Action is "created", "updated" or "deleted", and "enabled" or "disabled" for repositories.
+type BasicEvent struct {
+	Event  Event   `json:"event"`
+	Action string  `json:"action"`
+	System *System `json:"system,omitempty"`
+}
+
+// User contains a Drone user. Created, Updated and LastLogin are the seconds since the epoch.
+type User struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Email     string `json:"email"`
+	Machine   bool   `json:"machine"`
+	Admin     bool   `json:"admin"`
+	Active    bool   `json:"active"`
+	Avatar    string `json:"avatar"`
+	Syncing   bool   `json:"syncing"`
+	Synced    int64  `json:"synced"`
+	Created   int64  `json:"created"`
+	Updated   int64  `json:"updated"`
+	LastLogin int64  `json:"last_login"`
+}
+
+// Repository contains a repository of the Drone server, Slug is its namespace and name, e.g. "octocat/hello-world".
+// Counter is the number of the last build.
+type Repository struct {
+	ID            int64  `json:"id"`
+	UID           string `json:"uid"`
+	UserID        int64  `json:"user_id"`
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name"`
+	Slug          string `json:"slug"`
+	SCM           string `json:"scm"`
+	HTTPURL       string `json:"git_http_url"`
+	SSHURL        string `json:"git_ssh_url"`
+	Link          string `json:"link"`
+	DefaultBranch string `json:"default_branch"`
+	Private       bool   `json:"private"`
+	Visibility    string `json:"visibility"`
+	Active        bool   `json:"active"`
+	ConfigPath    string `json:"config_path"`
+	Trusted       bool   `json:"trusted"`
+	Protected     bool   `json:"protected"`
+	Timeout       int64  `json:"timeout"`
+	Counter       int64  `json:"counter"`
+	Synced        int64  `json:"synced"`
+	Created       int64  `json:"created"`
+	Updated       int64  `json:"updated"`
+	Version       int64  `json:"version"`
+}
+
+// Step contains a step of a stage.
+type Step struct {
+	ID        int64  `json:"id"`
+	StageID   int64  `json:"step_id"`
+	Number    int    `json:"number"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	ErrIgnore bool   `json:"errignore,omitempty"`
+	ExitCode  int    `json:"exit_code"`
+	Started   int64  `json:"started,omitempty"`
+	Stopped   int64  `json:"stopped,omitempty"`
+	Version   int64  `json:"version"`
+	Image     string `json:"image,omitempty"`
+}
+
+// Stage contains a stage, a pipeline of the configuration, of a build and the runner it ran on.
+type Stage struct {
+	ID        int64             `json:"id"`
+	RepoID    int64             `json:"repo_id"`
+	BuildID   int64             `json:"build_id"`
+	Number    int               `json:"number"`
+	Name      string            `json:"name"`
+	Kind      string            `json:"kind,omitempty"`
+	Type      string            `json:"type,omitempty"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	ErrIgnore bool              `json:"errignore"`
+	ExitCode  int               `json:"exit_code"`
+	Machine   string            `json:"machine,omitempty"`
+	OS        string            `json:"os"`
+	Arch      string            `json:"arch"`
+	Started   int64             `json:"started"`
+	Stopped   int64             `json:"stopped"`
+	Created   int64             `json:"created"`
+	Updated   int64             `json:"updated"`
+	Version   int64             `json:"version"`
+	OnSuccess bool              `json:"on_success"`
+	OnFailure bool              `json:"on_failure"`
+	DependsOn []string          `json:"depends_on,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Steps     []*Step           `json:"steps,omitempty"`
+}
+
+// Build contains a build of a repository. Status is e.g. "pending", "running", "success", "failure", "error"
+// or "killed", and Event the event it runs for, e.g. "push", "pull_request", "tag", "promote", "rollback" or "cron".
+// Before and After are the commits of a push, Source and Target the branches of a pull request.
+// Started and Finished are the seconds since the epoch, zero until the build starts and finishes.
+type Build struct {
+	ID           int64             `json:"id"`
+	RepoID       int64             `json:"repo_id"`
+	Trigger      string            `json:"trigger"`
+	Number       int64             `json:"number"`
+	Parent       int64             `json:"parent,omitempty"`
+	Status       string            `json:"status"`
+	Error        string            `json:"error,omitempty"`
+	Event        string            `json:"event"`
+	Action       string            `json:"action"`
+	Link         string            `json:"link"`
+	Timestamp    int64             `json:"timestamp"`
+	Title        string            `json:"title,omitempty"`
+	Message      string            `json:"message"`
+	Before       string            `json:"before"`
+	After        string            `json:"after"`
+	Ref          string            `json:"ref"`
+	Fork         string            `json:"source_repo"`
+	Source       string            `json:"source"`
+	Target       string            `json:"target"`
+	AuthorLogin  string            `json:"author_login"`
+	AuthorName   string            `json:"author_name"`
+	AuthorEmail  string            `json:"author_email"`
+	AuthorAvatar string            `json:"author_avatar"`
+	Sender       string            `json:"sender"`
+	Params       map[string]string `json:"params,omitempty"`
+	Cron         string            `json:"cron,omitempty"`
+	Deploy       string            `json:"deploy_to,omitempty"`
+	DeployID     int64             `json:"deploy_id,omitempty"`
+	Debug        bool              `json:"debug,omitempty"`
+	Started      int64             `json:"started"`
+	Finished     int64             `json:"finished"`
+	Created      int64             `json:"created"`
+	Updated      int64             `json:"updated"`
+	Version      int64             `json:"version"`
+	Stages       []*Stage          `json:"stages,omitempty"`
+}
+
+// Duration returns how long the build ran, zero until it finished.
+func (b Build) Duration() time.Duration {
+	if b.Started == 0 || b.Finished == 0 {
+		return 0
+	}
+	return time.Duration(b.Finished-b.Started) * time.Second
+}
+
+// UserPayload contains the information for Drone's user event.
+type UserPayload struct {
+	BasicEvent
+	User *User `json:"user"`
+}
+
+// RepoPayload contains the information for Drone's repo event.
+type RepoPayload struct {
+	BasicEvent
+	Repo *Repository `json:"repo"`
+}
+
+// BuildPayload contains the information for Drone's build event, sent when a build is created
+// and updated on every change of its status or the status of its stages.
+type BuildPayload struct {
+	BasicEvent
+	Repo  *Repository `json:"repo"`
+	Build *Build      `json:"build"`
+}
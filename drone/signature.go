@@ -0,0 +1,33 @@
+package drone
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/pchchv/wh/internal/httpsig"
+)
+
+// verify checks the Digest header against the payload, the Signature header, which must cover it and the Date header,
+// against the HMAC-SHA256 of the signed headers, and the freshness of the Date.
+func (hook Webhook) verify(r *http.Request, payload []byte) error {
+	date, err := httpsig.Verify(r, payload, "hmac-sha256", func(signing, signature string) error {
+		mac := hmac.New(sha256.New, []byte(hook.secret))
+		_, _ = mac.Write([]byte(signing))
+		expectedMAC := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(signature), []byte(expectedMAC)) {
+			return errors.New("HMAC verification failed")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if httpsig.Expired(date, hook.now(), hook.tolerance) {
+		return ErrTimestampExpired
+	}
+	return nil
+}
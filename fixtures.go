@@ -0,0 +1,10 @@
+// Package wh holds the testdata fixtures of the provider packages, e.g. github and gitlab,
+// embedded for the wh command to send them without a checkout of the module.
+package wh
+
+import "embed"
+
+// Fixtures contains the JSON fixtures of the provider packages by path, e.g. github/testdata/push.json.
+//
+//go:embed */testdata/*.json
+var Fixtures embed.FS
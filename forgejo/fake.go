@@ -0,0 +1,183 @@
+package forgejo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/gitea"
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic payloads of a repository to unit test handlers.
+// The payloads of a faker agree with each other on the repository, users, refs and SHAs,
+// and encode to the JSON Forgejo sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same payloads.
+type Faker struct {
+	host   string
+	owner  string
+	name   string
+	id     int64
+	sender *User
+	src    *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given full name, e.g. "forgejo/forgejo", hosted on codeberg.org.
+// Events are triggered by the repository owner.
+func NewFaker(fullName string) *Faker {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok {
+		owner, name = "forgejo", fullName
+	}
+
+	src := fake.New("forgejo/" + fullName)
+	f := &Faker{host: "https://codeberg.org", owner: owner, name: name, id: src.ID(), src: src}
+	f.sender = f.user(owner)
+	return f
+}
+
+// Sender sets the login of the user triggering the events.
+func (f *Faker) Sender(login string) *Faker {
+	f.sender = f.user(login)
+	return f
+}
+
+// SHA returns a new commit SHA.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// PushPayload returns the push of commits to ref, a branch name or a full ref such as "refs/tags/v1.0.0".
+// The commits follow each other, the last one being the head commit and the after SHA.
+func (f *Faker) PushPayload(ref string, commits int) PushPayload {
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	pl := PushPayload{PushPayload: gitea.PushPayload{
+		Ref:     ref,
+		Before:  f.SHA(),
+		Commits: []*PayloadCommit{},
+		Pusher:  f.sender,
+		Sender:  f.sender,
+		Repo:    f.repository(),
+	}}
+
+	pl.After = pl.Before
+	for i := 0; i < commits; i++ {
+		c := f.commit(fmt.Sprintf("Change %d", i+1))
+		pl.Commits = append(pl.Commits, c)
+		pl.HeadCommit = c
+		pl.After = c.ID
+	}
+	pl.TotalCommits = commits
+	pl.CompareURL = fmt.Sprintf("%s/compare/%s...%s", f.htmlURL(), pl.Before, pl.After)
+	return pl
+}
+
+// PullRequestPayload returns a pull request event merging the head branch into the base branch.
+// Closing actions mark the pull request as merged.
+func (f *Faker) PullRequestPayload(action HookIssueAction, head, base string) PullRequestPayload {
+	id := f.src.ID()
+	number := id%1000 + 1
+	created := f.src.Time()
+	html := fmt.Sprintf("%s/pulls/%d", f.htmlURL(), number)
+	repo := f.repository()
+	pr := &PullRequest{
+		ID:        id,
+		Index:     number,
+		URL:       html,
+		HTMLURL:   html,
+		DiffURL:   html + ".diff",
+		PatchURL:  html + ".patch",
+		Title:     "Merge " + head + " into " + base,
+		Body:      "Changes of " + head + ".",
+		Mergeable: true,
+		State:     "open",
+		Created:   &created,
+		Updated:   &created,
+		Poster:    f.sender,
+		Assignees: []*User{},
+		Labels:    []*Label{},
+		MergeBase: f.SHA(),
+		Base:      &PRBranchInfo{Name: base, Ref: base, Sha: f.SHA(), RepoID: f.id, Repository: repo},
+		Head:      &PRBranchInfo{Name: head, Ref: head, Sha: f.SHA(), RepoID: f.id, Repository: repo},
+	}
+
+	if action == HookIssueClosed {
+		merged := f.src.Time()
+		sha := f.SHA()
+		pr.State = "closed"
+		pr.HasMerged = true
+		pr.Mergeable = false
+		pr.MergedCommitID = &sha
+		pr.MergedBy = f.sender
+		pr.Updated, pr.Merged, pr.Closed = &merged, &merged, &merged
+	}
+
+	return PullRequestPayload{PullRequestPayload: gitea.PullRequestPayload{
+		Index:       number,
+		Action:      action,
+		Sender:      f.sender,
+		Repository:  repo,
+		PullRequest: pr,
+	}}
+}
+
+func (f *Faker) commit(message string) *PayloadCommit {
+	sha := f.SHA()
+	author := &PayloadUser{Name: f.sender.FullName, Email: f.sender.Email, UserName: f.sender.UserName}
+	return &PayloadCommit{
+		ID:        sha,
+		URL:       f.htmlURL() + "/commit/" + sha,
+		Message:   message + "\n",
+		Added:     []string{},
+		Removed:   []string{},
+		Modified:  []string{"README.md"},
+		Author:    author,
+		Committer: author,
+		Timestamp: f.src.Time(),
+	}
+}
+
+func (f *Faker) repository() *Repository {
+	return &Repository{
+		ID:                f.id,
+		Owner:             f.user(f.owner),
+		Name:              f.name,
+		FullName:          f.owner + "/" + f.name,
+		Description:       "The " + f.name + " repository",
+		HTMLURL:           f.htmlURL(),
+		CloneURL:          f.htmlURL() + ".git",
+		SSHURL:            "git@" + strings.TrimPrefix(f.host, "https://") + ":" + f.owner + "/" + f.name + ".git",
+		DefaultBranch:     "main",
+		DefaultMergeStyle: "merge",
+		HasIssues:         true,
+		HasWiki:           true,
+		HasPullRequests:   true,
+		AllowMerge:        true,
+		AllowRebase:       true,
+		AllowSquash:       true,
+		Permissions:       &Permission{Admin: true, Push: true, Pull: true},
+		Created:           fake.Epoch,
+		Updated:           fake.Epoch,
+	}
+}
+
+func (f *Faker) user(login string) *User {
+	id := fake.NameID(login)
+	return &User{
+		ID:         id,
+		UserName:   login,
+		FullName:   login,
+		Email:      login + "@noreply.codeberg.org",
+		AvatarURL:  fmt.Sprintf("%s/avatars/%x", f.host, id),
+		Visibility: "public",
+		IsActive:   true,
+		Created:    fake.Epoch,
+		LastLogin:  fake.Epoch,
+	}
+}
+
+func (f *Faker) htmlURL() string {
+	return f.host + "/" + f.owner + "/" + f.name
+}
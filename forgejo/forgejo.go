@@ -0,0 +1,297 @@
+// The `forgejo` package verifies and parses the webhooks of Forgejo, e.g. Codeberg.
+//
+// Forgejo started as a fork of Gitea and most of its payloads are still identical,
+// they are aliases of the gitea types, the payloads that have diverged embed them.
+// Deliveries are identified by the X-Forgejo-Event and signed in the X-Forgejo-Signature header.
+package forgejo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = WebhookOptions{}
+	// ErrActionNotDefined is returned by ParseActions when the action
+	// of a parsed event is not one of the actions asked to be parsed.
+	ErrActionNotDefined = errors.New("action not defined to be parsed")
+	// ErrParsingPayload is returned when a payload cannot be read or decoded.
+	ErrParsingPayload = errors.New("error parsing payload")
+)
+
+const (
+	// Forgejo hook types.
+	ForkEvent                 Event = "fork"
+	PushEvent                 Event = "push"
+	CreateEvent               Event = "create"
+	DeleteEvent               Event = "delete"
+	IssuesEvent               Event = "issues"
+	ReleaseEvent              Event = "release"
+	RepositoryEvent           Event = "repository"
+	IssueLabelEvent           Event = "issue_label"
+	IssueAssignEvent          Event = "issue_assign"
+	PullRequestEvent          Event = "pull_request"
+	IssueCommentEvent         Event = "issue_comment"
+	IssueMilestoneEvent       Event = "issue_milestone"
+	PullRequestSyncEvent      Event = "pull_request_sync"
+	PullRequestLabelEvent     Event = "pull_request_label"
+	PullRequestAssignEvent    Event = "pull_request_assign"
+	PullRequestReviewEvent    Event = "pull_request_review"
+	PullRequestCommentEvent   Event = "pull_request_comment"
+	PullRequestMilestoneEvent Event = "pull_request_milestone"
+	// Forgejo sends reviews and review requests as events of their own and the
+	// wiki and package registry events.
+	WikiEvent                      Event = "wiki"
+	PackageEvent                   Event = "package"
+	PullRequestReviewRequestEvent  Event = "pull_request_review_request"
+	PullRequestReviewCommentEvent  Event = "pull_request_review_comment"
+	PullRequestReviewApprovedEvent Event = "pull_request_review_approved"
+	PullRequestReviewRejectedEvent Event = "pull_request_review_rejected"
+)
+
+// Event defines a Forgejo hook event type by the X-Forgejo-Event Header.
+type Event string
+
+// EventActions pairs an event with the actions of it that should be parsed.
+// An empty Actions list matches every action of the event.
+type EventActions = observe.EventActions[Event, Action]
+
+// On returns an EventActions filter for the event and the given actions of any of the Hook*Action types,
+// e.g. On(PullRequestEvent, HookIssueOpened, HookIssueSynchronized), or On[Action](PullRequestEvent) for every action.
+func On[A ~string](event Event, actions ...A) EventActions {
+	f := EventActions{Event: event}
+	for _, a := range actions {
+		f.Actions = append(f.Actions, Action(a))
+	}
+	return f
+}
+
+// provider describes the deliveries of Forgejo to the instrumentation.
+var provider = &observe.Provider{
+	Name:           "forgejo",
+	EventHeader:    "X-Forgejo-Event",
+	DeliveryHeader: "X-Forgejo-Delivery",
+	SecretHeaders:  []string{"X-Forgejo-Signature", "X-Forgejo-Signature", "X-Hub-Signature", "X-Hub-Signature-256", "X-Gogs-Signature"},
+}
+
+// Webhook instance contains all methods needed to process events.
+type Webhook struct {
+	secret   string
+	observer observe.Observer
+}
+
+// Parse verifies and parses the events specified and returns the payload object or an error.
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
+	defer func() {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}()
+
+	if len(events) == 0 {
+		return nil, errors.New("no Event specified to parse")
+	}
+
+	if r.Method != http.MethodPost {
+		return nil, errors.New("invalid HTTP Method")
+	}
+
+	event := r.Header.Get("X-Forgejo-Event")
+	if len(event) == 0 {
+		return nil, errors.New("missing X-Forgejo-Event Header")
+	}
+
+	var found bool
+	forgejoEvent := Event(event)
+	for _, evt := range events {
+		if evt == forgejoEvent {
+			found = true
+			break
+		}
+	}
+
+	// event not defined to be parsed
+	if !found {
+		return nil, errors.New("event not defined to be parsed")
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil || len(payload) == 0 {
+		return nil, ErrParsingPayload
+	}
+
+	// if Secret set exists, MAC must be checked
+	err = d.Verify(len(hook.secret) > 0, func() error {
+		signature := r.Header.Get("X-Forgejo-Signature")
+		if len(signature) == 0 {
+			return errors.New("missing X-Forgejo-Signature Header")
+		}
+
+		sig256 := hmac.New(sha256.New, []byte(hook.secret))
+		_, _ = io.Writer(sig256).Write([]byte(payload))
+		expectedMAC := hex.EncodeToString(sig256.Sum(nil))
+		if !hmac.Equal([]byte(signature), []byte(expectedMAC)) {
+			return errors.New("HMAC verification failed")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.Decoding()
+	switch forgejoEvent {
+	case CreateEvent:
+		var pl CreatePayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case DeleteEvent:
+		var pl DeletePayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case ForkEvent:
+		var pl ForkPayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case PushEvent:
+		var pl PushPayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case IssuesEvent, IssueAssignEvent, IssueLabelEvent, IssueMilestoneEvent:
+		var pl IssuePayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case IssueCommentEvent, PullRequestCommentEvent:
+		var pl IssueCommentPayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case PullRequestEvent, PullRequestAssignEvent, PullRequestLabelEvent, PullRequestMilestoneEvent, PullRequestReviewEvent, PullRequestSyncEvent,
+		PullRequestReviewRequestEvent, PullRequestReviewCommentEvent, PullRequestReviewApprovedEvent, PullRequestReviewRejectedEvent:
+		var pl PullRequestPayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case WikiEvent:
+		var pl WikiPayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case PackageEvent:
+		var pl PackagePayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case RepositoryEvent:
+		var pl RepositoryPayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	case ReleaseEvent:
+		var pl ReleasePayload
+		err = json.Unmarshal([]byte(payload), &pl)
+		return pl, err
+	default:
+		return nil, fmt.Errorf("unknown event %s", forgejoEvent)
+	}
+}
+
+// ParseActions verifies and parses the events specified in filters
+// and returns the payload object only if its action matches one of the actions
+// registered for the event, otherwise ErrActionNotDefined is returned.
+func (hook Webhook) ParseActions(r *http.Request, filters ...EventActions) (interface{}, error) {
+	pl, err := hook.Parse(r, observe.Events(filters)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !observe.Matches(filters, Event(r.Header.Get("X-Forgejo-Event")), pl) {
+		return nil, ErrActionNotDefined
+	}
+	return pl, nil
+}
+
+// ActionOf returns the action of a parsed payload,
+// or an empty Action if the payload has no action.
+func ActionOf(payload interface{}) Action {
+	return Action(observe.Action(payload))
+}
+
+// Option is a configuration option for the webhook.
+type Option func(*Webhook) error
+
+// New creates and returns a WebHook instance denoted by the Provider type.
+func New(options ...Option) (*Webhook, error) {
+	hook := new(Webhook)
+	for _, opt := range options {
+		if err := opt(hook); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+	return hook, nil
+}
+
+// WebhookOptions is a namespace for configuration option methods.
+type WebhookOptions struct{}
+
+// Secret registers the Forgejo secret.
+func (WebhookOptions) Secret(secret string) Option {
+	return func(hook *Webhook) error {
+		hook.secret = secret
+		return nil
+	}
+}
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
@@ -0,0 +1,576 @@
+package forgejo
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const path = "/webhooks"
+
+var hook *Webhook
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	hook, err = New(Options.Secret("IsWishesWereHorsesWedAllBeEatingSteak!"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func TestWebhooks(t *testing.T) {
+	assert := require.New(t)
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+		headers  http.Header
+	}{
+		{
+			name:     "CreateEvent",
+			event:    CreateEvent,
+			typ:      CreatePayload{},
+			filename: "./testdata/create-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"create"},
+				"X-Forgejo-Signature": []string{"eb0319d8bd02c41188c0e67cb2d7381212b872752a8b1f1535c8a1d385fe7c52"},
+			},
+		},
+		{
+			name:     "DeleteEvent",
+			event:    DeleteEvent,
+			typ:      DeletePayload{},
+			filename: "./testdata/delete-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"delete"},
+				"X-Forgejo-Signature": []string{"6c2517561b06c84a818e0f3216f7e277c40f3ba46ae12c7adab4acc407b43f69"},
+			},
+		},
+		{
+			name:     "ForkEvent",
+			event:    ForkEvent,
+			typ:      ForkPayload{},
+			filename: "./testdata/fork-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"fork"},
+				"X-Forgejo-Signature": []string{"eeb23621fe28361158297c5eb3468568d96da5107d63a42024ebc1a2501ddc96"},
+			},
+		},
+		{
+			name:     "IssuesEvent",
+			event:    IssuesEvent,
+			typ:      IssuePayload{},
+			filename: "./testdata/issues-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"issues"},
+				"X-Forgejo-Signature": []string{"fa87e0088b1fe630e799e5c23510a810ff4578dc710f23a43f2096d8d9b1be53"},
+			},
+		},
+		{
+			name:     "IssueAssignEvent",
+			event:    IssueAssignEvent,
+			typ:      IssuePayload{},
+			filename: "./testdata/issue-assign-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"issue_assign"},
+				"X-Forgejo-Signature": []string{"25f3bdf4fae2e6a5ce598784a883fb2a7b955b2ba6c521e263772ac77ebfd776"},
+			},
+		},
+		{
+			name:     "IssueLabelEvent",
+			event:    IssueLabelEvent,
+			typ:      IssuePayload{},
+			filename: "./testdata/issue-label-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"issue_label"},
+				"X-Forgejo-Signature": []string{"4c0c74d0275eef96b6084f8e1fd8960a9b3f74f7e0ac9fa7d9a1a50e6f231319"},
+			},
+		},
+		{
+			name:     "IssueMilestoneEvent",
+			event:    IssueMilestoneEvent,
+			typ:      IssuePayload{},
+			filename: "./testdata/issue-milestone-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"issue_milestone"},
+				"X-Forgejo-Signature": []string{"1e7311c5deb0f51aa09aad36fd30065f58afd6ca5b9aa80796f8b4a695ed9cd7"},
+			},
+		},
+		{
+			name:     "IssueCommentEvent",
+			event:    IssueCommentEvent,
+			typ:      IssueCommentPayload{},
+			filename: "./testdata/issue-comment-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"issue_comment"},
+				"X-Forgejo-Signature": []string{"532548782b25429119dcf22131fe937d2be0a1b3d64b4fb55ed205565f88400b"},
+			},
+		},
+		{
+			name:     "PushEvent",
+			event:    PushEvent,
+			typ:      PushPayload{},
+			filename: "./testdata/push-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"push"},
+				"X-Forgejo-Signature": []string{"9f916f669fec9578ff2b6e6085a588b8cf645f920d00e574755808d1294f3b8a"},
+			},
+		},
+		{
+			name:     "PullRequestEvent",
+			event:    PullRequestEvent,
+			typ:      PullRequestPayload{},
+			filename: "./testdata/pull-request-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request"},
+				"X-Forgejo-Signature": []string{"a823d252fa77aec43413f10aa6ff7347499c0d2422b64e9ea74c080f66778346"},
+			},
+		},
+		{
+			name:     "PullRequestAssignEvent",
+			event:    PullRequestAssignEvent,
+			typ:      PullRequestPayload{},
+			filename: "./testdata/pull-request-assign-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request_assign"},
+				"X-Forgejo-Signature": []string{"6748a01ece6a22acc1532626cabed6846c43ea3d52033537d0e7f26443fe0eaf"},
+			},
+		},
+		{
+			name:     "PullRequestLabelEvent",
+			event:    PullRequestLabelEvent,
+			typ:      PullRequestPayload{},
+			filename: "./testdata/pull-request-label-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request_label"},
+				"X-Forgejo-Signature": []string{"283ea0c9e032d1f50e1e0cf14204cb0cdc928d0fcbaa230c406d0badf177ac21"},
+			},
+		},
+		{
+			name:     "PullRequestMilestoneEvent",
+			event:    PullRequestMilestoneEvent,
+			typ:      PullRequestPayload{},
+			filename: "./testdata/pull-request-milestone-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request_milestone"},
+				"X-Forgejo-Signature": []string{"3954c25f4ff3ee401afc10b0814349e59fd166e336b0523485f523c1630a8ab0"},
+			},
+		},
+		{
+			name:     "PullRequestCommentEvent",
+			event:    PullRequestCommentEvent,
+			typ:      IssueCommentPayload{},
+			filename: "./testdata/pull-request-comment-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request_comment"},
+				"X-Forgejo-Signature": []string{"38a046803eaf80c818bf246db1a3edd0b62c6c03d0b4345f44d0219bfb6a3506"},
+			},
+		},
+		{
+			name:     "PullRequestReviewEvent",
+			event:    PullRequestReviewEvent,
+			typ:      PullRequestPayload{},
+			filename: "./testdata/pull-request-review-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request_review"},
+				"X-Forgejo-Signature": []string{"5027753d3694283c42e0a9915053b35387bb9011eca0501bf2bd6bec05f32eef"},
+			},
+		},
+		{
+			name:     "PullRequestReviewApprovedEvent",
+			event:    PullRequestReviewApprovedEvent,
+			typ:      PullRequestPayload{},
+			filename: "./testdata/pull-request-review-approved-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request_review_approved"},
+				"X-Forgejo-Signature": []string{"d2ad431dd833c8f9535e649f3fa2f9978cd8e96c9b381c3d8791a6bda5a57315"},
+			},
+		},
+		{
+			name:     "PullRequestReviewRequestEvent",
+			event:    PullRequestReviewRequestEvent,
+			typ:      PullRequestPayload{},
+			filename: "./testdata/pull-request-review-request-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request_review_request"},
+				"X-Forgejo-Signature": []string{"98980bbcdf11fb1942bbf1c5e823632a0cfa82df97fc48c43be07c9a67156fab"},
+			},
+		},
+		{
+			name:     "RepositoryEvent",
+			event:    RepositoryEvent,
+			typ:      RepositoryPayload{},
+			filename: "./testdata/repository-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"repository"},
+				"X-Forgejo-Signature": []string{"f539afab33794be0e8adf6ca834eb6dc2474b9a6495503af90ec633f2542079d"},
+			},
+		},
+		{
+			name:     "ReleaseEvent",
+			event:    ReleaseEvent,
+			typ:      ReleasePayload{},
+			filename: "./testdata/release-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"release"},
+				"X-Forgejo-Signature": []string{"999331086993a862a941a08a61b89a411be6ce84463aa3a5c06c87ce02812a98"},
+			},
+		},
+		{
+			name:     "WikiEvent",
+			event:    WikiEvent,
+			typ:      WikiPayload{},
+			filename: "./testdata/wiki-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"wiki"},
+				"X-Forgejo-Signature": []string{"3befd988e1571b3ab45a7c694f1af119957ac119773790269ea40d61c8659b14"},
+			},
+		},
+		{
+			name:     "PackageEvent",
+			event:    PackageEvent,
+			typ:      PackagePayload{},
+			filename: "./testdata/package-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"package"},
+				"X-Forgejo-Signature": []string{"3ba1578f1e8f735943e9ed721c31de2b398a4339fca0ac39376e419faa1a738f"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		client := &http.Client{}
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			payload, err := os.Open(tc.filename)
+			assert.NoError(err)
+			defer func() {
+				_ = payload.Close()
+			}()
+
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, payload)
+			assert.NoError(err)
+			req.Header = tc.headers
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := client.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	assert := require.New(t)
+	tests := []struct {
+		name    string
+		event   Event
+		payload io.Reader
+		headers http.Header
+	}{
+		{
+			name:    "BadNoEventHeader",
+			event:   PushEvent,
+			payload: bytes.NewBuffer([]byte("{}")),
+			headers: http.Header{},
+		},
+		{
+			name:    "UnsubscribedEvent",
+			event:   PushEvent,
+			payload: bytes.NewBuffer([]byte("{}")),
+			headers: http.Header{
+				"X-Forgejo-Event": []string{"noneexistant_event"},
+			},
+		},
+		{
+			name:    "BadBody",
+			event:   PushEvent,
+			payload: bytes.NewBuffer([]byte("")),
+			headers: http.Header{
+				"X-Forgejo-Event": []string{"push"},
+			},
+		},
+		{
+			name:    "BadSignatureLength",
+			event:   PushEvent,
+			payload: bytes.NewBuffer([]byte("{}")),
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"push"},
+				"X-Forgejo-Signature": []string{""},
+			},
+		},
+		{
+			name:    "BadSignatureMatch",
+			event:   PushEvent,
+			payload: bytes.NewBuffer([]byte("{}")),
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"push"},
+				"X-Forgejo-Signature": []string{"111"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		client := &http.Client{}
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var parseError error
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				_, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, tc.payload)
+			assert.NoError(err)
+			req.Header = tc.headers
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := client.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.Error(parseError)
+		})
+	}
+}
+
+func TestParseActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []EventActions
+		filename string
+		headers  http.Header
+		action   Action
+		wantErr  error
+	}{
+		{
+			name:     "MatchingAction",
+			filters:  []EventActions{On(PullRequestEvent, HookIssueOpened, HookIssueReOpened)},
+			filename: "./testdata/pull-request-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request"},
+				"X-Forgejo-Signature": []string{"a823d252fa77aec43413f10aa6ff7347499c0d2422b64e9ea74c080f66778346"},
+			},
+			action: Action(HookIssueOpened),
+		},
+		{
+			name:     "AnyAction",
+			filters:  []EventActions{On[Action](PullRequestEvent)},
+			filename: "./testdata/pull-request-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request"},
+				"X-Forgejo-Signature": []string{"a823d252fa77aec43413f10aa6ff7347499c0d2422b64e9ea74c080f66778346"},
+			},
+			action: Action(HookIssueOpened),
+		},
+		{
+			name:     "UnmatchedAction",
+			filters:  []EventActions{On(PullRequestEvent, HookIssueSynchronized)},
+			filename: "./testdata/pull-request-event.json",
+			headers: http.Header{
+				"X-Forgejo-Event":     []string{"pull_request"},
+				"X-Forgejo-Signature": []string{"a823d252fa77aec43413f10aa6ff7347499c0d2422b64e9ea74c080f66778346"},
+			},
+			wantErr: ErrActionNotDefined,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.ReadFile(tc.filename)
+			assert.NoError(err)
+
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+			req.Header = tc.headers
+			results, err := hook.ParseActions(req, tc.filters...)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(tc.action, ActionOf(results))
+		})
+	}
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	tests := []struct {
+		event    Event
+		filename string
+		check    func(pl interface{})
+	}{
+		{
+			event:    PushEvent,
+			filename: "./testdata/push-event.json",
+			check: func(pl interface{}) {
+				push := pl.(PushPayload)
+				assert.Equal(len(push.Commits), push.TotalCommits)
+				assert.Equal("refs/heads/master", push.Ref)
+			},
+		},
+		{
+			event:    PullRequestReviewRequestEvent,
+			filename: "./testdata/pull-request-review-request-event.json",
+			check: func(pl interface{}) {
+				pr := pl.(PullRequestPayload)
+				assert.Equal(HookIssueReviewRequested, pr.Action)
+				assert.Equal("example2", pr.RequestedReviewer.UserName)
+			},
+		},
+		{
+			event:    PullRequestReviewApprovedEvent,
+			filename: "./testdata/pull-request-review-approved-event.json",
+			check: func(pl interface{}) {
+				pr := pl.(PullRequestPayload)
+				assert.Equal("pull_request_review_approved", pr.Review.Type)
+				assert.Equal(pr.PullRequest.Head.Sha, pr.CommitID)
+			},
+		},
+		{
+			event:    WikiEvent,
+			filename: "./testdata/wiki-event.json",
+			check: func(pl interface{}) {
+				wiki := pl.(WikiPayload)
+				assert.Equal(HookWikiEdited, wiki.Action)
+				assert.Equal("Home", wiki.Page)
+			},
+		},
+		{
+			event:    PackageEvent,
+			filename: "./testdata/package-event.json",
+			check: func(pl interface{}) {
+				pkg := pl.(PackagePayload)
+				assert.Equal(HookPackageCreated, pkg.Action)
+				assert.Equal("1.0.0", pkg.Package.Version)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		r := whtest.Forgejo(tc.event, whtest.Fixture(t, tc.filename)).Secret(hook.secret).Request()
+		pl, err := hook.Parse(r, tc.event)
+		assert.NoError(err)
+		tc.check(pl)
+	}
+
+	// Gitea headers alone are not a Forgejo delivery
+	r := whtest.Gitea(PushEvent, whtest.Fixture(t, "./testdata/push-event.json")).Secret(hook.secret).Request()
+	_, err := hook.Parse(r, PushEvent)
+	assert.Error(err)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("forgejo/forgejo").Sender("fnetx")
+	push := faker.PushPayload("main", 3)
+	assert.Equal("refs/heads/main", push.Ref)
+	assert.Len(push.Commits, 3)
+	assert.Equal(push.After, push.Commits[2].ID)
+	assert.Equal(push.After, push.HeadCommit.ID)
+	assert.Equal(3, push.TotalCommits)
+	assert.Equal("forgejo/forgejo", push.Repo.FullName)
+	assert.Equal("fnetx", push.Sender.UserName)
+	assert.Equal(push, NewFaker("forgejo/forgejo").Sender("fnetx").PushPayload("main", 3))
+
+	pr := faker.PullRequestPayload(HookIssueClosed, "feature", "main")
+	assert.True(pr.PullRequest.HasMerged)
+	assert.Equal("feature", pr.PullRequest.Head.Ref)
+	assert.Equal("main", pr.PullRequest.Base.Ref)
+	assert.Equal(push.Repo.ID, pr.Repository.ID)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: PushEvent, payload: push},
+		{event: PullRequestEvent, payload: pr},
+		{event: PullRequestEvent, payload: faker.PullRequestPayload(HookIssueOpened, "feature", "main")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Forgejo(tc.event, tc.payload).Secret(hook.secret).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{CreateEvent, "./testdata/create-event.json"},
+		{DeleteEvent, "./testdata/delete-event.json"},
+		{ForkEvent, "./testdata/fork-event.json"},
+		{IssuesEvent, "./testdata/issues-event.json"},
+		{IssueAssignEvent, "./testdata/issue-assign-event.json"},
+		{IssueLabelEvent, "./testdata/issue-label-event.json"},
+		{IssueMilestoneEvent, "./testdata/issue-milestone-event.json"},
+		{IssueCommentEvent, "./testdata/issue-comment-event.json"},
+		{PushEvent, "./testdata/push-event.json"},
+		{PullRequestEvent, "./testdata/pull-request-event.json"},
+		{PullRequestAssignEvent, "./testdata/pull-request-assign-event.json"},
+		{PullRequestLabelEvent, "./testdata/pull-request-label-event.json"},
+		{PullRequestMilestoneEvent, "./testdata/pull-request-milestone-event.json"},
+		{PullRequestCommentEvent, "./testdata/pull-request-comment-event.json"},
+		{PullRequestReviewEvent, "./testdata/pull-request-review-event.json"},
+		{PullRequestReviewApprovedEvent, "./testdata/pull-request-review-approved-event.json"},
+		{PullRequestReviewRequestEvent, "./testdata/pull-request-review-request-event.json"},
+		{RepositoryEvent, "./testdata/repository-event.json"},
+		{ReleaseEvent, "./testdata/release-event.json"},
+		{WikiEvent, "./testdata/wiki-event.json"},
+		{PackageEvent, "./testdata/package-event.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), "", payload)
+	}
+	f.Add(string(seeds[0].event), "zz", []byte("{}"))
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event, signature string, payload []byte) {
+		d := whtest.Forgejo(event, payload).Secret(hook.secret)
+		if signature != "" {
+			d.Header("X-Forgejo-Signature", signature)
+		}
+
+		r := d.Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
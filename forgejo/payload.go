@@ -0,0 +1,143 @@
+package forgejo
+
+import (
+	"time"
+
+	"github.com/pchchv/wh/gitea"
+)
+
+// Types Forgejo shares unchanged with Gitea.
+type (
+	Label                     = gitea.Label
+	Permission                = gitea.Permission
+	User                      = gitea.User
+	Milestone                 = gitea.Milestone
+	Issue                     = gitea.Issue
+	Repository                = gitea.Repository
+	Comment                   = gitea.Comment
+	Release                   = gitea.Release
+	Attachment                = gitea.Attachment
+	PullRequest               = gitea.PullRequest
+	InternalTracker           = gitea.InternalTracker
+	ExternalTracker           = gitea.ExternalTracker
+	ExternalWiki              = gitea.ExternalWiki
+	RepositoryMeta            = gitea.RepositoryMeta
+	PullRequestMeta           = gitea.PullRequestMeta
+	PayloadUser               = gitea.PayloadUser
+	PayloadCommit             = gitea.PayloadCommit
+	PayloadCommitVerification = gitea.PayloadCommitVerification
+	PRBranchInfo              = gitea.PRBranchInfo
+	ReviewPayload             = gitea.ReviewPayload
+	ChangesPayload            = gitea.ChangesPayload
+	ChangesFromPayload        = gitea.ChangesFromPayload
+	IssueCommentPayload       = gitea.IssueCommentPayload
+	ReleasePayload            = gitea.ReleasePayload
+	RepositoryPayload         = gitea.RepositoryPayload
+	ForkPayload               = gitea.ForkPayload
+	CreatePayload             = gitea.CreatePayload
+	DeletePayload             = gitea.DeletePayload
+)
+
+// Action and state types Forgejo shares with Gitea.
+type (
+	PusherType             = gitea.PusherType
+	StateType              = gitea.StateType
+	Action                 = gitea.Action
+	HookRepoAction         = gitea.HookRepoAction
+	HookIssueAction        = gitea.HookIssueAction
+	HookReleaseAction      = gitea.HookReleaseAction
+	HookIssueCommentAction = gitea.HookIssueCommentAction
+)
+
+// HookWikiAction defines hook wiki action type.
+type HookWikiAction string
+
+// HookPackageAction defines hook package action type.
+type HookPackageAction string
+
+const (
+	// HookRepoAction values.
+	HookRepoCreated = gitea.HookRepoCreated
+	HookRepoDeleted = gitea.HookRepoDeleted
+	// HookIssueAction values.
+	HookIssueOpened               = gitea.HookIssueOpened
+	HookIssueClosed               = gitea.HookIssueClosed
+	HookIssueEdited               = gitea.HookIssueEdited
+	HookIssueReOpened             = gitea.HookIssueReOpened
+	HookIssueAssigned             = gitea.HookIssueAssigned
+	HookIssueReviewed             = gitea.HookIssueReviewed
+	HookIssueUnassigned           = gitea.HookIssueUnassigned
+	HookIssueMilestoned           = gitea.HookIssueMilestoned
+	HookIssueDemilestoned         = gitea.HookIssueDemilestoned
+	HookIssueSynchronized         = gitea.HookIssueSynchronized
+	HookIssueLabelUpdated         = gitea.HookIssueLabelUpdated
+	HookIssueLabelCleared         = gitea.HookIssueLabelCleared
+	HookIssueReviewRequested      = gitea.HookIssueReviewRequested
+	HookIssueReviewRequestRemoved = gitea.HookIssueReviewRequestRemoved
+	// HookReleaseAction values.
+	HookReleaseUpdated   = gitea.HookReleaseUpdated
+	HookReleaseDeleted   = gitea.HookReleaseDeleted
+	HookReleasePublished = gitea.HookReleasePublished
+	// HookIssueCommentAction values.
+	HookIssueCommentEdited  = gitea.HookIssueCommentEdited
+	HookIssueCommentCreated = gitea.HookIssueCommentCreated
+	HookIssueCommentDeleted = gitea.HookIssueCommentDeleted
+	// HookWikiAction values.
+	HookWikiCreated HookWikiAction = "created"
+	HookWikiEdited  HookWikiAction = "edited"
+	HookWikiDeleted HookWikiAction = "deleted"
+	// HookPackageAction values.
+	HookPackageCreated HookPackageAction = "created"
+	HookPackageDeleted HookPackageAction = "deleted"
+)
+
+// Package represents a package of the package registry.
+type Package struct {
+	ID         int64       `json:"id"`
+	Owner      *User       `json:"owner"`
+	Repository *Repository `json:"repository"`
+	Creator    *User       `json:"creator"`
+	Type       string      `json:"type"`
+	Name       string      `json:"name"`
+	Version    string      `json:"version"`
+	HTMLURL    string      `json:"html_url"`
+	Created    time.Time   `json:"created_at"`
+}
+
+// IssuePayload represents the payload information that is sent along with an issue event.
+type IssuePayload struct {
+	gitea.IssuePayload
+	CommitID string `json:"commit_id"`
+}
+
+// PushPayload represents a payload information of push event.
+type PushPayload struct {
+	gitea.PushPayload
+	TotalCommits int `json:"total_commits"`
+}
+
+// PullRequestPayload represents a payload information of pull request event.
+// RequestedReviewer is set on review requests, CommitID on reviews.
+type PullRequestPayload struct {
+	gitea.PullRequestPayload
+	RequestedReviewer *User  `json:"requested_reviewer"`
+	CommitID          string `json:"commit_id"`
+}
+
+// WikiPayload represents a payload information of wiki event.
+type WikiPayload struct {
+	Action     HookWikiAction `json:"action"`
+	Repository *Repository    `json:"repository"`
+	Sender     *User          `json:"sender"`
+	Page       string         `json:"page"`
+	Comment    string         `json:"comment"`
+}
+
+// PackagePayload represents a payload information of package event.
+type PackagePayload struct {
+	Action       HookPackageAction `json:"action"`
+	Repository   *Repository       `json:"repository"`
+	Package      *Package          `json:"package"`
+	Organization *User             `json:"organization"`
+	Sender       *User             `json:"sender"`
+}
@@ -0,0 +1,123 @@
+package gerrit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic events of a project to unit test handlers.
+// The events of a faker agree with each other on the project, users and the change under review on each branch,
+// and encode to the JSON the Gerrit webhooks plugin sends, e.g. to deliver them with the whtest package.
+// The same project always yields the same events.
+type Faker struct {
+	project string
+	user    Account
+	changes map[string]ChangeEvent
+	src     *fake.Source
+}
+
+// NewFaker returns a faker of the project with the given name, e.g. "platform/build".
+// Events are triggered by the user "Jane Roe".
+func NewFaker(project string) *Faker {
+	f := &Faker{project: project, changes: map[string]ChangeEvent{}, src: fake.New("gerrit/" + project)}
+	return f.User("Jane Roe")
+}
+
+// User sets the name of the user triggering the events.
+func (f *Faker) User(name string) *Faker {
+	username := strings.ToLower(strings.ReplaceAll(name, " ", "."))
+	f.user = Account{Name: name, Email: username + "@example.com", Username: username}
+	return f
+}
+
+// SHA returns a new commit SHA.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// PatchsetCreatedPayload returns the upload of the first patch set of the change to branch.
+func (f *Faker) PatchsetCreatedPayload(branch string) PatchsetCreatedPayload {
+	return PatchsetCreatedPayload{ChangeEvent: f.event(PatchsetCreatedEvent, branch, "NEW"), Uploader: f.user}
+}
+
+// CommentAddedPayload returns a review of the change to branch voting value on label, e.g. "Code-Review" and "2".
+func (f *Faker) CommentAddedPayload(branch, label, value string) CommentAddedPayload {
+	vote := value
+	if !strings.HasPrefix(vote, "-") {
+		vote = "+" + vote
+	}
+
+	return CommentAddedPayload{
+		ChangeEvent: f.event(CommentAddedEvent, branch, "NEW"),
+		Author:      f.user,
+		Approvals:   []Approval{{Type: label, Description: label, Value: value}},
+		Comment:     "Patch Set 1: " + label + vote,
+	}
+}
+
+// ChangeMergedPayload returns the submission of the change to branch.
+func (f *Faker) ChangeMergedPayload(branch string) ChangeMergedPayload {
+	e := f.event(ChangeMergedEvent, branch, "MERGED")
+	return ChangeMergedPayload{ChangeEvent: e, Submitter: f.user, NewRev: e.PatchSet.Revision}
+}
+
+// RefUpdatedPayload returns a direct push to ref, a branch name or a full ref such as "refs/tags/v1.0.0".
+func (f *Faker) RefUpdatedPayload(ref string) RefUpdatedPayload {
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	return RefUpdatedPayload{
+		BasicEvent: BasicEvent{Type: RefUpdatedEvent, EventCreatedOn: f.timestamp()},
+		Submitter:  f.user,
+		RefUpdate:  RefUpdate{OldRev: f.SHA(), NewRev: f.SHA(), RefName: ref, Project: f.project},
+	}
+}
+
+// event returns an event of the change to branch, created by the first event of the branch.
+func (f *Faker) event(typ Event, branch, status string) ChangeEvent {
+	e, ok := f.changes[branch]
+	if !ok {
+		number := f.src.ID() + 10000
+		changeID := "I" + f.SHA()
+		subject := "Update " + branch
+		created := f.timestamp()
+		e = ChangeEvent{
+			Change: Change{
+				Project:       f.project,
+				Branch:        branch,
+				ID:            changeID,
+				Number:        number,
+				Subject:       subject,
+				Owner:         f.user,
+				URL:           fmt.Sprintf("https://review.example.com/c/%s/+/%d", f.project, number),
+				CommitMessage: subject + "\n\nChange-Id: " + changeID + "\n",
+				CreatedOn:     created,
+			},
+			PatchSet: PatchSet{
+				Number:    1,
+				Revision:  f.SHA(),
+				Parents:   []string{f.SHA()},
+				Ref:       fmt.Sprintf("refs/changes/%02d/%d/1", number%100, number),
+				Uploader:  f.user,
+				Author:    f.user,
+				CreatedOn: created,
+				Kind:      "REWORK",
+			},
+			Project:   f.project,
+			RefName:   "refs/heads/" + branch,
+			ChangeKey: ChangeKey{ID: changeID},
+		}
+		f.changes[branch] = e
+	}
+
+	e.Type, e.EventCreatedOn = typ, f.timestamp()
+	e.Change.Status = status
+	return e
+}
+
+func (f *Faker) timestamp() Timestamp {
+	return Timestamp(f.src.Time().Unix())
+}
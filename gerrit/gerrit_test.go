@@ -0,0 +1,302 @@
+package gerrit
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	path   = "/webhooks"
+	secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+)
+
+var hook *Webhook
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	hook, err = New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func TestWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+	}{
+		{
+			name:     "PatchsetCreatedEvent",
+			event:    PatchsetCreatedEvent,
+			typ:      PatchsetCreatedPayload{},
+			filename: "./testdata/patchset-created.json",
+		},
+		{
+			name:     "ChangeMergedEvent",
+			event:    ChangeMergedEvent,
+			typ:      ChangeMergedPayload{},
+			filename: "./testdata/change-merged.json",
+		},
+		{
+			name:     "CommentAddedEvent",
+			event:    CommentAddedEvent,
+			typ:      CommentAddedPayload{},
+			filename: "./testdata/comment-added.json",
+		},
+		{
+			name:     "RefUpdatedEvent",
+			event:    RefUpdatedEvent,
+			typ:      RefUpdatedPayload{},
+			filename: "./testdata/ref-updated.json",
+		},
+		{
+			name:     "ChangeAbandonedEvent",
+			event:    ChangeAbandonedEvent,
+			typ:      ChangeAbandonedPayload{},
+			filename: "./testdata/change-abandoned.json",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.Open(tc.filename)
+			assert.NoError(err)
+			defer func() {
+				_ = payload.Close()
+			}()
+
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, payload)
+			assert.NoError(err)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	parse := func(event Event, filename string) interface{} {
+		pl, err := hook.Parse(whtest.Gerrit(whtest.Fixture(t, filename)).Request(), event)
+		assert.NoError(err)
+		return pl
+	}
+
+	created := parse(PatchsetCreatedEvent, "./testdata/patchset-created.json").(PatchsetCreatedPayload)
+	assert.Equal(PatchsetCreatedEvent, created.Type)
+	assert.Equal("platform/build", created.Project)
+	assert.Equal(int64(12345), created.Change.Number)
+	assert.Equal(created.Change.ID, created.ChangeKey.ID)
+	assert.Equal("refs/changes/45/12345/2", created.PatchSet.Ref)
+	assert.Equal("jroe", created.Uploader.Username)
+	assert.Equal(time.Date(2024, 6, 3, 8, 44, 1, 0, time.UTC), created.EventCreatedOn.Time())
+
+	comment := parse(CommentAddedEvent, "./testdata/comment-added.json").(CommentAddedPayload)
+	assert.Len(comment.Approvals, 2)
+	assert.Equal("Code-Review", comment.Approvals[0].Type)
+	assert.Equal("2", comment.Approvals[0].Value)
+	assert.Equal("0", comment.Approvals[0].OldValue)
+
+	merged := parse(ChangeMergedEvent, "./testdata/change-merged.json").(ChangeMergedPayload)
+	assert.Equal("MERGED", merged.Change.Status)
+	assert.Equal(merged.PatchSet.Revision, merged.NewRev)
+
+	ref := parse(RefUpdatedEvent, "./testdata/ref-updated.json").(RefUpdatedPayload)
+	assert.Equal("refs/heads/main", ref.RefUpdate.RefName)
+	assert.Equal(merged.NewRev, ref.RefUpdate.OldRev)
+}
+
+func TestBadRequests(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/patchset-created.json")
+	tests := []struct {
+		name   string
+		events []Event
+		r      *http.Request
+	}{
+		{name: "NoEvents", r: whtest.Gerrit(body).Request()},
+		{name: "UnsubscribedEvent", events: []Event{ChangeMergedEvent}, r: whtest.Gerrit(body).Request()},
+		{name: "BadMethod", events: []Event{PatchsetCreatedEvent}, r: whtest.Gerrit(body).Method(http.MethodGet).Request()},
+		{name: "BadBody", events: []Event{PatchsetCreatedEvent}, r: whtest.Gerrit("").Request()},
+		{name: "BadJSON", events: []Event{PatchsetCreatedEvent}, r: whtest.Gerrit("{").Request()},
+		{name: "UnknownEvent", events: []Event{"project-created"}, r: whtest.Gerrit(`{"type":"project-created"}`).Request()},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := hook.Parse(tc.r, tc.events...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestVerification(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/ref-updated.json")
+	tests := []struct {
+		name    string
+		options []Option
+		r       *http.Request
+		wantErr error
+	}{
+		{
+			name:    "Secret",
+			options: []Option{Options.Secret(secret)},
+			r:       whtest.Gerrit(body).Target(path + "?token=" + secret).Request(),
+		},
+		{
+			name:    "WrongSecret",
+			options: []Option{Options.Secret(secret)},
+			r:       whtest.Gerrit(body).Target(path + "?token=wrong").Request(),
+			wantErr: ErrSecretVerificationFailed,
+		},
+		{
+			name:    "MissingSecret",
+			options: []Option{Options.Secret(secret)},
+			r:       whtest.Gerrit(body).Request(),
+			wantErr: ErrSecretVerificationFailed,
+		},
+		{
+			name:    "BasicAuth",
+			options: []Option{Options.BasicAuth("gerrit", secret)},
+			r:       whtest.Gerrit(body).BasicAuth("gerrit", secret).Request(),
+		},
+		{
+			name:    "WrongPassword",
+			options: []Option{Options.BasicAuth("gerrit", secret)},
+			r:       whtest.Gerrit(body).BasicAuth("gerrit", secret).Tampered(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+		{
+			name:    "MissingBasicAuth",
+			options: []Option{Options.BasicAuth("gerrit", secret)},
+			r:       whtest.Gerrit(body).Request(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+		{
+			name:    "SecretAndBasicAuth",
+			options: []Option{Options.Secret(secret), Options.BasicAuth("gerrit", secret)},
+			r:       whtest.Gerrit(body).BasicAuth("gerrit", secret).Target(path + "?token=" + secret).Request(),
+		},
+		{
+			name:    "SecretWithoutBasicAuth",
+			options: []Option{Options.Secret(secret), Options.BasicAuth("gerrit", secret)},
+			r:       whtest.Gerrit(body).Target(path + "?token=" + secret).Request(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			h, err := New(tc.options...)
+			assert.NoError(err)
+			_, err = h.Parse(tc.r, RefUpdatedEvent)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+
+	_, err := New(Options.Secret(""))
+	require.Error(t, err)
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("platform/build").User("John Doe")
+	created := faker.PatchsetCreatedPayload("main")
+	assert.Equal(PatchsetCreatedEvent, created.Type)
+	assert.Equal("refs/heads/main", created.RefName)
+	assert.Equal("john.doe", created.Uploader.Username)
+	assert.True(strings.HasPrefix(created.Change.ID, "I"))
+
+	comment := faker.CommentAddedPayload("main", "Code-Review", "2")
+	assert.Equal("Patch Set 1: Code-Review+2", comment.Comment)
+	merged := faker.ChangeMergedPayload("main")
+	assert.Equal(created.Change.Number, merged.Change.Number)
+	assert.Equal(created.PatchSet.Revision, merged.NewRev)
+	assert.Equal("MERGED", merged.Change.Status)
+	assert.NotEqual(created.Change.Number, faker.PatchsetCreatedPayload("develop").Change.Number)
+	assert.Equal(created, NewFaker("platform/build").User("John Doe").PatchsetCreatedPayload("main"))
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: PatchsetCreatedEvent, payload: created},
+		{event: CommentAddedEvent, payload: comment},
+		{event: ChangeMergedEvent, payload: merged},
+		{event: RefUpdatedEvent, payload: faker.RefUpdatedPayload("refs/tags/v1.0.0")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Gerrit(tc.payload).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{PatchsetCreatedEvent, "./testdata/patchset-created.json"},
+		{ChangeMergedEvent, "./testdata/change-merged.json"},
+		{CommentAddedEvent, "./testdata/comment-added.json"},
+		{RefUpdatedEvent, "./testdata/ref-updated.json"},
+		{ChangeAbandonedEvent, "./testdata/change-abandoned.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), payload)
+	}
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event string, payload []byte) {
+		r := whtest.Gerrit(payload).Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package gerrit
+
+import "time"
+
+// Timestamp is a Gerrit time, encoded as the seconds since the epoch.
+type Timestamp int64
+
+// Time returns the timestamp as a time.Time.
+func (t Timestamp) Time() time.Time {
+	return time.Unix(int64(t), 0).UTC()
+}
+
+// Account contains the Gerrit account information.
+type Account struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+// Change contains all of the Gerrit change information,
+// ID is the Change-Id of the commit message and Number the change number of the URL.
+type Change struct {
+	Project       string    `json:"project"`
+	Branch        string    `json:"branch"`
+	Topic         string    `json:"topic"`
+	ID            string    `json:"id"`
+	Number        int64     `json:"number"`
+	Subject       string    `json:"subject"`
+	Owner         Account   `json:"owner"`
+	URL           string    `json:"url"`
+	CommitMessage string    `json:"commitMessage"`
+	Hashtags      []string  `json:"hashtags"`
+	CreatedOn     Timestamp `json:"createdOn"`
+	Status        string    `json:"status"`
+	WIP           bool      `json:"wip"`
+	Private       bool      `json:"private"`
+}
+
+// PatchSet contains the Gerrit patch set information,
+// Kind is e.g. "REWORK", "TRIVIAL_REBASE" or "NO_CODE_CHANGE".
+type PatchSet struct {
+	Number         int64     `json:"number"`
+	Revision       string    `json:"revision"`
+	Parents        []string  `json:"parents"`
+	Ref            string    `json:"ref"`
+	Uploader       Account   `json:"uploader"`
+	Author         Account   `json:"author"`
+	CreatedOn      Timestamp `json:"createdOn"`
+	Kind           string    `json:"kind"`
+	SizeInsertions int64     `json:"sizeInsertions"`
+	SizeDeletions  int64     `json:"sizeDeletions"`
+}
+
+// Approval contains a vote on a label, e.g. "Code-Review" or "Verified", Value is the vote, e.g. "2" or "-1".
+type Approval struct {
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	Value       string    `json:"value"`
+	OldValue    string    `json:"oldValue"`
+	GrantedOn   Timestamp `json:"grantedOn"`
+	By          *Account  `json:"by"`
+}
+
+// ChangeKey contains the Change-Id of a change.
+type ChangeKey struct {
+	ID string `json:"id"`
+}
+
+// RefUpdate contains an updated ref, OldRev is all zeros for created refs and NewRev for deleted ones.
+type RefUpdate struct {
+	OldRev  string `json:"oldRev"`
+	NewRev  string `json:"newRev"`
+	RefName string `json:"refName"`
+	Project string `json:"project"`
+}
+
+// BasicEvent contains the type and creation time common to all Gerrit events.
+type BasicEvent struct {
+	Type           Event     `json:"type"`
+	EventCreatedOn Timestamp `json:"eventCreatedOn"`
+}
+
+// ChangeEvent contains the change and patch set common to the events of a change.
+type ChangeEvent struct {
+	BasicEvent
+	Change    Change    `json:"change"`
+	PatchSet  PatchSet  `json:"patchSet"`
+	Project   string    `json:"project"`
+	RefName   string    `json:"refName"`
+	ChangeKey ChangeKey `json:"changeKey"`
+}
+
+// PatchsetCreatedPayload contains the information for Gerrit's patchset-created event.
+type PatchsetCreatedPayload struct {
+	ChangeEvent
+	Uploader Account `json:"uploader"`
+}
+
+// ChangeMergedPayload contains the information for Gerrit's change-merged event,
+// NewRev is the commit the target branch was updated to.
+type ChangeMergedPayload struct {
+	ChangeEvent
+	Submitter Account `json:"submitter"`
+	NewRev    string  `json:"newRev"`
+}
+
+// CommentAddedPayload contains the information for Gerrit's comment-added event,
+// the review message and the votes cast with it.
+type CommentAddedPayload struct {
+	ChangeEvent
+	Author    Account    `json:"author"`
+	Approvals []Approval `json:"approvals"`
+	Comment   string     `json:"comment"`
+}
+
+// RefUpdatedPayload contains the information for Gerrit's ref-updated event, sent for pushes bypassing review.
+type RefUpdatedPayload struct {
+	BasicEvent
+	Submitter Account   `json:"submitter"`
+	RefUpdate RefUpdate `json:"refUpdate"`
+}
+
+// ChangeAbandonedPayload contains the information for Gerrit's change-abandoned event.
+type ChangeAbandonedPayload struct {
+	ChangeEvent
+	Abandoner Account `json:"abandoner"`
+	Reason    string  `json:"reason"`
+}
@@ -8,11 +8,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
 )
 
-// Options is a namespace var for configuration options.
-var Options = WebhookOptions{}
+var (
+	// Options is a namespace var for configuration options.
+	Options = WebhookOptions{}
+	// ErrActionNotDefined is returned by ParseActions when the action
+	// of a parsed event is not one of the actions asked to be parsed.
+	ErrActionNotDefined = errors.New("action not defined to be parsed")
+	// ErrParsingPayload is returned when a payload cannot be read or decoded.
+	ErrParsingPayload = errors.New("error parsing payload")
+)
 
 const (
 	// Gitea hook types.
@@ -39,13 +52,48 @@ const (
 // Event defines a GitLab hook event type by the X-Gitlab-Event Header.
 type Event string
 
+// EventActions pairs an event with the actions of it that should be parsed.
+// An empty Actions list matches every action of the event.
+type EventActions = observe.EventActions[Event, Action]
+
+// On returns an EventActions filter for the event and the given actions of any of the Hook*Action types,
+// e.g. On(PullRequestEvent, HookIssueOpened, HookIssueSynchronized), or On[Action](PullRequestEvent) for every action.
+func On[A ~string](event Event, actions ...A) EventActions {
+	f := EventActions{Event: event}
+	for _, a := range actions {
+		f.Actions = append(f.Actions, Action(a))
+	}
+	return f
+}
+
+// provider describes the deliveries of Gitea to the instrumentation.
+var provider = &observe.Provider{
+	Name:           "gitea",
+	EventHeader:    "X-Gitea-Event",
+	DeliveryHeader: "X-Gitea-Delivery",
+	SecretHeaders:  []string{"X-Gitea-Signature", "X-Hub-Signature", "X-Hub-Signature-256", "X-Gogs-Signature"},
+}
+
 // Webhook instance contains all methods needed to process events.
 type Webhook struct {
-	secret string
+	secret   string
+	observer observe.Observer
 }
 
 // Parse verifies and parses the events specified and returns the payload object or an error.
-func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error) {
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
 	defer func() {
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
@@ -80,24 +128,29 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil || len(payload) == 0 {
-		return nil, errors.New("error parsing payload")
+		return nil, ErrParsingPayload
 	}
 
 	// if Secret set exists, MAC must be checked
-	if len(hook.secret) > 0 {
+	err = d.Verify(len(hook.secret) > 0, func() error {
 		signature := r.Header.Get("X-Gitea-Signature")
 		if len(signature) == 0 {
-			return nil, errors.New("missing X-Gitea-Signature Header")
+			return errors.New("missing X-Gitea-Signature Header")
 		}
 
 		sig256 := hmac.New(sha256.New, []byte(hook.secret))
 		_, _ = io.Writer(sig256).Write([]byte(payload))
 		expectedMAC := hex.EncodeToString(sig256.Sum(nil))
 		if !hmac.Equal([]byte(signature), []byte(expectedMAC)) {
-			return nil, errors.New("HMAC verification failed")
+			return errors.New("HMAC verification failed")
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	d.Decoding()
 	switch giteaEvent {
 	case CreateEvent:
 		var pl CreatePayload
@@ -140,6 +193,27 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 	}
 }
 
+// ParseActions verifies and parses the events specified in filters
+// and returns the payload object only if its action matches one of the actions
+// registered for the event, otherwise ErrActionNotDefined is returned.
+func (hook Webhook) ParseActions(r *http.Request, filters ...EventActions) (interface{}, error) {
+	pl, err := hook.Parse(r, observe.Events(filters)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !observe.Matches(filters, Event(r.Header.Get("X-Gitea-Event")), pl) {
+		return nil, ErrActionNotDefined
+	}
+	return pl, nil
+}
+
+// ActionOf returns the action of a parsed payload,
+// or an empty Action if the payload has no action.
+func ActionOf(payload interface{}) Action {
+	return Action(observe.Action(payload))
+}
+
 // Option is a configuration option for the webhook.
 type Option func(*Webhook) error
 
@@ -164,3 +238,38 @@ func (WebhookOptions) Secret(secret string) Option {
 		return nil
 	}
 }
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
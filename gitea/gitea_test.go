@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/pchchv/wh/whtest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -314,8 +315,150 @@ func TestBadRequests(t *testing.T) {
 	}
 }
 
+func TestParseActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []EventActions
+		filename string
+		headers  http.Header
+		action   Action
+		wantErr  error
+	}{
+		{
+			name:     "MatchingAction",
+			filters:  []EventActions{On(PullRequestEvent, HookIssueOpened, HookIssueReOpened)},
+			filename: "./testdata/pull-request-event.json",
+			headers: http.Header{
+				"X-Gitea-Event":     []string{"pull_request"},
+				"X-Gitea-Signature": []string{"65c18a212efc7bde0f336acaec87f596fe20e80b2a0e7e51a790dd38393ff771"},
+			},
+			action: Action(HookIssueOpened),
+		},
+		{
+			name:     "AnyAction",
+			filters:  []EventActions{On[Action](PullRequestEvent)},
+			filename: "./testdata/pull-request-event.json",
+			headers: http.Header{
+				"X-Gitea-Event":     []string{"pull_request"},
+				"X-Gitea-Signature": []string{"65c18a212efc7bde0f336acaec87f596fe20e80b2a0e7e51a790dd38393ff771"},
+			},
+			action: Action(HookIssueOpened),
+		},
+		{
+			name:     "UnmatchedAction",
+			filters:  []EventActions{On(PullRequestEvent, HookIssueSynchronized)},
+			filename: "./testdata/pull-request-event.json",
+			headers: http.Header{
+				"X-Gitea-Event":     []string{"pull_request"},
+				"X-Gitea-Signature": []string{"65c18a212efc7bde0f336acaec87f596fe20e80b2a0e7e51a790dd38393ff771"},
+			},
+			wantErr: ErrActionNotDefined,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.ReadFile(tc.filename)
+			assert.NoError(err)
+
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+			req.Header = tc.headers
+			results, err := hook.ParseActions(req, tc.filters...)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(tc.action, ActionOf(results))
+		})
+	}
+}
+
 func newServer(handler http.HandlerFunc) *httptest.Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc(path, handler)
 	return httptest.NewServer(mux)
 }
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("gitea/tea").Sender("lunny")
+	push := faker.PushPayload("main", 3)
+	assert.Equal("refs/heads/main", push.Ref)
+	assert.Len(push.Commits, 3)
+	assert.Equal(push.After, push.Commits[2].ID)
+	assert.Equal(push.After, push.HeadCommit.ID)
+	assert.Equal("gitea/tea", push.Repo.FullName)
+	assert.Equal("lunny", push.Sender.UserName)
+	assert.Equal(push, NewFaker("gitea/tea").Sender("lunny").PushPayload("main", 3))
+
+	pr := faker.PullRequestPayload(HookIssueClosed, "feature", "main")
+	assert.True(pr.PullRequest.HasMerged)
+	assert.Equal("feature", pr.PullRequest.Head.Ref)
+	assert.Equal("main", pr.PullRequest.Base.Ref)
+	assert.Equal(push.Repo.ID, pr.Repository.ID)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: PushEvent, payload: push},
+		{event: PullRequestEvent, payload: pr},
+		{event: PullRequestEvent, payload: faker.PullRequestPayload(HookIssueOpened, "feature", "main")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Gitea(tc.event, tc.payload).Secret(hook.secret).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{CreateEvent, "./testdata/create-event.json"},
+		{DeleteEvent, "./testdata/delete-event.json"},
+		{ForkEvent, "./testdata/fork-event.json"},
+		{IssuesEvent, "./testdata/issues-event.json"},
+		{IssueAssignEvent, "./testdata/issue-assign-event.json"},
+		{IssueLabelEvent, "./testdata/issue-label-event.json"},
+		{IssueMilestoneEvent, "./testdata/issue-milestone-event.json"},
+		{IssueCommentEvent, "./testdata/issue-comment-event.json"},
+		{PushEvent, "./testdata/push-event.json"},
+		{PullRequestEvent, "./testdata/pull-request-event.json"},
+		{PullRequestAssignEvent, "./testdata/pull-request-assign-event.json"},
+		{PullRequestLabelEvent, "./testdata/pull-request-label-event.json"},
+		{PullRequestMilestoneEvent, "./testdata/pull-request-milestone-event.json"},
+		{PullRequestCommentEvent, "./testdata/pull-request-comment-event.json"},
+		{PullRequestReviewEvent, "./testdata/pull-request-review-event.json"},
+		{RepositoryEvent, "./testdata/repository-event.json"},
+		{ReleaseEvent, "./testdata/release-event.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), "", payload)
+	}
+	f.Add(string(seeds[0].event), "zz", []byte("{}"))
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event, signature string, payload []byte) {
+		d := whtest.Gitea(event, payload).Secret(hook.secret)
+		if signature != "" {
+			d.Header("X-Gitea-Signature", signature)
+		}
+
+		r := d.Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
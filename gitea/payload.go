@@ -8,10 +8,13 @@ type PusherType string
 // StateType issue state type.
 type StateType string
 
+// Action is the action of a hook event, whatever its Hook*Action type, see ActionOf.
+type Action string
+
 // HookRepoAction an action that happens to a repo.
 type HookRepoAction string
 
-// HookIssueAction FIXME.
+// HookIssueAction defines hook issue and pull request action type.
 type HookIssueAction string
 
 // HookReleaseAction defines hook release action type.
@@ -20,6 +23,35 @@ type HookReleaseAction string
 // HookIssueCommentAction defines hook issue comment action.
 type HookIssueCommentAction string
 
+const (
+	// HookRepoAction values.
+	HookRepoCreated HookRepoAction = "created"
+	HookRepoDeleted HookRepoAction = "deleted"
+	// HookIssueAction values.
+	HookIssueOpened               HookIssueAction = "opened"
+	HookIssueClosed               HookIssueAction = "closed"
+	HookIssueEdited               HookIssueAction = "edited"
+	HookIssueReOpened             HookIssueAction = "reopened"
+	HookIssueAssigned             HookIssueAction = "assigned"
+	HookIssueReviewed             HookIssueAction = "reviewed"
+	HookIssueUnassigned           HookIssueAction = "unassigned"
+	HookIssueMilestoned           HookIssueAction = "milestoned"
+	HookIssueDemilestoned         HookIssueAction = "demilestoned"
+	HookIssueSynchronized         HookIssueAction = "synchronized"
+	HookIssueLabelUpdated         HookIssueAction = "label_updated"
+	HookIssueLabelCleared         HookIssueAction = "label_cleared"
+	HookIssueReviewRequested      HookIssueAction = "review_requested"
+	HookIssueReviewRequestRemoved HookIssueAction = "review_request_removed"
+	// HookReleaseAction values.
+	HookReleaseUpdated   HookReleaseAction = "updated"
+	HookReleaseDeleted   HookReleaseAction = "deleted"
+	HookReleasePublished HookReleaseAction = "published"
+	// HookIssueCommentAction values.
+	HookIssueCommentEdited  HookIssueCommentAction = "edited"
+	HookIssueCommentCreated HookIssueCommentAction = "created"
+	HookIssueCommentDeleted HookIssueCommentAction = "deleted"
+)
+
 // Label a label to an issue or a pr.
 type Label struct {
 	ID          int64  `json:"id"`
@@ -0,0 +1,219 @@
+package gitee
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic payloads of a repository to unit test handlers.
+// The payloads of a faker agree with each other on the repository, users, refs and SHAs,
+// and encode to the JSON Gitee sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same payloads.
+type Faker struct {
+	owner  string
+	name   string
+	id     int64
+	sender *User
+	src    *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given full name, e.g. "oschina/git-osc".
+// Events are triggered by the repository owner.
+func NewFaker(fullName string) *Faker {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok {
+		owner, name = "oschina", fullName
+	}
+
+	src := fake.New("gitee/" + fullName)
+	f := &Faker{owner: owner, name: name, id: src.ID(), src: src}
+	f.sender = f.user(owner)
+	return f
+}
+
+// Sender sets the login of the user triggering the events.
+func (f *Faker) Sender(login string) *Faker {
+	f.sender = f.user(login)
+	return f
+}
+
+// SHA returns a new commit SHA.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// PushEventPayload returns the push of commits to ref, a branch name or a full ref such as "refs/heads/master".
+// The commits follow each other, the last one being the head commit and the after SHA.
+func (f *Faker) PushEventPayload(ref string, commits int) PushEventPayload {
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	pl := PushEventPayload{
+		Hook:              Hook{HookName: "push_hooks"},
+		Ref:               ref,
+		Before:            f.SHA(),
+		Commits:           []Commit{},
+		TotalCommitsCount: int64(commits),
+		Repository:        f.project(),
+		Project:           f.project(),
+		UserID:            f.sender.ID,
+		UserName:          f.sender.Name,
+		User:              f.sender,
+		Pusher:            f.sender,
+		Sender:            f.sender,
+	}
+
+	pl.After = pl.Before
+	for i := 0; i < commits; i++ {
+		c := f.commit(fmt.Sprintf("Change %d", i+1))
+		pl.Commits = append(pl.Commits, c)
+		pl.HeadCommit = &c
+		pl.After = c.ID
+	}
+	pl.Compare = fmt.Sprintf("%s/compare/%s...%s", f.htmlURL(), pl.Before, pl.After)
+	return pl
+}
+
+// MergeRequestEventPayload returns a merge request event merging the source branch into the target branch.
+// The merge action marks the pull request as merged, the close action as closed.
+func (f *Faker) MergeRequestEventPayload(action Action, source, target string) MergeRequestEventPayload {
+	id := f.src.ID()
+	number := id%1000 + 1
+	created := f.src.Time()
+	html := fmt.Sprintf("%s/pulls/%d", f.htmlURL(), number)
+	pr := &PullRequest{
+		ID:          id,
+		Number:      number,
+		State:       "open",
+		HTMLURL:     html,
+		DiffURL:     html + ".diff",
+		PatchURL:    html + ".patch",
+		Title:       "Merge " + source + " into " + target,
+		Body:        "Changes of " + source + ".",
+		User:        f.sender,
+		Assignees:   []User{},
+		Testers:     []User{},
+		NeedReview:  true,
+		Head:        Branch{Label: source, Ref: source, Sha: f.SHA(), User: f.sender, Repo: f.project()},
+		Base:        Branch{Label: target, Ref: target, Sha: f.SHA(), User: f.user(f.owner), Repo: f.project()},
+		Mergeable:   true,
+		MergeStatus: "can_be_merged",
+		UpdatedBy:   f.sender,
+		Commits:     1,
+		CreatedAt:   created,
+		UpdatedAt:   created,
+	}
+
+	switch action {
+	case MergeAction:
+		merged := f.src.Time()
+		pr.State, pr.Merged, pr.Mergeable = "merged", true, false
+		pr.MergeCommitSha = f.SHA()
+		pr.UpdatedAt, pr.MergedAt, pr.ClosedAt = merged, &merged, &merged
+	case CloseAction:
+		closed := f.src.Time()
+		pr.State = "closed"
+		pr.UpdatedAt, pr.ClosedAt = closed, &closed
+	}
+
+	repo := &RepoInfo{Project: f.project(), Repository: f.project()}
+	return MergeRequestEventPayload{
+		Hook:           Hook{HookName: "merge_request_hooks"},
+		Action:         action,
+		PullRequest:    pr,
+		Number:         number,
+		IID:            number,
+		Title:          pr.Title,
+		Body:           pr.Body,
+		State:          pr.State,
+		MergeStatus:    pr.MergeStatus,
+		MergeCommitSha: pr.MergeCommitSha,
+		URL:            html,
+		SourceBranch:   source,
+		SourceRepo:     repo,
+		TargetBranch:   target,
+		TargetRepo:     repo,
+		Repository:     f.project(),
+		Project:        f.project(),
+		Author:         f.sender,
+		UpdatedBy:      f.sender,
+		Sender:         f.sender,
+	}
+}
+
+func (f *Faker) commit(message string) Commit {
+	sha := f.SHA()
+	author := CommitUser{
+		ID:       f.sender.ID,
+		Name:     f.sender.Name,
+		Email:    f.sender.Email,
+		UserName: f.sender.Login,
+		URL:      f.sender.HTMLURL,
+		Time:     f.src.Time(),
+	}
+	return Commit{
+		ID:        sha,
+		TreeID:    f.SHA(),
+		ParentIDs: []string{},
+		Distinct:  true,
+		Message:   message + "\n",
+		Timestamp: author.Time,
+		URL:       f.htmlURL() + "/commit/" + sha,
+		Author:    author,
+		Committer: author,
+		Added:     []string{},
+		Removed:   []string{},
+		Modified:  []string{"README.md"},
+	}
+}
+
+func (f *Faker) project() *Project {
+	fullName := f.owner + "/" + f.name
+	return &Project{
+		ID:                f.id,
+		Name:              f.name,
+		Path:              f.name,
+		FullName:          fullName,
+		Owner:             f.user(f.owner),
+		Description:       "The " + f.name + " repository",
+		URL:               f.htmlURL(),
+		HTMLURL:           f.htmlURL(),
+		GitURL:            "git://gitee.com/" + fullName + ".git",
+		SSHURL:            "git@gitee.com:" + fullName + ".git",
+		CloneURL:          f.htmlURL() + ".git",
+		SVNURL:            "svn://gitee.com/" + fullName,
+		GitHTTPURL:        f.htmlURL() + ".git",
+		GitSSHURL:         "git@gitee.com:" + fullName + ".git",
+		GitSVNURL:         "svn://gitee.com/" + fullName,
+		HasIssues:         true,
+		HasWiki:           true,
+		DefaultBranch:     "master",
+		Namespace:         f.owner,
+		NameWithNamespace: fullName,
+		PathWithNamespace: fullName,
+		CreatedAt:         fake.Epoch,
+		UpdatedAt:         fake.Epoch,
+		PushedAt:          fake.Epoch,
+	}
+}
+
+func (f *Faker) user(login string) *User {
+	return &User{
+		ID:        fake.NameID(login),
+		Name:      login,
+		Email:     login + "@example.com",
+		Login:     login,
+		UserName:  login,
+		URL:       "https://gitee.com/" + login,
+		HTMLURL:   "https://gitee.com/" + login,
+		AvatarURL: "https://foruda.gitee.com/avatar/" + login + ".png",
+		Type:      "User",
+	}
+}
+
+func (f *Faker) htmlURL() string {
+	return "https://gitee.com/" + f.owner + "/" + f.name
+}
@@ -0,0 +1,328 @@
+package gitee
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+const (
+	// Gitee hook types.
+	TagEvents          Event = "Tag Push Hook"
+	PushEvents         Event = "Push Hook"
+	IssuesEvents       Event = "Issue Hook"
+	CommentEvents      Event = "Note Hook"
+	MergeRequestEvents Event = "Merge Request Hook"
+)
+
+const (
+	// Gitee issue actions.
+	OpenAction        Action = "open"
+	UpdateAction      Action = "update"
+	DeleteAction      Action = "delete"
+	StateChangeAction Action = "state_change"
+	AssignAction      Action = "assign"
+	// Gitee merge request actions.
+	CloseAction    Action = "close"
+	MergeAction    Action = "merge"
+	TestAction     Action = "test"
+	TestedAction   Action = "tested"
+	ApprovedAction Action = "approved"
+	// Gitee comment actions.
+	CommentAction Action = "comment"
+)
+
+// DefaultTolerance is how far the X-Gitee-Timestamp of a signed delivery may be from the current time.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// Options is a namespace variable for configuration options.
+	Options = WebhookOptions{}
+	// ErrActionNotDefined is returned by ParseActions when the action
+	// of a parsed event is not one of the actions asked to be parsed.
+	ErrActionNotDefined = errors.New("action not defined to be parsed")
+	// ErrParsingPayload is returned when a payload cannot be read or decoded.
+	ErrParsingPayload = errors.New("error parsing payload")
+	// ErrTimestampExpired is returned when the X-Gitee-Timestamp of a signed delivery
+	// is further from the current time than the tolerance, e.g. of a replayed delivery.
+	ErrTimestampExpired = errors.New("X-Gitee-Timestamp out of tolerance")
+)
+
+// Event defines a Gitee hook event type by the X-Gitee-Event Header.
+type Event string
+
+// Action defines a Gitee hook event action, sent in the "action" field of the payload.
+type Action string
+
+// EventActions pairs an event with the actions of it that should be parsed.
+// An empty Actions list matches every action of the event.
+type EventActions = observe.EventActions[Event, Action]
+
+// On returns an EventActions filter for the event and the given actions.
+func On(event Event, actions ...Action) EventActions {
+	return EventActions{Event: event, Actions: actions}
+}
+
+// provider describes the deliveries of Gitee to the instrumentation.
+var provider = &observe.Provider{
+	Name:          "gitee",
+	EventHeader:   "X-Gitee-Event",
+	SecretHeaders: []string{"X-Gitee-Token"},
+}
+
+// Webhook instance contains all methods needed to process events.
+type Webhook struct {
+	secretHash []byte
+	signingKey []byte
+	tolerance  time.Duration
+	now        func() time.Time
+	observer   observe.Observer
+}
+
+// Parse verifies and parses the events specified and returns the payload object or an error.
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
+	defer func() {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}()
+
+	if len(events) == 0 {
+		return nil, errors.New("no Event specified to parse")
+	}
+
+	if r.Method != http.MethodPost {
+		return nil, errors.New("invalid HTTP Method")
+	}
+
+	err := d.Verify(len(hook.secretHash) > 0 || len(hook.signingKey) > 0, func() error {
+		if len(hook.signingKey) > 0 {
+			return hook.verifySignature(r.Header.Get("X-Gitee-Token"), r.Header.Get("X-Gitee-Timestamp"))
+		}
+
+		// the password is compared in constant time
+		tokenHash := sha512.Sum512([]byte(r.Header.Get("X-Gitee-Token")))
+		if subtle.ConstantTimeCompare(tokenHash[:], hook.secretHash) == 0 {
+			return errors.New("X-Gitee-Token validation failed")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	event := r.Header.Get("X-Gitee-Event")
+	if len(event) == 0 {
+		return nil, errors.New("missing X-Gitee-Event Header")
+	}
+
+	giteeEvent := Event(event)
+	var found bool
+	for _, evt := range events {
+		if evt == giteeEvent {
+			found = true
+			break
+		}
+	}
+
+	// event not defined to be parsed
+	if !found {
+		return nil, errors.New("event not defined to be parsed")
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil || len(payload) == 0 {
+		return nil, ErrParsingPayload
+	}
+
+	d.Decoding()
+	switch giteeEvent {
+	case PushEvents:
+		var pl PushEventPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case TagEvents:
+		var pl TagPushEventPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case IssuesEvents:
+		var pl IssueEventPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case MergeRequestEvents:
+		var pl MergeRequestEventPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case CommentEvents:
+		var pl NoteEventPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	default:
+		return nil, fmt.Errorf("unknown event %s", giteeEvent)
+	}
+}
+
+// verifySignature checks the token of a signed delivery, the base64 encoded HMAC-SHA256
+// of the timestamp and the signing key joined by a newline, and the freshness of the timestamp.
+func (hook Webhook) verifySignature(token, timestamp string) error {
+	if len(token) == 0 {
+		return errors.New("missing X-Gitee-Token Header")
+	}
+
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid X-Gitee-Timestamp Header")
+	}
+
+	mac := hmac.New(sha256.New, hook.signingKey)
+	_, _ = io.WriteString(mac, timestamp+"\n"+string(hook.signingKey))
+	expectedMAC := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(token), []byte(expectedMAC)) {
+		return errors.New("HMAC verification failed")
+	}
+
+	// the timestamp is only trusted once the signature covering it is verified
+	if age := hook.now().Sub(time.UnixMilli(ms)); age > hook.tolerance || age < -hook.tolerance {
+		return ErrTimestampExpired
+	}
+	return nil
+}
+
+// ParseActions verifies and parses the events specified in filters
+// and returns the payload object only if its action matches one of the actions
+// registered for the event, otherwise ErrActionNotDefined is returned.
+func (hook Webhook) ParseActions(r *http.Request, filters ...EventActions) (interface{}, error) {
+	pl, err := hook.Parse(r, observe.Events(filters)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !observe.Matches(filters, Event(r.Header.Get("X-Gitee-Event")), pl) {
+		return nil, ErrActionNotDefined
+	}
+	return pl, nil
+}
+
+// ActionOf returns the action of a parsed payload,
+// or an empty Action if the payload has no action.
+func ActionOf(payload interface{}) Action {
+	return Action(observe.Action(payload))
+}
+
+// Option is a configuration option for the webhook.
+type Option func(*Webhook) error
+
+// New creates and returns a WebHook instance denoted by the Provider type.
+func New(options ...Option) (*Webhook, error) {
+	hook := &Webhook{tolerance: DefaultTolerance, now: time.Now}
+	for _, opt := range options {
+		if err := opt(hook); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+
+	return hook, nil
+}
+
+// WebhookOptions is a namespace for configuration option methods.
+type WebhookOptions struct{}
+
+// Secret registers the WebHook password Gitee sends as is in the X-Gitee-Token header.
+// It cannot be combined with SigningKey.
+func (WebhookOptions) Secret(secret string) Option {
+	return func(hook *Webhook) error {
+		if len(hook.signingKey) > 0 {
+			return errors.New("secret and signing key are mutually exclusive")
+		}
+		// already convert here to prevent timing attack
+		// (conversion depends on secret)
+		hash := sha512.Sum512([]byte(secret))
+		hook.secretHash = hash[:]
+		return nil
+	}
+}
+
+// SigningKey registers the signing key of a WebHook using signatures:
+// Gitee sends the HMAC-SHA256 of the X-Gitee-Timestamp and the key in the X-Gitee-Token header instead of the key,
+// deliveries whose timestamp is further from the current time than the tolerance are rejected with ErrTimestampExpired.
+// It cannot be combined with Secret.
+func (WebhookOptions) SigningKey(key string) Option {
+	return func(hook *Webhook) error {
+		if len(hook.secretHash) > 0 {
+			return errors.New("secret and signing key are mutually exclusive")
+		}
+		hook.signingKey = []byte(key)
+		return nil
+	}
+}
+
+// Tolerance sets how far the timestamp of a signed delivery may be from the current time, DefaultTolerance by default.
+func (WebhookOptions) Tolerance(tolerance time.Duration) Option {
+	return func(hook *Webhook) error {
+		if tolerance <= 0 {
+			return errors.New("tolerance must be positive")
+		}
+		hook.tolerance = tolerance
+		return nil
+	}
+}
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
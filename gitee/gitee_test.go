@@ -0,0 +1,395 @@
+package gitee
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const path = "/webhooks"
+
+var hook *Webhook
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	if hook, err = New(Options.Secret("sampleToken!")); err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func TestBadRequests(t *testing.T) {
+	assert := require.New(t)
+	tests := []struct {
+		name    string
+		event   Event
+		payload io.Reader
+		headers http.Header
+	}{
+		{
+			name:    "BadNoEventHeader",
+			event:   PushEvents,
+			payload: bytes.NewBuffer([]byte("{}")),
+			headers: http.Header{
+				"X-Gitee-Token": []string{"sampleToken!"},
+			},
+		},
+		{
+			name:    "UnsubscribedEvent",
+			event:   PushEvents,
+			payload: bytes.NewBuffer([]byte("{}")),
+			headers: http.Header{
+				"X-Gitee-Event": []string{"noneexistant_event"},
+				"X-Gitee-Token": []string{"sampleToken!"},
+			},
+		},
+		{
+			name:    "BadBody",
+			event:   PushEvents,
+			payload: bytes.NewBuffer([]byte("")),
+			headers: http.Header{
+				"X-Gitee-Event": []string{"Push Hook"},
+				"X-Gitee-Token": []string{"sampleToken!"},
+			},
+		},
+		{
+			name:    "TokenMismatch",
+			event:   PushEvents,
+			payload: bytes.NewBuffer([]byte("{}")),
+			headers: http.Header{
+				"X-Gitee-Event": []string{"Push Hook"},
+				"X-Gitee-Token": []string{"badsampleToken!!"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		client := &http.Client{}
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var parseError error
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				_, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, tc.payload)
+			assert.NoError(err)
+			req.Header = tc.headers
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := client.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.Error(parseError)
+		})
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	assert := require.New(t)
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+		headers  http.Header
+	}{
+		{
+			name:     "PushEvent",
+			event:    PushEvents,
+			typ:      PushEventPayload{},
+			filename: "./testdata/push-event.json",
+			headers: http.Header{
+				"X-Gitee-Event": []string{"Push Hook"},
+			},
+		},
+		{
+			name:     "TagPushEvent",
+			event:    TagEvents,
+			typ:      TagPushEventPayload{},
+			filename: "./testdata/tag-push-event.json",
+			headers: http.Header{
+				"X-Gitee-Event": []string{"Tag Push Hook"},
+			},
+		},
+		{
+			name:     "IssueEvent",
+			event:    IssuesEvents,
+			typ:      IssueEventPayload{},
+			filename: "./testdata/issue-event.json",
+			headers: http.Header{
+				"X-Gitee-Event": []string{"Issue Hook"},
+			},
+		},
+		{
+			name:     "MergeRequestEvent",
+			event:    MergeRequestEvents,
+			typ:      MergeRequestEventPayload{},
+			filename: "./testdata/merge-request-event.json",
+			headers: http.Header{
+				"X-Gitee-Event": []string{"Merge Request Hook"},
+			},
+		},
+		{
+			name:     "NoteEvent",
+			event:    CommentEvents,
+			typ:      NoteEventPayload{},
+			filename: "./testdata/note-event.json",
+			headers: http.Header{
+				"X-Gitee-Event": []string{"Note Hook"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		client := &http.Client{}
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			payload, err := os.Open(tc.filename)
+			assert.NoError(err)
+			defer func() {
+				_ = payload.Close()
+			}()
+
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, payload)
+			assert.NoError(err)
+			req.Header = tc.headers
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Gitee-Token", "sampleToken!")
+
+			resp, err := client.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestSignature(t *testing.T) {
+	assert := require.New(t)
+	const key = "SEC1a2b3c4d5e6f"
+	now := time.UnixMilli(1716169267000)
+	signed, err := New(Options.SigningKey(key), Options.Tolerance(time.Minute))
+	assert.NoError(err)
+	signed.now = func() time.Time { return now }
+
+	timestamp := func(t time.Time) string {
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	sign := func(key, timestamp string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		_, _ = io.WriteString(mac, timestamp+"\n"+key)
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	expired, future, skewed := timestamp(now.Add(-2*time.Minute)), timestamp(now.Add(2*time.Minute)), timestamp(now.Add(30*time.Second))
+	tests := []struct {
+		name      string
+		timestamp string
+		token     string
+		valid     bool
+		wantErr   error
+	}{
+		{name: "Valid", timestamp: timestamp(now), token: sign(key, timestamp(now)), valid: true},
+		{name: "ClockSkew", timestamp: skewed, token: sign(key, skewed), valid: true},
+		{name: "Expired", timestamp: expired, token: sign(key, expired), wantErr: ErrTimestampExpired},
+		{name: "Future", timestamp: future, token: sign(key, future), wantErr: ErrTimestampExpired},
+		{name: "WrongKey", timestamp: timestamp(now), token: sign("wrong", timestamp(now))},
+		{name: "OtherTimestamp", timestamp: skewed, token: sign(key, timestamp(now))},
+		{name: "PlainKey", timestamp: timestamp(now), token: key},
+		{name: "MissingTimestamp", token: sign(key, timestamp(now))},
+		{name: "MissingToken", timestamp: timestamp(now)},
+	}
+
+	for _, tc := range tests {
+		r := whtest.Gitee(PushEvents, whtest.Fixture(t, "./testdata/push-event.json")).
+			Header("X-Gitee-Token", tc.token).
+			Header("X-Gitee-Timestamp", tc.timestamp).
+			Request()
+		pl, err := signed.Parse(r, PushEvents)
+		switch {
+		case tc.valid:
+			assert.NoError(err, tc.name)
+			assert.Equal("refs/heads/master", pl.(PushEventPayload).Ref)
+		case tc.wantErr != nil:
+			assert.ErrorIs(err, tc.wantErr, tc.name)
+		default:
+			assert.Error(err, tc.name)
+		}
+	}
+
+	// the deliveries whtest signs for the current time pass with the default tolerance
+	signed, err = New(Options.SigningKey(key))
+	assert.NoError(err)
+	d := whtest.GiteeSigned(PushEvents, whtest.Fixture(t, "./testdata/push-event.json")).Secret(key)
+	_, err = signed.Parse(d.Request(), PushEvents)
+	assert.NoError(err)
+	_, err = signed.Parse(d.Tampered(), PushEvents)
+	assert.Error(err)
+	_, err = signed.Parse(d.Unsigned(), PushEvents)
+	assert.Error(err)
+}
+
+func TestOptions(t *testing.T) {
+	assert := require.New(t)
+	_, err := New(Options.Secret("password"), Options.SigningKey("key"))
+	assert.Error(err)
+	_, err = New(Options.SigningKey("key"), Options.Secret("password"))
+	assert.Error(err)
+	_, err = New(Options.Tolerance(0))
+	assert.Error(err)
+}
+
+func TestParseActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []EventActions
+		filename string
+		event    string
+		action   Action
+		wantErr  error
+	}{
+		{
+			name:     "MatchingAction",
+			filters:  []EventActions{On(MergeRequestEvents, OpenAction, MergeAction)},
+			filename: "./testdata/merge-request-event.json",
+			event:    "Merge Request Hook",
+			action:   OpenAction,
+		},
+		{
+			name:     "AnyAction",
+			filters:  []EventActions{On(CommentEvents)},
+			filename: "./testdata/note-event.json",
+			event:    "Note Hook",
+			action:   CommentAction,
+		},
+		{
+			name:     "UnmatchedAction",
+			filters:  []EventActions{On(IssuesEvents, StateChangeAction)},
+			filename: "./testdata/issue-event.json",
+			event:    "Issue Hook",
+			wantErr:  ErrActionNotDefined,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.ReadFile(tc.filename)
+			assert.NoError(err)
+
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+			req.Header.Set("X-Gitee-Token", "sampleToken!")
+			req.Header.Set("X-Gitee-Event", tc.event)
+			results, err := hook.ParseActions(req, tc.filters...)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(tc.action, ActionOf(results))
+		})
+	}
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("oschina/git-osc").Sender("lunny")
+	push := faker.PushEventPayload("master", 3)
+	assert.Equal("refs/heads/master", push.Ref)
+	assert.Len(push.Commits, 3)
+	assert.Equal(push.After, push.Commits[2].ID)
+	assert.Equal(push.After, push.HeadCommit.ID)
+	assert.Equal(int64(3), push.TotalCommitsCount)
+	assert.Equal("oschina/git-osc", push.Repository.FullName)
+	assert.Equal("lunny", push.Sender.Login)
+	assert.Equal(push, NewFaker("oschina/git-osc").Sender("lunny").PushEventPayload("master", 3))
+
+	mr := faker.MergeRequestEventPayload(MergeAction, "feature", "master")
+	assert.True(mr.PullRequest.Merged)
+	assert.Equal("feature", mr.SourceBranch)
+	assert.Equal("master", mr.PullRequest.Base.Ref)
+	assert.Equal(push.Repository.ID, mr.Repository.ID)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: PushEvents, payload: push},
+		{event: MergeRequestEvents, payload: mr},
+		{event: MergeRequestEvents, payload: faker.MergeRequestEventPayload(OpenAction, "feature", "master")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Gitee(tc.event, tc.payload).Secret("sampleToken!").Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{PushEvents, "./testdata/push-event.json"},
+		{TagEvents, "./testdata/tag-push-event.json"},
+		{IssuesEvents, "./testdata/issue-event.json"},
+		{MergeRequestEvents, "./testdata/merge-request-event.json"},
+		{CommentEvents, "./testdata/note-event.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), "", payload)
+	}
+	f.Add(string(seeds[0].event), "sampleToken", []byte("{}"))
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event, signature string, payload []byte) {
+		d := whtest.Gitee(event, payload).Secret("sampleToken!")
+		if signature != "" {
+			d.Header("X-Gitee-Token", signature)
+		}
+
+		r := d.Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
@@ -0,0 +1,302 @@
+package gitee
+
+import "time"
+
+// User contains all of the Gitee user information.
+type User struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Login     string     `json:"login"`
+	UserName  string     `json:"username"`
+	URL       string     `json:"url"`
+	HTMLURL   string     `json:"html_url"`
+	AvatarURL string     `json:"avatar_url"`
+	Type      string     `json:"type"`
+	SiteAdmin bool       `json:"site_admin"`
+	Time      *time.Time `json:"time"`
+	Remark    string     `json:"remark"`
+}
+
+// Project contains all of the Gitee repository information,
+// sent both as the repository and the project of the payloads.
+type Project struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	Path              string    `json:"path"`
+	FullName          string    `json:"full_name"`
+	Owner             *User     `json:"owner"`
+	Private           bool      `json:"private"`
+	Fork              bool      `json:"fork"`
+	Description       string    `json:"description"`
+	URL               string    `json:"url"`
+	HTMLURL           string    `json:"html_url"`
+	GitURL            string    `json:"git_url"`
+	SSHURL            string    `json:"ssh_url"`
+	CloneURL          string    `json:"clone_url"`
+	SVNURL            string    `json:"svn_url"`
+	GitHTTPURL        string    `json:"git_http_url"`
+	GitSSHURL         string    `json:"git_ssh_url"`
+	GitSVNURL         string    `json:"git_svn_url"`
+	Homepage          string    `json:"homepage"`
+	Language          string    `json:"language"`
+	License           string    `json:"license"`
+	StargazersCount   int64     `json:"stargazers_count"`
+	WatchersCount     int64     `json:"watchers_count"`
+	ForksCount        int64     `json:"forks_count"`
+	OpenIssuesCount   int64     `json:"open_issues_count"`
+	HasIssues         bool      `json:"has_issues"`
+	HasWiki           bool      `json:"has_wiki"`
+	HasPages          bool      `json:"has_pages"`
+	DefaultBranch     string    `json:"default_branch"`
+	Namespace         string    `json:"namespace"`
+	NameWithNamespace string    `json:"name_with_namespace"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	PushedAt          time.Time `json:"pushed_at"`
+}
+
+// Enterprise contains the Gitee enterprise a repository belongs to.
+type Enterprise struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// CommitUser contains the author or committer of a commit.
+type CommitUser struct {
+	ID       int64     `json:"id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	UserName string    `json:"username"`
+	URL      string    `json:"url"`
+	Time     time.Time `json:"time"`
+}
+
+// Commit contains all of the Gitee commit information.
+type Commit struct {
+	ID        string     `json:"id"`
+	TreeID    string     `json:"tree_id"`
+	ParentIDs []string   `json:"parent_ids"`
+	Distinct  bool       `json:"distinct"`
+	Message   string     `json:"message"`
+	Timestamp time.Time  `json:"timestamp"`
+	URL       string     `json:"url"`
+	Author    CommitUser `json:"author"`
+	Committer CommitUser `json:"committer"`
+	Added     []string   `json:"added"`
+	Removed   []string   `json:"removed"`
+	Modified  []string   `json:"modified"`
+}
+
+// Label contains all of the Gitee label information.
+type Label struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// Milestone contains all of the Gitee milestone information.
+type Milestone struct {
+	ID             int64      `json:"id"`
+	Number         int64      `json:"number"`
+	HTMLURL        string     `json:"html_url"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	OpenIssues     int64      `json:"open_issues"`
+	StartedIssues  int64      `json:"started_issues"`
+	ClosedIssues   int64      `json:"closed_issues"`
+	ApprovedIssues int64      `json:"approved_issues"`
+	State          string     `json:"state"`
+	DueOn          *time.Time `json:"due_on"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Issue contains all of the Gitee issue information.
+type Issue struct {
+	ID        int64      `json:"id"`
+	Number    string     `json:"number"`
+	HTMLURL   string     `json:"html_url"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	User      *User      `json:"user"`
+	Labels    []Label    `json:"labels"`
+	State     string     `json:"state"`
+	StateName string     `json:"state_name"`
+	TypeName  string     `json:"type_name"`
+	Assignee  *User      `json:"assignee"`
+	Milestone *Milestone `json:"milestone"`
+	Comments  int64      `json:"comments"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Branch contains the head or base branch of a pull request.
+type Branch struct {
+	Label string   `json:"label"`
+	Ref   string   `json:"ref"`
+	Sha   string   `json:"sha"`
+	User  *User    `json:"user"`
+	Repo  *Project `json:"repo"`
+}
+
+// PullRequest contains all of the Gitee pull request information.
+type PullRequest struct {
+	ID                 int64      `json:"id"`
+	Number             int64      `json:"number"`
+	State              string     `json:"state"`
+	HTMLURL            string     `json:"html_url"`
+	DiffURL            string     `json:"diff_url"`
+	PatchURL           string     `json:"patch_url"`
+	Title              string     `json:"title"`
+	Body               string     `json:"body"`
+	User               *User      `json:"user"`
+	Assignee           *User      `json:"assignee"`
+	Assignees          []User     `json:"assignees"`
+	Tester             *User      `json:"tester"`
+	Testers            []User     `json:"testers"`
+	NeedTest           bool       `json:"need_test"`
+	NeedReview         bool       `json:"need_review"`
+	Milestone          *Milestone `json:"milestone"`
+	Head               Branch     `json:"head"`
+	Base               Branch     `json:"base"`
+	Merged             bool       `json:"merged"`
+	Mergeable          bool       `json:"mergeable"`
+	MergeStatus        string     `json:"merge_status"`
+	MergeCommitSha     string     `json:"merge_commit_sha"`
+	MergeReferenceName string     `json:"merge_reference_name"`
+	UpdatedBy          *User      `json:"updated_by"`
+	Comments           int64      `json:"comments"`
+	Commits            int64      `json:"commits"`
+	Additions          int64      `json:"additions"`
+	Deletions          int64      `json:"deletions"`
+	ChangedFiles       int64      `json:"changed_files"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	ClosedAt           *time.Time `json:"closed_at"`
+	MergedAt           *time.Time `json:"merged_at"`
+}
+
+// Note contains all of the Gitee comment information.
+type Note struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	User      *User     `json:"user"`
+	HTMLURL   string    `json:"html_url"`
+	Position  string    `json:"position"`
+	CommitID  string    `json:"commit_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Hook contains the hook fields Gitee adds to every payload.
+// The timestamp is the one the signature was computed with, in milliseconds since the epoch.
+type Hook struct {
+	HookID    int64  `json:"hook_id"`
+	HookName  string `json:"hook_name"`
+	HookURL   string `json:"hook_url"`
+	Timestamp string `json:"timestamp"`
+}
+
+// PushEventPayload contains the information for Gitee's push event.
+type PushEventPayload struct {
+	Hook
+	Ref                string      `json:"ref"`
+	Before             string      `json:"before"`
+	After              string      `json:"after"`
+	Created            bool        `json:"created"`
+	Deleted            bool        `json:"deleted"`
+	Compare            string      `json:"compare"`
+	TotalCommitsCount  int64       `json:"total_commits_count"`
+	CommitsMoreThanTen bool        `json:"commits_more_than_ten"`
+	Commits            []Commit    `json:"commits"`
+	HeadCommit         *Commit     `json:"head_commit"`
+	Repository         *Project    `json:"repository"`
+	Project            *Project    `json:"project"`
+	UserID             int64       `json:"user_id"`
+	UserName           string      `json:"user_name"`
+	User               *User       `json:"user"`
+	Pusher             *User       `json:"pusher"`
+	Sender             *User       `json:"sender"`
+	Enterprise         *Enterprise `json:"enterprise"`
+}
+
+// TagPushEventPayload contains the information for Gitee's tag push event.
+type TagPushEventPayload PushEventPayload
+
+// IssueEventPayload contains the information for Gitee's issue event.
+type IssueEventPayload struct {
+	Hook
+	Action      Action      `json:"action"`
+	Issue       *Issue      `json:"issue"`
+	Repository  *Project    `json:"repository"`
+	Project     *Project    `json:"project"`
+	Sender      *User       `json:"sender"`
+	TargetUser  *User       `json:"target_user"`
+	User        *User       `json:"user"`
+	Assignee    *User       `json:"assignee"`
+	UpdatedBy   *User       `json:"updated_by"`
+	IID         string      `json:"iid"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	State       string      `json:"state"`
+	Milestone   string      `json:"milestone"`
+	URL         string      `json:"url"`
+	Enterprise  *Enterprise `json:"enterprise"`
+}
+
+// RepoInfo contains the source or target repository of a merge request.
+type RepoInfo struct {
+	Project    *Project `json:"project"`
+	Repository *Project `json:"repository"`
+}
+
+// MergeRequestEventPayload contains the information for Gitee's merge request event.
+type MergeRequestEventPayload struct {
+	Hook
+	Action         Action       `json:"action"`
+	ActionDesc     string       `json:"action_desc"`
+	PullRequest    *PullRequest `json:"pull_request"`
+	Number         int64        `json:"number"`
+	IID            int64        `json:"iid"`
+	Title          string       `json:"title"`
+	Body           string       `json:"body"`
+	State          string       `json:"state"`
+	MergeStatus    string       `json:"merge_status"`
+	MergeCommitSha string       `json:"merge_commit_sha"`
+	URL            string       `json:"url"`
+	SourceBranch   string       `json:"source_branch"`
+	SourceRepo     *RepoInfo    `json:"source_repo"`
+	TargetBranch   string       `json:"target_branch"`
+	TargetRepo     *RepoInfo    `json:"target_repo"`
+	Repository     *Project     `json:"repository"`
+	Project        *Project     `json:"project"`
+	Author         *User        `json:"author"`
+	UpdatedBy      *User        `json:"updated_by"`
+	Sender         *User        `json:"sender"`
+	TargetUser     *User        `json:"target_user"`
+	Enterprise     *Enterprise  `json:"enterprise"`
+}
+
+// NoteEventPayload contains the information for Gitee's comment event,
+// NoteableType tells whether the comment is on an "Issue", a "PullRequest" or a "Commit".
+type NoteEventPayload struct {
+	Hook
+	Action        Action       `json:"action"`
+	Comment       *Note        `json:"comment"`
+	Repository    *Project     `json:"repository"`
+	Project       *Project     `json:"project"`
+	Author        *User        `json:"author"`
+	Sender        *User        `json:"sender"`
+	URL           string       `json:"url"`
+	Note          string       `json:"note"`
+	NoteableType  string       `json:"noteable_type"`
+	NoteableID    int64        `json:"noteable_id"`
+	Title         string       `json:"title"`
+	PerIID        string       `json:"per_iid"`
+	ShortCommitID string       `json:"short_commit_id"`
+	Issue         *Issue       `json:"issue"`
+	PullRequest   *PullRequest `json:"pull_request"`
+	Enterprise    *Enterprise  `json:"enterprise"`
+}
@@ -0,0 +1,248 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic payloads of a repository to unit test handlers.
+// The payloads of a faker agree with each other on the repository, users, refs and SHAs,
+// and encode to the JSON GitHub sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same payloads.
+type Faker struct {
+	owner  string
+	name   string
+	id     int64
+	sender string
+	src    *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given full name, e.g. "octocat/hello-world".
+// Deliveries are sent by the repository owner.
+func NewFaker(fullName string) *Faker {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok {
+		owner, name = "octocat", fullName
+	}
+	src := fake.New("github/" + fullName)
+	return &Faker{owner: owner, name: name, id: src.ID(), sender: owner, src: src}
+}
+
+// Sender sets the login of the user triggering the events.
+func (f *Faker) Sender(login string) *Faker {
+	f.sender = login
+	return f
+}
+
+// SHA returns a new commit SHA.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// PushPayload returns the push of commits to ref, a branch name or a full ref such as "refs/tags/v1.0.0".
+// The commits follow each other, the last one being the head commit and the after SHA.
+func (f *Faker) PushPayload(ref string, commits int) PushPayload {
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	var pl PushPayload
+	before := f.SHA()
+	after := before
+	pl.Commits = fake.Make(pl.Commits, commits)
+	for i := range pl.Commits {
+		after = f.SHA()
+		values := f.commit(after, fmt.Sprintf("Change %d", i+1))
+		fake.Set(&pl.Commits[i], values)
+		if i == len(pl.Commits)-1 {
+			fake.Set(&pl.HeadCommit, values)
+		}
+	}
+
+	fake.Set(&pl, map[string]interface{}{
+		"ref":     ref,
+		"before":  before,
+		"after":   after,
+		"compare": fmt.Sprintf("%s/compare/%s...%s", f.htmlURL(), before[:12], after[:12]),
+	})
+	f.repository(&pl.Repository)
+	fake.Set(&pl.Repository, map[string]interface{}{"master_branch": "main"})
+	f.user(&pl.Sender, f.sender)
+	fake.Set(&pl.Pusher, map[string]interface{}{"name": f.sender, "email": f.email(f.sender)})
+	return pl
+}
+
+// PullRequestPayload returns a pull request event merging the head branch into the base branch.
+// Closing actions mark the pull request as merged.
+func (f *Faker) PullRequestPayload(action Action, head, base string) PullRequestPayload {
+	var pl PullRequestPayload
+	id := f.src.ID()
+	number := id%1000 + 1
+	created := f.src.Time()
+	headSHA, baseSHA := f.SHA(), f.SHA()
+	api := fmt.Sprintf("%s/pulls/%d", f.apiURL(), number)
+	issue := fmt.Sprintf("%s/issues/%d", f.apiURL(), number)
+	html := fmt.Sprintf("%s/pull/%d", f.htmlURL(), number)
+	values := map[string]interface{}{
+		"url":                         api,
+		"id":                          id,
+		"node_id":                     f.nodeID("PR_", id),
+		"html_url":                    html,
+		"diff_url":                    html + ".diff",
+		"patch_url":                   html + ".patch",
+		"issue_url":                   issue,
+		"number":                      number,
+		"state":                       "open",
+		"title":                       "Merge " + head + " into " + base,
+		"body":                        "Changes of " + head + ".",
+		"created_at":                  created,
+		"updated_at":                  created,
+		"commits_url":                 api + "/commits",
+		"review_comments_url":         api + "/comments",
+		"review_comment_url":          f.apiURL() + "/pulls/comments{/number}",
+		"comments_url":                issue + "/comments",
+		"statuses_url":                f.apiURL() + "/statuses/" + headSHA,
+		"head.label":                  f.owner + ":" + head,
+		"head.ref":                    head,
+		"head.sha":                    headSHA,
+		"base.label":                  f.owner + ":" + base,
+		"base.ref":                    base,
+		"base.sha":                    baseSHA,
+		"_links.self.href":            api,
+		"_links.html.href":            html,
+		"_links.issue.href":           issue,
+		"_links.comments.href":        issue + "/comments",
+		"_links.review_comments.href": api + "/comments",
+		"_links.review_comment.href":  f.apiURL() + "/pulls/comments{/number}",
+		"_links.commits.href":         api + "/commits",
+		"_links.statuses.href":        f.apiURL() + "/statuses/" + headSHA,
+		"mergeable_state":             "clean",
+		"commits":                     1,
+		"additions":                   1,
+		"changed_files":               1,
+	}
+
+	if action == ClosedAction {
+		merged := f.src.Time()
+		values["state"] = "closed"
+		values["updated_at"] = merged
+		values["closed_at"] = merged
+		values["merged_at"] = merged
+		values["merged"] = true
+		values["merge_commit_sha"] = f.SHA()
+	}
+
+	fake.Set(&pl.PullRequest, values)
+	f.user(&pl.PullRequest.User, f.sender)
+	f.user(&pl.PullRequest.Head.User, f.owner)
+	f.user(&pl.PullRequest.Base.User, f.owner)
+	f.repository(&pl.PullRequest.Head.Repo)
+	f.repository(&pl.PullRequest.Base.Repo)
+	f.repository(&pl.Repository)
+	f.user(&pl.Sender, f.sender)
+	if action == ClosedAction {
+		pl.PullRequest.MergedBy = &MergedBy{}
+		f.user(pl.PullRequest.MergedBy, f.sender)
+	}
+
+	pl.Action = action
+	pl.Number = number
+	return pl
+}
+
+func (f *Faker) commit(sha, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                 sha,
+		"tree_id":            f.SHA(),
+		"distinct":           true,
+		"message":            message,
+		"timestamp":          f.src.Time(),
+		"url":                f.htmlURL() + "/commit/" + sha,
+		"author.name":        f.sender,
+		"author.email":       f.email(f.sender),
+		"author.username":    f.sender,
+		"committer.name":     f.sender,
+		"committer.email":    f.email(f.sender),
+		"committer.username": f.sender,
+		"modified":           []string{"README.md"},
+		"added":              []string{},
+		"removed":            []string{},
+	}
+}
+
+func (f *Faker) repository(v interface{}) {
+	created := fake.Epoch
+	fake.Set(v, map[string]interface{}{
+		"id":                f.id,
+		"node_id":           f.nodeID("R_", f.id),
+		"name":              f.name,
+		"full_name":         f.owner + "/" + f.name,
+		"html_url":          f.htmlURL(),
+		"url":               f.apiURL(),
+		"description":       "The " + f.name + " repository",
+		"forks_url":         f.apiURL() + "/forks",
+		"hooks_url":         f.apiURL() + "/hooks",
+		"events_url":        f.apiURL() + "/events",
+		"branches_url":      f.apiURL() + "/branches{/branch}",
+		"tags_url":          f.apiURL() + "/tags",
+		"statuses_url":      f.apiURL() + "/statuses/{sha}",
+		"commits_url":       f.apiURL() + "/commits{/sha}",
+		"compare_url":       f.apiURL() + "/compare/{base}...{head}",
+		"issues_url":        f.apiURL() + "/issues{/number}",
+		"pulls_url":         f.apiURL() + "/pulls{/number}",
+		"created_at":        created,
+		"updated_at":        created,
+		"pushed_at":         created,
+		"git_url":           "git://github.com/" + f.owner + "/" + f.name + ".git",
+		"ssh_url":           "git@github.com:" + f.owner + "/" + f.name + ".git",
+		"clone_url":         f.htmlURL() + ".git",
+		"svn_url":           f.htmlURL(),
+		"has_issues":        true,
+		"has_downloads":     true,
+		"has_wiki":          true,
+		"default_branch":    "main",
+		"owner.login":       f.owner,
+		"owner.id":          fake.NameID(f.owner),
+		"owner.node_id":     f.nodeID("U_", fake.NameID(f.owner)),
+		"owner.avatar_url":  fmt.Sprintf("https://avatars.githubusercontent.com/u/%d?v=4", fake.NameID(f.owner)),
+		"owner.url":         "https://api.github.com/users/" + f.owner,
+		"owner.html_url":    "https://github.com/" + f.owner,
+		"owner.repos_url":   "https://api.github.com/users/" + f.owner + "/repos",
+		"owner.events_url":  "https://api.github.com/users/" + f.owner + "/events{/privacy}",
+		"owner.type":        "User",
+		"owner.gravatar_id": "",
+	})
+}
+
+func (f *Faker) user(v interface{}, login string) {
+	fake.Set(v, map[string]interface{}{
+		"login":       login,
+		"id":          fake.NameID(login),
+		"node_id":     f.nodeID("U_", fake.NameID(login)),
+		"avatar_url":  fmt.Sprintf("https://avatars.githubusercontent.com/u/%d?v=4", fake.NameID(login)),
+		"url":         "https://api.github.com/users/" + login,
+		"html_url":    "https://github.com/" + login,
+		"repos_url":   "https://api.github.com/users/" + login + "/repos",
+		"events_url":  "https://api.github.com/users/" + login + "/events{/privacy}",
+		"type":        "User",
+		"gravatar_id": "",
+	})
+}
+
+func (f *Faker) nodeID(prefix string, id int64) string {
+	return fmt.Sprintf("%s%08x", prefix, id)
+}
+
+func (f *Faker) email(login string) string {
+	return login + "@users.noreply.github.com"
+}
+
+func (f *Faker) htmlURL() string {
+	return "https://github.com/" + f.owner + "/" + f.name
+}
+
+func (f *Faker) apiURL() string {
+	return "https://api.github.com/repos/" + f.owner + "/" + f.name
+}
@@ -8,8 +8,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
 )
 
 const (
@@ -69,6 +75,83 @@ const (
 	BranchSubtype EventSubtype = "branch"
 )
 
+const (
+	// GitHub hook event actions.
+	AddedAction                  Action = "added"
+	EditedAction                 Action = "edited"
+	MovedAction                  Action = "moved"
+	FixedAction                  Action = "fixed"
+	QueuedAction                 Action = "queued"
+	OpenedAction                 Action = "opened"
+	ClosedAction                 Action = "closed"
+	LockedAction                 Action = "locked"
+	PinnedAction                 Action = "pinned"
+	BlockedAction                Action = "blocked"
+	CreatedAction                Action = "created"
+	DeletedAction                Action = "deleted"
+	RemovedAction                Action = "removed"
+	RenamedAction                Action = "renamed"
+	StartedAction                Action = "started"
+	WaitingAction                Action = "waiting"
+	ReleasedAction               Action = "released"
+	RevokedAction                Action = "revoked"
+	SuspendAction                Action = "suspend"
+	LabeledAction                Action = "labeled"
+	UpdatedAction                Action = "updated"
+	ArchivedAction               Action = "archived"
+	AssignedAction               Action = "assigned"
+	ReopenedAction               Action = "reopened"
+	UnlockedAction               Action = "unlocked"
+	UnpinnedAction               Action = "unpinned"
+	CompletedAction              Action = "completed"
+	ConvertedAction              Action = "converted"
+	DismissedAction              Action = "dismissed"
+	PerformedAction              Action = "performed"
+	PublishedAction              Action = "published"
+	RequestedAction              Action = "requested"
+	SubmittedAction              Action = "submitted"
+	UnblockedAction              Action = "unblocked"
+	UnlabeledAction              Action = "unlabeled"
+	UnsuspendAction              Action = "unsuspend"
+	WithdrawnAction              Action = "withdrawn"
+	InProgressAction             Action = "in_progress"
+	MilestonedAction             Action = "milestoned"
+	PrivatizedAction             Action = "privatized"
+	PublicizedAction             Action = "publicized"
+	UnarchivedAction             Action = "unarchived"
+	UnassignedAction             Action = "unassigned"
+	SynchronizeAction            Action = "synchronize"
+	TransferredAction            Action = "transferred"
+	MemberAddedAction            Action = "member_added"
+	PrereleasedAction            Action = "prereleased"
+	RerequestedAction            Action = "rerequested"
+	UnpublishedAction            Action = "unpublished"
+	DemilestonedAction           Action = "demilestoned"
+	AppearedInBranchAction       Action = "appeared_in_branch"
+	AutoDismissedAction          Action = "auto_dismissed"
+	AutoReopenedAction           Action = "auto_reopened"
+	ClosedByUserAction           Action = "closed_by_user"
+	MemberInvitedAction          Action = "member_invited"
+	MemberRemovedAction          Action = "member_removed"
+	ReintroducedAction           Action = "reintroduced"
+	ReadyForReviewAction         Action = "ready_for_review"
+	ReopenedByUserAction         Action = "reopened_by_user"
+	RequestedActionAction        Action = "requested_action"
+	ReviewRequestedAction        Action = "review_requested"
+	ConvertedToDraftAction       Action = "converted_to_draft"
+	AutoMergeEnabledAction       Action = "auto_merge_enabled"
+	AutoMergeDisabledAction      Action = "auto_merge_disabled"
+	ReviewRequestRemovedAction   Action = "review_request_removed"
+	NewPermissionsAcceptedAction Action = "new_permissions_accepted"
+)
+
+// ErrActionNotDefined is returned by ParseActions when the action
+// of a parsed event is not one of the actions asked to be parsed.
+var ErrActionNotDefined = errors.New("action not defined to be parsed")
+
+// ErrParsingPayload is returned when a payload cannot be read or decoded.
+var ErrParsingPayload = errors.New("error parsing payload")
+
 // Options is a namespace var for configuration options.
 var Options = WebhookOptions{}
 
@@ -78,12 +161,33 @@ type Event string
 // EventSubtype defines a GitHub Hook Event subtype.
 type EventSubtype string
 
+// Action defines a GitHub hook event action, sent in the "action" field of the payload.
+type Action string
+
+// EventActions pairs an event with the actions of it that should be parsed.
+// An empty Actions list matches every action of the event.
+type EventActions = observe.EventActions[Event, Action]
+
+// On returns an EventActions filter for the event and the given actions.
+func On(event Event, actions ...Action) EventActions {
+	return EventActions{Event: event, Actions: actions}
+}
+
 // Option is a configuration option for the webhook.
 type Option func(*Webhook) error
 
+// provider describes the deliveries of GitHub to the instrumentation.
+var provider = &observe.Provider{
+	Name:           "github",
+	EventHeader:    "X-GitHub-Event",
+	DeliveryHeader: "X-GitHub-Delivery",
+	SecretHeaders:  []string{"X-Hub-Signature", "X-Hub-Signature-256"},
+}
+
 // Webhook instance contains all methods needed to process events.
 type Webhook struct {
-	secret string
+	secret   string
+	observer observe.Observer
 }
 
 // New creates and returns a WebHook instance denoted by the Provider type.
@@ -98,7 +202,19 @@ func New(options ...Option) (*Webhook, error) {
 }
 
 // Parse verifies and parses the events specified and returns the payload object or an error.
-func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error) {
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
 	defer func() {
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
@@ -133,14 +249,14 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil || len(payload) == 0 {
-		return nil, errors.New("error parsing payload")
+		return nil, ErrParsingPayload
 	}
 
 	// If we have a Secret set, we should check the MAC
-	if len(hook.secret) > 0 {
+	err = d.Verify(len(hook.secret) > 0, func() error {
 		signature := r.Header.Get("X-Hub-Signature-256")
 		if len(signature) == 0 {
-			return nil, errors.New("missing X-Hub-Signature-256 Header")
+			return errors.New("missing X-Hub-Signature-256 Header")
 		}
 
 		signature = strings.TrimPrefix(signature, "sha256=")
@@ -148,10 +264,15 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 		_, _ = mac.Write(payload)
 		expectedMAC := hex.EncodeToString(mac.Sum(nil))
 		if !hmac.Equal([]byte(signature), []byte(expectedMAC)) {
-			return nil, errors.New("HMAC verification failed")
+			return errors.New("HMAC verification failed")
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	d.Decoding()
 	switch gitHubEvent {
 	case CheckRunEvent:
 		var pl CheckRunPayload
@@ -338,6 +459,27 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 	}
 }
 
+// ParseActions verifies and parses the events specified in filters
+// and returns the payload object only if its action matches one of the actions
+// registered for the event, otherwise ErrActionNotDefined is returned.
+func (hook Webhook) ParseActions(r *http.Request, filters ...EventActions) (interface{}, error) {
+	pl, err := hook.Parse(r, observe.Events(filters)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !observe.Matches(filters, Event(r.Header.Get("X-GitHub-Event")), pl) {
+		return nil, ErrActionNotDefined
+	}
+	return pl, nil
+}
+
+// ActionOf returns the action of a parsed payload,
+// or an empty Action if the payload has no action.
+func ActionOf(payload interface{}) Action {
+	return Action(observe.Action(payload))
+}
+
 // WebhookOptions is a namespace for configuration option methods.
 type WebhookOptions struct{}
 
@@ -348,3 +490,38 @@ func (WebhookOptions) Secret(secret string) Option {
 		return nil
 	}
 }
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
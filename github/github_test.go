@@ -13,6 +13,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/pchchv/wh/whtest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -581,8 +582,185 @@ func TestBadRequests(t *testing.T) {
 	}
 }
 
+func TestParseActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []EventActions
+		filename string
+		event    string
+		action   Action
+		wantErr  error
+	}{
+		{
+			name:     "MatchingAction",
+			filters:  []EventActions{On(PullRequestEvent, ClosedAction, OpenedAction)},
+			filename: "./testdata/pull-request.json",
+			event:    "pull_request",
+			action:   OpenedAction,
+		},
+		{
+			name:     "AnyAction",
+			filters:  []EventActions{On(IssuesEvent), On(PullRequestEvent, ClosedAction)},
+			filename: "./testdata/issues.json",
+			event:    "issues",
+			action:   OpenedAction,
+		},
+		{
+			name:     "UnmatchedAction",
+			filters:  []EventActions{On(PullRequestEvent, SynchronizeAction)},
+			filename: "./testdata/pull-request.json",
+			event:    "pull_request",
+			wantErr:  ErrActionNotDefined,
+		},
+		{
+			name:     "NoActionPayload",
+			filters:  []EventActions{On(PushEvent, CreatedAction)},
+			filename: "./testdata/push.json",
+			event:    "push",
+			wantErr:  ErrActionNotDefined,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.ReadFile(tc.filename)
+			assert.NoError(err)
+
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+			req.Header.Set("X-Github-Event", tc.event)
+			mac := hmac.New(sha256.New, []byte(hook.secret))
+			mac.Write(payload)
+			req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+			results, err := hook.ParseActions(req, tc.filters...)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(tc.action, ActionOf(results))
+		})
+	}
+}
+
 func newServer(handler http.HandlerFunc) *httptest.Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc(path, handler)
 	return httptest.NewServer(mux)
 }
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("octocat/hello-world").Sender("hubot")
+	push := faker.PushPayload("main", 3)
+	assert.Equal("refs/heads/main", push.Ref)
+	assert.Len(push.Commits, 3)
+	assert.Equal(push.After, push.Commits[2].ID)
+	assert.Equal(push.After, push.HeadCommit.ID)
+	assert.Equal("octocat/hello-world", push.Repository.FullName)
+	assert.Equal("hubot", push.Sender.Login)
+	assert.Equal(push, NewFaker("octocat/hello-world").Sender("hubot").PushPayload("main", 3))
+
+	pr := faker.PullRequestPayload(ClosedAction, "feature", "main")
+	assert.True(pr.PullRequest.Merged)
+	assert.Equal("feature", pr.PullRequest.Head.Ref)
+	assert.Equal("main", pr.PullRequest.Base.Ref)
+	assert.Equal(push.Repository.ID, pr.Repository.ID)
+	assert.Equal(push.Repository.HTMLURL, pr.PullRequest.Base.Repo.HTMLURL)
+	assert.NotEqual(push.After, pr.PullRequest.Head.Sha)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: PushEvent, payload: push},
+		{event: PullRequestEvent, payload: pr},
+		{event: PullRequestEvent, payload: faker.PullRequestPayload(OpenedAction, "feature", "main")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.GitHub(tc.event, tc.payload).Secret(hook.secret).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{CheckRunEvent, "./testdata/check-run.json"},
+		{CheckSuiteEvent, "./testdata/check-suite.json"},
+		{CommitCommentEvent, "./testdata/commit-comment.json"},
+		{CreateEvent, "./testdata/create.json"},
+		{DeleteEvent, "./testdata/delete.json"},
+		{DependabotAlertEvent, "./testdata/dependabot_alert.json"},
+		{DeployKeyEvent, "./testdata/deploy_key.json"},
+		{DeploymentEvent, "./testdata/deployment.json"},
+		{DeploymentStatusEvent, "./testdata/deployment-status.json"},
+		{ForkEvent, "./testdata/fork.json"},
+		{GollumEvent, "./testdata/gollum.json"},
+		{InstallationEvent, "./testdata/installation.json"},
+		{InstallationRepositoriesEvent, "./testdata/installation-repositories.json"},
+		{IntegrationInstallationEvent, "./testdata/integration-installation.json"},
+		{IntegrationInstallationRepositoriesEvent, "./testdata/integration-installation-repositories.json"},
+		{IssueCommentEvent, "./testdata/issue-comment.json"},
+		{IssueCommentEvent, "./testdata/pull-request-issue-comment.json"},
+		{IssuesEvent, "./testdata/issues.json"},
+		{LabelEvent, "./testdata/label.json"},
+		{MemberEvent, "./testdata/member.json"},
+		{MembershipEvent, "./testdata/membership.json"},
+		{MilestoneEvent, "./testdata/milestone.json"},
+		{OrganizationEvent, "./testdata/organization.json"},
+		{OrgBlockEvent, "./testdata/org-block.json"},
+		{PageBuildEvent, "./testdata/page-build.json"},
+		{PingEvent, "./testdata/ping.json"},
+		{ProjectCardEvent, "./testdata/project-card.json"},
+		{ProjectColumnEvent, "./testdata/project-column.json"},
+		{ProjectEvent, "./testdata/project.json"},
+		{PublicEvent, "./testdata/public.json"},
+		{PullRequestEvent, "./testdata/pull-request.json"},
+		{PullRequestReviewEvent, "./testdata/pull-request-review.json"},
+		{PullRequestReviewCommentEvent, "./testdata/pull-request-review-comment.json"},
+		{PushEvent, "./testdata/push.json"},
+		{ReleaseEvent, "./testdata/release.json"},
+		{RepositoryEvent, "./testdata/repository.json"},
+		{RepositoryEvent, "./testdata/repository-edited.json"},
+		{RepositoryVulnerabilityAlertEvent, "./testdata/repository-vulnerability-alert.json"},
+		{SecurityAdvisoryEvent, "./testdata/security-advisory.json"},
+		{StatusEvent, "./testdata/status.json"},
+		{TeamEvent, "./testdata/team.json"},
+		{TeamAddEvent, "./testdata/team-add.json"},
+		{WatchEvent, "./testdata/watch.json"},
+		{WorkflowDispatchEvent, "./testdata/workflow_dispatch.json"},
+		{WorkflowJobEvent, "./testdata/workflow_job.json"},
+		{WorkflowRunEvent, "./testdata/workflow_run.json"},
+		{GitHubAppAuthorizationEvent, "./testdata/github-app-authorization.json"},
+		{CodeScanningAlertEvent, "./testdata/code_scanning_alert.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), "", payload)
+	}
+	f.Add(string(seeds[0].event), "sha256", []byte("{}"))
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event, signature string, payload []byte) {
+		d := whtest.GitHub(event, payload).Secret(hook.secret)
+		if signature != "" {
+			d.Header("X-Hub-Signature-256", signature)
+		}
+
+		r := d.Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
@@ -162,7 +162,7 @@ type Asset struct {
 
 // GitHubAppAuthorizationPayload contains revoke action payload.
 type GitHubAppAuthorizationPayload struct {
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Sender struct {
 		ID                int64  `json:"id"`
 		URL               string `json:"url"`
@@ -188,7 +188,7 @@ type GitHubAppAuthorizationPayload struct {
 // MembershipPayload contains the information for GitHub's membership hook event.
 type MembershipPayload struct {
 	Scope  string `json:"scope"`
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Member struct {
 		Login             string `json:"login"`
 		ID                int64  `json:"id"`
@@ -245,7 +245,7 @@ type MembershipPayload struct {
 
 // OrganizationPayload contains the information for GitHub's organization hook event.
 type OrganizationPayload struct {
-	Action     string `json:"action"`
+	Action     Action `json:"action"`
 	Invitation struct {
 		ID     int64   `json:"id"`
 		NodeID string  `json:"node_id"`
@@ -317,7 +317,7 @@ type OrganizationPayload struct {
 
 // OrgBlockPayload contains the information for GitHub's org_block hook event.
 type OrgBlockPayload struct {
-	Action      string `json:"action"`
+	Action      Action `json:"action"`
 	BlockedUser struct {
 		Login             string `json:"login"`
 		ID                int64  `json:"id"`
@@ -377,7 +377,7 @@ type OrgBlockPayload struct {
 // RepositoryVulnerabilityAlertEvent contains the
 // information for GitHub's repository_vulnerability_alert hook event.
 type RepositoryVulnerabilityAlertPayload struct {
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Alert  struct {
 		ID                  int64  `json:"id"`
 		Summary             string `json:"summary"`
@@ -411,7 +411,7 @@ type RepositoryVulnerabilityAlertPayload struct {
 
 // RepositoryPayload contains the information for GitHub's repository hook event.
 type RepositoryPayload struct {
-	Action  string `json:"action"`
+	Action  Action `json:"action"`
 	Changes struct {
 		DefaultBranch struct {
 			From string `json:"from"`
@@ -559,7 +559,7 @@ type RepositoryPayload struct {
 // TeamPayload contains the information for GitHub's team hook event.
 type TeamPayload struct {
 	Team         *Team  `json:"team"`
-	Action       string `json:"action"`
+	Action       Action `json:"action"`
 	Organization struct {
 		Login            string `json:"login"`
 		ID               int64  `json:"id"`
@@ -724,7 +724,7 @@ type TeamAddPayload struct {
 
 // CommitCommentPayload contains the information for GitHub's commit_comment hook event.
 type CommitCommentPayload struct {
-	Action  string `json:"action"`
+	Action  Action `json:"action"`
 	Comment struct {
 		URL     string `json:"url"`
 		HTMLURL string `json:"html_url"`
@@ -875,7 +875,7 @@ type CommitCommentPayload struct {
 
 // ProjectCardPayload contains the information for GitHub's project_payload hook event.
 type ProjectCardPayload struct {
-	Action      string `json:"action"`
+	Action      Action `json:"action"`
 	ProjectCard struct {
 		URL        string  `json:"url"`
 		ProjectURL string  `json:"project_url"`
@@ -1032,7 +1032,7 @@ type ProjectCardPayload struct {
 
 // ProjectColumnPayload contains the information for GitHub's project_column hook event.
 type ProjectColumnPayload struct {
-	Action        string `json:"action"`
+	Action        Action `json:"action"`
 	ProjectColumn struct {
 		URL        string `json:"url"`
 		ProjectURL string `json:"project_url"`
@@ -1167,7 +1167,7 @@ type ProjectColumnPayload struct {
 
 // ProjectPayload contains the information for GitHub's project hook event.
 type ProjectPayload struct {
-	Action  string `json:"action"`
+	Action  Action `json:"action"`
 	Project struct {
 		OwnerURL   string `json:"owner_url"`
 		URL        string `json:"url"`
@@ -1438,7 +1438,7 @@ type PublicPayload struct {
 
 // MilestonePayload contains the information for GitHub's milestone hook event
 type MilestonePayload struct {
-	Action    string `json:"action"`
+	Action    Action `json:"action"`
 	Milestone struct {
 		URL         string  `json:"url"`
 		HTMLURL     string  `json:"html_url"`
@@ -1750,7 +1750,7 @@ type PageBuildPayload struct {
 
 // PullRequestPayload contains the information for GitHub's pull_request hook event.
 type PullRequestPayload struct {
-	Action      string `json:"action"`
+	Action      Action `json:"action"`
 	Number      int64  `json:"number"`
 	PullRequest struct {
 		URL      string `json:"url"`
@@ -2241,7 +2241,7 @@ type PullRequestPayload struct {
 
 // PullRequestReviewPayload contains the information for GitHub's pull_request_review hook event
 type PullRequestReviewPayload struct {
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Review struct {
 		ID     int64  `json:"id"`
 		NodeID string `json:"node_id"`
@@ -2699,7 +2699,7 @@ type PullRequestReviewPayload struct {
 
 // PullRequestReviewCommentPayload contains the information for GitHub's pull_request_review_comments hook event.
 type PullRequestReviewCommentPayload struct {
-	Action  string `json:"action"`
+	Action  Action `json:"action"`
 	Comment struct {
 		URL              string `json:"url"`
 		ID               int64  `json:"id"`
@@ -3544,7 +3544,7 @@ type ForkPayload struct {
 
 // IssueCommentPayload contains the information for GitHub's issue_comment hook event.
 type IssueCommentPayload struct {
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Issue  struct {
 		URL         string `json:"url"`
 		LabelsURL   string `json:"labels_url"`
@@ -3753,7 +3753,7 @@ type IssueCommentPayload struct {
 
 // IssuesPayload contains the information for GitHub's issues hook event.
 type IssuesPayload struct {
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Issue  struct {
 		URL         string `json:"url"`
 		LabelsURL   string `json:"labels_url"`
@@ -3930,7 +3930,7 @@ type IssuesPayload struct {
 
 // CodeScanningAlertPayload contains code scanning alert payload.
 type CodeScanningAlertPayload struct {
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Alert  struct {
 		Number           int         `json:"number"`
 		CreatedAt        time.Time   `json:"created_at"`
@@ -4132,7 +4132,7 @@ type CodeScanningAlertPayload struct {
 
 // ReleasePayload contains the information for GitHub's release hook event.
 type ReleasePayload struct {
-	Action  string `json:"action"`
+	Action  Action `json:"action"`
 	Release struct {
 		ID              int64   `json:"id"`
 		URL             string  `json:"url"`
@@ -4615,7 +4615,7 @@ type DeploymentPayload struct {
 
 // DeployKeyPayload contains the information for GitHub's deploy_key hook.
 type DeployKeyPayload struct {
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Key    struct {
 		ID        int       `json:"id"`
 		Key       string    `json:"key"`
@@ -4742,7 +4742,7 @@ type DeployKeyPayload struct {
 
 // CheckRunPayload contains the information for GitHub's check_run hook event.
 type CheckRunPayload struct {
-	Action   string `json:"action"`
+	Action   Action `json:"action"`
 	CheckRun struct {
 		ID          int64     `json:"id"`
 		NodeID      string    `json:"node_id"`
@@ -4952,7 +4952,7 @@ type CheckRunPayload struct {
 
 // CheckSuitePayload contains the information for GitHub's check_suite hook event.
 type CheckSuitePayload struct {
-	Action     string `json:"action"`
+	Action     Action `json:"action"`
 	CheckSuite struct {
 		ID           int64                `json:"id"`
 		NodeID       string               `json:"node_id"`
@@ -5389,7 +5389,7 @@ type PingPayload struct {
 
 // WatchPayload contains the information for GitHub's watch hook event.
 type WatchPayload struct {
-	Action     string `json:"action"`
+	Action     Action `json:"action"`
 	Repository struct {
 		ID       int64  `json:"id"`
 		Name     string `json:"name"`
@@ -5503,7 +5503,7 @@ type WatchPayload struct {
 
 // DependabotAlertPayload contains the information for GitHub's dependabot_alert hook event.
 type DependabotAlertPayload struct {
-	Action string `json:"action"` // "created", "dissmissed", "fixed", "reintroduced", "reopened"
+	Action Action `json:"action"` // "created", "dissmissed", "fixed", "reintroduced", "reopened"
 	Alert  struct {
 		Number     uint32 `json:"number"`
 		State      string `json:"state"` // "dissmissed", "fixed", "open"
@@ -5710,9 +5710,9 @@ type DependabotAlertPayload struct {
 
 // DeletePayload contains the information for GitHub's delete hook event.
 type DeletePayload struct {
-	Ref        string `json:"ref"`
-	RefType    string `json:"ref_type"`
-	PusherType string `json:"pusher_type"`
+	Ref        string       `json:"ref"`
+	RefType    EventSubtype `json:"ref_type"`
+	PusherType string       `json:"pusher_type"`
 	Repository struct {
 		ID       int64  `json:"id"`
 		NodeID   string `json:"node_id"`
@@ -5958,7 +5958,7 @@ type WorkflowDispatchPayload struct {
 
 // WorkflowJobPayload contains the information for GitHub's workflow job event.
 type WorkflowJobPayload struct {
-	Action      string `json:"action"`
+	Action      Action `json:"action"`
 	WorkflowJob struct {
 		ID          int64     `json:"id"`
 		RunID       int64     `json:"run_id"`
@@ -6143,7 +6143,7 @@ type WorkflowJobPayload struct {
 
 // WorkflowRunPayload contains the information for GitHub's workflow run event.
 type WorkflowRunPayload struct {
-	Action      string `json:"action"`
+	Action      Action `json:"action"`
 	WorkflowRun struct {
 		ID               int64     `json:"id"`
 		Name             string    `json:"name"`
@@ -6482,7 +6482,7 @@ type WorkflowRunPayload struct {
 
 // InstallationPayload contains the information for GitHub's installation and integration_installation hook events.
 type InstallationPayload struct {
-	Action       string `json:"action"`
+	Action       Action `json:"action"`
 	Installation struct {
 		ID      int64  `json:"id"`
 		NodeID  string `json:"node_id"`
@@ -6555,7 +6555,7 @@ type InstallationPayload struct {
 
 // InstallationRepositoriesPayload contains the information for GitHub's installation_repositories hook events.
 type InstallationRepositoriesPayload struct {
-	Action       string `json:"action"`
+	Action       Action `json:"action"`
 	Installation struct {
 		ID      int64  `json:"id"`
 		NodeID  string `json:"node_id"`
@@ -6644,7 +6644,7 @@ type GollumPayload struct {
 		PageName string  `json:"page_name"`
 		Title    string  `json:"title"`
 		Summary  *string `json:"summary"`
-		Action   string  `json:"action"`
+		Action   Action  `json:"action"`
 		Sha      string  `json:"sha"`
 		HTMLURL  string  `json:"html_url"`
 	} `json:"pages"`
@@ -6761,7 +6761,7 @@ type GollumPayload struct {
 
 // SecurityAdvisoryPayload contains the information for GitHub's security_advisory hook event.
 type SecurityAdvisoryPayload struct {
-	Action           string `json:"action"`
+	Action           Action `json:"action"`
 	SecurityAdvisory struct {
 		GHSAID      string `json:"ghsa_id"`
 		Summary     string `json:"summary"`
@@ -6793,7 +6793,7 @@ type SecurityAdvisoryPayload struct {
 
 // MemberPayload contains the information for GitHub's member hook event
 type MemberPayload struct {
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Member struct {
 		Login             string `json:"login"`
 		ID                int64  `json:"id"`
@@ -7123,7 +7123,7 @@ type StatusPayload struct {
 
 // LabelPayload contains the information for GitHub's label hook event.
 type LabelPayload struct {
-	Action string `json:"action"`
+	Action Action `json:"action"`
 	Label  struct {
 		ID          int64  `json:"id"`
 		NodeID      string `json:"node_id"`
@@ -7260,11 +7260,11 @@ type LabelPayload struct {
 
 // CreatePayload contains the information for GitHub's create hook event.
 type CreatePayload struct {
-	Ref          string `json:"ref"`
-	RefType      string `json:"ref_type"`
-	MasterBranch string `json:"master_branch"`
-	Description  string `json:"description"`
-	PusherType   string `json:"pusher_type"`
+	Ref          string       `json:"ref"`
+	RefType      EventSubtype `json:"ref_type"`
+	MasterBranch string       `json:"master_branch"`
+	Description  string       `json:"description"`
+	PusherType   string       `json:"pusher_type"`
 	Repository   struct {
 		ID       int64  `json:"id"`
 		NodeID   string `json:"node_id"`
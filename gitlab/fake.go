@@ -0,0 +1,255 @@
+package gitlab
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic payloads of a project to unit test handlers.
+// The payloads of a faker agree with each other on the project, users, refs and SHAs,
+// and encode to the JSON GitLab sends, e.g. to deliver them with the whtest package.
+// The same project always yields the same payloads.
+type Faker struct {
+	namespace string
+	name      string
+	id        int64
+	user      User
+	src       *fake.Source
+}
+
+// NewFaker returns a faker of the project with the given path, e.g. "gitlab-org/gitlab-test".
+// Events are triggered by the namespace owner.
+func NewFaker(pathWithNamespace string) *Faker {
+	src := fake.New("gitlab/" + pathWithNamespace)
+	namespace, name := "gitlab-org", pathWithNamespace
+	if i := strings.LastIndex(pathWithNamespace, "/"); i >= 0 {
+		namespace, name = pathWithNamespace[:i], pathWithNamespace[i+1:]
+	}
+
+	f := &Faker{namespace: namespace, name: name, id: src.ID(), src: src}
+	f.User(f.group())
+	return f
+}
+
+// User sets the username of the user triggering the events.
+func (f *Faker) User(username string) *Faker {
+	f.user = User{
+		ID:        f.src.ID() % 10000,
+		Name:      username,
+		UserName:  username,
+		AvatarURL: "https://www.gravatar.com/avatar/" + f.src.Hex(16) + "?s=80&d=identicon",
+		Email:     username + "@example.com",
+	}
+	return f
+}
+
+// SHA returns a new commit SHA.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// PushEventPayload returns the push of commits to ref, a branch name or a full ref such as "refs/heads/main".
+// The commits follow each other, the last one being the after and checkout SHA.
+func (f *Faker) PushEventPayload(ref string, commits int) PushEventPayload {
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	pl := PushEventPayload{
+		ObjectKind:        "push",
+		Ref:               ref,
+		Before:            f.SHA(),
+		UserID:            f.user.ID,
+		UserName:          f.user.Name,
+		UserUsername:      f.user.UserName,
+		UserEmail:         f.user.Email,
+		UserAvatar:        f.user.AvatarURL,
+		ProjectID:         f.id,
+		Project:           f.project(),
+		Repository:        f.repository(),
+		TotalCommitsCount: int64(commits),
+	}
+
+	pl.After = pl.Before
+	for i := 0; i < commits; i++ {
+		pl.Commits = append(pl.Commits, f.commit(fmt.Sprintf("Change %d", i+1)))
+		pl.After = pl.Commits[i].ID
+	}
+	pl.CheckoutSHA = pl.After
+	return pl
+}
+
+// TagEventPayload returns the push of a new tag pointing to a new commit.
+func (f *Faker) TagEventPayload(tag string) TagEventPayload {
+	c := f.commit("Release " + tag)
+	return TagEventPayload{
+		ObjectKind:        "tag_push",
+		Ref:               "refs/tags/" + tag,
+		Before:            "0000000000000000000000000000000000000000",
+		After:             c.ID,
+		CheckoutSHA:       c.ID,
+		UserID:            f.user.ID,
+		UserName:          f.user.Name,
+		UserUsername:      f.user.UserName,
+		UserAvatar:        f.user.AvatarURL,
+		ProjectID:         f.id,
+		Project:           f.project(),
+		Repository:        f.repository(),
+		Commits:           []Commit{c},
+		TotalCommitsCount: 1,
+	}
+}
+
+// MergeRequestEventPayload returns a merge request event merging the source branch into the target branch.
+// The merge action marks the merge request as merged, the close action as closed.
+func (f *Faker) MergeRequestEventPayload(action Action, source, target string) MergeRequestEventPayload {
+	id := f.src.ID()
+	iid := id%1000 + 1
+	created := f.src.Time()
+	last := f.commit("Change of " + source)
+	attrs := ObjectAttributes{
+		ID:              id,
+		IID:             iid,
+		StateID:         1,
+		State:           "opened",
+		Action:          action,
+		AuthorID:        f.user.ID,
+		SourceProjectID: f.id,
+		TargetProjectID: f.id,
+		SourceBranch:    source,
+		TargetBranch:    target,
+		Title:           "Merge " + source + " into " + target,
+		Description:     "Changes of " + source + ".",
+		MergeStatus:     "can_be_merged",
+		URL:             fmt.Sprintf("%s/-/merge_requests/%d", f.webURL(), iid),
+		CreatedAt:       customTime{created},
+		UpdatedAt:       customTime{created},
+		Source:          f.source(),
+		Target:          f.target(),
+		LastCommit: LastCommit{
+			ID:        last.ID,
+			URL:       last.URL,
+			Title:     last.Title,
+			Message:   last.Message,
+			Author:    last.Author,
+			Timestamp: last.Timestamp,
+		},
+		AssigneeIDS: []int64{},
+		ReviewerIDs: []int64{},
+	}
+
+	switch action {
+	case MergeAction:
+		attrs.StateID, attrs.State = 3, "merged"
+		attrs.UpdatedAt = customTime{f.src.Time()}
+	case CloseAction:
+		attrs.StateID, attrs.State = 2, "closed"
+		attrs.UpdatedAt = customTime{f.src.Time()}
+	}
+
+	return MergeRequestEventPayload{
+		ObjectKind:       "merge_request",
+		EventType:        "merge_request",
+		User:             f.user,
+		Project:          f.project(),
+		Repository:       f.repository(),
+		ObjectAttributes: attrs,
+		Labels:           []Label{},
+		Assignees:        []Assignee{},
+		Reviewers:        []Reviewers{},
+	}
+}
+
+func (f *Faker) commit(message string) Commit {
+	sha := f.SHA()
+	return Commit{
+		ID:        sha,
+		URL:       f.webURL() + "/-/commit/" + sha,
+		Title:     message,
+		Message:   message + "\n",
+		Added:     []string{},
+		Removed:   []string{},
+		Modified:  []string{"README.md"},
+		Author:    Author{Name: f.user.Name, Email: f.user.Email},
+		Timestamp: customTime{f.src.Time()},
+	}
+}
+
+func (f *Faker) project() Project {
+	t := f.target()
+	return Project{
+		ID:                f.id,
+		Name:              t.Name,
+		Description:       t.Description,
+		WebURL:            t.WebURL,
+		GitSSHURL:         t.GitSSHURL,
+		GitHTTPURL:        t.GitHTTPURL,
+		Namespace:         t.Namespace,
+		PathWithNamespace: t.PathWithNamespace,
+		DefaultBranch:     t.DefaultBranch,
+		Homepage:          t.Homepage,
+		URL:               t.URL,
+		SSHURL:            t.SSHURL,
+		HTTPURL:           t.HTTPURL,
+		CiConfigPath:      ".gitlab-ci.yml",
+	}
+}
+
+func (f *Faker) repository() Repository {
+	t := f.target()
+	return Repository{
+		Name:        t.Name,
+		URL:         t.URL,
+		Description: t.Description,
+		Homepage:    t.Homepage,
+		GitSSHURL:   t.GitSSHURL,
+		GitHTTPURL:  t.GitHTTPURL,
+	}
+}
+
+func (f *Faker) source() Source {
+	t := f.target()
+	return Source{
+		Name:              t.Name,
+		Description:       t.Description,
+		WebURL:            t.WebURL,
+		GitSSHURL:         t.GitSSHURL,
+		GitHTTPURL:        t.GitHTTPURL,
+		Namespace:         t.Namespace,
+		PathWithNamespace: t.PathWithNamespace,
+		DefaultBranch:     t.DefaultBranch,
+		Homepage:          t.Homepage,
+		URL:               t.URL,
+		SSHURL:            t.SSHURL,
+		HTTPURL:           t.HTTPURL,
+	}
+}
+
+func (f *Faker) target() Target {
+	pathWithNamespace := f.namespace + "/" + f.name
+	return Target{
+		Name:              f.name,
+		Description:       "The " + f.name + " project",
+		WebURL:            f.webURL(),
+		GitSSHURL:         "git@gitlab.com:" + pathWithNamespace + ".git",
+		GitHTTPURL:        f.webURL() + ".git",
+		Namespace:         f.group(),
+		PathWithNamespace: pathWithNamespace,
+		DefaultBranch:     "main",
+		Homepage:          f.webURL(),
+		URL:               "git@gitlab.com:" + pathWithNamespace + ".git",
+		SSHURL:            "git@gitlab.com:" + pathWithNamespace + ".git",
+		HTTPURL:           f.webURL() + ".git",
+	}
+}
+
+// group returns the innermost group of the namespace.
+func (f *Faker) group() string {
+	return f.namespace[strings.LastIndex(f.namespace, "/")+1:]
+}
+
+func (f *Faker) webURL() string {
+	return "https://gitlab.com/" + f.namespace + "/" + f.name
+}
@@ -7,7 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
 )
 
 const (
@@ -52,19 +58,76 @@ const (
 	eventUserRemoveFromGroup  string = "user_remove_from_group"
 )
 
-// Options is a namespace variable for configuration options.
-var Options = WebhookOptions{}
+const (
+	// GitLab object attributes actions.
+	OpenAction       Action = "open"
+	CloseAction      Action = "close"
+	MergeAction      Action = "merge"
+	ReopenAction     Action = "reopen"
+	UpdateAction     Action = "update"
+	ApprovedAction   Action = "approved"
+	ApprovalAction   Action = "approval"
+	UnapprovedAction Action = "unapproved"
+	UnapprovalAction Action = "unapproval"
+	// GitLab wiki page and release actions.
+	CreateAction Action = "create"
+	DeleteAction Action = "delete"
+)
+
+var (
+	// Options is a namespace variable for configuration options.
+	Options = WebhookOptions{}
+	// ErrActionNotDefined is returned by ParseActions when the action
+	// of a parsed event is not one of the actions asked to be parsed.
+	ErrActionNotDefined = errors.New("action not defined to be parsed")
+	// ErrParsingPayload is returned when a payload cannot be read or decoded.
+	ErrParsingPayload = errors.New("error parsing payload")
+)
 
 // Event defines a GitLab hook event type by the X-Gitlab-Event Header.
 type Event string
 
+// Action defines a GitLab hook event action, sent in the "action" field
+// of the object attributes or of the release payload.
+type Action string
+
+// EventActions pairs an event with the actions of it that should be parsed.
+// An empty Actions list matches every action of the event.
+type EventActions = observe.EventActions[Event, Action]
+
+// On returns an EventActions filter for the event and the given actions.
+func On(event Event, actions ...Action) EventActions {
+	return EventActions{Event: event, Actions: actions}
+}
+
+// provider describes the deliveries of GitLab to the instrumentation.
+var provider = &observe.Provider{
+	Name:           "gitlab",
+	EventHeader:    "X-Gitlab-Event",
+	DeliveryHeader: "X-Gitlab-Event-UUID",
+	SecretHeaders:  []string{"X-Gitlab-Token"},
+}
+
 // Webhook instance contains all methods needed to process events.
 type Webhook struct {
 	secretHash []byte
+	observer   observe.Observer
 }
 
 // Parse verifies and parses the events specified and returns the payload object or an error.
-func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error) {
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
 	defer func() {
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
@@ -79,11 +142,15 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 	}
 
 	// шf a secret set is existing, it is necessary to check it in a constant time
-	if len(hook.secretHash) > 0 {
+	err := d.Verify(len(hook.secretHash) > 0, func() error {
 		tokenHash := sha512.Sum512([]byte(r.Header.Get("X-Gitlab-Token")))
 		if subtle.ConstantTimeCompare(tokenHash[:], hook.secretHash[:]) == 0 {
-			return nil, errors.New("X-Gitlab-Token validation failed")
+			return errors.New("X-Gitlab-Token validation failed")
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	event := r.Header.Get("X-Gitlab-Event")
@@ -94,12 +161,52 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 	gitLabEvent := Event(event)
 	payload, err := io.ReadAll(r.Body)
 	if err != nil || len(payload) == 0 {
-		return nil, errors.New("error parsing payload")
+		return nil, ErrParsingPayload
 	}
 
+	d.Decoding()
 	return eventParsing(gitLabEvent, events, payload)
 }
 
+// ParseActions verifies and parses the events specified in filters
+// and returns the payload object only if its action matches one of the actions
+// registered for the event, otherwise ErrActionNotDefined is returned.
+func (hook Webhook) ParseActions(r *http.Request, filters ...EventActions) (interface{}, error) {
+	pl, err := hook.Parse(r, observe.Events(filters)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !observe.Matches(filters, resolvedEvent(Event(r.Header.Get("X-Gitlab-Event")), pl), pl) {
+		return nil, ErrActionNotDefined
+	}
+	return pl, nil
+}
+
+// ActionOf returns the action of a parsed payload,
+// or an empty Action if the payload has no action.
+func ActionOf(payload interface{}) Action {
+	return Action(observe.Action(payload))
+}
+
+// resolvedEvent returns the event the payload was parsed as,
+// which differs from the header event for system and job hooks
+// forwarded to the push, tag, merge request and build parsers.
+func resolvedEvent(event Event, payload interface{}) Event {
+	switch payload.(type) {
+	case PushEventPayload:
+		return PushEvents
+	case TagEventPayload:
+		return TagEvents
+	case MergeRequestEventPayload:
+		return MergeRequestEvents
+	case BuildEventPayload:
+		return BuildEvents
+	default:
+		return event
+	}
+}
+
 func eventParsing(gitLabEvent Event, events []Event, payload []byte) (interface{}, error) {
 	var found bool
 	for _, evt := range events {
@@ -318,3 +425,38 @@ func (WebhookOptions) Secret(secret string) Option {
 		return nil
 	}
 }
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
@@ -2,14 +2,19 @@ package gitlab
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"testing"
+	"time"
 
+	"github.com/pchchv/wh/whtest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -502,8 +507,232 @@ func TestSystemHooks(t *testing.T) {
 	}
 }
 
+func TestParseActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []EventActions
+		filename string
+		event    string
+		action   Action
+		wantErr  error
+	}{
+		{
+			name:     "MatchingAction",
+			filters:  []EventActions{On(MergeRequestEvents, OpenAction, ReopenAction)},
+			filename: "./testdata/merge-request-event.json",
+			event:    "Merge Request Hook",
+			action:   OpenAction,
+		},
+		{
+			name:     "ReleaseAction",
+			filters:  []EventActions{On(ReleaseEvents, CreateAction)},
+			filename: "./testdata/release-event.json",
+			event:    "Release Hook",
+			action:   CreateAction,
+		},
+		{
+			name:     "SystemHookAnyAction",
+			filters:  []EventActions{On(SystemHookEvents), On(MergeRequestEvents)},
+			filename: "./testdata/system-merge-request-event.json",
+			event:    "System Hook",
+		},
+		{
+			name:     "SystemHookUnmatchedAction",
+			filters:  []EventActions{On(SystemHookEvents), On(MergeRequestEvents, OpenAction)},
+			filename: "./testdata/system-merge-request-event.json",
+			event:    "System Hook",
+			wantErr:  ErrActionNotDefined,
+		},
+		{
+			name:     "UnmatchedAction",
+			filters:  []EventActions{On(MergeRequestEvents, MergeAction)},
+			filename: "./testdata/merge-request-event.json",
+			event:    "Merge Request Hook",
+			wantErr:  ErrActionNotDefined,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.ReadFile(tc.filename)
+			assert.NoError(err)
+
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+			req.Header.Set("X-Gitlab-Token", "sampleToken!")
+			req.Header.Set("X-Gitlab-Event", tc.event)
+			results, err := hook.ParseActions(req, tc.filters...)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(tc.action, ActionOf(results))
+		})
+	}
+}
+
 func newServer(handler http.HandlerFunc) *httptest.Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc(path, handler)
 	return httptest.NewServer(mux)
 }
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("gitlab-org/gitlab-test").User("root")
+	push := faker.PushEventPayload("main", 3)
+	assert.Equal("refs/heads/main", push.Ref)
+	assert.Len(push.Commits, 3)
+	assert.Equal(push.After, push.Commits[2].ID)
+	assert.Equal(push.After, push.CheckoutSHA)
+	assert.Equal(int64(3), push.TotalCommitsCount)
+	assert.Equal("gitlab-org/gitlab-test", push.Project.PathWithNamespace)
+	assert.Equal("root", push.UserUsername)
+	assert.Equal(push, NewFaker("gitlab-org/gitlab-test").User("root").PushEventPayload("main", 3))
+
+	mr := faker.MergeRequestEventPayload(MergeAction, "feature", "main")
+	assert.Equal("merged", mr.ObjectAttributes.State)
+	assert.Equal("feature", mr.ObjectAttributes.SourceBranch)
+	assert.Equal("main", mr.ObjectAttributes.TargetBranch)
+	assert.Equal(push.Project.ID, mr.Project.ID)
+	assert.Equal(mr.ObjectAttributes.Source.PathWithNamespace, push.Project.PathWithNamespace)
+
+	tag := faker.TagEventPayload("v1.0.0")
+	assert.Equal("refs/tags/v1.0.0", tag.Ref)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: PushEvents, payload: push},
+		{event: TagEvents, payload: tag},
+		{event: MergeRequestEvents, payload: mr},
+		{event: MergeRequestEvents, payload: faker.MergeRequestEventPayload(OpenAction, "feature", "main")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.GitLab(tc.event, tc.payload).Secret("sampleToken!").Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{PushEvents, "./testdata/push-event.json"},
+		{TagEvents, "./testdata/tag-event.json"},
+		{IssuesEvents, "./testdata/issue-event.json"},
+		{ConfidentialIssuesEvents, "./testdata/confidential-issue-event.json"},
+		{CommentEvents, "./testdata/comment-commit-event.json"},
+		{ConfidentialCommentEvents, "./testdata/confidential-comment-event.json"},
+		{CommentEvents, "./testdata/comment-merge-request-event.json"},
+		{CommentEvents, "./testdata/comment-issue-event.json"},
+		{CommentEvents, "./testdata/comment-snippet-event.json"},
+		{MergeRequestEvents, "./testdata/merge-request-event.json"},
+		{WikiPageEvents, "./testdata/wikipage-event.json"},
+		{PipelineEvents, "./testdata/pipeline-event.json"},
+		{BuildEvents, "./testdata/build-event.json"},
+		{DeploymentEvents, "./testdata/deployment-event.json"},
+		{ReleaseEvents, "./testdata/release-event.json"},
+		{PushEvents, "./testdata/system-push-event.json"},
+		{TagEvents, "./testdata/system-tag-event.json"},
+		{MergeRequestEvents, "./testdata/system-merge-request-event.json"},
+		{SystemHookEvents, "./testdata/system-project-created.json"},
+		{SystemHookEvents, "./testdata/system-project-destroyed.json"},
+		{SystemHookEvents, "./testdata/system-project-renamed.json"},
+		{SystemHookEvents, "./testdata/system-project-transferred.json"},
+		{SystemHookEvents, "./testdata/system-project-updated.json"},
+		{SystemHookEvents, "./testdata/system-team-member-added.json"},
+		{SystemHookEvents, "./testdata/system-team-member-removed.json"},
+		{SystemHookEvents, "./testdata/system-team-member-updated.json"},
+		{SystemHookEvents, "./testdata/system-user-created.json"},
+		{SystemHookEvents, "./testdata/system-user-removed.json"},
+		{SystemHookEvents, "./testdata/system-user-failed-login.json"},
+		{SystemHookEvents, "./testdata/system-user-renamed.json"},
+		{SystemHookEvents, "./testdata/system-key-added.json"},
+		{SystemHookEvents, "./testdata/system-key-removed.json"},
+		{SystemHookEvents, "./testdata/system-group-created.json"},
+		{SystemHookEvents, "./testdata/system-group-removed.json"},
+		{SystemHookEvents, "./testdata/system-group-renamed.json"},
+		{SystemHookEvents, "./testdata/system-group-member-added.json"},
+		{SystemHookEvents, "./testdata/system-group-member-removed.json"},
+		{SystemHookEvents, "./testdata/system-group-member-updated.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), "", payload)
+	}
+	f.Add(string(seeds[0].event), "sampleToken", []byte("{}"))
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event, signature string, payload []byte) {
+		d := whtest.GitLab(event, payload).Secret("sampleToken!")
+		if signature != "" {
+			d.Header("X-Gitlab-Token", signature)
+		}
+
+		r := d.Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
+
+func FuzzCustomTime(f *testing.F) {
+	files, err := filepath.Glob("./testdata/*.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	dates := regexp.MustCompile(`"\d{4}-\d{2}-\d{2}[ T][^"]*"`)
+	seen := map[string]bool{}
+	for _, file := range files {
+		payload, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		for _, date := range dates.FindAll(payload, -1) {
+			if !seen[string(date)] {
+				seen[string(date)] = true
+				f.Add(date)
+			}
+		}
+	}
+	f.Add([]byte("null"))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var d customTime
+		if err := d.UnmarshalJSON(b); err != nil {
+			return
+		}
+
+		// years outside of four digits cannot be encoded by the layout
+		if year := d.Time.Year(); year < 0 || year > 9999 {
+			return
+		}
+
+		encoded, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded customTime
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("decoding %s encoded from %q: %v", encoded, b, err)
+		}
+
+		if !decoded.Time.Equal(d.Time.Truncate(time.Second)) {
+			t.Fatalf("%q decoded to %v, encoded as %s decoded to %v", b, d.Time, encoded, decoded.Time)
+		}
+	})
+}
@@ -384,7 +384,7 @@ type ObjectAttributes struct {
 	Slug             string     `json:"slug"`
 	Title            string     `json:"title"`
 	State            string     `json:"state"`
-	Action           string     `json:"action"`
+	Action           Action     `json:"action"`
 	Format           string     `json:"format"`
 	Status           string     `json:"status"`
 	Content          string     `json:"content"`
@@ -707,7 +707,7 @@ type ReleaseEventPayload struct {
 	Tag         string     `json:"tag"`
 	URL         string     `json:"url"`
 	Name        string     `json:"name"`
-	Action      string     `json:"action"`
+	Action      Action     `json:"action"`
 	ObjectKind  string     `json:"object_kind"`
 	Description string     `json:"description"`
 	Assets      Assets     `json:"assets"`
@@ -894,10 +894,11 @@ type customTime struct {
 }
 
 func (t *customTime) UnmarshalJSON(b []byte) (err error) {
+	// numeric offsets first, the MST layout accepts small ones such as +0001 as a zone name without offset
 	layout := []string{
-		"2006-01-02 15:04:05 MST",
 		"2006-01-02 15:04:05 Z07:00",
 		"2006-01-02 15:04:05 Z0700",
+		"2006-01-02 15:04:05 MST",
 		time.RFC3339,
 	}
 	s := strings.Trim(string(b), "\"")
@@ -915,3 +916,15 @@ func (t *customTime) UnmarshalJSON(b []byte) (err error) {
 
 	return
 }
+
+// MarshalJSON encodes the time the way GitLab does, null when zero.
+func (t customTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	if t.Location() == time.UTC {
+		return []byte(`"` + t.Format("2006-01-02 15:04:05 UTC") + `"`), nil
+	}
+	return []byte(`"` + t.Format("2006-01-02 15:04:05 -0700") + `"`), nil
+}
@@ -0,0 +1,163 @@
+package gogs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+
+	client "github.com/gogits/go-gogs-client"
+)
+
+// Faker builds realistic payloads of a repository to unit test handlers.
+// The payloads of a faker agree with each other on the repository, users, refs and SHAs,
+// and encode to the JSON Gogs sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same payloads.
+type Faker struct {
+	host   string
+	owner  string
+	name   string
+	id     int64
+	sender *client.User
+	src    *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given full name, e.g. "gogs/gogs", hosted on try.gogs.io.
+// Events are triggered by the repository owner.
+func NewFaker(fullName string) *Faker {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok {
+		owner, name = "gogs", fullName
+	}
+
+	src := fake.New("gogs/" + fullName)
+	f := &Faker{host: "https://try.gogs.io", owner: owner, name: name, id: src.ID(), src: src}
+	f.sender = f.user(owner)
+	return f
+}
+
+// Sender sets the login of the user triggering the events.
+func (f *Faker) Sender(login string) *Faker {
+	f.sender = f.user(login)
+	return f
+}
+
+// SHA returns a new commit SHA.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// PushPayload returns the push of commits to ref, a branch name or a full ref such as "refs/tags/v1.0.0".
+// The commits follow each other, the last one being the after SHA.
+func (f *Faker) PushPayload(ref string, commits int) client.PushPayload {
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	pl := client.PushPayload{
+		Ref:     ref,
+		Before:  f.SHA(),
+		Commits: []*client.PayloadCommit{},
+		Pusher:  f.sender,
+		Sender:  f.sender,
+		Repo:    f.repository(),
+	}
+
+	pl.After = pl.Before
+	for i := 0; i < commits; i++ {
+		c := f.commit(fmt.Sprintf("Change %d", i+1))
+		pl.Commits = append(pl.Commits, c)
+		pl.After = c.ID
+	}
+	pl.CompareURL = fmt.Sprintf("%s/compare/%s...%s", f.htmlURL(), pl.Before, pl.After)
+	return pl
+}
+
+// PullRequestPayload returns a pull request event merging the head branch into the base branch.
+// Closing actions mark the pull request as merged.
+func (f *Faker) PullRequestPayload(action client.HookIssueAction, head, base string) client.PullRequestPayload {
+	id := f.src.ID()
+	number := id%1000 + 1
+	repo := f.repository()
+	mergeable := true
+	pr := &client.PullRequest{
+		ID:         id,
+		Index:      number,
+		Poster:     f.sender,
+		Title:      "Merge " + head + " into " + base,
+		Body:       "Changes of " + head + ".",
+		Labels:     []*client.Label{},
+		State:      client.STATE_OPEN,
+		HeadBranch: head,
+		HeadRepo:   repo,
+		BaseBranch: base,
+		BaseRepo:   repo,
+		HTMLURL:    fmt.Sprintf("%s/pulls/%d", f.htmlURL(), number),
+		Mergeable:  &mergeable,
+	}
+
+	if action == client.HOOK_ISSUE_CLOSED {
+		merged := f.src.Time()
+		pr.State = client.STATE_CLOSED
+		pr.HasMerged = true
+		pr.Merged = &merged
+		pr.MergedCommitID = &[]string{f.SHA()}[0]
+		pr.MergedBy = f.sender
+	}
+
+	return client.PullRequestPayload{
+		Action:      action,
+		Index:       number,
+		PullRequest: pr,
+		Repository:  repo,
+		Sender:      f.sender,
+	}
+}
+
+func (f *Faker) commit(message string) *client.PayloadCommit {
+	sha := f.SHA()
+	author := &client.PayloadUser{Name: f.sender.FullName, Email: f.sender.Email, UserName: f.sender.UserName}
+	return &client.PayloadCommit{
+		ID:        sha,
+		Message:   message + "\n",
+		URL:       f.htmlURL() + "/commit/" + sha,
+		Author:    author,
+		Committer: author,
+		Added:     []string{},
+		Removed:   []string{},
+		Modified:  []string{"README.md"},
+		Timestamp: f.src.Time(),
+	}
+}
+
+func (f *Faker) repository() *client.Repository {
+	return &client.Repository{
+		ID:            f.id,
+		Owner:         f.user(f.owner),
+		Name:          f.name,
+		FullName:      f.owner + "/" + f.name,
+		Description:   "The " + f.name + " repository",
+		HTMLURL:       f.htmlURL(),
+		CloneURL:      f.htmlURL() + ".git",
+		SSHURL:        "git@" + strings.TrimPrefix(f.host, "https://") + ":" + f.owner + "/" + f.name + ".git",
+		DefaultBranch: "master",
+		Created:       fake.Epoch,
+		Updated:       fake.Epoch,
+	}
+}
+
+func (f *Faker) user(login string) *client.User {
+	id := fake.NameID(login)
+	return &client.User{
+		ID:        id,
+		UserName:  login,
+		Login:     login,
+		FullName:  login,
+		Email:     login + "@example.com",
+		AvatarUrl: fmt.Sprintf("%s/avatars/%d", f.host, id),
+	}
+}
+
+func (f *Faker) htmlURL() string {
+	return f.host + "/" + f.owner + "/" + f.name
+}
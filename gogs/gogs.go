@@ -8,9 +8,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"time"
 
 	client "github.com/gogits/go-gogs-client"
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
 )
 
 const (
@@ -25,6 +30,9 @@ const (
 	IssueCommentEvent Event = "issue_comment"
 )
 
+// ErrParsingPayload is returned when a payload cannot be read or decoded.
+var ErrParsingPayload = errors.New("error parsing payload")
+
 // Options is a namespace var for configuration options
 var Options = WebhookOptions{}
 
@@ -45,13 +53,34 @@ func New(options ...Option) (*Webhook, error) {
 	return hook, nil
 }
 
+// provider describes the deliveries of Gogs to the instrumentation.
+var provider = &observe.Provider{
+	Name:           "gogs",
+	EventHeader:    "X-Gogs-Event",
+	DeliveryHeader: "X-Gogs-Delivery",
+	SecretHeaders:  []string{"X-Gogs-Signature"},
+}
+
 // Webhook instance contains all methods needed to process events.
 type Webhook struct {
-	secret string
+	secret   string
+	observer observe.Observer
 }
 
 // Parse verifies and parses the events specified and returns the payload object or an error.
-func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error) {
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
 	defer func() {
 		_, _ = io.Copy(io.Discard, r.Body)
 		_ = r.Body.Close()
@@ -86,24 +115,29 @@ func (hook Webhook) Parse(r *http.Request, events ...Event) (interface{}, error)
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil || len(payload) == 0 {
-		return nil, errors.New("error parsing payload")
+		return nil, ErrParsingPayload
 	}
 
 	// If we have a Secret set, we should check the MAC
-	if len(hook.secret) > 0 {
+	err = d.Verify(len(hook.secret) > 0, func() error {
 		signature := r.Header.Get("X-Gogs-Signature")
 		if len(signature) == 0 {
-			return nil, errors.New("missing X-Gogs-Signature Header")
+			return errors.New("missing X-Gogs-Signature Header")
 		}
 
 		mac := hmac.New(sha256.New, []byte(hook.secret))
 		_, _ = mac.Write(payload)
 		expectedMAC := hex.EncodeToString(mac.Sum(nil))
 		if !hmac.Equal([]byte(signature), []byte(expectedMAC)) {
-			return nil, errors.New("HMAC verification failed")
+			return errors.New("HMAC verification failed")
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	d.Decoding()
 	switch gogsEvent {
 	case CreateEvent:
 		var pl client.CreatePayload
@@ -152,3 +186,38 @@ func (WebhookOptions) Secret(secret string) Option {
 		return nil
 	}
 }
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	client "github.com/gogits/go-gogs-client"
+	"github.com/pchchv/wh/whtest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -239,3 +240,72 @@ func newServer(handler http.HandlerFunc) *httptest.Server {
 	mux.HandleFunc(path, handler)
 	return httptest.NewServer(mux)
 }
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("gogs/gogs").Sender("unknwon")
+	push := faker.PushPayload("master", 3)
+	assert.Equal("refs/heads/master", push.Ref)
+	assert.Len(push.Commits, 3)
+	assert.Equal(push.After, push.Commits[2].ID)
+	assert.Equal("gogs/gogs", push.Repo.FullName)
+	assert.Equal("unknwon", push.Sender.UserName)
+	assert.Equal(push, NewFaker("gogs/gogs").Sender("unknwon").PushPayload("master", 3))
+
+	pr := faker.PullRequestPayload(client.HOOK_ISSUE_CLOSED, "feature", "master")
+	assert.True(pr.PullRequest.HasMerged)
+	assert.Equal("feature", pr.PullRequest.HeadBranch)
+	assert.Equal("master", pr.PullRequest.BaseBranch)
+	assert.Equal(push.Repo.ID, pr.Repository.ID)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: PushEvent, payload: push},
+		{event: PullRequestEvent, payload: pr},
+		{event: PullRequestEvent, payload: faker.PullRequestPayload(client.HOOK_ISSUE_OPENED, "feature", "master")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Gogs(tc.event, tc.payload).Secret(hook.secret).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{CreateEvent, "./testdata/create-event.json"},
+		{DeleteEvent, "./testdata/delete-event.json"},
+		{ForkEvent, "./testdata/fork-event.json"},
+		{PushEvent, "./testdata/push-event.json"},
+		{IssuesEvent, "./testdata/issues-event.json"},
+		{IssueCommentEvent, "./testdata/issue-comment-event.json"},
+		{PullRequestEvent, "./testdata/pull-request-event.json"},
+		{ReleaseEvent, "./testdata/release-event.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), "", payload)
+	}
+	f.Add(string(seeds[0].event), "zz", []byte("{}"))
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event, signature string, payload []byte) {
+		d := whtest.Gogs(event, payload).Secret(hook.secret)
+		if signature != "" {
+			d.Header("X-Gogs-Signature", signature)
+		}
+
+		r := d.Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package harbor
+
+import (
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic events of a repository to unit test handlers.
+// The events of a faker agree with each other on the repository, operator and the digest of each tag,
+// and encode to the JSON Harbor sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same events.
+type Faker struct {
+	host      string
+	namespace string
+	name      string
+	operator  string
+	digests   map[string]string
+	src       *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given full name, e.g. "library/nginx",
+// of the registry harbor.example.com. Events are triggered by the user "admin".
+func NewFaker(fullName string) *Faker {
+	namespace, name, ok := strings.Cut(fullName, "/")
+	if !ok {
+		namespace, name = "library", fullName
+	}
+
+	return &Faker{
+		host:      "harbor.example.com",
+		namespace: namespace,
+		name:      name,
+		operator:  "admin",
+		digests:   map[string]string{},
+		src:       fake.New("harbor/" + fullName),
+	}
+}
+
+// Operator sets the user or robot account triggering the events, e.g. "robot$ci".
+func (f *Faker) Operator(name string) *Faker {
+	f.operator = name
+	return f
+}
+
+// ArtifactPayload returns the push, pull or deletion of the artifact with the tag.
+func (f *Faker) ArtifactPayload(event Event, tag string) ArtifactPayload {
+	return ArtifactPayload{BasicEvent: f.event(event), EventData: f.data(tag)}
+}
+
+// ScanningPayload returns the completed scan of the artifact with the tag, finding vulnerabilities of the severities,
+// e.g. {"High": 2, "Medium": 5}.
+func (f *Faker) ScanningPayload(tag string, vulnerabilities map[string]int) ScanningPayload {
+	severity, total := "None", 0
+	for _, s := range []string{"Low", "Medium", "High", "Critical"} {
+		if vulnerabilities[s] > 0 {
+			severity = s
+		}
+		total += vulnerabilities[s]
+	}
+
+	start := f.src.Time()
+	data := f.data(tag)
+	data.Resources[0].ScanOverview = map[string]ScanOverview{
+		"application/vnd.security.vulnerability.report; version=1.1": {
+			ReportID:        f.src.UUID(),
+			ScanStatus:      "Success",
+			Severity:        severity,
+			Duration:        60,
+			Summary:         &VulnerabilitySummary{Total: total, Summary: vulnerabilities},
+			StartTime:       start,
+			EndTime:         f.src.Time(),
+			Scanner:         Scanner{Name: "Trivy", Vendor: "Aqua Security", Version: "v0.51.1"},
+			CompletePercent: 100,
+		},
+	}
+	return ScanningPayload{BasicEvent: f.event(ScanningCompletedEvent), EventData: data}
+}
+
+func (f *Faker) event(typ Event) BasicEvent {
+	return BasicEvent{Type: typ, OccurAt: f.src.Time().Unix(), Operator: f.operator}
+}
+
+func (f *Faker) data(tag string) EventData {
+	digest, ok := f.digests[tag]
+	if !ok {
+		digest = "sha256:" + f.src.Hex(32)
+		f.digests[tag] = digest
+	}
+
+	return EventData{
+		Resources: []Resource{{
+			Digest:      digest,
+			Tag:         tag,
+			ResourceURL: f.host + "/" + f.namespace + "/" + f.name + ":" + tag,
+		}},
+		Repository: Repository{
+			DateCreated: fake.Epoch.Unix(),
+			Name:        f.name,
+			Namespace:   f.namespace,
+			FullName:    f.namespace + "/" + f.name,
+			RepoType:    "public",
+		},
+	}
+}
@@ -0,0 +1,200 @@
+// The `harbor` package accepts the webhooks of the Harbor container registry, in its default payload format.
+//
+// Harbor sends the event type in the payload and authenticates its deliveries
+// with the Auth Header of the webhook policy, sent as is in the Authorization header.
+package harbor
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+const (
+	// Harbor event types.
+	PushArtifactEvent      Event = "PUSH_ARTIFACT"
+	PullArtifactEvent      Event = "PULL_ARTIFACT"
+	DeleteArtifactEvent    Event = "DELETE_ARTIFACT"
+	ScanningCompletedEvent Event = "SCANNING_COMPLETED"
+	ScanningFailedEvent    Event = "SCANNING_FAILED"
+	ScanningStoppedEvent   Event = "SCANNING_STOPPED"
+)
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = WebhookOptions{}
+	// Parse errors.
+	ErrParsingPayload                  = errors.New("error parsing payload")
+	ErrAuthorizationVerificationFailed = errors.New("Authorization header verification failed")
+)
+
+// Event defines a Harbor hook event type by the type field of the payload.
+type Event string
+
+// provider describes the deliveries of Harbor to the instrumentation.
+var provider = &observe.Provider{
+	Name: "harbor",
+}
+
+// Webhook instance contains all methods needed to process events.
+type Webhook struct {
+	authHash []byte
+	observer observe.Observer
+}
+
+// Parse verifies and parses the events specified and returns the payload object or an error.
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
+	defer func() {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}()
+
+	if len(events) == 0 {
+		return nil, errors.New("no Event specified to parse")
+	}
+
+	if r.Method != http.MethodPost {
+		return nil, errors.New("invalid HTTP Method")
+	}
+
+	// if an auth header is set, it is checked in a constant time
+	err := d.Verify(len(hook.authHash) > 0, func() error {
+		authHash := sha512.Sum512([]byte(r.Header.Get("Authorization")))
+		if subtle.ConstantTimeCompare(authHash[:], hook.authHash) == 0 {
+			return ErrAuthorizationVerificationFailed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil || len(payload) == 0 {
+		return nil, ErrParsingPayload
+	}
+
+	d.Decoding()
+	var pl BasicEvent
+	if err = json.Unmarshal(payload, &pl); err != nil {
+		return nil, ErrParsingPayload
+	}
+
+	// Harbor sends the event type in the payload
+	d.Event = string(pl.Type)
+
+	var found bool
+	for _, evt := range events {
+		if evt == pl.Type {
+			found = true
+			break
+		}
+	}
+
+	// event not defined to be parsed
+	if !found {
+		return nil, errors.New("event not defined to be parsed")
+	}
+
+	switch pl.Type {
+	case PushArtifactEvent, PullArtifactEvent, DeleteArtifactEvent:
+		var fpl ArtifactPayload
+		err = json.Unmarshal(payload, &fpl)
+		return fpl, err
+	case ScanningCompletedEvent, ScanningFailedEvent, ScanningStoppedEvent:
+		var fpl ScanningPayload
+		err = json.Unmarshal(payload, &fpl)
+		return fpl, err
+	default:
+		return nil, fmt.Errorf("unknown event %s", pl.Type)
+	}
+}
+
+// Option is a configuration option for the webhook.
+type Option func(*Webhook) error
+
+// New creates and returns a WebHook instance.
+func New(options ...Option) (*Webhook, error) {
+	hook := new(Webhook)
+	for _, opt := range options {
+		if err := opt(hook); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+	return hook, nil
+}
+
+// WebhookOptions is a namespace for configuration option methods.
+type WebhookOptions struct{}
+
+// AuthHeader registers the Auth Header of the webhook policy, the exact value of the Authorization header,
+// e.g. "Bearer secret".
+func (WebhookOptions) AuthHeader(value string) Option {
+	return func(hook *Webhook) error {
+		if value == "" {
+			return errors.New("auth header must not be empty")
+		}
+		// already convert here to prevent timing attack
+		// (conversion depends on secret)
+		hash := sha512.Sum512([]byte(value))
+		hook.authHash = hash[:]
+		return nil
+	}
+}
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
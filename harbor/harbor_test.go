@@ -0,0 +1,253 @@
+package harbor
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	path   = "/webhooks"
+	secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+)
+
+var hook *Webhook
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	hook, err = New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func TestWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+	}{
+		{
+			name:     "PushArtifactEvent",
+			event:    PushArtifactEvent,
+			typ:      ArtifactPayload{},
+			filename: "./testdata/push-artifact.json",
+		},
+		{
+			name:     "DeleteArtifactEvent",
+			event:    DeleteArtifactEvent,
+			typ:      ArtifactPayload{},
+			filename: "./testdata/delete-artifact.json",
+		},
+		{
+			name:     "ScanningCompletedEvent",
+			event:    ScanningCompletedEvent,
+			typ:      ScanningPayload{},
+			filename: "./testdata/scanning-completed.json",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.Open(tc.filename)
+			assert.NoError(err)
+			defer func() {
+				_ = payload.Close()
+			}()
+
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, payload)
+			assert.NoError(err)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	parse := func(event Event, filename string) interface{} {
+		pl, err := hook.Parse(whtest.Harbor(whtest.Fixture(t, filename)).Request(), event)
+		assert.NoError(err)
+		return pl
+	}
+
+	push := parse(PushArtifactEvent, "./testdata/push-artifact.json").(ArtifactPayload)
+	assert.Equal(PushArtifactEvent, push.Type)
+	assert.Equal("robot$ci", push.Operator)
+	assert.Equal("library/nginx", push.Repository.FullName)
+	assert.Len(push.Resources, 1)
+	assert.Equal("1.27.0", push.Resources[0].Tag)
+	assert.Equal("harbor.example.com/library/nginx:1.27.0", push.Resources[0].ResourceURL)
+
+	scan := parse(ScanningCompletedEvent, "./testdata/scanning-completed.json").(ScanningPayload)
+	assert.Equal(push.Resources[0].Digest, scan.Resources[0].Digest)
+	overview, ok := scan.Resources[0].ScanOverview["application/vnd.security.vulnerability.report; version=1.1"]
+	assert.True(ok)
+	assert.Equal("Success", overview.ScanStatus)
+	assert.Equal("High", overview.Severity)
+	assert.Equal(14, overview.Summary.Total)
+	assert.Equal(2, overview.Summary.Summary["High"])
+	assert.Equal("Trivy", overview.Scanner.Name)
+	assert.Equal(time.Date(2024, 6, 3, 8, 45, 2, 0, time.UTC), overview.EndTime)
+
+	deleted := parse(DeleteArtifactEvent, "./testdata/delete-artifact.json").(ArtifactPayload)
+	assert.Equal(DeleteArtifactEvent, deleted.Type)
+	assert.Nil(deleted.Resources[0].ScanOverview)
+}
+
+func TestBadRequests(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/push-artifact.json")
+	tests := []struct {
+		name   string
+		events []Event
+		r      *http.Request
+	}{
+		{name: "NoEvents", r: whtest.Harbor(body).Request()},
+		{name: "UnsubscribedEvent", events: []Event{DeleteArtifactEvent}, r: whtest.Harbor(body).Request()},
+		{name: "BadMethod", events: []Event{PushArtifactEvent}, r: whtest.Harbor(body).Method(http.MethodGet).Request()},
+		{name: "BadBody", events: []Event{PushArtifactEvent}, r: whtest.Harbor("").Request()},
+		{name: "BadJSON", events: []Event{PushArtifactEvent}, r: whtest.Harbor("{").Request()},
+		{name: "UnknownEvent", events: []Event{"QUOTA_EXCEED"}, r: whtest.Harbor(`{"type":"QUOTA_EXCEED"}`).Request()},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := hook.Parse(tc.r, tc.events...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAuthHeader(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/push-artifact.json")
+	tests := []struct {
+		name    string
+		r       *http.Request
+		wantErr error
+	}{
+		{
+			name: "AuthHeader",
+			r:    whtest.Harbor(body).Secret("Bearer " + secret).Request(),
+		},
+		{
+			name:    "WrongAuthHeader",
+			r:       whtest.Harbor(body).Secret("Bearer " + secret).Tampered(),
+			wantErr: ErrAuthorizationVerificationFailed,
+		},
+		{
+			name:    "MissingAuthHeader",
+			r:       whtest.Harbor(body).Request(),
+			wantErr: ErrAuthorizationVerificationFailed,
+		},
+	}
+
+	h, err := New(Options.AuthHeader("Bearer " + secret))
+	require.NoError(t, err)
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			_, err := h.Parse(tc.r, PushArtifactEvent)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+
+	_, err = New(Options.AuthHeader(""))
+	require.Error(t, err)
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("library/nginx").Operator("robot$ci")
+	push := faker.ArtifactPayload(PushArtifactEvent, "1.27.0")
+	assert.Equal(PushArtifactEvent, push.Type)
+	assert.Equal("robot$ci", push.Operator)
+	assert.Equal("library/nginx", push.Repository.FullName)
+	assert.Equal("harbor.example.com/library/nginx:1.27.0", push.Resources[0].ResourceURL)
+
+	scan := faker.ScanningPayload("1.27.0", map[string]int{"High": 2, "Medium": 5})
+	overview := scan.Resources[0].ScanOverview["application/vnd.security.vulnerability.report; version=1.1"]
+	assert.Equal(push.Resources[0].Digest, scan.Resources[0].Digest)
+	assert.Equal("High", overview.Severity)
+	assert.Equal(7, overview.Summary.Total)
+	assert.NotEqual(push.Resources[0].Digest, faker.ArtifactPayload(PushArtifactEvent, "1.26.1").Resources[0].Digest)
+	assert.Equal(push, NewFaker("library/nginx").Operator("robot$ci").ArtifactPayload(PushArtifactEvent, "1.27.0"))
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: PushArtifactEvent, payload: push},
+		{event: ScanningCompletedEvent, payload: scan},
+		{event: DeleteArtifactEvent, payload: faker.ArtifactPayload(DeleteArtifactEvent, "1.27.0")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Harbor(tc.payload).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{PushArtifactEvent, "./testdata/push-artifact.json"},
+		{DeleteArtifactEvent, "./testdata/delete-artifact.json"},
+		{ScanningCompletedEvent, "./testdata/scanning-completed.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), payload)
+	}
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event string, payload []byte) {
+		r := whtest.Harbor(payload).Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
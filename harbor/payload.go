@@ -0,0 +1,76 @@
+package harbor
+
+import "time"
+
+// BasicEvent contains the type, time and operator common to all Harbor events,
+// OccurAt is the seconds since the epoch.
+type BasicEvent struct {
+	Type     Event  `json:"type"`
+	OccurAt  int64  `json:"occur_at"`
+	Operator string `json:"operator"`
+}
+
+// Repository contains the Harbor repository information, FullName is the project and repository name.
+type Repository struct {
+	DateCreated int64  `json:"date_created"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	FullName    string `json:"repo_full_name"`
+	RepoType    string `json:"repo_type"`
+}
+
+// Resource contains an artifact of an event, ResourceURL is its pull reference.
+// ScanOverview is keyed by the MIME type of the report of the scanner.
+type Resource struct {
+	Digest       string                  `json:"digest"`
+	Tag          string                  `json:"tag"`
+	ResourceURL  string                  `json:"resource_url"`
+	ScanOverview map[string]ScanOverview `json:"scan_overview,omitempty"`
+}
+
+// Scanner contains the scanner of a vulnerability report, e.g. Trivy.
+type Scanner struct {
+	Name    string `json:"name"`
+	Vendor  string `json:"vendor"`
+	Version string `json:"version"`
+}
+
+// VulnerabilitySummary contains the number of vulnerabilities of a report, Summary counts them by severity.
+type VulnerabilitySummary struct {
+	Total   int            `json:"total"`
+	Fixable int            `json:"fixable"`
+	Summary map[string]int `json:"summary"`
+}
+
+// ScanOverview contains the outcome of a scan, ScanStatus is e.g. "Success", "Error" or "Stopped"
+// and Severity the highest severity found, e.g. "None", "Low", "Medium", "High" or "Critical".
+type ScanOverview struct {
+	ReportID        string                `json:"report_id"`
+	ScanStatus      string                `json:"scan_status"`
+	Severity        string                `json:"severity"`
+	Duration        int64                 `json:"duration"`
+	Summary         *VulnerabilitySummary `json:"summary"`
+	StartTime       time.Time             `json:"start_time"`
+	EndTime         time.Time             `json:"end_time"`
+	Scanner         Scanner               `json:"scanner"`
+	CompletePercent int                   `json:"complete_percent"`
+}
+
+// EventData contains the artifacts and repository of an event.
+type EventData struct {
+	Resources  []Resource `json:"resources"`
+	Repository Repository `json:"repository"`
+}
+
+// ArtifactPayload contains the information for Harbor's PUSH_ARTIFACT, PULL_ARTIFACT and DELETE_ARTIFACT events.
+type ArtifactPayload struct {
+	BasicEvent
+	EventData `json:"event_data"`
+}
+
+// ScanningPayload contains the information for Harbor's SCANNING_COMPLETED, SCANNING_FAILED and SCANNING_STOPPED events,
+// the resources carry the scan overview.
+type ScanningPayload struct {
+	BasicEvent
+	EventData `json:"event_data"`
+}
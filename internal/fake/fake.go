@@ -0,0 +1,179 @@
+// Package fake generates the synthetic data the provider fakers fill payloads with.
+package fake
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Epoch is the time of the first event of every source.
+var Epoch = time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Source generates deterministic IDs, SHAs and times, the same seed always yields the same sequence.
+type Source struct {
+	rand *rand.Rand
+	now  time.Time
+	id   int64
+}
+
+// New returns a source seeded with seed, e.g. the full name of the faked repository.
+func New(seed string) *Source {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(seed))
+	sum := h.Sum64()
+	return &Source{
+		rand: rand.New(rand.NewPCG(sum, sum>>1)),
+		now:  Epoch,
+		id:   int64(sum%900_000) + 100_000,
+	}
+}
+
+// SHA returns a random 40 hex digits commit SHA.
+func (s *Source) SHA() string {
+	return s.Hex(20)
+}
+
+// Hex returns n random bytes hex encoded.
+func (s *Source) Hex(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(s.rand.UintN(256))
+	}
+	return hex.EncodeToString(b)
+}
+
+// UUID returns a random version 4 UUID.
+func (s *Source) UUID() string {
+	b := make([]byte, 16)
+	for i := range b {
+		b[i] = byte(s.rand.UintN(256))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ID returns the next numeric ID.
+func (s *Source) ID() int64 {
+	s.id++
+	return s.id
+}
+
+// Time returns the time of the next event, one minute after the previous one.
+func (s *Source) Time() time.Time {
+	s.now = s.now.Add(time.Minute)
+	return s.now
+}
+
+// NameID derives a stable ID from a name, e.g. so a user has the same ID in every payload.
+func NameID(name string) int64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum32()%10_000_000) + 1
+}
+
+// Make returns a slice of n zero elements of the type of s, which may be a slice of anonymous structs.
+func Make[S ~[]E, E any](s S, n int) S {
+	return make(S, n)
+}
+
+// Set assigns values to the fields of the struct v points to, keyed by JSON name.
+// A key may be a dotted path into nested structs, e.g. "head.repo.full_name".
+// Set panics on keys of fields v does not have, e.g. misspelled ones, like on values of the wrong type.
+//
+// Values are converted to the field type: pointers are allocated, integers are converted,
+// times become Unix seconds in integer fields and RFC 3339 in string fields.
+func Set(v interface{}, values map[string]interface{}) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("fake: Set of %T, want a pointer to a struct", v))
+	}
+
+	for key, value := range values {
+		assign(field(rv.Elem(), key), reflect.ValueOf(value), key)
+	}
+}
+
+// field returns the field at the dotted path key, allocating the nil pointers on the way.
+// It panics if a segment of the path is not a field, e.g. on a misspelled key.
+func field(v reflect.Value, key string) reflect.Value {
+	for _, name := range strings.Split(key, ".") {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+
+		f, ok := reflect.Value{}, false
+		if v.Kind() == reflect.Struct {
+			f, ok = fieldByJSONName(v, name)
+		}
+		if !ok {
+			panic(fmt.Sprintf("fake: cannot set %s, %s has no field %s", key, v.Type(), name))
+		}
+		v = f
+	}
+	return v
+}
+
+func fieldByJSONName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if tag == name || (tag == "" && strings.EqualFold(sf.Name, name)) {
+			return v.Field(i), true
+		}
+
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+			if f, ok := fieldByJSONName(v.Field(i), name); ok {
+				return f, true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func assign(f, value reflect.Value, key string) {
+	if f.Kind() == reflect.Pointer && value.Type() != f.Type() {
+		p := reflect.New(f.Type().Elem())
+		assign(p.Elem(), value, key)
+		f.Set(p)
+		return
+	}
+
+	if t, ok := value.Interface().(time.Time); ok {
+		switch {
+		case f.Kind() == reflect.Int64 || f.Kind() == reflect.Int:
+			f.SetInt(t.Unix())
+			return
+		case f.Kind() == reflect.String:
+			f.SetString(t.Format(time.RFC3339))
+			return
+		case f.Kind() == reflect.Struct && f.Type() != timeType:
+			// types embedding time.Time for custom encodings
+			if inner := f.FieldByName("Time"); inner.IsValid() && inner.Type() == timeType {
+				inner.Set(value)
+				return
+			}
+		}
+	}
+
+	// integers convert to strings as runes, never what a payload wants
+	if !value.Type().ConvertibleTo(f.Type()) || (f.Kind() == reflect.String && value.Kind() != reflect.String) {
+		panic(fmt.Sprintf("fake: cannot set %s of type %s to %T", key, f.Type(), value.Interface()))
+	}
+	f.Set(value.Convert(f.Type()))
+}
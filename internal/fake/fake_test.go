@@ -0,0 +1,87 @@
+package fake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource(t *testing.T) {
+	assert := require.New(t)
+	a, b := New("github/octocat/hello-world"), New("github/octocat/hello-world")
+	assert.Equal(a.SHA(), b.SHA())
+	assert.Equal(a.UUID(), b.UUID())
+	assert.Equal(a.ID(), b.ID())
+	assert.Len(a.SHA(), 40)
+	assert.Regexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, a.UUID())
+	assert.Equal(a.ID()+1, a.ID())
+	assert.Equal(Epoch.Add(time.Minute), a.Time())
+	assert.Equal(Epoch.Add(2*time.Minute), a.Time())
+	assert.NotEqual(a.SHA(), New("github/octocat/spoon-knife").SHA())
+	assert.Equal(NameID("octocat"), NameID("octocat"))
+}
+
+func TestSet(t *testing.T) {
+	assert := require.New(t)
+	type stamp struct {
+		time.Time
+	}
+
+	var v struct {
+		ID       int64   `json:"id"`
+		Name     *string `json:"name"`
+		Created  string  `json:"created_at"`
+		Pushed   int64   `json:"pushed_at"`
+		Updated  stamp   `json:"updated_at"`
+		Untagged bool
+		Owner    *struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	}
+
+	Set(&v, map[string]interface{}{
+		"id":          42,
+		"name":        "hello-world",
+		"created_at":  Epoch,
+		"pushed_at":   Epoch,
+		"updated_at":  Epoch,
+		"untagged":    true,
+		"owner.login": "octocat",
+	})
+	assert.Equal(int64(42), v.ID)
+	assert.Equal("hello-world", *v.Name)
+	assert.Equal("2024-01-02T15:04:05Z", v.Created)
+	assert.Equal(Epoch.Unix(), v.Pushed)
+	assert.Equal(Epoch, v.Updated.Time)
+	assert.True(v.Untagged)
+	assert.Equal("octocat", v.Owner.Login)
+
+	assert.Panics(func() { Set(&v, map[string]interface{}{"created_at": 42}) })
+	assert.Panics(func() { Set(&v, map[string]interface{}{"missing": "typo"}) })
+	assert.Panics(func() { Set(&v, map[string]interface{}{"id.value": 1}) })
+	assert.Panics(func() { Set(v, nil) })
+}
+
+func TestSetMisspelled(t *testing.T) {
+	assert := require.New(t)
+	type head struct {
+		SHA string `json:"sha"`
+	}
+	type pullRequest struct {
+		Head *head `json:"head"`
+	}
+
+	var pl struct {
+		PullRequest pullRequest `json:"pull_request"`
+	}
+	Set(&pl, map[string]interface{}{"pull_request.head.sha": "a10867b14bb761a232cd80139fbd4c0d33264240"})
+	assert.Equal("a10867b14bb761a232cd80139fbd4c0d33264240", pl.PullRequest.Head.SHA)
+
+	assert.PanicsWithValue("fake: cannot set pull_request.heda.sha, fake.pullRequest has no field heda", func() {
+		Set(&pl, map[string]interface{}{"pull_request.heda.sha": "a10867b14bb761a232cd80139fbd4c0d33264240"})
+	})
+	assert.PanicsWithValue("fake: cannot set pull_request.head.sha.short, string has no field short", func() {
+		Set(&pl, map[string]interface{}{"pull_request.head.sha.short": "a10867b"})
+	})
+}
@@ -0,0 +1,152 @@
+// Package httpsig verifies the Signature and Digest headers of the HTTP Signatures draft,
+// e.g. keyId="hmac-key",algorithm="hmac-sha256",headers="(request-target) date digest",signature="...",
+// shared by the providers signing their deliveries with it.
+package httpsig
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Signature contains the parameters of a Signature header.
+type Signature struct {
+	Algorithm string
+	Headers   []string
+	Signature string
+}
+
+// Parse parses the comma separated, quoted parameters of a Signature header.
+func Parse(header string) (Signature, error) {
+	var sig Signature
+	for rest := strings.TrimSpace(header); rest != ""; {
+		name, value, ok := strings.Cut(rest, "=")
+		if !ok || !strings.HasPrefix(value, `"`) {
+			return sig, errors.New("invalid Signature Header")
+		}
+
+		value, rest, ok = strings.Cut(value[1:], `"`)
+		if !ok {
+			return sig, errors.New("invalid Signature Header")
+		}
+
+		switch strings.TrimSpace(name) {
+		case "algorithm":
+			sig.Algorithm = value
+		case "headers":
+			sig.Headers = strings.Fields(strings.ToLower(value))
+		case "signature":
+			sig.Signature = value
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+	}
+
+	if sig.Signature == "" {
+		return sig, errors.New("invalid Signature Header")
+	}
+
+	// the Date header is signed by default
+	if len(sig.Headers) == 0 {
+		sig.Headers = []string{"date"}
+	}
+	return sig, nil
+}
+
+// SigningString returns the headers signed in the order of the signature, one "name: value" line each.
+func SigningString(r *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		if name == "(request-target)" {
+			lines = append(lines, name+": "+strings.ToLower(r.Method)+" "+r.URL.RequestURI())
+			continue
+		}
+
+		values := r.Header.Values(name)
+		if len(values) == 0 {
+			return "", errors.New("missing signed " + http.CanonicalHeaderKey(name) + " Header")
+		}
+		lines = append(lines, name+": "+strings.Join(values, ", "))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Verify checks the Signature header of r, which must use the algorithm and cover the Digest and Date headers,
+// and the Digest header against the payload. check verifies the signature of the signing string.
+// It returns the Date, only trusted once the signature covering it is verified.
+func Verify(r *http.Request, payload []byte, algorithm string, check func(signing, signature string) error) (time.Time, error) {
+	header := r.Header.Get("Signature")
+	if len(header) == 0 {
+		return time.Time{}, errors.New("missing Signature Header")
+	}
+
+	sig, err := Parse(header)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !strings.EqualFold(sig.Algorithm, algorithm) {
+		return time.Time{}, errors.New("unsupported signature algorithm " + sig.Algorithm)
+	}
+
+	var digestCovered, dateCovered bool
+	for _, name := range sig.Headers {
+		digestCovered = digestCovered || name == "digest"
+		dateCovered = dateCovered || name == "date"
+	}
+
+	// a signature not covering the digest does not authenticate the body
+	if !digestCovered {
+		return time.Time{}, errors.New("Signature does not cover the Digest Header")
+	}
+
+	// nor one not covering the date its freshness, it could be replayed forever
+	if !dateCovered {
+		return time.Time{}, errors.New("Signature does not cover the Date Header")
+	}
+
+	if err = checkDigest(r.Header.Get("Digest"), payload); err != nil {
+		return time.Time{}, err
+	}
+
+	signing, err := SigningString(r, sig.Headers)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err = check(signing, sig.Signature); err != nil {
+		return time.Time{}, err
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, errors.New("invalid Date Header")
+	}
+	return date, nil
+}
+
+// checkDigest checks the SHA-256 of a Digest header against the payload.
+func checkDigest(digest string, payload []byte) error {
+	if len(digest) == 0 {
+		return errors.New("missing Digest Header")
+	}
+
+	sum := sha256.Sum256(payload)
+	expected := base64.StdEncoding.EncodeToString(sum[:])
+	for _, d := range strings.Split(digest, ",") {
+		algorithm, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(algorithm, "SHA-256") && subtle.ConstantTimeCompare([]byte(value), []byte(expected)) == 1 {
+			return nil
+		}
+	}
+	return errors.New("Digest verification failed")
+}
+
+// Expired reports whether date is further from now than the tolerance.
+func Expired(date, now time.Time, tolerance time.Duration) bool {
+	age := now.Sub(date)
+	return age > tolerance || age < -tolerance
+}
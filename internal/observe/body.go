@@ -0,0 +1,122 @@
+package observe
+
+import (
+	"context"
+	"io"
+)
+
+// Body reads the body of a delivery, counting the bytes read and failing once its context is done,
+// e.g. when the client disconnects or sends the body slower than the read timeout allows.
+//
+// A Body of NewBody reads the body directly and checks the context between reads, a read blocked on a stalled
+// client returns when the server closes the connection. A Body of NewAbortableBody returns as soon as its context
+// is done instead: a single goroutine, started by the first Read, reads the body while Read waits for it or the
+// context. Once aborted every Read returns the error of the context.
+type Body struct {
+	body io.ReadCloser
+	ctx  context.Context
+	n    int64
+	err  error
+	// next and reads connect Read and the goroutine of an abortable body, buf is the buffer it reads into.
+	next    chan []byte
+	reads   chan read
+	buf     []byte
+	started bool
+	pending bool
+	closed  bool
+}
+
+type read struct {
+	n   int
+	err error
+}
+
+// NewBody returns a Body reading body until ctx is done.
+func NewBody(ctx context.Context, body io.ReadCloser) *Body {
+	return &Body{body: body, ctx: ctx}
+}
+
+// NewAbortableBody returns a Body reading body until ctx is done, aborting a read blocked when it is.
+func NewAbortableBody(ctx context.Context, body io.ReadCloser) *Body {
+	return &Body{body: body, ctx: ctx, next: make(chan []byte), reads: make(chan read, 1)}
+}
+
+// Read implements io.Reader.
+func (b *Body) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	if err := b.ctx.Err(); err != nil {
+		b.err = err
+		return 0, err
+	}
+
+	if b.next == nil {
+		n, err := b.body.Read(p)
+		b.n += int64(n)
+		return n, err
+	}
+
+	if !b.started {
+		b.started = true
+		go b.pump()
+	}
+
+	// the buffer is only handed to the goroutine while Read waits for it, or abandoned to it once aborted
+	if len(b.buf) < len(p) {
+		b.buf = make([]byte, len(p))
+	}
+	b.next <- b.buf[:len(p)]
+
+	select {
+	case res := <-b.reads:
+		n := copy(p, b.buf[:res.n])
+		b.n += int64(n)
+		return n, res.err
+	case <-b.ctx.Done():
+		b.pending, b.err = true, b.ctx.Err()
+		return 0, b.err
+	}
+}
+
+// pump reads the body into the buffers of Read until the body is closed.
+func (b *Body) pump() {
+	for buf := range b.next {
+		n, err := b.body.Read(buf)
+		b.reads <- read{n: n, err: err}
+	}
+}
+
+// Close implements io.Closer.
+// The body of an aborted read is closed once the read returns, without waiting for it.
+func (b *Body) Close() error {
+	if b.closed {
+		return nil
+	}
+
+	b.closed = true
+	if b.next == nil {
+		return b.body.Close()
+	}
+
+	close(b.next)
+	if b.pending {
+		go func() {
+			<-b.reads
+			_ = b.body.Close()
+		}()
+		return nil
+	}
+	return b.body.Close()
+}
+
+// Len returns the number of bytes read.
+func (b *Body) Len() int64 {
+	return b.n
+}
+
+// Err returns the error of the context the read was aborted with, if any.
+func (b *Body) Err() error {
+	return b.err
+}
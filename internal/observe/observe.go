@@ -0,0 +1,450 @@
+// Package observe instruments the Parse of the provider packages.
+//
+// A provider describes the headers it sends with a Provider, its Parse starts a Delivery for every request,
+// reports the verification and decoding steps to it and finishes it with the parsed payload or the error.
+package observe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+const (
+	// Verification outcomes of a delivery.
+	VerificationOK      Verification = "ok"
+	VerificationFailed  Verification = "failed"
+	VerificationSkipped Verification = "skipped"
+	// Reasons a delivery is rejected for.
+	ReasonRequest      Reason = "request"
+	ReasonVerification Reason = "verification"
+	ReasonDecode       Reason = "decode"
+	ReasonCanceled     Reason = "canceled"
+)
+
+// redacted replaces the values of the headers carrying secrets.
+const redacted = "[REDACTED]"
+
+// Verification is the outcome of verifying the signature, token or credentials of a delivery.
+// It is empty when the delivery was rejected before it was verified.
+type Verification string
+
+// Reason is the step a delivery was rejected at.
+type Reason string
+
+// Provider describes the headers a provider sends with its deliveries.
+type Provider struct {
+	Name           string
+	EventHeader    string
+	DeliveryHeader string
+	// SecretHeaders carry signatures, tokens or credentials, their values are never logged.
+	// The Authorization header is always redacted.
+	SecretHeaders []string
+}
+
+// Observer holds the instrumentation configured with the Logger, Metrics, Tracer and ReadTimeout options
+// of a provider, it documents them for every provider. The zero Observer does nothing.
+type Observer struct {
+	// Logger logs every delivery: the request at debug level with the Authorization and SecretHeaders redacted,
+	// the parsed payload at info level and a rejected delivery at warn level with the reason and the error.
+	Logger *slog.Logger
+	// Metrics receives the deliveries received, verified, rejected and parsed and their body sizes.
+	Metrics metrics.Recorder
+	// Tracer traces every delivery with a span annotated with the provider, event, action, repository,
+	// delivery ID and verification result, and child spans verifying and decoding it.
+	// Deliveries parsed by a dispatcher with a tracer are annotated in its span instead.
+	Tracer tracing.Tracer
+	// ReadTimeout bounds the time reading the body of a delivery takes, if positive, e.g. of a client sending it
+	// too slowly, independently of the server timeouts. Reading the body of a cancelled request is always aborted,
+	// the error returned wraps the context error.
+	ReadTimeout time.Duration
+}
+
+// Delivery tracks the parsing of a delivery.
+type Delivery struct {
+	Provider     *Provider
+	Event        string
+	ID           string
+	Verification Verification
+	ctx          context.Context
+	logger       *slog.Logger
+	cancel       context.CancelFunc
+	metrics      metrics.Recorder
+	body         *Body
+	decoding     bool
+	canceled     bool
+	tracer       tracing.Tracer
+	span         tracing.Span
+	decodeSpan   tracing.Span
+	// ownSpan reports whether span was started by the delivery rather than by a dispatcher
+	ownSpan bool
+}
+
+// Scope is put in the request context by a dispatcher calling Parse,
+// to share its delivery span with the provider and to learn the delivery the provider parsed.
+type Scope struct {
+	// Tracer started Span, the delivery span the Delivery annotates and nests its spans in instead of starting its own.
+	Tracer tracing.Tracer
+	Span   tracing.Span
+	// Delivery is set by Start.
+	Delivery *Delivery
+}
+
+type scopeKey struct{}
+
+// ContextWithScope returns a copy of ctx carrying s.
+func ContextWithScope(ctx context.Context, s *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// Start starts tracking the parsing of the delivery r of provider p.
+func (o Observer) Start(p *Provider, r *http.Request) *Delivery {
+	d := &Delivery{Provider: p, ctx: r.Context(), logger: o.Logger, metrics: o.Metrics}
+	if d.logger == nil {
+		d.logger = slog.New(slog.DiscardHandler)
+	}
+
+	if d.metrics != nil {
+		d.metrics.Received(p.Name)
+	}
+
+	if p.EventHeader != "" {
+		d.Event = r.Header.Get(p.EventHeader)
+	}
+
+	if p.DeliveryHeader != "" {
+		d.ID = r.Header.Get(p.DeliveryHeader)
+	}
+
+	s, _ := d.ctx.Value(scopeKey{}).(*Scope)
+	if s != nil {
+		s.Delivery = d
+	}
+
+	if s != nil && s.Span != nil {
+		d.tracer, d.span = s.Tracer, s.Span
+	} else if o.Tracer != nil {
+		d.tracer, d.ownSpan = o.Tracer, true
+		d.ctx, d.span = o.Tracer.Start(d.ctx, tracing.DeliverySpan)
+	}
+
+	if d.span != nil {
+		d.span.SetAttributes(tracing.String(tracing.ProviderKey, p.Name))
+	}
+
+	// only a read timeout needs reads to be abortable, the others return when the server closes the connection
+	if r.Body != nil {
+		if o.ReadTimeout > 0 {
+			var ctx context.Context
+			ctx, d.cancel = context.WithTimeout(d.ctx, o.ReadTimeout)
+			d.body = NewAbortableBody(ctx, r.Body)
+		} else {
+			d.body = NewBody(d.ctx, r.Body)
+		}
+		r.Body = d.body
+	}
+
+	d.logger.DebugContext(d.ctx, "webhook delivery received",
+		slog.String("provider", p.Name),
+		slog.String("event", d.Event),
+		slog.String("delivery", d.ID),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int64("content_length", r.ContentLength),
+		slog.Any("headers", Header{Header: r.Header, Secrets: p.SecretHeaders}),
+	)
+	return d
+}
+
+// Verify records the outcome of verify, which is only called when a secret is configured.
+// The context error is returned instead when the request is already cancelled.
+func (d *Delivery) Verify(configured bool, verify func() error) error {
+	if err := d.ctx.Err(); err != nil {
+		d.canceled = true
+		return err
+	}
+
+	var span tracing.Span
+	if d.tracer != nil {
+		_, span = d.tracer.Start(d.ctx, tracing.VerifySpan)
+	}
+
+	err := d.verify(configured, verify)
+	if d.metrics != nil {
+		d.metrics.Verified(d.Provider.Name, string(d.Verification))
+	}
+
+	if span != nil {
+		span.SetAttributes(tracing.String(tracing.VerificationKey, string(d.Verification)))
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+	return err
+}
+
+func (d *Delivery) verify(configured bool, verify func() error) error {
+	if !configured {
+		d.Verification = VerificationSkipped
+		return nil
+	}
+
+	if err := verify(); err != nil {
+		d.Verification = VerificationFailed
+		return err
+	}
+
+	d.Verification = VerificationOK
+	return nil
+}
+
+// Decoding records that the delivery was accepted and its payload is being decoded,
+// errors reported to Done from then on are decode errors.
+func (d *Delivery) Decoding() {
+	d.decoding = true
+	if d.tracer != nil && d.decodeSpan == nil {
+		_, d.decodeSpan = d.tracer.Start(d.ctx, tracing.DecodeSpan)
+	}
+}
+
+// Done finishes tracking the delivery, logging and counting the parsed payload or the reason it was rejected.
+// It returns err, wrapping the context error when reading the body was aborted.
+func (d *Delivery) Done(payload interface{}, err error) error {
+	if d.cancel != nil {
+		d.cancel()
+	}
+
+	if err != nil && d.body != nil && d.body.Err() != nil && !errors.Is(err, d.body.Err()) {
+		d.canceled = true
+		err = fmt.Errorf("%w: %w", err, d.body.Err())
+	}
+
+	if d.metrics != nil {
+		d.measure(err)
+	}
+
+	if d.span != nil {
+		d.trace(payload, err)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("provider", d.Provider.Name),
+		slog.String("event", d.Event),
+		slog.String("delivery", d.ID),
+		slog.String("verification", string(d.Verification)),
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.String("reason", string(d.reason())), slog.String("error", err.Error()))
+		d.logger.LogAttrs(d.ctx, slog.LevelWarn, "webhook delivery rejected", attrs...)
+		return err
+	}
+
+	attrs = append(attrs, slog.String("repository", Repository(payload)))
+	d.logger.LogAttrs(d.ctx, slog.LevelInfo, "webhook delivery parsed", attrs...)
+	return nil
+}
+
+func (d *Delivery) measure(err error) {
+	if d.body != nil {
+		d.metrics.BodySize(d.Provider.Name, d.body.Len())
+	}
+
+	if err != nil {
+		d.metrics.Rejected(d.Provider.Name, string(d.reason()))
+	} else {
+		d.metrics.Parsed(d.Provider.Name, d.Event)
+	}
+}
+
+func (d *Delivery) trace(payload interface{}, err error) {
+	if d.decodeSpan != nil {
+		if err != nil {
+			d.decodeSpan.RecordError(err)
+		}
+		d.decodeSpan.End()
+	}
+
+	d.span.SetAttributes(
+		tracing.String(tracing.EventKey, d.Event),
+		tracing.String(tracing.DeliveryIDKey, d.ID),
+		tracing.String(tracing.VerificationKey, string(d.Verification)),
+	)
+	if err != nil {
+		d.span.RecordError(err)
+	} else {
+		d.span.SetAttributes(
+			tracing.String(tracing.ActionKey, Action(payload)),
+			tracing.String(tracing.RepositoryKey, Repository(payload)),
+		)
+	}
+
+	if d.ownSpan {
+		d.span.End()
+	}
+}
+
+func (d *Delivery) reason() Reason {
+	switch {
+	case d.canceled:
+		return ReasonCanceled
+	case d.Verification == VerificationFailed:
+		return ReasonVerification
+	case d.decoding:
+		return ReasonDecode
+	default:
+		return ReasonRequest
+	}
+}
+
+// Header logs the headers of a request with the values of the secret headers redacted.
+type Header struct {
+	Header  http.Header
+	Secrets []string
+}
+
+// LogValue implements slog.LogValuer.
+func (h Header) LogValue() slog.Value {
+	names := make([]string, 0, len(h.Header))
+	for name := range h.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]slog.Attr, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(h.Header[name], ", ")
+		if h.secret(name) {
+			value = redacted
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (h Header) secret(name string) bool {
+	if strings.EqualFold(name, "Authorization") {
+		return true
+	}
+
+	for _, s := range h.Secrets {
+		if strings.EqualFold(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository returns the full name of the repository or project a payload refers to,
+// looking for the Repository, Repo, Project and Resource fields used by the provider payloads,
+// either a struct with the name or the name itself.
+func Repository(payload interface{}) string {
+	return repository(reflect.ValueOf(payload), 0)
+}
+
+func repository(v reflect.Value, depth int) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	// Gerrit names the project of an event by a string
+	if v.Kind() == reflect.String && depth > 0 {
+		return v.String()
+	}
+
+	if v.Kind() != reflect.Struct || depth > 2 {
+		return ""
+	}
+
+	if depth > 0 {
+		for _, name := range []string{"FullName", "PathWithNamespace", "RepoName", "Name"} {
+			if f := v.FieldByName(name); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+				return f.String()
+			}
+		}
+	}
+
+	for _, name := range []string{"Repository", "Repo", "Project", "Resource"} {
+		if f := v.FieldByName(name); f.IsValid() {
+			if key := repository(f, depth+1); key != "" {
+				return key
+			}
+		}
+	}
+
+	return ""
+}
+
+// Action returns the action of a payload, looking for the Action field of the payload
+// or of the ObjectAttributes of the GitLab payloads.
+func Action(payload interface{}) string {
+	v := reflect.ValueOf(payload)
+	for depth := 0; depth < 2; depth++ {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return ""
+			}
+			v = v.Elem()
+		}
+
+		if v.Kind() != reflect.Struct {
+			return ""
+		}
+
+		if f := v.FieldByName("Action"); f.IsValid() && f.Kind() == reflect.String {
+			return f.String()
+		}
+		v = v.FieldByName("ObjectAttributes")
+	}
+	return ""
+}
+
+// EventActions pairs an event with the actions of it that should be parsed.
+// An empty Actions list matches every action of the event.
+type EventActions[E, A ~string] struct {
+	Event   E
+	Actions []A
+}
+
+// Events returns the events of the filters, the ones to parse.
+func Events[E, A ~string](filters []EventActions[E, A]) []E {
+	events := make([]E, 0, len(filters))
+	for _, f := range filters {
+		events = append(events, f.Event)
+	}
+	return events
+}
+
+// Matches reports whether the filters register the event of a parsed payload and the action of the payload.
+func Matches[E, A ~string](filters []EventActions[E, A], event E, payload interface{}) bool {
+	action := A(Action(payload))
+	for _, f := range filters {
+		if f.Event != event {
+			continue
+		}
+
+		if len(f.Actions) == 0 {
+			return true
+		}
+
+		for _, a := range f.Actions {
+			if a == action {
+				return true
+			}
+		}
+	}
+	return false
+}
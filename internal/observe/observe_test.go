@@ -0,0 +1,284 @@
+package observe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testProvider = &Provider{
+	Name:           "test",
+	EventHeader:    "X-Event",
+	DeliveryHeader: "X-Delivery",
+	SecretHeaders:  []string{"X-Signature"},
+}
+
+func request() *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader("{}"))
+	r.Header.Set("X-Event", "push")
+	r.Header.Set("X-Delivery", "42")
+	r.Header.Set("X-Signature", "sha256=deadbeef")
+	r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	r.Header.Set("User-Agent", "test")
+	return r
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var recs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestDelivery(t *testing.T) {
+	type repo struct{ FullName string }
+	type payload struct{ Repository repo }
+
+	tests := []struct {
+		name         string
+		run          func(d *Delivery) (interface{}, error)
+		level        string
+		msg          string
+		verification Verification
+		reason       Reason
+	}{
+		{
+			name: "Parsed",
+			run: func(d *Delivery) (interface{}, error) {
+				if err := d.Verify(true, func() error { return nil }); err != nil {
+					return nil, err
+				}
+				d.Decoding()
+				return payload{Repository: repo{FullName: "octocat/hello-world"}}, nil
+			},
+			level:        "INFO",
+			msg:          "webhook delivery parsed",
+			verification: VerificationOK,
+		},
+		{
+			name: "Unverified",
+			run: func(d *Delivery) (interface{}, error) {
+				_ = d.Verify(false, nil)
+				d.Decoding()
+				return payload{}, nil
+			},
+			level:        "INFO",
+			msg:          "webhook delivery parsed",
+			verification: VerificationSkipped,
+		},
+		{
+			name: "BadMethod",
+			run: func(d *Delivery) (interface{}, error) {
+				return nil, errors.New("invalid HTTP Method")
+			},
+			level:  "WARN",
+			msg:    "webhook delivery rejected",
+			reason: ReasonRequest,
+		},
+		{
+			name: "BadSignature",
+			run: func(d *Delivery) (interface{}, error) {
+				return nil, d.Verify(true, func() error { return errors.New("HMAC verification failed") })
+			},
+			level:        "WARN",
+			msg:          "webhook delivery rejected",
+			verification: VerificationFailed,
+			reason:       ReasonVerification,
+		},
+		{
+			name: "BadPayload",
+			run: func(d *Delivery) (interface{}, error) {
+				_ = d.Verify(true, func() error { return nil })
+				d.Decoding()
+				return nil, errors.New("error parsing payload")
+			},
+			level:        "WARN",
+			msg:          "webhook delivery rejected",
+			verification: VerificationOK,
+			reason:       ReasonDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+			var buf bytes.Buffer
+			o := Observer{Logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+			d := o.Start(testProvider, request())
+			assert.Equal("push", d.Event)
+			assert.Equal("42", d.ID)
+			d.Done(tc.run(d))
+			assert.Equal(tc.verification, d.Verification)
+			assert.NotContains(buf.String(), "deadbeef")
+			assert.NotContains(buf.String(), "dXNlcjpwYXNz")
+
+			recs := records(t, &buf)
+			assert.Len(recs, 2)
+			assert.Equal("DEBUG", recs[0]["level"])
+			assert.Equal("webhook delivery received", recs[0]["msg"])
+			headers := recs[0]["headers"].(map[string]interface{})
+			assert.Equal(redacted, headers["X-Signature"])
+			assert.Equal(redacted, headers["Authorization"])
+			assert.Equal("test", headers["User-Agent"])
+
+			assert.Equal(tc.level, recs[1]["level"])
+			assert.Equal(tc.msg, recs[1]["msg"])
+			assert.Equal("test", recs[1]["provider"])
+			assert.Equal("push", recs[1]["event"])
+			assert.Equal("42", recs[1]["delivery"])
+			assert.Equal(string(tc.verification), recs[1]["verification"])
+			if tc.reason != "" {
+				assert.Equal(string(tc.reason), recs[1]["reason"])
+			} else {
+				assert.NotContains(recs[1], "reason")
+			}
+		})
+	}
+}
+
+func TestZeroObserver(t *testing.T) {
+	assert := require.New(t)
+	d := Observer{}.Start(&Provider{Name: "test"}, request())
+	assert.Empty(d.Event)
+	assert.Empty(d.ID)
+	assert.NotPanics(func() { d.Done(nil, errors.New("error")) })
+}
+
+func TestRepository(t *testing.T) {
+	assert := require.New(t)
+	type project struct{ PathWithNamespace string }
+	type repo struct{ Name, FullName string }
+	type resource struct{ Repository *repo }
+
+	assert.Equal("group/project", Repository(struct{ Project project }{project{"group/project"}}))
+	assert.Equal("octocat/hello-world", Repository(&struct{ Repository repo }{repo{"hello-world", "octocat/hello-world"}}))
+	assert.Equal("fabrikam", Repository(struct{ Resource resource }{resource{&repo{Name: "fabrikam"}}}))
+	assert.Empty(Repository(struct{ Resource resource }{}))
+	assert.Equal("platform/build", Repository(struct{ Project string }{"platform/build"}))
+	assert.Empty(Repository(nil))
+	assert.Empty(Repository("push"))
+}
+
+func TestAction(t *testing.T) {
+	assert := require.New(t)
+	type action string
+	type attributes struct{ Action string }
+
+	assert.Equal("opened", Action(struct{ Action action }{"opened"}))
+	assert.Equal("merge", Action(&struct{ ObjectAttributes attributes }{attributes{"merge"}}))
+	assert.Empty(Action(struct{ Action int }{1}))
+	assert.Empty(Action(struct{ Ref string }{"main"}))
+	assert.Empty(Action(nil))
+}
+
+func TestEventActions(t *testing.T) {
+	assert := require.New(t)
+	type event string
+	type action string
+	filters := []EventActions[event, action]{
+		{Event: "pull_request", Actions: []action{"opened", "reopened"}},
+		{Event: "push"},
+	}
+
+	assert.Equal([]event{"pull_request", "push"}, Events(filters))
+	assert.True(Matches(filters, "pull_request", struct{ Action string }{"reopened"}))
+	assert.False(Matches(filters, "pull_request", struct{ Action string }{"closed"}))
+	assert.True(Matches(filters, "push", struct{ Ref string }{"main"}))
+	assert.False(Matches(filters, "issues", struct{ Action string }{"opened"}))
+}
+
+// stalled is a body sending its first bytes and then nothing, like a slow-loris client.
+type stalled struct {
+	sent    bool
+	release chan struct{}
+}
+
+func (s *stalled) Read(p []byte) (int, error) {
+	if !s.sent {
+		s.sent = true
+		return copy(p, "{"), nil
+	}
+	<-s.release
+	return 0, io.EOF
+}
+
+func (s *stalled) Close() error {
+	return nil
+}
+
+func TestBody(t *testing.T) {
+	assert := require.New(t)
+	b := NewBody(context.Background(), io.NopCloser(strings.NewReader("payload")))
+	data, err := io.ReadAll(b)
+	assert.NoError(err)
+	assert.Equal("payload", string(data))
+	assert.Equal(int64(7), b.Len())
+	assert.NoError(b.Err())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	body := &stalled{release: make(chan struct{})}
+	defer close(body.release)
+	b = NewAbortableBody(ctx, body)
+	start := time.Now()
+	data, err = io.ReadAll(b)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Equal("{", string(data))
+	assert.Less(time.Since(start), time.Second)
+	assert.Equal(int64(1), b.Len())
+
+	// an aborted body fails right away and does not wait for the pending read to close
+	_, err = b.Read(make([]byte, 8))
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.NoError(b.Close())
+	assert.NoError(b.Close())
+
+	data, err = io.ReadAll(NewAbortableBody(context.Background(), io.NopCloser(strings.NewReader("payload"))))
+	assert.NoError(err)
+	assert.Equal("payload", string(data))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = NewBody(ctx, io.NopCloser(strings.NewReader("payload"))).Read(make([]byte, 8))
+	assert.ErrorIs(err, context.Canceled)
+}
+
+func TestCanceled(t *testing.T) {
+	assert := require.New(t)
+	errParsing := errors.New("error parsing payload")
+	body := &stalled{release: make(chan struct{})}
+	defer close(body.release)
+	r := httptest.NewRequest(http.MethodPost, "/hooks", body)
+	d := Observer{ReadTimeout: 20 * time.Millisecond}.Start(testProvider, r)
+	_, err := io.ReadAll(r.Body)
+	assert.Error(err)
+	err = d.Done(nil, errParsing)
+	assert.ErrorIs(err, errParsing)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Equal(ReasonCanceled, d.reason())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d = Observer{}.Start(testProvider, request().WithContext(ctx))
+	err = d.Verify(true, func() error { return nil })
+	assert.ErrorIs(err, context.Canceled)
+	assert.Empty(d.Verification)
+	assert.Equal(ReasonCanceled, d.reason())
+}
@@ -0,0 +1,75 @@
+package jenkins
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic notifications of a job to unit test handlers.
+// The notifications of a faker agree with each other on the job and the number, commit and start of the current build,
+// and encode to the JSON the Jenkins Notification plugin sends, e.g. to deliver them with the whtest package.
+// The same job always yields the same notifications.
+type Faker struct {
+	host  string
+	path  []string
+	build Build
+	src   *fake.Source
+}
+
+// NewFaker returns a faker of the job with the given full name, e.g. "platform/api/main" for a branch
+// of a multibranch pipeline, on jenkins.example.com.
+func NewFaker(fullName string) *Faker {
+	return &Faker{
+		host: "https://jenkins.example.com/",
+		path: strings.Split(fullName, "/"),
+		src:  fake.New("jenkins/" + fullName),
+	}
+}
+
+// JobPayload returns the notification of the phase of the current build, finished with the status
+// from the COMPLETED phase on, e.g. "SUCCESS" or "FAILURE". The QUEUED phase queues a new build of a new commit.
+func (f *Faker) JobPayload(phase Event, status string) JobPayload {
+	if phase == QueuedEvent || f.build.Number == 0 {
+		f.next()
+	}
+
+	build := f.build
+	build.Phase = phase
+	if phase == CompletedEvent || phase == FinalizedEvent {
+		build.Status = status
+		build.Duration = Milliseconds(f.src.Time().Sub(build.Timestamp.Time()).Milliseconds())
+	}
+
+	url := f.url()
+	return JobPayload{
+		Name:        f.path[len(f.path)-1],
+		DisplayName: f.path[len(f.path)-1],
+		URL:         url,
+		Build:       build,
+	}
+}
+
+// next queues the next build of the job.
+func (f *Faker) next() {
+	url := f.url()
+	number := f.build.Number + 1
+	f.build = Build{
+		FullURL:   f.host + url + strconv.FormatInt(number, 10) + "/",
+		Number:    number,
+		QueueID:   1000 + f.src.ID(),
+		Timestamp: Timestamp(f.src.Time().UnixMilli()),
+		URL:       url + strconv.FormatInt(number, 10) + "/",
+		SCM: &SCM{
+			URL:    "https://github.com/example/" + f.path[0] + ".git",
+			Branch: "origin/" + f.path[len(f.path)-1],
+			Commit: f.src.SHA(),
+		},
+	}
+}
+
+// url returns the path of the job relative to the Jenkins root.
+func (f *Faker) url() string {
+	return "job/" + strings.Join(f.path, "/job/") + "/"
+}
@@ -0,0 +1,289 @@
+package jenkins
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	path   = "/webhooks"
+	secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+)
+
+var hook *Webhook
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	hook, err = New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func TestWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+	}{
+		{
+			name:     "StartedEvent",
+			event:    StartedEvent,
+			typ:      JobPayload{},
+			filename: "./testdata/started.json",
+		},
+		{
+			name:     "CompletedEvent",
+			event:    CompletedEvent,
+			typ:      JobPayload{},
+			filename: "./testdata/completed.json",
+		},
+		{
+			name:     "FinalizedEvent",
+			event:    FinalizedEvent,
+			typ:      JobPayload{},
+			filename: "./testdata/finalized.json",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.Open(tc.filename)
+			assert.NoError(err)
+			defer func() {
+				_ = payload.Close()
+			}()
+
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, payload)
+			assert.NoError(err)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	parse := func(event Event, filename string) JobPayload {
+		pl, err := hook.Parse(whtest.Jenkins(whtest.Fixture(t, filename)).Request(), event)
+		assert.NoError(err)
+		return pl.(JobPayload)
+	}
+
+	started := parse(StartedEvent, "./testdata/started.json")
+	assert.Equal("main", started.Name)
+	assert.Equal("job/platform/job/api/job/main/", started.URL)
+	assert.Equal(int64(42), started.Build.Number)
+	assert.Equal(StartedEvent, started.Build.Phase)
+	assert.Empty(started.Build.Status)
+	assert.Equal("origin/main", started.Build.SCM.Branch)
+	assert.Equal(time.Date(2024, 6, 3, 8, 44, 1, 0, time.UTC), started.Build.Timestamp.Time())
+
+	completed := parse(CompletedEvent, "./testdata/completed.json")
+	assert.Equal(started.Build.SCM.Commit, completed.Build.SCM.Commit)
+	assert.Equal("UNSTABLE", completed.Build.Status)
+	assert.Equal(187342*time.Millisecond, completed.Build.Duration.Duration())
+	assert.Equal(2, completed.Build.TestSummary.Failed)
+	assert.Equal([]string{"TestCache/Eviction", "TestCache/Expiry"}, completed.Build.TestSummary.FailedTests)
+	assert.Contains(completed.Build.Artifacts, "api-linux-amd64.tar.gz")
+
+	finalized := parse(FinalizedEvent, "./testdata/finalized.json")
+	assert.Equal(completed.Build.Status, finalized.Build.Status)
+	assert.Nil(finalized.Build.TestSummary)
+}
+
+func TestBadRequests(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/started.json")
+	tests := []struct {
+		name   string
+		events []Event
+		r      *http.Request
+	}{
+		{name: "NoEvents", r: whtest.Jenkins(body).Request()},
+		{name: "UnsubscribedEvent", events: []Event{CompletedEvent}, r: whtest.Jenkins(body).Request()},
+		{name: "BadMethod", events: []Event{StartedEvent}, r: whtest.Jenkins(body).Method(http.MethodGet).Request()},
+		{name: "BadBody", events: []Event{StartedEvent}, r: whtest.Jenkins("").Request()},
+		{name: "BadJSON", events: []Event{StartedEvent}, r: whtest.Jenkins("{").Request()},
+		{name: "UnknownEvent", events: []Event{"DELETED"}, r: whtest.Jenkins(`{"build":{"phase":"DELETED"}}`).Request()},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := hook.Parse(tc.r, tc.events...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestVerification(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/completed.json")
+	tests := []struct {
+		name    string
+		options []Option
+		r       *http.Request
+		wantErr error
+	}{
+		{
+			name:    "Secret",
+			options: []Option{Options.Secret(secret)},
+			r:       whtest.Jenkins(body).Target(path + "?token=" + secret).Request(),
+		},
+		{
+			name:    "WrongSecret",
+			options: []Option{Options.Secret(secret)},
+			r:       whtest.Jenkins(body).Target(path + "?token=wrong").Request(),
+			wantErr: ErrSecretVerificationFailed,
+		},
+		{
+			name:    "MissingSecret",
+			options: []Option{Options.Secret(secret)},
+			r:       whtest.Jenkins(body).Request(),
+			wantErr: ErrSecretVerificationFailed,
+		},
+		{
+			name:    "BasicAuth",
+			options: []Option{Options.BasicAuth("jenkins", secret)},
+			r:       whtest.Jenkins(body).BasicAuth("jenkins", secret).Request(),
+		},
+		{
+			name:    "WrongPassword",
+			options: []Option{Options.BasicAuth("jenkins", secret)},
+			r:       whtest.Jenkins(body).BasicAuth("jenkins", secret).Tampered(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+		{
+			name:    "MissingBasicAuth",
+			options: []Option{Options.BasicAuth("jenkins", secret)},
+			r:       whtest.Jenkins(body).Request(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+		{
+			name:    "SecretAndBasicAuth",
+			options: []Option{Options.Secret(secret), Options.BasicAuth("jenkins", secret)},
+			r:       whtest.Jenkins(body).BasicAuth("jenkins", secret).Target(path + "?token=" + secret).Request(),
+		},
+		{
+			name:    "SecretWithoutBasicAuth",
+			options: []Option{Options.Secret(secret), Options.BasicAuth("jenkins", secret)},
+			r:       whtest.Jenkins(body).Target(path + "?token=" + secret).Request(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			h, err := New(tc.options...)
+			assert.NoError(err)
+			_, err = h.Parse(tc.r, CompletedEvent)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+
+	_, err := New(Options.Secret(""))
+	require.Error(t, err)
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("platform/api/main")
+	queued := faker.JobPayload(QueuedEvent, "")
+	assert.Equal("main", queued.Name)
+	assert.Equal("job/platform/job/api/job/main/", queued.URL)
+	assert.Equal(int64(1), queued.Build.Number)
+	assert.Equal("https://jenkins.example.com/job/platform/job/api/job/main/1/", queued.Build.FullURL)
+
+	started := faker.JobPayload(StartedEvent, "")
+	completed := faker.JobPayload(CompletedEvent, "SUCCESS")
+	assert.Equal(queued.Build.SCM.Commit, completed.Build.SCM.Commit)
+	assert.Equal(queued.Build.Timestamp, completed.Build.Timestamp)
+	assert.Equal("SUCCESS", completed.Build.Status)
+	assert.Positive(completed.Build.Duration.Duration())
+	assert.Empty(started.Build.Status)
+
+	next := faker.JobPayload(QueuedEvent, "")
+	assert.Equal(int64(2), next.Build.Number)
+	assert.NotEqual(queued.Build.SCM.Commit, next.Build.SCM.Commit)
+	assert.Equal(queued, NewFaker("platform/api/main").JobPayload(QueuedEvent, ""))
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: QueuedEvent, payload: queued},
+		{event: StartedEvent, payload: started},
+		{event: CompletedEvent, payload: completed},
+		{event: FinalizedEvent, payload: faker.JobPayload(FinalizedEvent, "FAILURE")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Jenkins(tc.payload).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{StartedEvent, "./testdata/started.json"},
+		{CompletedEvent, "./testdata/completed.json"},
+		{FinalizedEvent, "./testdata/finalized.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), payload)
+	}
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event string, payload []byte) {
+		r := whtest.Jenkins(payload).Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package jenkins
+
+import "time"
+
+// Timestamp is a point in time in milliseconds since the epoch as Jenkins sends it.
+type Timestamp int64
+
+// Time returns the timestamp as a time in UTC.
+func (t Timestamp) Time() time.Time {
+	return time.UnixMilli(int64(t)).UTC()
+}
+
+// Milliseconds is a duration in milliseconds as Jenkins sends it.
+type Milliseconds int64
+
+// Duration returns the milliseconds as a duration.
+func (m Milliseconds) Duration() time.Duration {
+	return time.Duration(m) * time.Millisecond
+}
+
+// BasicEvent contains the build phase of a notification, the event of the notification.
+type BasicEvent struct {
+	Build struct {
+		Phase Event `json:"phase"`
+	} `json:"build"`
+}
+
+// SCM contains the checkout of a build, Branch is the remote branch, e.g. "origin/main".
+// Changes are the files and Culprits the users changing them since the previous build.
+type SCM struct {
+	URL      string   `json:"url,omitempty"`
+	Branch   string   `json:"branch,omitempty"`
+	Commit   string   `json:"commit,omitempty"`
+	Changes  []string `json:"changes,omitempty"`
+	Culprits []string `json:"culprits,omitempty"`
+}
+
+// Artifact contains the URLs of an archived artifact of a build.
+type Artifact struct {
+	Archive string `json:"archive,omitempty"`
+	S3      string `json:"s3,omitempty"`
+}
+
+// TestSummary contains the test results of a build, FailedTests are the names of the failed tests.
+type TestSummary struct {
+	Total       int      `json:"total"`
+	Failed      int      `json:"failed"`
+	Passed      int      `json:"passed"`
+	Skipped     int      `json:"skipped"`
+	FailedTests []string `json:"failed_tests,omitempty"`
+}
+
+// Build contains a build of a job. Status is "SUCCESS", "UNSTABLE", "FAILURE", "NOT_BUILT" or "ABORTED"
+// from the COMPLETED phase on, and Duration is known from then on too.
+// Log holds the last lines of the console log if the endpoint is configured to send them.
+type Build struct {
+	FullURL     string              `json:"full_url"`
+	Number      int64               `json:"number"`
+	QueueID     int64               `json:"queue_id"`
+	Timestamp   Timestamp           `json:"timestamp"`
+	Duration    Milliseconds        `json:"duration"`
+	Phase       Event               `json:"phase"`
+	Status      string              `json:"status,omitempty"`
+	URL         string              `json:"url"`
+	SCM         *SCM                `json:"scm,omitempty"`
+	Parameters  map[string]string   `json:"parameters,omitempty"`
+	Log         string              `json:"log,omitempty"`
+	Notes       string              `json:"notes,omitempty"`
+	Artifacts   map[string]Artifact `json:"artifacts,omitempty"`
+	TestSummary *TestSummary        `json:"test_summary,omitempty"`
+}
+
+// JobPayload contains the information for the notifications of all build phases,
+// URL is the path of the job relative to the Jenkins root, e.g. "job/platform/job/api/job/main/".
+type JobPayload struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	URL         string `json:"url"`
+	Build       Build  `json:"build"`
+}
@@ -0,0 +1,558 @@
+// The `journal` package durably records verified webhook deliveries on disk
+// so they can be replayed after an outage of the downstream systems.
+//
+// The journal is an append-only log split into segment files.
+// Every record is framed with its length and a CRC-32 checksum,
+// delivery records hold the raw headers and body and status records track their processing.
+//
+// Records are written to the segment files without fsync by default: they survive a crash of the process,
+// but the last ones can be lost on a power failure or a crash of the operating system unless the Sync option is set.
+package journal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Processing statuses of a delivery.
+	StatusPending Status = "pending"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+	// record kinds
+	kindDelivery = "delivery"
+	kindStatus   = "status"
+	// segment files
+	segmentExt  = ".seg"
+	frameHeader = 8
+)
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = JournalOptions{}
+	// Journal errors.
+	ErrNotFound = errors.New("delivery not found in journal")
+	ErrCorrupt  = errors.New("journal segment is corrupt")
+	ErrClosed   = errors.New("journal is closed")
+	// crcTable is the CRC-32 table used for record checksums.
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+	// deliveryHeaders lists the delivery ID headers of the supported providers.
+	deliveryHeaders = []string{
+		"X-GitHub-Delivery",
+		"X-Gitea-Delivery",
+		"X-Gogs-Delivery",
+		"X-Gitlab-Event-UUID",
+		"X-Request-UUID",
+	}
+)
+
+// Status defines the processing status of a recorded delivery.
+type Status string
+
+// Entry is a delivery recorded in the journal.
+type Entry struct {
+	// ID is the journal ID of the record, unique in the journal.
+	ID string
+	// DeliveryID is the ID the provider sent the delivery with, see DeliveryID,
+	// shared by the records of a delivery the provider redelivered.
+	DeliveryID string
+	Received   time.Time
+	Updated    time.Time
+	Header     http.Header
+	Body       []byte
+	Status     Status
+	Error      string
+}
+
+type record struct {
+	Kind       string      `json:"kind"`
+	ID         string      `json:"id"`
+	DeliveryID string      `json:"delivery_id,omitempty"`
+	Time       time.Time   `json:"time"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	Status     Status      `json:"status,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type location struct {
+	segment    int
+	offset     int64
+	deliveryID string
+	received   time.Time
+	updated    time.Time
+	status     Status
+	err        string
+}
+
+// Option is a configuration option for the journal.
+type Option func(*Journal) error
+
+// Journal is an append-only on-disk log of deliveries.
+type Journal struct {
+	dir        string
+	maxSegment int64
+	sync       bool
+	now        func() time.Time
+	mu         sync.Mutex
+	active     *os.File
+	segment    int
+	size       int64
+	index      map[string]*location
+	// deliveries maps the provider delivery IDs to the journal IDs of their records, in reception order
+	deliveries map[string][]string
+	closed     bool
+}
+
+// Open opens or creates the journal stored in dir and rebuilds its index.
+// A torn record at the end of the last segment, left by a crash, is truncated.
+// A record that cannot be read anywhere else fails with ErrCorrupt, leaving the segment untouched.
+func Open(dir string, options ...Option) (*Journal, error) {
+	j := &Journal{
+		dir:        dir,
+		maxSegment: 64 << 20,
+		now:        time.Now,
+		index:      make(map[string]*location),
+		deliveries: make(map[string][]string),
+	}
+	for _, opt := range options {
+		if err := opt(j); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	segments, err := j.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		if err := j.load(seg, last); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(segments) == 0 {
+		j.segment = 1
+	} else {
+		j.segment = segments[len(segments)-1]
+	}
+
+	if err := j.openActive(); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// DeliveryID returns the provider delivery ID found in the headers,
+// or an empty string if the provider does not send one.
+func DeliveryID(header http.Header) string {
+	for _, h := range deliveryHeaders {
+		if id := header.Get(h); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// Record appends a verified delivery with a pending status and returns its journal ID, a random ID of its own.
+// The provider delivery ID is recorded alongside, a delivery the provider sends again is recorded again
+// and the records of a delivery are found with ByDeliveryID.
+func (j *Journal) Record(header http.Header, body []byte) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b[:])
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := j.now()
+	rec := record{Kind: kindDelivery, ID: id, DeliveryID: DeliveryID(header), Time: now, Header: header.Clone(), Body: body, Status: StatusPending}
+	offset, err := j.append(rec)
+	if err != nil {
+		return "", err
+	}
+
+	j.add(rec, j.segment, offset)
+	return id, nil
+}
+
+// Done records the outcome of processing a delivery,
+// a nil error marks it done and a non-nil error marks it failed.
+func (j *Journal) Done(id string, err error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	loc, ok := j.index[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	rec := record{Kind: kindStatus, ID: id, Time: j.now(), Status: StatusDone}
+	if err != nil {
+		rec.Status = StatusFailed
+		rec.Error = err.Error()
+	}
+
+	if _, err := j.append(rec); err != nil {
+		return err
+	}
+
+	loc.status, loc.err, loc.updated = rec.Status, rec.Error, rec.Time
+	return nil
+}
+
+// Get returns the delivery with the given ID.
+func (j *Journal) Get(id string) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	loc, ok := j.index[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return j.read(id, loc)
+}
+
+// ByDeliveryID returns the records of the delivery the provider sent with the given ID, ordered by reception time,
+// or ErrNotFound if there is none.
+func (j *Journal) ByDeliveryID(deliveryID string) ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ids := j.deliveries[deliveryID]
+	if deliveryID == "" || len(ids) == 0 {
+		return nil, ErrNotFound
+	}
+
+	entries := make([]Entry, 0, len(ids))
+	for _, id := range ids {
+		e, err := j.read(id, j.index[id])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Entries returns the deliveries received in [from, to) ordered by reception time.
+// A zero from or to leaves that end of the range open.
+func (j *Journal) Entries(from, to time.Time) ([]Entry, error) {
+	return j.filter(func(loc *location) bool {
+		return (from.IsZero() || !loc.received.Before(from)) && (to.IsZero() || loc.received.Before(to))
+	})
+}
+
+// Pending returns the deliveries that are not processed successfully yet.
+func (j *Journal) Pending() ([]Entry, error) {
+	return j.filter(func(loc *location) bool {
+		return loc.status != StatusDone
+	})
+}
+
+// Replay passes the deliveries received in [from, to) to fn in reception order
+// and records the error fn returns as their new status.
+func (j *Journal) Replay(from, to time.Time, fn func(Entry) error) error {
+	entries, err := j.Entries(from, to)
+	if err != nil {
+		return err
+	}
+	return j.replay(entries, fn)
+}
+
+// ReplayID passes the delivery with the given ID to fn and records the error fn returns as its new status.
+func (j *Journal) ReplayID(id string, fn func(Entry) error) error {
+	e, err := j.Get(id)
+	if err != nil {
+		return err
+	}
+	return j.replay([]Entry{e}, fn)
+}
+
+// Close closes the active segment.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return nil
+	}
+	j.closed = true
+	return j.active.Close()
+}
+
+func (j *Journal) replay(entries []Entry, fn func(Entry) error) error {
+	var errs []error
+	for _, e := range entries {
+		ferr := fn(e)
+		if ferr != nil {
+			errs = append(errs, fmt.Errorf("replay %s: %w", e.ID, ferr))
+		}
+		if err := j.Done(e.ID, ferr); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (j *Journal) filter(match func(*location) bool) ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var entries []Entry
+	for id, loc := range j.index {
+		if !match(loc) {
+			continue
+		}
+
+		e, err := j.read(id, loc)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Received.Before(entries[b].Received)
+	})
+	return entries, nil
+}
+
+func (j *Journal) read(id string, loc *location) (Entry, error) {
+	f, err := os.Open(j.path(loc.segment))
+	if err != nil {
+		return Entry{}, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(loc.offset, io.SeekStart); err != nil {
+		return Entry{}, err
+	}
+
+	rec, _, err := readRecord(bufio.NewReader(f))
+	if err != nil {
+		return Entry{}, fmt.Errorf("%w: %s: %v", ErrCorrupt, j.path(loc.segment), err)
+	}
+
+	return Entry{
+		ID:         id,
+		DeliveryID: loc.deliveryID,
+		Received:   rec.Time,
+		Updated:    loc.updated,
+		Header:     rec.Header,
+		Body:       rec.Body,
+		Status:     loc.status,
+		Error:      loc.err,
+	}, nil
+}
+
+// add indexes a delivery record appended at offset of the segment.
+func (j *Journal) add(rec record, segment int, offset int64) {
+	j.index[rec.ID] = &location{segment: segment, offset: offset, deliveryID: rec.DeliveryID, received: rec.Time, updated: rec.Time, status: StatusPending}
+	if rec.DeliveryID != "" {
+		j.deliveries[rec.DeliveryID] = append(j.deliveries[rec.DeliveryID], rec.ID)
+	}
+}
+
+func (j *Journal) append(rec record) (int64, error) {
+	if j.closed {
+		return 0, ErrClosed
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+
+	frame := make([]byte, frameHeader+len(data))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(data, crcTable))
+	copy(frame[frameHeader:], data)
+
+	if j.size > 0 && j.size+int64(len(frame)) > j.maxSegment {
+		if err := j.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	offset := j.size
+	if _, err := j.active.Write(frame); err != nil {
+		return 0, err
+	}
+	j.size += int64(len(frame))
+
+	if j.sync {
+		if err := j.active.Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	return offset, nil
+}
+
+func (j *Journal) rotate() error {
+	if err := j.active.Close(); err != nil {
+		return err
+	}
+	j.segment++
+	return j.openActive()
+}
+
+func (j *Journal) openActive() error {
+	f, err := os.OpenFile(j.path(j.segment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	j.active, j.size = f, st.Size()
+	return nil
+}
+
+func (j *Journal) load(segment int, last bool) error {
+	f, err := os.Open(j.path(segment))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var offset int64
+	r := bufio.NewReader(f)
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			// only the last record of the last segment can be a torn write left by a crash, drop it,
+			// a bad record followed by others is corruption that dropping would delete them with
+			if last && (errors.Is(err, errTorn) || atEOF(r)) {
+				return os.Truncate(j.path(segment), offset)
+			}
+			return fmt.Errorf("%w: %s at offset %d: %v", ErrCorrupt, j.path(segment), offset, err)
+		}
+
+		switch rec.Kind {
+		case kindDelivery:
+			j.add(rec, segment, offset)
+		case kindStatus:
+			if loc, ok := j.index[rec.ID]; ok {
+				loc.status, loc.err, loc.updated = rec.Status, rec.Error, rec.Time
+			}
+		}
+		offset += n
+	}
+}
+
+func (j *Journal) segments() ([]int, error) {
+	files, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []int
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		var n int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, segmentExt), "%d", &n); err == nil {
+			segments = append(segments, n)
+		}
+	}
+
+	sort.Ints(segments)
+	return segments, nil
+}
+
+func (j *Journal) path(segment int) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%08d%s", segment, segmentExt))
+}
+
+// errTorn is returned by readRecord for a record cut short by the end of the segment.
+var errTorn = errors.New("torn record")
+
+// atEOF reports whether r has nothing left to read.
+func atEOF(r *bufio.Reader) bool {
+	_, err := r.Peek(1)
+	return err == io.EOF
+}
+
+func readRecord(r io.Reader) (record, int64, error) {
+	var rec record
+	var header [frameHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return rec, 0, fmt.Errorf("%w: truncated record header", errTorn)
+		}
+		return rec, 0, err
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > 1<<30 {
+		return rec, 0, errors.New("invalid record length")
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return rec, 0, fmt.Errorf("%w: truncated record", errTorn)
+	}
+
+	if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return rec, 0, errors.New("checksum mismatch")
+	}
+
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, 0, err
+	}
+
+	return rec, int64(frameHeader + len(data)), nil
+}
+
+// JournalOptions is a namespace for configuration option methods.
+type JournalOptions struct{}
+
+// MaxSegmentSize sets the size in bytes after which a new segment file is started, 64 MiB by default.
+func (JournalOptions) MaxSegmentSize(size int64) Option {
+	return func(j *Journal) error {
+		if size <= 0 {
+			return errors.New("segment size must be positive")
+		}
+		j.maxSegment = size
+		return nil
+	}
+}
+
+// Sync makes every record fsync'ed to disk before it is acknowledged,
+// so no acknowledged delivery is lost on a power failure, at the cost of a disk flush per record.
+func (JournalOptions) Sync() Option {
+	return func(j *Journal) error {
+		j.sync = true
+		return nil
+	}
+}
@@ -0,0 +1,257 @@
+package journal
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func clock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func openJournal(t *testing.T, dir string, options ...Option) *Journal {
+	j, err := Open(dir, options...)
+	require.NoError(t, err)
+	j.now = clock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return j
+}
+
+func TestRecordAndReopen(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	j := openJournal(t, dir)
+
+	header := http.Header{"X-Github-Delivery": []string{"72d3162e-cc78-11e3-81ab-4c9367dc0958"}, "X-Github-Event": []string{"push"}}
+	id, err := j.Record(header, []byte(`{"ref":"refs/heads/main"}`))
+	assert.NoError(err)
+	assert.Len(id, 32)
+
+	other, err := j.Record(http.Header{}, []byte(`{}`))
+	assert.NoError(err)
+	assert.Len(other, 32)
+
+	assert.NoError(j.Done(id, nil))
+	assert.NoError(j.Done(other, errors.New("downstream unavailable")))
+	assert.ErrorIs(j.Done("unknown", nil), ErrNotFound)
+	assert.NoError(j.Close())
+
+	j = openJournal(t, dir)
+	defer j.Close()
+	e, err := j.Get(id)
+	assert.NoError(err)
+	assert.Equal(StatusDone, e.Status)
+	assert.Equal("72d3162e-cc78-11e3-81ab-4c9367dc0958", e.DeliveryID)
+	assert.Equal("push", e.Header.Get("X-GitHub-Event"))
+	assert.Equal(`{"ref":"refs/heads/main"}`, string(e.Body))
+
+	pending, err := j.Pending()
+	assert.NoError(err)
+	assert.Len(pending, 1)
+	assert.Equal(other, pending[0].ID)
+	assert.Equal(StatusFailed, pending[0].Status)
+	assert.Equal("downstream unavailable", pending[0].Error)
+}
+
+func TestSegmentRotation(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	j := openJournal(t, dir, Options.MaxSegmentSize(256))
+
+	var ids []string
+	for i := 0; i < 10; i++ {
+		id, err := j.Record(http.Header{}, []byte(`{"padding":"0123456789012345678901234567890123456789"}`))
+		assert.NoError(err)
+		ids = append(ids, id)
+	}
+	assert.NoError(j.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	assert.NoError(err)
+	assert.Greater(len(segments), 1)
+
+	j = openJournal(t, dir)
+	defer j.Close()
+	entries, err := j.Entries(time.Time{}, time.Time{})
+	assert.NoError(err)
+	assert.Len(entries, len(ids))
+	for i, e := range entries {
+		assert.Equal(ids[i], e.ID)
+	}
+}
+
+func TestTornTail(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	j := openJournal(t, dir)
+	id, err := j.Record(http.Header{}, []byte(`{}`))
+	assert.NoError(err)
+	assert.NoError(j.Close())
+
+	path := j.path(1)
+	st, err := os.Stat(path)
+	assert.NoError(err)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	assert.NoError(err)
+	_, err = f.Write([]byte{0, 0, 1, 0, 42})
+	assert.NoError(err)
+	assert.NoError(f.Close())
+
+	j = openJournal(t, dir)
+	defer j.Close()
+	_, err = j.Get(id)
+	assert.NoError(err)
+
+	truncated, err := os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(st.Size(), truncated.Size())
+}
+
+func TestCorruptTail(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	j := openJournal(t, dir)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := j.Record(http.Header{}, []byte(`{}`))
+		assert.NoError(err)
+		ids = append(ids, id)
+	}
+	assert.NoError(j.Close())
+
+	path := j.path(1)
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+
+	// a bad checksum in the middle of the last segment must not drop the records after it
+	corrupt := append([]byte(nil), data...)
+	corrupt[frameHeader+2] ^= 0xff
+	assert.NoError(os.WriteFile(path, corrupt, 0o644))
+	_, err = Open(dir)
+	assert.ErrorIs(err, ErrCorrupt)
+	st, err := os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(int64(len(data)), st.Size())
+
+	// while the last record of it is a torn write
+	corrupt = append([]byte(nil), data...)
+	corrupt[len(corrupt)-2] ^= 0xff
+	assert.NoError(os.WriteFile(path, corrupt, 0o644))
+	j = openJournal(t, dir)
+	defer j.Close()
+	_, err = j.Get(ids[1])
+	assert.NoError(err)
+	_, err = j.Get(ids[2])
+	assert.ErrorIs(err, ErrNotFound)
+}
+
+func TestCorruptSegment(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	j := openJournal(t, dir, Options.MaxSegmentSize(64))
+	for i := 0; i < 3; i++ {
+		_, err := j.Record(http.Header{}, []byte(`{}`))
+		assert.NoError(err)
+	}
+	assert.NoError(j.Close())
+
+	data, err := os.ReadFile(j.path(1))
+	assert.NoError(err)
+	data[len(data)-2] ^= 0xff
+	assert.NoError(os.WriteFile(j.path(1), data, 0o644))
+
+	_, err = Open(dir)
+	assert.ErrorIs(err, ErrCorrupt)
+}
+
+func TestReplay(t *testing.T) {
+	assert := require.New(t)
+	j := openJournal(t, t.TempDir())
+	defer j.Close()
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		id, err := j.Record(http.Header{}, []byte(`{}`))
+		assert.NoError(err)
+		ids = append(ids, id)
+	}
+
+	first, err := j.Get(ids[1])
+	assert.NoError(err)
+	last, err := j.Get(ids[3])
+	assert.NoError(err)
+
+	var replayed []string
+	err = j.Replay(first.Received, last.Received, func(e Entry) error {
+		replayed = append(replayed, e.ID)
+		if e.ID == ids[2] {
+			return errors.New("still failing")
+		}
+		return nil
+	})
+	assert.ErrorContains(err, "still failing")
+	assert.Equal(ids[1:3], replayed)
+
+	e, err := j.Get(ids[1])
+	assert.NoError(err)
+	assert.Equal(StatusDone, e.Status)
+	e, err = j.Get(ids[2])
+	assert.NoError(err)
+	assert.Equal(StatusFailed, e.Status)
+
+	assert.NoError(j.ReplayID(ids[2], func(e Entry) error { return nil }))
+	e, err = j.Get(ids[2])
+	assert.NoError(err)
+	assert.Equal(StatusDone, e.Status)
+	assert.ErrorIs(j.ReplayID("unknown", func(e Entry) error { return nil }), ErrNotFound)
+}
+
+func TestDeliveryID(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal("a", DeliveryID(http.Header{"X-Gitea-Delivery": []string{"a"}}))
+	assert.Equal("b", DeliveryID(http.Header{"X-Gitlab-Event-Uuid": []string{"b"}}))
+	assert.Equal("c", DeliveryID(http.Header{"X-Request-Uuid": []string{"c"}}))
+	assert.Equal("", DeliveryID(http.Header{"X-Request-Id": []string{"d"}}))
+	assert.Equal("", DeliveryID(http.Header{}))
+}
+
+func TestRedelivery(t *testing.T) {
+	assert := require.New(t)
+	dir := t.TempDir()
+	j := openJournal(t, dir)
+
+	header := http.Header{"X-Gitea-Delivery": []string{"f6266f16-1bf3-46a5-9ea4-602e06ead473"}}
+	first, err := j.Record(header, []byte(`{"attempt":1}`))
+	assert.NoError(err)
+	assert.NoError(j.Done(first, errors.New("downstream unavailable")))
+	second, err := j.Record(header, []byte(`{"attempt":2}`))
+	assert.NoError(err)
+	assert.NotEqual(first, second)
+	assert.NoError(j.Close())
+
+	// the redelivery does not replace the first record, both are kept and indexed after a reopen
+	j = openJournal(t, dir)
+	defer j.Close()
+	entries, err := j.ByDeliveryID("f6266f16-1bf3-46a5-9ea4-602e06ead473")
+	assert.NoError(err)
+	assert.Len(entries, 2)
+	assert.Equal(first, entries[0].ID)
+	assert.Equal(StatusFailed, entries[0].Status)
+	assert.Equal(`{"attempt":1}`, string(entries[0].Body))
+	assert.Equal(second, entries[1].ID)
+	assert.Equal(StatusPending, entries[1].Status)
+
+	_, err = j.ByDeliveryID("unknown")
+	assert.ErrorIs(err, ErrNotFound)
+	_, err = j.ByDeliveryID("")
+	assert.ErrorIs(err, ErrNotFound)
+}
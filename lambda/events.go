@@ -0,0 +1,97 @@
+package lambda
+
+// APIGatewayProxyRequest is the proxy integration event of an API Gateway REST API,
+// the payload format version 1.0.
+type APIGatewayProxyRequest struct {
+	Resource                        string                 `json:"resource"`
+	Path                            string                 `json:"path"`
+	HTTPMethod                      string                 `json:"httpMethod"`
+	Headers                         map[string]string      `json:"headers"`
+	MultiValueHeaders               map[string][]string    `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string      `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string    `json:"multiValueQueryStringParameters"`
+	PathParameters                  map[string]string      `json:"pathParameters"`
+	StageVariables                  map[string]string      `json:"stageVariables"`
+	RequestContext                  APIGatewayProxyContext `json:"requestContext"`
+	Body                            string                 `json:"body"`
+	IsBase64Encoded                 bool                   `json:"isBase64Encoded"`
+}
+
+// APIGatewayProxyContext is the request context of an APIGatewayProxyRequest.
+type APIGatewayProxyContext struct {
+	AccountID    string `json:"accountId"`
+	ResourceID   string `json:"resourceId"`
+	Stage        string `json:"stage"`
+	RequestID    string `json:"requestId"`
+	ResourcePath string `json:"resourcePath"`
+	HTTPMethod   string `json:"httpMethod"`
+	APIID        string `json:"apiId"`
+	DomainName   string `json:"domainName"`
+	Protocol     string `json:"protocol"`
+	Identity     struct {
+		SourceIP  string `json:"sourceIp"`
+		UserAgent string `json:"userAgent"`
+	} `json:"identity"`
+}
+
+// APIGatewayProxyResponse is the response to an APIGatewayProxyRequest.
+type APIGatewayProxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// APIGatewayV2HTTPRequest is the event of an API Gateway HTTP API, the payload format version 2.0.
+// Header names are lower-cased and the values of repeated headers are joined with commas.
+type APIGatewayV2HTTPRequest struct {
+	Version               string                         `json:"version"`
+	RouteKey              string                         `json:"routeKey"`
+	RawPath               string                         `json:"rawPath"`
+	RawQueryString        string                         `json:"rawQueryString"`
+	Cookies               []string                       `json:"cookies,omitempty"`
+	Headers               map[string]string              `json:"headers"`
+	QueryStringParameters map[string]string              `json:"queryStringParameters,omitempty"`
+	PathParameters        map[string]string              `json:"pathParameters,omitempty"`
+	StageVariables        map[string]string              `json:"stageVariables,omitempty"`
+	RequestContext        APIGatewayV2HTTPRequestContext `json:"requestContext"`
+	Body                  string                         `json:"body,omitempty"`
+	IsBase64Encoded       bool                           `json:"isBase64Encoded"`
+}
+
+// APIGatewayV2HTTPRequestContext is the request context of an APIGatewayV2HTTPRequest.
+type APIGatewayV2HTTPRequestContext struct {
+	AccountID    string `json:"accountId"`
+	APIID        string `json:"apiId"`
+	DomainName   string `json:"domainName"`
+	DomainPrefix string `json:"domainPrefix"`
+	RequestID    string `json:"requestId"`
+	RouteKey     string `json:"routeKey"`
+	Stage        string `json:"stage"`
+	Time         string `json:"time"`
+	TimeEpoch    int64  `json:"timeEpoch"`
+	HTTP         struct {
+		Method    string `json:"method"`
+		Path      string `json:"path"`
+		Protocol  string `json:"protocol"`
+		SourceIP  string `json:"sourceIp"`
+		UserAgent string `json:"userAgent"`
+	} `json:"http"`
+}
+
+// APIGatewayV2HTTPResponse is the response to an APIGatewayV2HTTPRequest.
+type APIGatewayV2HTTPResponse struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	Cookies         []string          `json:"cookies,omitempty"`
+}
+
+// FunctionURLRequest is the event of a Lambda function URL, which uses the payload format version 2.0
+// of API Gateway HTTP APIs without the route and stage.
+type FunctionURLRequest = APIGatewayV2HTTPRequest
+
+// FunctionURLResponse is the response to a FunctionURLRequest.
+type FunctionURLResponse = APIGatewayV2HTTPResponse
@@ -0,0 +1,258 @@
+// The `lambda` package runs webhook receivers as AWS Lambda functions.
+//
+// An Adapter converts API Gateway REST API (v1) and HTTP API (v2) proxy events and Lambda function URL events
+// into http.Requests, serves them with an http.Handler, e.g. a Receiver verifying and parsing deliveries
+// with the Parse of a provider, and converts the response back into the proxy response of the event.
+// It depends on nothing but the standard library, the Adapter implements the Invoke method
+// the Lambda Go runtime calls:
+//
+//	hook, _ := github.New(github.Options.Secret(secret))
+//	adapter := lambda.New(lambda.Receiver(func(r *http.Request) (interface{}, error) {
+//		return hook.Parse(r, github.PushEvent)
+//	}, handle))
+//	awslambda.StartHandler(adapter)
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnknownEvent is returned by Invoke for events that are neither proxy nor function URL events.
+var ErrUnknownEvent = errors.New("unknown Lambda event")
+
+// ParseFunc verifies and parses a delivery, usually wrapping a provider's Parse.
+type ParseFunc func(r *http.Request) (interface{}, error)
+
+// Handler processes a parsed payload.
+type Handler func(ctx context.Context, payload interface{}) error
+
+// Adapter serves Lambda events with an http.Handler.
+type Adapter struct {
+	handler http.Handler
+}
+
+// New returns an Adapter serving the events with handler.
+func New(handler http.Handler) *Adapter {
+	return &Adapter{handler: handler}
+}
+
+// Receiver returns an http.Handler parsing deliveries with parse and handling the payloads with handle synchronously,
+// since a Lambda function cannot keep working once it answered.
+// It answers 204 No Content once the payload is handled, 400 Bad Request when parsing fails
+// and 500 Internal Server Error when the handler fails, so the provider retries the delivery.
+func Receiver(parse ParseFunc, handle Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := parse(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := handle(r.Context(), payload); err != nil {
+			http.Error(w, "error handling payload", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ProxyV1 serves an API Gateway REST API proxy event.
+func (a *Adapter) ProxyV1(ctx context.Context, event APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	query := url.Values(event.MultiValueQueryStringParameters)
+	if len(query) == 0 {
+		query = url.Values{}
+		for k, v := range event.QueryStringParameters {
+			query.Set(k, v)
+		}
+	}
+
+	header := http.Header{}
+	for k, values := range event.MultiValueHeaders {
+		for _, v := range values {
+			header.Add(k, v)
+		}
+	}
+	for k, v := range event.Headers {
+		if _, ok := header[http.CanonicalHeaderKey(k)]; !ok {
+			header.Set(k, v)
+		}
+	}
+
+	r, err := newRequest(ctx, event.HTTPMethod, event.Path, query.Encode(), header, event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: err.Error()}, nil
+	}
+	r.RemoteAddr = event.RequestContext.Identity.SourceIP
+
+	w := a.serve(r)
+	res := APIGatewayProxyResponse{StatusCode: w.status, Headers: map[string]string{}, MultiValueHeaders: map[string][]string{}}
+	for k, values := range w.header {
+		res.Headers[k] = values[len(values)-1]
+		res.MultiValueHeaders[k] = values
+	}
+	res.Body, res.IsBase64Encoded = encodeBody(w.body.Bytes())
+	return res, nil
+}
+
+// ProxyV2 serves an API Gateway HTTP API event.
+func (a *Adapter) ProxyV2(ctx context.Context, event APIGatewayV2HTTPRequest) (APIGatewayV2HTTPResponse, error) {
+	header := http.Header{}
+	for k, v := range event.Headers {
+		header.Set(k, v)
+	}
+	if len(event.Cookies) > 0 {
+		header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+
+	method, path := event.RequestContext.HTTP.Method, event.RawPath
+	if path == "" {
+		path = event.RequestContext.HTTP.Path
+	}
+
+	r, err := newRequest(ctx, method, path, event.RawQueryString, header, event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return APIGatewayV2HTTPResponse{StatusCode: http.StatusBadRequest, Body: err.Error()}, nil
+	}
+	r.RemoteAddr = event.RequestContext.HTTP.SourceIP
+
+	w := a.serve(r)
+	res := APIGatewayV2HTTPResponse{StatusCode: w.status, Headers: map[string]string{}}
+	for k, values := range w.header {
+		if k == "Set-Cookie" {
+			res.Cookies = values
+			continue
+		}
+		res.Headers[k] = strings.Join(values, ",")
+	}
+	res.Body, res.IsBase64Encoded = encodeBody(w.body.Bytes())
+	return res, nil
+}
+
+// FunctionURL serves a Lambda function URL event.
+func (a *Adapter) FunctionURL(ctx context.Context, event FunctionURLRequest) (FunctionURLResponse, error) {
+	return a.ProxyV2(ctx, event)
+}
+
+// Invoke serves the JSON encoded event of any kind, telling the payload format 2.0 of HTTP APIs
+// and function URLs from the REST API proxy events by its version, and returns the JSON encoded response.
+// It implements the Handler interface of the Lambda Go runtime.
+func (a *Adapter) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var probe struct {
+		Version    string `json:"version"`
+		HTTPMethod string `json:"httpMethod"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case probe.Version == "2.0":
+		var event APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		res, err := a.ProxyV2(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(res)
+	case probe.HTTPMethod != "":
+		var event APIGatewayProxyRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		res, err := a.ProxyV1(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(res)
+	default:
+		return nil, ErrUnknownEvent
+	}
+}
+
+func (a *Adapter) serve(r *http.Request) *response {
+	w := &response{header: http.Header{}}
+	a.handler.ServeHTTP(w, r)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w
+}
+
+func newRequest(ctx context.Context, method, path, query string, header http.Header, body string, isBase64 bool) (*http.Request, error) {
+	data := []byte(body)
+	if isBase64 {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(body); err != nil {
+			return nil, errors.New("invalid base64 body")
+		}
+	}
+
+	if path == "" {
+		path = "/"
+	}
+
+	target := path
+	if query != "" {
+		target += "?" + query
+	}
+
+	u, err := url.ParseRequestURI(target)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header = header
+	r.Host = header.Get("Host")
+	r.RequestURI = u.RequestURI()
+	r.ContentLength = int64(len(data))
+	r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return r, nil
+}
+
+// encodeBody returns the body of a proxy response, base64 encoded unless it is valid UTF-8.
+func encodeBody(body []byte) (string, bool) {
+	if utf8.Valid(body) {
+		return string(body), false
+	}
+	return base64.StdEncoding.EncodeToString(body), true
+}
+
+// response records the response of the handler.
+type response struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *response) Header() http.Header {
+	return w.header
+}
+
+func (w *response) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(b)
+}
+
+func (w *response) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
@@ -0,0 +1,160 @@
+package lambda_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/pchchv/wh/github"
+	"github.com/pchchv/wh/gitlab"
+	"github.com/pchchv/wh/lambda"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+
+func event(t *testing.T, filename string, v interface{}) []byte {
+	b, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, v))
+	return b
+}
+
+// receiver parses GitHub ping and GitLab tag deliveries depending on the path.
+func receiver(t *testing.T, handled chan<- interface{}) http.Handler {
+	gh, err := github.New(github.Options.Secret(secret))
+	require.NoError(t, err)
+	gl, err := gitlab.New(gitlab.Options.Secret(secret))
+	require.NoError(t, err)
+
+	return lambda.Receiver(func(r *http.Request) (interface{}, error) {
+		if r.URL.Path == "/webhooks/gitlab" {
+			return gl.Parse(r, gitlab.TagEvents)
+		}
+		return gh.Parse(r, github.PingEvent)
+	}, func(ctx context.Context, payload interface{}) error {
+		if handled == nil {
+			return errors.New("downstream unavailable")
+		}
+		handled <- payload
+		return nil
+	})
+}
+
+func TestProxyV1(t *testing.T) {
+	assert := require.New(t)
+	var req lambda.APIGatewayProxyRequest
+	event(t, "testdata/api-gateway-v1.json", &req)
+
+	handled := make(chan interface{}, 1)
+	res, err := lambda.New(receiver(t, handled)).ProxyV1(context.Background(), req)
+	assert.NoError(err)
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+	assert.Equal(20081052, (<-handled).(github.PingPayload).HookID)
+
+	req.Body += " "
+	res, err = lambda.New(receiver(t, handled)).ProxyV1(context.Background(), req)
+	assert.NoError(err)
+	assert.Equal(http.StatusBadRequest, res.StatusCode)
+	assert.Contains(res.Body, "HMAC verification failed")
+	assert.Equal("text/plain; charset=utf-8", res.Headers["Content-Type"])
+	assert.Equal([]string{"nosniff"}, res.MultiValueHeaders["X-Content-Type-Options"])
+
+	// the single value headers are used without the multi-value ones
+	req.Body = req.Body[:len(req.Body)-1]
+	req.MultiValueHeaders = nil
+	res, err = lambda.New(receiver(t, nil)).ProxyV1(context.Background(), req)
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+}
+
+func TestProxyV2(t *testing.T) {
+	assert := require.New(t)
+	var req lambda.APIGatewayV2HTTPRequest
+	event(t, "testdata/api-gateway-v2.json", &req)
+	assert.True(req.IsBase64Encoded)
+
+	var r *http.Request
+	handled := make(chan interface{}, 1)
+	adapter := lambda.New(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r = req
+		receiver(t, handled).ServeHTTP(w, req)
+	}))
+	res, err := adapter.ProxyV2(context.Background(), req)
+	assert.NoError(err)
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+	assert.Equal(20081052, (<-handled).(github.PingPayload).HookID)
+	assert.Equal(http.MethodPost, r.Method)
+	assert.Equal("/webhooks/github", r.URL.Path)
+	assert.Equal("github", r.URL.Query().Get("source"))
+	assert.Equal("ping", r.Header.Get("X-GitHub-Event"))
+	assert.Equal("abcdef1234.execute-api.eu-west-1.amazonaws.com", r.Host)
+	assert.Equal("34.74.90.64", r.RemoteAddr)
+
+	req.Body = "not base64"
+	res, err = adapter.ProxyV2(context.Background(), req)
+	assert.NoError(err)
+	assert.Equal(http.StatusBadRequest, res.StatusCode)
+}
+
+func TestFunctionURL(t *testing.T) {
+	assert := require.New(t)
+	var req lambda.FunctionURLRequest
+	event(t, "testdata/function-url.json", &req)
+
+	handled := make(chan interface{}, 1)
+	res, err := lambda.New(receiver(t, handled)).FunctionURL(context.Background(), req)
+	assert.NoError(err)
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+	assert.Equal("refs/tags/v1.0.0", (<-handled).(gitlab.TagEventPayload).Ref)
+
+	req.Headers["x-gitlab-token"] = "wrong"
+	res, err = lambda.New(receiver(t, handled)).FunctionURL(context.Background(), req)
+	assert.NoError(err)
+	assert.Equal(http.StatusBadRequest, res.StatusCode)
+}
+
+func TestInvoke(t *testing.T) {
+	assert := require.New(t)
+	handled := make(chan interface{}, 1)
+	adapter := lambda.New(receiver(t, handled))
+
+	for _, filename := range []string{"testdata/api-gateway-v1.json", "testdata/api-gateway-v2.json", "testdata/function-url.json"} {
+		var raw json.RawMessage
+		out, err := adapter.Invoke(context.Background(), event(t, filename, &raw))
+		assert.NoError(err, filename)
+		<-handled
+
+		var res lambda.APIGatewayV2HTTPResponse
+		assert.NoError(json.Unmarshal(out, &res))
+		assert.Equal(http.StatusNoContent, res.StatusCode, filename)
+	}
+
+	_, err := adapter.Invoke(context.Background(), []byte(`{"Records":[]}`))
+	assert.ErrorIs(err, lambda.ErrUnknownEvent)
+	_, err = adapter.Invoke(context.Background(), []byte(`{`))
+	assert.Error(err)
+}
+
+func TestBinaryResponse(t *testing.T) {
+	assert := require.New(t)
+	adapter := lambda.New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+		http.SetCookie(w, &http.Cookie{Name: "b", Value: "2"})
+		_, _ = w.Write([]byte{0xff, 0xfe})
+	}))
+
+	var req lambda.APIGatewayV2HTTPRequest
+	event(t, "testdata/api-gateway-v2.json", &req)
+	res, err := adapter.ProxyV2(context.Background(), req)
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.True(res.IsBase64Encoded)
+	assert.Equal(base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe}), res.Body)
+	assert.Equal([]string{"a=1", "b=2"}, res.Cookies)
+	assert.NotContains(res.Headers, "Set-Cookie")
+}
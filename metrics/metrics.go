@@ -0,0 +1,37 @@
+// The `metrics` package measures the deliveries parsed by the providers and handled by the dispatcher.
+//
+// Providers and the dispatcher report to a Recorder set with their Metrics option.
+// Prometheus is a Recorder exposing the measurements in the Prometheus text format,
+// any other monitoring system can be plugged in by implementing Recorder.
+package metrics
+
+import (
+	"time"
+)
+
+const (
+	// Handler outcomes.
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+	OutcomePanic = "panic"
+)
+
+// Recorder receives the measurements of the providers and the dispatcher.
+// Its methods are called concurrently.
+type Recorder interface {
+	// Received counts a delivery received by provider.
+	Received(provider string)
+	// Verified counts the verification of a delivery, result is "ok", "failed" or "skipped"
+	// when no secret is configured.
+	Verified(provider, result string)
+	// Rejected counts a delivery rejected for reason, "request", "verification", "decode"
+	// or "canceled" when the request was cancelled or its body took longer than the read timeout.
+	Rejected(provider, reason string)
+	// Parsed counts a delivery parsed into the payload of event.
+	Parsed(provider, event string)
+	// BodySize observes the size in bytes of the body read from a delivery.
+	BodySize(provider string, bytes int64)
+	// Handled observes the time a handler took to process a payload and its outcome,
+	// OutcomeOK, OutcomeError or OutcomePanic.
+	Handled(outcome string, latency time.Duration)
+}
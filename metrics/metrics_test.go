@@ -0,0 +1,93 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/github"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+
+func scrape(t *testing.T, prom *metrics.Prometheus) string {
+	w := httptest.NewRecorder()
+	prom.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	return w.Body.String()
+}
+
+func TestPrometheus(t *testing.T) {
+	assert := require.New(t)
+	prom := metrics.NewPrometheusBuckets([]float64{100, 10}, []float64{1})
+	prom.Received("github")
+	prom.Received("github")
+	prom.Verified("github", "ok")
+	prom.Rejected("github", "verification")
+	prom.Parsed("github", `a "quoted"\event`+"\n")
+	prom.BodySize("github", 50)
+	prom.BodySize("github", 500)
+	prom.Handled(metrics.OutcomeOK, 1500*time.Millisecond)
+
+	assert.Equal(`# HELP wh_deliveries_received_total Webhook deliveries received.
+# TYPE wh_deliveries_received_total counter
+wh_deliveries_received_total{provider="github"} 2
+# HELP wh_deliveries_verified_total Webhook deliveries verified by result.
+# TYPE wh_deliveries_verified_total counter
+wh_deliveries_verified_total{provider="github",result="ok"} 1
+# HELP wh_deliveries_rejected_total Webhook deliveries rejected by reason.
+# TYPE wh_deliveries_rejected_total counter
+wh_deliveries_rejected_total{provider="github",reason="verification"} 1
+# HELP wh_deliveries_parsed_total Webhook deliveries parsed by event.
+# TYPE wh_deliveries_parsed_total counter
+wh_deliveries_parsed_total{provider="github",event="a \"quoted\"\\event\n"} 1
+# HELP wh_delivery_body_bytes Size of the webhook delivery bodies.
+# TYPE wh_delivery_body_bytes histogram
+wh_delivery_body_bytes_bucket{provider="github",le="10"} 0
+wh_delivery_body_bytes_bucket{provider="github",le="100"} 1
+wh_delivery_body_bytes_bucket{provider="github",le="+Inf"} 2
+wh_delivery_body_bytes_sum{provider="github"} 550
+wh_delivery_body_bytes_count{provider="github"} 2
+# HELP wh_handler_duration_seconds Time taken by the handlers to process a payload.
+# TYPE wh_handler_duration_seconds histogram
+wh_handler_duration_seconds_bucket{outcome="ok",le="1"} 0
+wh_handler_duration_seconds_bucket{outcome="ok",le="+Inf"} 1
+wh_handler_duration_seconds_sum{outcome="ok"} 1.5
+wh_handler_duration_seconds_count{outcome="ok"} 1
+`, scrape(t, prom))
+}
+
+func TestProvider(t *testing.T) {
+	assert := require.New(t)
+	prom := metrics.NewPrometheus()
+	hook, err := github.New(github.Options.Secret(secret), github.Options.Metrics(prom))
+	assert.NoError(err)
+
+	d := whtest.GitHub(github.PushEvent, whtest.Fixture(t, "../github/testdata/push.json")).Secret(secret)
+	_, err = hook.Parse(d.Request(), github.PushEvent)
+	assert.NoError(err)
+	_, err = hook.Parse(d.Tampered(), github.PushEvent)
+	assert.Error(err)
+	_, err = hook.Parse(whtest.GitHub(github.PushEvent, "{").Secret(secret).Request(), github.PushEvent)
+	assert.Error(err)
+	_, err = hook.Parse(d.Method(http.MethodGet).Request(), github.PushEvent)
+	assert.Error(err)
+
+	out := scrape(t, prom)
+	assert.Contains(out, `wh_deliveries_received_total{provider="github"} 4`)
+	assert.Contains(out, `wh_deliveries_verified_total{provider="github",result="ok"} 2`)
+	assert.Contains(out, `wh_deliveries_verified_total{provider="github",result="failed"} 1`)
+	assert.Contains(out, `wh_deliveries_rejected_total{provider="github",reason="verification"} 1`)
+	assert.Contains(out, `wh_deliveries_rejected_total{provider="github",reason="decode"} 1`)
+	assert.Contains(out, `wh_deliveries_rejected_total{provider="github",reason="request"} 1`)
+	assert.Contains(out, `wh_deliveries_parsed_total{provider="github",event="push"} 1`)
+	assert.Contains(out, `wh_delivery_body_bytes_count{provider="github"} 4`)
+	// the rejected GET body is drained too, the tampered body has a trailing space
+	assert.Contains(out, `wh_delivery_body_bytes_sum{provider="github"} `+strconv.Itoa(3*len(d.Body())+2))
+}
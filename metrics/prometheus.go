@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DefBodySizeBuckets are the default buckets of the body size histogram, from 1 KiB to 16 MiB.
+	DefBodySizeBuckets = []float64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+	// DefLatencyBuckets are the default buckets of the handler latency histogram in seconds.
+	DefLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// labelEscaper escapes label values as the text format requires.
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// Prometheus is a Recorder keeping the measurements in memory
+// and serving them in the Prometheus text exposition format:
+//
+//	prom := metrics.NewPrometheus()
+//	hook, _ := github.New(github.Options.Secret(secret), github.Options.Metrics(prom))
+//	go http.ListenAndServe("localhost:9090", prom)
+type Prometheus struct {
+	mu       sync.Mutex
+	received *family
+	verified *family
+	rejected *family
+	parsed   *family
+	bodySize *family
+	handled  *family
+}
+
+type family struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	values []string
+	// value is the counter value, or the sum of the observations of a histogram
+	value  float64
+	counts []uint64
+	count  uint64
+}
+
+// NewPrometheus returns a Prometheus recorder with the default histogram buckets.
+func NewPrometheus() *Prometheus {
+	return NewPrometheusBuckets(DefBodySizeBuckets, DefLatencyBuckets)
+}
+
+// NewPrometheusBuckets returns a Prometheus recorder with the given upper bounds
+// of the body size buckets in bytes and of the handler latency buckets in seconds.
+func NewPrometheusBuckets(bodySize, latency []float64) *Prometheus {
+	return &Prometheus{
+		received: newFamily("wh_deliveries_received_total", "Webhook deliveries received.", nil, "provider"),
+		verified: newFamily("wh_deliveries_verified_total", "Webhook deliveries verified by result.", nil, "provider", "result"),
+		rejected: newFamily("wh_deliveries_rejected_total", "Webhook deliveries rejected by reason.", nil, "provider", "reason"),
+		parsed:   newFamily("wh_deliveries_parsed_total", "Webhook deliveries parsed by event.", nil, "provider", "event"),
+		bodySize: newFamily("wh_delivery_body_bytes", "Size of the webhook delivery bodies.", bodySize, "provider"),
+		handled:  newFamily("wh_handler_duration_seconds", "Time taken by the handlers to process a payload.", latency, "outcome"),
+	}
+}
+
+func newFamily(name, help string, buckets []float64, labels ...string) *family {
+	if buckets != nil {
+		buckets = append([]float64(nil), buckets...)
+		sort.Float64s(buckets)
+	}
+	return &family{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*series{}}
+}
+
+// Received implements Recorder.
+func (p *Prometheus) Received(provider string) {
+	p.add(p.received, provider)
+}
+
+// Verified implements Recorder.
+func (p *Prometheus) Verified(provider, result string) {
+	p.add(p.verified, provider, result)
+}
+
+// Rejected implements Recorder.
+func (p *Prometheus) Rejected(provider, reason string) {
+	p.add(p.rejected, provider, reason)
+}
+
+// Parsed implements Recorder.
+func (p *Prometheus) Parsed(provider, event string) {
+	p.add(p.parsed, provider, event)
+}
+
+// BodySize implements Recorder.
+func (p *Prometheus) BodySize(provider string, bytes int64) {
+	p.observe(p.bodySize, float64(bytes), provider)
+}
+
+// Handled implements Recorder.
+func (p *Prometheus) Handled(outcome string, latency time.Duration) {
+	p.observe(p.handled, latency.Seconds(), outcome)
+}
+
+func (p *Prometheus) add(f *family, values ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f.get(values).value++
+}
+
+func (p *Prometheus) observe(f *family, v float64, values ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := f.get(values)
+	s.value += v
+	s.count++
+	for i, le := range f.buckets {
+		if v <= le {
+			s.counts[i]++
+		}
+	}
+}
+
+func (f *family) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: values}
+		if f.buckets != nil {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// ServeHTTP serves the measurements in the Prometheus text exposition format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+// WriteTo writes the measurements to w in the Prometheus text exposition format.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	p.mu.Lock()
+	for _, f := range []*family{p.received, p.verified, p.rejected, p.parsed, p.bodySize, p.handled} {
+		f.write(&b)
+	}
+	p.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (f *family) write(b *strings.Builder) {
+	typ := "counter"
+	if f.buckets != nil {
+		typ = "histogram"
+	}
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, typ)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		labels := f.labelPairs(s.values)
+		if f.buckets == nil {
+			fmt.Fprintf(b, "%s{%s} %s\n", f.name, labels, format(s.value))
+			continue
+		}
+
+		for i, le := range f.buckets {
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", f.name, labels, format(le), s.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", f.name, labels, s.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", f.name, labels, format(s.value))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", f.name, labels, s.count)
+	}
+}
+
+func (f *family) labelPairs(values []string) string {
+	pairs := make([]string, len(f.labels))
+	for i, name := range f.labels {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func format(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
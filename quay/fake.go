@@ -0,0 +1,104 @@
+package quay
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic notifications of a repository to unit test handlers.
+// The notifications of a faker agree with each other on the repository, the build trigger and the committer,
+// and encode to the JSON Quay sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same notifications.
+type Faker struct {
+	repository Repository
+	triggerID  string
+	user       CommitUser
+	src        *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given namespace and name, e.g. "mynamespace/api",
+// on quay.io built by a GitHub trigger. Commits are made by the user "jdoe".
+func NewFaker(fullName string) *Faker {
+	namespace, name, ok := strings.Cut(fullName, "/")
+	if !ok {
+		namespace, name = "mynamespace", fullName
+	}
+
+	src := fake.New("quay/" + fullName)
+	f := &Faker{
+		repository: Repository{
+			Repository: namespace + "/" + name,
+			Namespace:  namespace,
+			Name:       name,
+			DockerURL:  "quay.io/" + namespace + "/" + name,
+			Homepage:   "https://quay.io/repository/" + namespace + "/" + name,
+		},
+		triggerID: src.UUID(),
+		src:       src,
+	}
+	return f.User("jdoe")
+}
+
+// User sets the GitHub user authoring the commits built.
+func (f *Faker) User(username string) *Faker {
+	f.user = CommitUser{
+		Username:  username,
+		URL:       "https://github.com/" + username,
+		AvatarURL: "https://avatars.githubusercontent.com/u/" + strconv.FormatInt(fake.NameID(username), 10),
+	}
+	return f
+}
+
+// RepoPushPayload returns the push of the tags.
+func (f *Faker) RepoPushPayload(tags ...string) RepoPushPayload {
+	return RepoPushPayload{Repository: f.repository, UpdatedTags: tags}
+}
+
+// BuildPayload returns a build of a new commit on the branch, tagged with the tags, e.g. to deliver
+// as build_queued, build_start, build_success or build_failure with the error message set.
+func (f *Faker) BuildPayload(branch string, tags ...string) BuildPayload {
+	id := f.src.UUID()
+	commit := f.src.SHA()
+	owner := f.repository.Namespace + "/" + f.repository.Name
+	user := f.user
+
+	pl := BuildPayload{
+		Repository:  f.repository,
+		BuildID:     id,
+		TriggerKind: "github",
+		TriggerID:   f.triggerID,
+		DockerTags:  tags,
+		TriggerMetadata: &TriggerMetadata{
+			DefaultBranch: "main",
+			Ref:           "refs/heads/" + branch,
+			Commit:        commit,
+			CommitInfo: &CommitInfo{
+				URL:       "https://github.com/" + owner + "/commit/" + commit,
+				Date:      f.src.Time().Unix(),
+				Message:   "Update " + branch,
+				Committer: &user,
+				Author:    &user,
+			},
+		},
+	}
+	pl.Homepage += "/build/" + id
+	return pl
+}
+
+// VulnerabilityFoundPayload returns a vulnerability of the priority found in the image with the tags.
+func (f *Faker) VulnerabilityFoundPayload(priority string, tags ...string) VulnerabilityFoundPayload {
+	id := "CVE-2024-" + strconv.FormatInt(1000+f.src.ID(), 10)
+	return VulnerabilityFoundPayload{
+		Repository: f.repository,
+		Tags:       tags,
+		Vulnerability: Vulnerability{
+			ID:          id,
+			Description: "A vulnerability in a package of the image.",
+			Link:        "https://nvd.nist.gov/vuln/detail/" + id,
+			Priority:    priority,
+			HasFix:      true,
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package quay
+
+// Repository contains the repository fields common to all Quay notifications,
+// Repository is the namespace and name, e.g. "mynamespace/repository", and DockerURL its pull reference.
+type Repository struct {
+	Repository string `json:"repository"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	DockerURL  string `json:"docker_url"`
+	Homepage   string `json:"homepage"`
+}
+
+// RepoPushPayload contains the information for Quay's repo_push event.
+type RepoPushPayload struct {
+	Repository
+	UpdatedTags []string `json:"updated_tags"`
+}
+
+// CommitUser contains the author or committer of the commit a build was triggered by.
+type CommitUser struct {
+	Username  string `json:"username"`
+	URL       string `json:"url"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// CommitInfo contains the commit a build was triggered by, Date is the seconds since the epoch.
+type CommitInfo struct {
+	URL       string      `json:"url"`
+	Date      int64       `json:"date"`
+	Message   string      `json:"message"`
+	Committer *CommitUser `json:"committer,omitempty"`
+	Author    *CommitUser `json:"author,omitempty"`
+}
+
+// TriggerMetadata contains the ref and commit a build trigger fired for.
+type TriggerMetadata struct {
+	DefaultBranch string      `json:"default_branch"`
+	Ref           string      `json:"ref"`
+	Commit        string      `json:"commit"`
+	CommitInfo    *CommitInfo `json:"commit_info,omitempty"`
+}
+
+// BuildPayload contains the information for Quay's build_queued, build_start, build_success,
+// build_failure and build_cancelled events, ErrorMessage is set for failed builds.
+// TriggerKind is e.g. "github", "gitlab" or "bitbucket", and empty for builds started manually.
+type BuildPayload struct {
+	Repository
+	BuildID         string           `json:"build_id"`
+	TriggerKind     string           `json:"trigger_kind"`
+	TriggerID       string           `json:"trigger_id"`
+	DockerTags      []string         `json:"docker_tags"`
+	TriggerMetadata *TriggerMetadata `json:"trigger_metadata,omitempty"`
+	ErrorMessage    string           `json:"error_message,omitempty"`
+}
+
+// Vulnerability contains a vulnerability found in an image, Priority is e.g. "Low", "Medium", "High" or "Critical".
+type Vulnerability struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	Priority    string `json:"priority"`
+	HasFix      bool   `json:"has_fix"`
+}
+
+// VulnerabilityFoundPayload contains the information for Quay's vulnerability_found event,
+// Tags are the tags of the vulnerable image.
+type VulnerabilityFoundPayload struct {
+	Repository
+	Tags          []string      `json:"tags"`
+	Vulnerability Vulnerability `json:"vulnerability"`
+}
@@ -0,0 +1,256 @@
+// The `quay` package accepts the repository notifications of the Quay container registry.
+//
+// Quay sends neither the event of a notification nor a signature. The event is the event query parameter
+// of the notification URL, e.g. https://example.com/webhooks?event=build_success, without which pushes
+// and vulnerabilities are recognized by their payload but builds are not. Receivers are authenticated
+// by a shared secret in the token query parameter of the URL or by basic auth credentials.
+package quay
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+const (
+	// Quay notification events.
+	RepoPushEvent           Event = "repo_push"
+	BuildQueuedEvent        Event = "build_queued"
+	BuildStartEvent         Event = "build_start"
+	BuildSuccessEvent       Event = "build_success"
+	BuildFailureEvent       Event = "build_failure"
+	BuildCancelledEvent     Event = "build_cancelled"
+	VulnerabilityFoundEvent Event = "vulnerability_found"
+)
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = WebhookOptions{}
+	// Parse errors.
+	ErrParsingPayload              = errors.New("error parsing payload")
+	ErrSecretVerificationFailed    = errors.New("token query parameter verification failed")
+	ErrBasicAuthVerificationFailed = errors.New("basic auth verification failed")
+)
+
+// Event defines a Quay notification event by the event query parameter of the notification URL.
+type Event string
+
+// provider describes the deliveries of Quay to the instrumentation.
+var provider = &observe.Provider{
+	Name: "quay",
+}
+
+// Webhook instance contains all methods needed to process events.
+type Webhook struct {
+	secretHash []byte
+	username   string
+	password   string
+	observer   observe.Observer
+}
+
+// Parse verifies and parses the events specified and returns the payload object or an error.
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
+	defer func() {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}()
+
+	if len(events) == 0 {
+		return nil, errors.New("no Event specified to parse")
+	}
+
+	if r.Method != http.MethodPost {
+		return nil, errors.New("invalid HTTP Method")
+	}
+
+	err := d.Verify(len(hook.secretHash) > 0 || hook.username != "" || hook.password != "", func() error {
+		return hook.verify(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil || len(payload) == 0 {
+		return nil, ErrParsingPayload
+	}
+
+	quayEvent := Event(r.URL.Query().Get("event"))
+	if quayEvent == "" {
+		if quayEvent, err = detectEvent(payload); err != nil {
+			return nil, err
+		}
+	}
+	d.Event = string(quayEvent)
+
+	var found bool
+	for _, evt := range events {
+		if evt == quayEvent {
+			found = true
+			break
+		}
+	}
+
+	// event not defined to be parsed
+	if !found {
+		return nil, errors.New("event not defined to be parsed")
+	}
+
+	d.Decoding()
+	switch quayEvent {
+	case RepoPushEvent:
+		var pl RepoPushPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case BuildQueuedEvent, BuildStartEvent, BuildSuccessEvent, BuildFailureEvent, BuildCancelledEvent:
+		var pl BuildPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case VulnerabilityFoundEvent:
+		var pl VulnerabilityFoundPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	default:
+		return nil, fmt.Errorf("unknown event %s", quayEvent)
+	}
+}
+
+// detectEvent recognizes the event of a notification without the event query parameter by its fields.
+func detectEvent(payload []byte) (Event, error) {
+	var fields struct {
+		UpdatedTags   json.RawMessage `json:"updated_tags"`
+		Vulnerability json.RawMessage `json:"vulnerability"`
+		BuildID       string          `json:"build_id"`
+	}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return "", ErrParsingPayload
+	}
+
+	switch {
+	case fields.UpdatedTags != nil:
+		return RepoPushEvent, nil
+	case fields.Vulnerability != nil:
+		return VulnerabilityFoundEvent, nil
+	case fields.BuildID != "":
+		return "", errors.New("build notifications need the event query parameter")
+	default:
+		return "", errors.New("missing event query parameter")
+	}
+}
+
+// verify checks the secret and the basic auth credentials configured, in constant time.
+func (hook Webhook) verify(r *http.Request) error {
+	if len(hook.secretHash) > 0 {
+		tokenHash := sha512.Sum512([]byte(r.URL.Query().Get("token")))
+		if subtle.ConstantTimeCompare(tokenHash[:], hook.secretHash) == 0 {
+			return ErrSecretVerificationFailed
+		}
+	}
+
+	if hook.username != "" || hook.password != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(hook.username)) == 0 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(hook.password)) == 0 {
+			return ErrBasicAuthVerificationFailed
+		}
+	}
+	return nil
+}
+
+// Option is a configuration option for the webhook.
+type Option func(*Webhook) error
+
+// New creates and returns a WebHook instance.
+func New(options ...Option) (*Webhook, error) {
+	hook := new(Webhook)
+	for _, opt := range options {
+		if err := opt(hook); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+	return hook, nil
+}
+
+// WebhookOptions is a namespace for configuration option methods.
+type WebhookOptions struct{}
+
+// Secret registers the shared secret the notification URL carries in its token query parameter.
+func (WebhookOptions) Secret(secret string) Option {
+	return func(hook *Webhook) error {
+		if secret == "" {
+			return errors.New("secret must not be empty")
+		}
+		// already convert here to prevent timing attack
+		// (conversion depends on secret)
+		hash := sha512.Sum512([]byte(secret))
+		hook.secretHash = hash[:]
+		return nil
+	}
+}
+
+// BasicAuth verifies payload using basic auth, e.g. with the credentials of the notification URL.
+func (WebhookOptions) BasicAuth(username, password string) Option {
+	return func(hook *Webhook) error {
+		hook.username = username
+		hook.password = password
+		return nil
+	}
+}
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
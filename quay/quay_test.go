@@ -0,0 +1,310 @@
+package quay
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	path   = "/webhooks"
+	secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+)
+
+var hook *Webhook
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	hook, err = New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func TestWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+	}{
+		{
+			name:     "RepoPushEvent",
+			event:    RepoPushEvent,
+			typ:      RepoPushPayload{},
+			filename: "./testdata/repo-push.json",
+		},
+		{
+			name:     "BuildSuccessEvent",
+			event:    BuildSuccessEvent,
+			typ:      BuildPayload{},
+			filename: "./testdata/build-success.json",
+		},
+		{
+			name:     "BuildFailureEvent",
+			event:    BuildFailureEvent,
+			typ:      BuildPayload{},
+			filename: "./testdata/build-failure.json",
+		},
+		{
+			name:     "VulnerabilityFoundEvent",
+			event:    VulnerabilityFoundEvent,
+			typ:      VulnerabilityFoundPayload{},
+			filename: "./testdata/vulnerability-found.json",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.Open(tc.filename)
+			assert.NoError(err)
+			defer func() {
+				_ = payload.Close()
+			}()
+
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path+"?event="+string(tc.event), payload)
+			assert.NoError(err)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	parse := func(event Event, filename string) interface{} {
+		pl, err := hook.Parse(whtest.Quay(event, whtest.Fixture(t, filename)).Request(), event)
+		assert.NoError(err)
+		return pl
+	}
+
+	push := parse(RepoPushEvent, "./testdata/repo-push.json").(RepoPushPayload)
+	assert.Equal("mynamespace/api", push.Repository.Repository)
+	assert.Equal("quay.io/mynamespace/api", push.DockerURL)
+	assert.Equal([]string{"latest", "v1.4.2"}, push.UpdatedTags)
+
+	build := parse(BuildSuccessEvent, "./testdata/build-success.json").(BuildPayload)
+	assert.Equal("296ec063-5f86-4706-a469-f0a400bf9df2", build.BuildID)
+	assert.Equal("github", build.TriggerKind)
+	assert.Equal("refs/heads/main", build.TriggerMetadata.Ref)
+	assert.Equal("jdoe", build.TriggerMetadata.CommitInfo.Author.Username)
+	assert.Empty(build.ErrorMessage)
+
+	failure := parse(BuildFailureEvent, "./testdata/build-failure.json").(BuildPayload)
+	assert.Equal(build.TriggerID, failure.TriggerID)
+	assert.Nil(failure.TriggerMetadata.CommitInfo.Committer)
+	assert.Equal("Could not find or access Dockerfile at /Dockerfile", failure.ErrorMessage)
+
+	vulnerability := parse(VulnerabilityFoundEvent, "./testdata/vulnerability-found.json").(VulnerabilityFoundPayload)
+	assert.Equal("CVE-2024-5535", vulnerability.Vulnerability.ID)
+	assert.Equal("Critical", vulnerability.Vulnerability.Priority)
+	assert.True(vulnerability.Vulnerability.HasFix)
+}
+
+func TestEventDetection(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		event    Event
+		wantErr  bool
+	}{
+		{name: "RepoPush", filename: "./testdata/repo-push.json", event: RepoPushEvent},
+		{name: "VulnerabilityFound", filename: "./testdata/vulnerability-found.json", event: VulnerabilityFoundEvent},
+		{name: "Build", filename: "./testdata/build-success.json", event: BuildSuccessEvent, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			r := whtest.Quay("", whtest.Fixture(t, tc.filename)).Target(path).Request()
+			_, err := hook.Parse(r, tc.event)
+			if tc.wantErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/build-success.json")
+	tests := []struct {
+		name   string
+		events []Event
+		r      *http.Request
+	}{
+		{name: "NoEvents", r: whtest.Quay(BuildSuccessEvent, body).Request()},
+		{name: "UnsubscribedEvent", events: []Event{BuildFailureEvent}, r: whtest.Quay(BuildSuccessEvent, body).Request()},
+		{name: "BadMethod", events: []Event{BuildSuccessEvent}, r: whtest.Quay(BuildSuccessEvent, body).Method(http.MethodGet).Request()},
+		{name: "BadBody", events: []Event{BuildSuccessEvent}, r: whtest.Quay(BuildSuccessEvent, "").Request()},
+		{name: "BadJSON", events: []Event{BuildSuccessEvent}, r: whtest.Quay(BuildSuccessEvent, "{").Request()},
+		{name: "MissingEvent", events: []Event{BuildSuccessEvent}, r: whtest.Quay("", `{}`).Target(path).Request()},
+		{name: "UnknownEvent", events: []Event{"repo_mirror_sync_started"}, r: whtest.Quay("repo_mirror_sync_started", `{}`).Request()},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := hook.Parse(tc.r, tc.events...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestVerification(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/repo-push.json")
+	tests := []struct {
+		name    string
+		options []Option
+		r       *http.Request
+		wantErr error
+	}{
+		{
+			name:    "Secret",
+			options: []Option{Options.Secret(secret)},
+			r:       whtest.Quay(RepoPushEvent, body).Target(path + "?event=repo_push&token=" + secret).Request(),
+		},
+		{
+			name:    "WrongSecret",
+			options: []Option{Options.Secret(secret)},
+			r:       whtest.Quay(RepoPushEvent, body).Target(path + "?event=repo_push&token=wrong").Request(),
+			wantErr: ErrSecretVerificationFailed,
+		},
+		{
+			name:    "MissingSecret",
+			options: []Option{Options.Secret(secret)},
+			r:       whtest.Quay(RepoPushEvent, body).Request(),
+			wantErr: ErrSecretVerificationFailed,
+		},
+		{
+			name:    "BasicAuth",
+			options: []Option{Options.BasicAuth("quay", secret)},
+			r:       whtest.Quay(RepoPushEvent, body).BasicAuth("quay", secret).Request(),
+		},
+		{
+			name:    "WrongPassword",
+			options: []Option{Options.BasicAuth("quay", secret)},
+			r:       whtest.Quay(RepoPushEvent, body).BasicAuth("quay", secret).Tampered(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+		{
+			name:    "MissingBasicAuth",
+			options: []Option{Options.BasicAuth("quay", secret)},
+			r:       whtest.Quay(RepoPushEvent, body).Request(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			h, err := New(tc.options...)
+			assert.NoError(err)
+			_, err = h.Parse(tc.r, RepoPushEvent)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+
+	_, err := New(Options.Secret(""))
+	require.Error(t, err)
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("mynamespace/api").User("jroe")
+	push := faker.RepoPushPayload("latest", "v1.4.2")
+	assert.Equal("mynamespace/api", push.Repository.Repository)
+	assert.Equal([]string{"latest", "v1.4.2"}, push.UpdatedTags)
+
+	build := faker.BuildPayload("main", "latest")
+	assert.Equal("refs/heads/main", build.TriggerMetadata.Ref)
+	assert.Equal("jroe", build.TriggerMetadata.CommitInfo.Author.Username)
+	assert.Contains(build.Homepage, build.BuildID)
+	other := faker.BuildPayload("develop", "develop")
+	assert.Equal(build.TriggerID, other.TriggerID)
+	assert.NotEqual(build.BuildID, other.BuildID)
+	assert.Equal(build, NewFaker("mynamespace/api").User("jroe").BuildPayload("main", "latest"))
+
+	failure := faker.BuildPayload("main", "latest")
+	failure.ErrorMessage = "Could not find or access Dockerfile at /Dockerfile"
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: RepoPushEvent, payload: push},
+		{event: BuildSuccessEvent, payload: build},
+		{event: BuildFailureEvent, payload: failure},
+		{event: VulnerabilityFoundEvent, payload: faker.VulnerabilityFoundPayload("High", "latest")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Quay(tc.event, tc.payload).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{RepoPushEvent, "./testdata/repo-push.json"},
+		{BuildSuccessEvent, "./testdata/build-success.json"},
+		{BuildFailureEvent, "./testdata/build-failure.json"},
+		{VulnerabilityFoundEvent, "./testdata/vulnerability-found.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), payload)
+	}
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event string, payload []byte) {
+		r := whtest.Quay(event, payload).Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
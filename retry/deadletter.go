@@ -0,0 +1,223 @@
+package retry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pchchv/wh/dispatch"
+)
+
+// ErrDeadLetterNotFound is returned when a dead letter does not exist in the sink.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is a delivery that could not be handled.
+type DeadLetter struct {
+	ID          string          `json:"id"`
+	Time        time.Time       `json:"time"`
+	Received    time.Time       `json:"received"`
+	Header      http.Header     `json:"header"`
+	Body        []byte          `json:"body"`
+	PayloadType string          `json:"payload_type"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Errors      []string        `json:"errors"`
+	Attempts    int             `json:"attempts"`
+	Permanent   bool            `json:"permanent"`
+}
+
+// Sink stores dead letters.
+type Sink interface {
+	Put(dl DeadLetter) error
+}
+
+// NewDeadLetter builds the dead letter of a delivery, its parse result and the error chain of the last attempt.
+func NewDeadLetter(delivery dispatch.Delivery, payload interface{}, err error, attempts int) (DeadLetter, error) {
+	dl := DeadLetter{
+		ID:          delivery.ID,
+		Time:        time.Now(),
+		Received:    delivery.Received,
+		Header:      delivery.Header,
+		Body:        delivery.Body,
+		PayloadType: fmt.Sprintf("%T", payload),
+		Errors:      errorChain(err),
+		Attempts:    attempts,
+		Permanent:   IsPermanent(err),
+	}
+
+	if dl.ID == "" {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return dl, err
+		}
+		dl.ID = hex.EncodeToString(b[:])
+	}
+
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return dl, err
+		}
+		dl.Payload = raw
+	}
+
+	return dl, nil
+}
+
+// errorChain flattens err and the errors it wraps, outermost first.
+func errorChain(err error) []string {
+	var chain []string
+	queue := []error{err}
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		if e == nil {
+			continue
+		}
+
+		chain = append(chain, e.Error())
+		switch u := e.(type) {
+		case interface{ Unwrap() []error }:
+			queue = append(queue, u.Unwrap()...)
+		case interface{ Unwrap() error }:
+			queue = append(queue, u.Unwrap())
+		}
+	}
+	return chain
+}
+
+// FileSink stores every dead letter as a JSON file in a directory.
+type FileSink struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileSink creates the directory if needed and returns a sink writing to it.
+func NewFileSink(dir string) (*FileSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSink{dir: dir}, nil
+}
+
+// Put atomically writes the dead letter to <dir>/<id>.json, replacing a previous one with the same ID.
+func (s *FileSink) Put(dl DeadLetter) error {
+	data, err := json.MarshalIndent(dl, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(dl.ID))
+}
+
+// Get returns the dead letter with the given ID.
+func (s *FileSink) Get(id string) (DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(s.path(id))
+}
+
+// List returns all dead letters ordered by the time they were dead-lettered.
+func (s *FileSink) List() ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	dls := make([]DeadLetter, 0, len(files))
+	for _, f := range files {
+		dl, err := s.read(f)
+		if err != nil {
+			return nil, err
+		}
+		dls = append(dls, dl)
+	}
+
+	sort.Slice(dls, func(i, j int) bool {
+		return dls[i].Time.Before(dls[j].Time)
+	})
+	return dls, nil
+}
+
+// Remove deletes the dead letter with the given ID.
+func (s *FileSink) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(id)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrDeadLetterNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Reprocess passes every dead letter to fn, e.g. dispatch.Dispatcher.Redeliver,
+// and removes the ones fn handles without error.
+func (s *FileSink) Reprocess(fn func(DeadLetter) error) error {
+	dls, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, dl := range dls {
+		if err := fn(dl); err != nil {
+			errs = append(errs, fmt.Errorf("reprocess %s: %w", dl.ID, err))
+			continue
+		}
+
+		if err := s.Remove(dl.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *FileSink) read(path string) (DeadLetter, error) {
+	var dl DeadLetter
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return dl, ErrDeadLetterNotFound
+		}
+		return dl, err
+	}
+
+	err = json.Unmarshal(data, &dl)
+	return dl, err
+}
+
+func (s *FileSink) path(id string) string {
+	// IDs come from provider headers, keep them inside the directory
+	id = strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(id)
+	return filepath.Join(s.dir, id+".json")
+}
@@ -0,0 +1,224 @@
+// The `retry` package retries failing handlers with exponential backoff
+// and stores deliveries that keep failing in a dead-letter sink for later inspection and reprocessing.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/pchchv/wh/dispatch"
+)
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = RetryOptions{}
+	// ErrExhausted wraps the last handler error once all attempts failed.
+	ErrExhausted = errors.New("retry attempts exhausted")
+)
+
+// Classifier reports whether a handler error is worth retrying.
+type Classifier func(err error) bool
+
+// Option is a configuration option for the retrier.
+type Option func(*Retrier) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as non-retryable, the delivery is dead-lettered right away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err or any error in its chain was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Retryable is the default Classifier.
+// Every error is retried except permanent errors and cancelled contexts.
+func Retryable(err error) bool {
+	return !IsPermanent(err) && !errors.Is(err, context.Canceled)
+}
+
+// Retrier retries a handler and dead-letters the deliveries it fails to handle.
+type Retrier struct {
+	attempts   int
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+	classify   Classifier
+	sink       Sink
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// New creates a Retrier making 5 attempts, waiting 1s before the first retry,
+// doubling the wait up to 1m with ±20% jitter and no dead-letter sink.
+func New(options ...Option) (*Retrier, error) {
+	r := &Retrier{
+		attempts:   5,
+		initial:    time.Second,
+		max:        time.Minute,
+		multiplier: 2,
+		jitter:     0.2,
+		classify:   Retryable,
+		sleep:      sleep,
+	}
+	for _, opt := range options {
+		if err := opt(r); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+	return r, nil
+}
+
+// Handler wraps h so every payload is retried and dead-lettered according to the Retrier configuration.
+// The raw delivery stored in the dead letter is taken from the dispatch context, see dispatch.DeliveryFromContext.
+func (r *Retrier) Handler(h dispatch.Handler) dispatch.Handler {
+	return func(ctx context.Context, payload interface{}) error {
+		return r.Do(ctx, payload, h)
+	}
+}
+
+// Do calls h until it succeeds, returns a non-retryable error or runs out of attempts.
+// When it gives up, the delivery is stored in the dead-letter sink and the last error is returned.
+func (r *Retrier) Do(ctx context.Context, payload interface{}, h dispatch.Handler) error {
+	var err error
+	var attempt int
+	for attempt = 1; ; attempt++ {
+		if err = h(ctx, payload); err == nil {
+			return nil
+		}
+
+		if attempt >= r.attempts || !r.classify(err) {
+			break
+		}
+
+		if serr := r.sleep(ctx, r.Backoff(attempt)); serr != nil {
+			err = errors.Join(err, serr)
+			break
+		}
+	}
+
+	if r.classify(err) {
+		err = fmt.Errorf("%w after %d attempts: %w", ErrExhausted, attempt, err)
+	}
+
+	if r.sink != nil {
+		delivery, _ := dispatch.DeliveryFromContext(ctx)
+		dl, derr := NewDeadLetter(delivery, payload, err, attempt)
+		if derr == nil {
+			derr = r.sink.Put(dl)
+		}
+
+		if derr != nil {
+			return errors.Join(err, fmt.Errorf("dead-letter: %w", derr))
+		}
+	}
+
+	return err
+}
+
+// Backoff returns the wait before the retry following the given attempt.
+func (r *Retrier) Backoff(attempt int) time.Duration {
+	d := float64(r.initial)
+	for i := 1; i < attempt; i++ {
+		d *= r.multiplier
+		if d >= float64(r.max) {
+			d = float64(r.max)
+			break
+		}
+	}
+
+	if r.jitter > 0 {
+		d += d * r.jitter * (2*rand.Float64() - 1)
+	}
+
+	if d > float64(r.max) {
+		d = float64(r.max)
+	}
+	return time.Duration(d)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryOptions is a namespace for configuration option methods.
+type RetryOptions struct{}
+
+// Attempts sets the maximum number of calls to the handler, including the first one.
+func (RetryOptions) Attempts(n int) Option {
+	return func(r *Retrier) error {
+		if n < 1 {
+			return errors.New("attempts must be positive")
+		}
+		r.attempts = n
+		return nil
+	}
+}
+
+// Backoff sets the wait before the first retry, the maximum wait and the growth factor between retries.
+func (RetryOptions) Backoff(initial, max time.Duration, multiplier float64) Option {
+	return func(r *Retrier) error {
+		if initial < 0 || max < initial || multiplier < 1 {
+			return errors.New("invalid backoff")
+		}
+		r.initial, r.max, r.multiplier = initial, max, multiplier
+		return nil
+	}
+}
+
+// Jitter sets the fraction of the backoff, between 0 and 1, that is randomly added or removed.
+func (RetryOptions) Jitter(fraction float64) Option {
+	return func(r *Retrier) error {
+		if fraction < 0 || fraction > 1 {
+			return errors.New("jitter must be between 0 and 1")
+		}
+		r.jitter = fraction
+		return nil
+	}
+}
+
+// Classify sets the function deciding which errors are retried, Retryable by default.
+func (RetryOptions) Classify(classify Classifier) Option {
+	return func(r *Retrier) error {
+		if classify == nil {
+			return errors.New("classifier is nil")
+		}
+		r.classify = classify
+		return nil
+	}
+}
+
+// DeadLetter sets the sink storing deliveries that could not be handled.
+func (RetryOptions) DeadLetter(sink Sink) Option {
+	return func(r *Retrier) error {
+		r.sink = sink
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/dispatch"
+	"github.com/stretchr/testify/require"
+)
+
+type payload struct {
+	Ref string `json:"ref"`
+}
+
+func newRetrier(t *testing.T, options ...Option) (*Retrier, *[]time.Duration) {
+	r, err := New(options...)
+	require.NoError(t, err)
+	var waits []time.Duration
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return r, &waits
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	assert := require.New(t)
+	r, waits := newRetrier(t, Options.Backoff(time.Second, 3*time.Second, 2), Options.Jitter(0))
+
+	var calls int
+	err := r.Do(context.Background(), payload{}, func(ctx context.Context, pl interface{}) error {
+		calls++
+		if calls < 4 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal(4, calls)
+	assert.Equal([]time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, *waits)
+}
+
+func TestDeadLetter(t *testing.T) {
+	assert := require.New(t)
+	sink, err := NewFileSink(t.TempDir())
+	assert.NoError(err)
+	r, _ := newRetrier(t, Options.Attempts(3), Options.DeadLetter(sink))
+
+	delivery := dispatch.Delivery{
+		ID:     "delivery-1",
+		Header: http.Header{"X-Github-Event": []string{"push"}},
+		Body:   []byte(`{"ref":"refs/heads/main"}`),
+	}
+	var calls int
+	handler := r.Handler(func(ctx context.Context, pl interface{}) error {
+		calls++
+		return fmt.Errorf("handle push: %w", errors.New("connection refused"))
+	})
+
+	pl := payload{Ref: "refs/heads/main"}
+	err = handler(dispatch.ContextWithDelivery(context.Background(), delivery), pl)
+	assert.ErrorIs(err, ErrExhausted)
+	assert.Equal(3, calls)
+
+	dl, err := sink.Get("delivery-1")
+	assert.NoError(err)
+	assert.Equal(3, dl.Attempts)
+	assert.False(dl.Permanent)
+	assert.Equal("retry.payload", dl.PayloadType)
+	assert.JSONEq(`{"ref":"refs/heads/main"}`, string(dl.Payload))
+	assert.Equal(delivery.Body, dl.Body)
+	assert.Equal("push", dl.Header.Get("X-GitHub-Event"))
+	assert.Equal([]string{
+		"retry attempts exhausted after 3 attempts: handle push: connection refused",
+		"retry attempts exhausted",
+		"handle push: connection refused",
+		"connection refused",
+	}, dl.Errors)
+}
+
+func TestPermanentError(t *testing.T) {
+	assert := require.New(t)
+	sink, err := NewFileSink(t.TempDir())
+	assert.NoError(err)
+	r, waits := newRetrier(t, Options.DeadLetter(sink))
+
+	var calls int
+	err = r.Do(context.Background(), payload{}, func(ctx context.Context, pl interface{}) error {
+		calls++
+		return Permanent(errors.New("invalid payload"))
+	})
+	assert.True(IsPermanent(err))
+	assert.NotErrorIs(err, ErrExhausted)
+	assert.Equal(1, calls)
+	assert.Empty(*waits)
+
+	dls, err := sink.List()
+	assert.NoError(err)
+	assert.Len(dls, 1)
+	assert.True(dls[0].Permanent)
+	assert.Len(dls[0].ID, 32)
+}
+
+func TestClassify(t *testing.T) {
+	assert := require.New(t)
+	errNotFound := errors.New("not found")
+	r, _ := newRetrier(t, Options.Classify(func(err error) bool {
+		return !errors.Is(err, errNotFound)
+	}))
+
+	var calls int
+	err := r.Do(context.Background(), payload{}, func(ctx context.Context, pl interface{}) error {
+		calls++
+		return errNotFound
+	})
+	assert.ErrorIs(err, errNotFound)
+	assert.Equal(1, calls)
+}
+
+func TestCancelledContext(t *testing.T) {
+	assert := require.New(t)
+	r, _ := newRetrier(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls int
+	err := r.Do(ctx, payload{}, func(ctx context.Context, pl interface{}) error {
+		calls++
+		return errors.New("temporary failure")
+	})
+	assert.ErrorIs(err, context.Canceled)
+	assert.Equal(1, calls)
+}
+
+func TestBackoffJitter(t *testing.T) {
+	assert := require.New(t)
+	r, err := New(Options.Backoff(100*time.Millisecond, time.Second, 2), Options.Jitter(0.5))
+	assert.NoError(err)
+	for i := 0; i < 100; i++ {
+		d := r.Backoff(2)
+		assert.GreaterOrEqual(d, 100*time.Millisecond)
+		assert.LessOrEqual(d, 300*time.Millisecond)
+		assert.LessOrEqual(r.Backoff(10), time.Second)
+	}
+
+	_, err = New(Options.Jitter(2))
+	assert.Error(err)
+	_, err = New(Options.Attempts(0))
+	assert.Error(err)
+}
+
+func TestReprocess(t *testing.T) {
+	assert := require.New(t)
+	sink, err := NewFileSink(t.TempDir())
+	assert.NoError(err)
+	assert.NoError(sink.Put(DeadLetter{ID: "ok", Time: time.Now(), Body: []byte(`{"ref":"a"}`)}))
+	assert.NoError(sink.Put(DeadLetter{ID: "../failing", Time: time.Now(), Body: []byte(`{"ref":"b"}`)}))
+
+	var handled []string
+	d, err := dispatch.New(func(r *http.Request) (interface{}, error) {
+		body, err := io.ReadAll(r.Body)
+		return string(body), err
+	}, func(ctx context.Context, pl interface{}) error {
+		handled = append(handled, pl.(string))
+		if strings.Contains(pl.(string), "b") {
+			return errors.New("still failing")
+		}
+		return nil
+	})
+	assert.NoError(err)
+	defer func() {
+		_ = d.Shutdown(context.Background())
+	}()
+
+	err = sink.Reprocess(func(dl DeadLetter) error {
+		return d.Redeliver(context.Background(), dl.Header, dl.Body)
+	})
+	assert.ErrorContains(err, "still failing")
+	assert.Len(handled, 2)
+
+	dls, err := sink.List()
+	assert.NoError(err)
+	assert.Len(dls, 1)
+	assert.Equal("../failing", dls[0].ID)
+	assert.ErrorIs(sink.Remove("ok"), ErrDeadLetterNotFound)
+}
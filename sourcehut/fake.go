@@ -0,0 +1,120 @@
+package sourcehut
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic payloads of a repository to unit test handlers.
+// The payloads of a faker agree with each other on the repository, users, refs and SHAs,
+// and encode to the JSON SourceHut sends, e.g. to deliver them with the whtest package.
+// The same repository always yields the same payloads.
+type Faker struct {
+	owner  string
+	name   string
+	id     int64
+	pusher User
+	src    *fake.Source
+}
+
+// NewFaker returns a faker of the repository with the given name, e.g. "~sircmpwn/git.sr.ht".
+// Events are triggered by the repository owner.
+func NewFaker(fullName string) *Faker {
+	owner, name, ok := strings.Cut(strings.TrimPrefix(fullName, "~"), "/")
+	if !ok {
+		owner, name = "sircmpwn", owner
+	}
+
+	src := fake.New("sourcehut/" + fullName)
+	f := &Faker{owner: owner, name: name, id: src.ID(), src: src}
+	f.pusher = f.user(owner)
+	return f
+}
+
+// Pusher sets the name of the user triggering the events.
+func (f *Faker) Pusher(name string) *Faker {
+	f.pusher = f.user(strings.TrimPrefix(name, "~"))
+	return f
+}
+
+// SHA returns a new commit SHA.
+func (f *Faker) SHA() string {
+	return f.src.SHA()
+}
+
+// RepositoryPayload returns the repository the faker builds payloads of.
+func (f *Faker) RepositoryPayload() RepositoryPayload {
+	return RepositoryPayload{
+		ID:          f.id,
+		Name:        f.name,
+		Description: "The " + f.name + " repository",
+		Visibility:  "public",
+		Owner:       f.user(f.owner),
+		Created:     fake.Epoch,
+		Updated:     fake.Epoch,
+	}
+}
+
+// PostUpdatePayload returns the push of commits to ref, a branch name or a full ref such as "refs/tags/v1.0.0".
+// The commits follow each other, the ref is updated from the parent of the first one to the last one.
+func (f *Faker) PostUpdatePayload(ref string, commits int) PostUpdatePayload {
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+
+	old := f.commit("Initial commit", nil)
+	last := old
+	for i := 0; i < commits; i++ {
+		last = f.commit(fmt.Sprintf("Change %d", i+1), []string{last.ID})
+	}
+
+	return PostUpdatePayload{
+		Push:   f.src.UUID(),
+		Pusher: f.pusher,
+		Refs:   []Ref{{Name: ref, Old: old, New: last}},
+	}
+}
+
+// JobPayload returns a builds.sr.ht job with the status, running a build and a test task.
+func (f *Faker) JobPayload(status string) JobPayload {
+	id := f.src.ID() % 10000000
+	logs := fmt.Sprintf("https://logs.sr.ht/~%s/%d", f.owner, id)
+	return JobPayload{
+		ID:       id,
+		Status:   status,
+		SetupLog: logs + "/log",
+		Tasks: []Task{
+			{Name: "build", Status: status, Log: logs + "/build/log"},
+			{Name: "test", Status: status, Log: logs + "/test/log"},
+		},
+		Runner:  "runner.sr.ht",
+		Tags:    f.name + "/commits/master",
+		Owner:   f.user(f.owner),
+		Created: f.src.Time(),
+		Updated: f.src.Time(),
+	}
+}
+
+func (f *Faker) commit(message string, parents []string) *Commit {
+	sha := f.SHA()
+	author := Person{Name: f.pusher.Name, Email: f.pusher.Name + "@example.org"}
+	if parents == nil {
+		parents = []string{}
+	}
+	return &Commit{
+		ID:        sha,
+		ShortID:   sha[:8],
+		Author:    author,
+		Committer: author,
+		Timestamp: f.src.Time(),
+		Message:   message + "\n",
+		Tree:      f.SHA(),
+		Parents:   parents,
+	}
+}
+
+func (f *Faker) user(name string) User {
+	return User{CanonicalName: "~" + name, Name: name}
+}
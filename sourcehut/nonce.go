@@ -0,0 +1,63 @@
+package sourcehut
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultNonceTTL is how long the default NonceStore remembers the nonce of a delivery.
+const DefaultNonceTTL = 24 * time.Hour
+
+// NonceStore remembers the nonces of verified deliveries so a replayed delivery is rejected.
+// Receivers running several instances share a store, e.g. backed by a database.
+type NonceStore interface {
+	// Remember records the nonce and reports whether it was not seen before.
+	Remember(nonce string) bool
+}
+
+// NonceCache is a NonceStore remembering the nonces in memory for a limited time.
+type NonceCache struct {
+	ttl   time.Duration
+	now   func() time.Time
+	mu    sync.Mutex
+	seen  map[string]struct{}
+	queue []remembered
+}
+
+type remembered struct {
+	nonce  string
+	expire time.Time
+}
+
+// NewNonceCache returns a NonceCache forgetting nonces after ttl.
+func NewNonceCache(ttl time.Duration) *NonceCache {
+	return &NonceCache{ttl: ttl, now: time.Now, seen: map[string]struct{}{}}
+}
+
+// Remember implements NonceStore.
+func (c *NonceCache) Remember(nonce string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	// nonces are queued in the order they expire
+	for len(c.queue) > 0 && !c.queue[0].expire.After(now) {
+		delete(c.seen, c.queue[0].nonce)
+		c.queue = c.queue[1:]
+	}
+
+	if _, ok := c.seen[nonce]; ok {
+		return false
+	}
+
+	c.seen[nonce] = struct{}{}
+	c.queue = append(c.queue, remembered{nonce: nonce, expire: now.Add(c.ttl)})
+	return true
+}
+
+// Len returns the number of nonces remembered.
+func (c *NonceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.seen)
+}
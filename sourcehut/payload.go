@@ -0,0 +1,148 @@
+package sourcehut
+
+import "time"
+
+// User contains the SourceHut user information, CanonicalName is the name prefixed with a tilde.
+type User struct {
+	CanonicalName string `json:"canonical_name"`
+	Name          string `json:"name"`
+}
+
+// Signature contains the GPG signature of a commit or annotated tag.
+type Signature struct {
+	Signature string `json:"signature"`
+	Data      string `json:"data"`
+}
+
+// Person contains the author or committer of a commit.
+type Person struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Commit contains all of the git.sr.ht commit information.
+type Commit struct {
+	ID        string     `json:"id"`
+	ShortID   string     `json:"short_id"`
+	Author    Person     `json:"author"`
+	Committer Person     `json:"committer"`
+	Timestamp time.Time  `json:"timestamp"`
+	Message   string     `json:"message"`
+	Tree      string     `json:"tree"`
+	Parents   []string   `json:"parents"`
+	Signature *Signature `json:"signature"`
+}
+
+// AnnotatedTag contains the annotated tag a ref points to.
+type AnnotatedTag struct {
+	Name      string     `json:"name"`
+	Message   string     `json:"message"`
+	Signature *Signature `json:"signature"`
+}
+
+// Ref contains an updated ref of a push, Old is nil for created refs and New for deleted ones.
+type Ref struct {
+	Name         string        `json:"name"`
+	AnnotatedTag *AnnotatedTag `json:"annotated_tag"`
+	Old          *Commit       `json:"old"`
+	New          *Commit       `json:"new"`
+}
+
+// Repository contains all of the git.sr.ht repository information.
+type Repository struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Visibility  string    `json:"visibility"`
+	Owner       User      `json:"owner"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+}
+
+// RepositoryPayload contains the repository created, updated or deleted by git.sr.ht.
+type RepositoryPayload Repository
+
+// PostUpdatePayload contains the information for git.sr.ht's repo:post-update event, sent once a push is applied.
+type PostUpdatePayload struct {
+	Push   string `json:"push"`
+	Pusher User   `json:"pusher"`
+	Refs   []Ref  `json:"refs"`
+}
+
+// Task contains the status of a task of a build job.
+type Task struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Log    string `json:"log"`
+}
+
+// JobPayload contains the status of a builds.sr.ht job,
+// Status is "pending", "queued", "running", "success", "failed", "timeout" or "cancelled".
+type JobPayload struct {
+	ID       int64     `json:"id"`
+	Status   string    `json:"status"`
+	SetupLog string    `json:"setup_log"`
+	Tasks    []Task    `json:"tasks"`
+	Note     string    `json:"note"`
+	Runner   string    `json:"runner"`
+	Tags     string    `json:"tags"`
+	Owner    User      `json:"owner"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Tracker contains all of the todo.sr.ht tracker information.
+type Tracker struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Owner       User      `json:"owner"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+}
+
+// Ticket contains all of the todo.sr.ht ticket information,
+// Ref is the ticket reference, e.g. "~sircmpwn/wh#42".
+type Ticket struct {
+	ID         int64     `json:"id"`
+	Ref        string    `json:"ref"`
+	Tracker    Tracker   `json:"tracker"`
+	Subject    string    `json:"subject"`
+	Body       string    `json:"body"`
+	Status     string    `json:"status"`
+	Resolution string    `json:"resolution"`
+	Labels     []string  `json:"labels"`
+	Assignees  []User    `json:"assignees"`
+	Submitter  User      `json:"submitter"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+}
+
+// TicketPayload contains the ticket created or updated in todo.sr.ht.
+type TicketPayload Ticket
+
+// TicketComment contains a comment of a ticket event.
+type TicketComment struct {
+	ID        int64     `json:"id"`
+	Text      string    `json:"text"`
+	Submitter User      `json:"submitter"`
+	Created   time.Time `json:"created"`
+}
+
+// TicketEventPayload contains the information for todo.sr.ht's event:create event,
+// an event in the history of a ticket. EventType lists what happened,
+// e.g. "comment", "status_change", "label_added" or "assigned_user".
+type TicketEventPayload struct {
+	ID            int64          `json:"id"`
+	EventType     []string       `json:"event_type"`
+	OldStatus     string         `json:"old_status"`
+	OldResolution string         `json:"old_resolution"`
+	NewStatus     string         `json:"new_status"`
+	NewResolution string         `json:"new_resolution"`
+	User          User           `json:"user"`
+	Ticket        Ticket         `json:"ticket"`
+	Comment       *TicketComment `json:"comment"`
+	Label         *string        `json:"label"`
+	ByUser        *User          `json:"by_user"`
+	Created       time.Time      `json:"created"`
+}
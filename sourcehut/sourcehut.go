@@ -0,0 +1,252 @@
+// The `sourcehut` package verifies and parses the webhooks of SourceHut, git.sr.ht, builds.sr.ht and todo.sr.ht.
+//
+// SourceHut signs its deliveries with an Ed25519 key instead of a shared secret:
+// X-Payload-Signature is the base64 encoded signature of the body followed by the X-Payload-Nonce.
+// The nonce of every verified delivery is remembered for DefaultNonceTTL, 24 hours, and a delivery reusing it
+// is rejected with ErrReplayedDelivery. The default store is in memory: it is lost on restart and not shared
+// between instances, so a captured delivery can still be replayed after a restart, to another instance
+// or once its nonce expired. Set a shared, persistent NonceStore to reject those too.
+package sourcehut
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+const (
+	// git.sr.ht hook types.
+	RepoCreateEvent     Event = "repo:create"
+	RepoUpdateEvent     Event = "repo:update"
+	RepoDeleteEvent     Event = "repo:delete"
+	RepoPostUpdateEvent Event = "repo:post-update"
+	// builds.sr.ht hook types.
+	JobCreateEvent Event = "job:create"
+	// todo.sr.ht hook types.
+	TicketCreateEvent Event = "ticket:create"
+	TicketUpdateEvent Event = "ticket:update"
+	EventCreateEvent  Event = "event:create"
+)
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = WebhookOptions{}
+	// ErrParsingPayload is returned when a payload cannot be read or decoded.
+	ErrParsingPayload = errors.New("error parsing payload")
+	// ErrReplayedDelivery is returned when the nonce of a delivery was already used by a verified delivery.
+	ErrReplayedDelivery = errors.New("X-Payload-Nonce already used, the delivery was replayed")
+)
+
+// Event defines a SourceHut hook event type by the X-Webhook-Event Header.
+type Event string
+
+// provider describes the deliveries of SourceHut to the instrumentation.
+var provider = &observe.Provider{
+	Name:           "sourcehut",
+	EventHeader:    "X-Webhook-Event",
+	DeliveryHeader: "X-Webhook-Delivery",
+	SecretHeaders:  []string{"X-Payload-Signature", "X-Payload-Nonce"},
+}
+
+// Webhook instance contains all methods needed to process events.
+type Webhook struct {
+	publicKey ed25519.PublicKey
+	nonces    NonceStore
+	observer  observe.Observer
+}
+
+// Parse verifies and parses the events specified and returns the payload object or an error.
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
+	defer func() {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}()
+
+	if len(events) == 0 {
+		return nil, errors.New("no Event specified to parse")
+	}
+
+	if r.Method != http.MethodPost {
+		return nil, errors.New("invalid HTTP Method")
+	}
+
+	event := r.Header.Get("X-Webhook-Event")
+	if len(event) == 0 {
+		return nil, errors.New("missing X-Webhook-Event Header")
+	}
+
+	var found bool
+	sourcehutEvent := Event(event)
+	for _, evt := range events {
+		if evt == sourcehutEvent {
+			found = true
+			break
+		}
+	}
+
+	// event not defined to be parsed
+	if !found {
+		return nil, errors.New("event not defined to be parsed")
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil || len(payload) == 0 {
+		return nil, ErrParsingPayload
+	}
+
+	// if a public key is set, the signature must be checked
+	err = d.Verify(len(hook.publicKey) > 0, func() error {
+		signature := r.Header.Get("X-Payload-Signature")
+		if len(signature) == 0 {
+			return errors.New("missing X-Payload-Signature Header")
+		}
+
+		nonce := r.Header.Get("X-Payload-Nonce")
+		if len(nonce) == 0 {
+			return errors.New("missing X-Payload-Nonce Header")
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(signature)
+		if err != nil {
+			return errors.New("invalid X-Payload-Signature Header")
+		}
+
+		signed := make([]byte, 0, len(payload)+len(nonce))
+		signed = append(append(signed, payload...), nonce...)
+		if !ed25519.Verify(hook.publicKey, signed, sig) {
+			return errors.New("Ed25519 verification failed")
+		}
+
+		// only the nonces of verified deliveries are remembered
+		if hook.nonces != nil && !hook.nonces.Remember(nonce) {
+			return ErrReplayedDelivery
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.Decoding()
+	switch sourcehutEvent {
+	case RepoCreateEvent, RepoUpdateEvent, RepoDeleteEvent:
+		var pl RepositoryPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case RepoPostUpdateEvent:
+		var pl PostUpdatePayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case JobCreateEvent:
+		var pl JobPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case TicketCreateEvent, TicketUpdateEvent:
+		var pl TicketPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	case EventCreateEvent:
+		var pl TicketEventPayload
+		err = json.Unmarshal(payload, &pl)
+		return pl, err
+	default:
+		return nil, fmt.Errorf("unknown event %s", sourcehutEvent)
+	}
+}
+
+// Option is a configuration option for the webhook.
+type Option func(*Webhook) error
+
+// New creates and returns a WebHook instance denoted by the Provider type.
+// Nonces are remembered for DefaultNonceTTL in memory unless another NonceStore is set.
+func New(options ...Option) (*Webhook, error) {
+	hook := &Webhook{nonces: NewNonceCache(DefaultNonceTTL)}
+	for _, opt := range options {
+		if err := opt(hook); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+	return hook, nil
+}
+
+// WebhookOptions is a namespace for configuration option methods.
+type WebhookOptions struct{}
+
+// PublicKey registers the base64 encoded Ed25519 public key the SourceHut instance signs its deliveries with,
+// the key of sr.ht is published in its API documentation.
+func (WebhookOptions) PublicKey(key string) Option {
+	return func(hook *Webhook) error {
+		b, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return errors.New("invalid Ed25519 public key")
+		}
+		hook.publicKey = ed25519.PublicKey(b)
+		return nil
+	}
+}
+
+// NonceStore sets the store remembering the nonces of verified deliveries,
+// a nil store disables the replay protection.
+func (WebhookOptions) NonceStore(store NonceStore) Option {
+	return func(hook *Webhook) error {
+		hook.nonces = store
+		return nil
+	}
+}
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
@@ -0,0 +1,305 @@
+package sourcehut
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const path = "/webhooks"
+
+var (
+	hook *Webhook
+	// privateKey signs the test deliveries, the key of a SourceHut instance
+	privateKey = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{0x5e}, ed25519.SeedSize))
+	secret     = base64.StdEncoding.EncodeToString(privateKey)
+	publicKey  = base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+)
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	hook, err = New(Options.PublicKey(publicKey))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func TestWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+	}{
+		{
+			name:     "RepoCreateEvent",
+			event:    RepoCreateEvent,
+			typ:      RepositoryPayload{},
+			filename: "./testdata/repo-create.json",
+		},
+		{
+			name:     "RepoPostUpdateEvent",
+			event:    RepoPostUpdateEvent,
+			typ:      PostUpdatePayload{},
+			filename: "./testdata/repo-post-update.json",
+		},
+		{
+			name:     "JobCreateEvent",
+			event:    JobCreateEvent,
+			typ:      JobPayload{},
+			filename: "./testdata/job-create.json",
+		},
+		{
+			name:     "TicketCreateEvent",
+			event:    TicketCreateEvent,
+			typ:      TicketPayload{},
+			filename: "./testdata/ticket-create.json",
+		},
+		{
+			name:     "TicketUpdateEvent",
+			event:    TicketUpdateEvent,
+			typ:      TicketPayload{},
+			filename: "./testdata/ticket-update.json",
+		},
+		{
+			name:     "EventCreateEvent",
+			event:    EventCreateEvent,
+			typ:      TicketEventPayload{},
+			filename: "./testdata/event-create.json",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+
+			r := whtest.SourceHut(tc.event, whtest.Fixture(t, tc.filename)).Secret(secret).Target(server.URL + path).Request()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, r.Body)
+			assert.NoError(err)
+			req.Header = r.Header
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	parse := func(event Event, filename string) interface{} {
+		pl, err := hook.Parse(whtest.SourceHut(event, whtest.Fixture(t, filename)).Secret(secret).Request(), event)
+		assert.NoError(err)
+		return pl
+	}
+
+	push := parse(RepoPostUpdateEvent, "./testdata/repo-post-update.json").(PostUpdatePayload)
+	assert.Equal("~emersion", push.Pusher.CanonicalName)
+	assert.Len(push.Refs, 2)
+	assert.Equal(push.Refs[0].Old.ID, push.Refs[0].New.Parents[0])
+	assert.Nil(push.Refs[1].Old)
+	assert.Equal("v0.1.0", push.Refs[1].AnnotatedTag.Name)
+
+	job := parse(JobCreateEvent, "./testdata/job-create.json").(JobPayload)
+	assert.Equal("success", job.Status)
+	assert.Len(job.Tasks, 2)
+
+	event := parse(EventCreateEvent, "./testdata/event-create.json").(TicketEventPayload)
+	assert.Equal([]string{"comment", "status_change"}, event.EventType)
+	assert.Equal("~sircmpwn/wh#42", event.Ticket.Ref)
+	assert.Equal("Fixed in 8e7d6c5b.", event.Comment.Text)
+}
+
+func TestBadRequests(t *testing.T) {
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{0x01}, ed25519.SeedSize))
+	body := whtest.Fixture(t, "./testdata/repo-create.json")
+	delivery := func() *whtest.Delivery {
+		return whtest.SourceHut(RepoCreateEvent, body).Secret(secret)
+	}
+
+	tests := []struct {
+		name string
+		r    *http.Request
+	}{
+		{name: "BadNoEventHeader", r: delivery().Header("X-Webhook-Event", "").Request()},
+		{name: "UnsubscribedEvent", r: delivery().Header("X-Webhook-Event", "repo:delete").Request()},
+		{name: "BadBody", r: whtest.SourceHut(RepoCreateEvent, "").Secret(secret).Request()},
+		{name: "MissingSignature", r: delivery().Unsigned()},
+		{name: "MissingNonce", r: delivery().Header("X-Payload-Nonce", "").Request()},
+		{name: "BadSignatureEncoding", r: delivery().Header("X-Payload-Signature", "not base64!").Request()},
+		{name: "OtherNonce", r: delivery().Header("X-Payload-Nonce", "0123456789abcdef").Request()},
+		{name: "OtherKey", r: whtest.SourceHut(RepoCreateEvent, body).Secret(base64.StdEncoding.EncodeToString(other)).Request()},
+		{name: "TamperedBody", r: delivery().Tampered()},
+		{name: "BadMethod", r: delivery().Method(http.MethodGet).Request()},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := hook.Parse(tc.r, RepoCreateEvent)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	assert := require.New(t)
+	d := whtest.SourceHut(RepoPostUpdateEvent, whtest.Fixture(t, "./testdata/repo-post-update.json")).Secret(secret)
+	r := d.Request()
+	body, header := d.Body(), r.Header.Clone()
+
+	_, err := hook.Parse(r, RepoPostUpdateEvent)
+	assert.NoError(err)
+
+	replay := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	replay.Header = header.Clone()
+	_, err = hook.Parse(replay, RepoPostUpdateEvent)
+	assert.ErrorIs(err, ErrReplayedDelivery)
+
+	// the nonce of a rejected delivery is not remembered
+	nonces := NewNonceCache(time.Hour)
+	withStore, err := New(Options.PublicKey(publicKey), Options.NonceStore(nonces))
+	assert.NoError(err)
+	_, err = withStore.Parse(d.Tampered(), RepoPostUpdateEvent)
+	assert.Error(err)
+	assert.Equal(0, nonces.Len())
+
+	withoutStore, err := New(Options.PublicKey(publicKey), Options.NonceStore(nil))
+	assert.NoError(err)
+	for i := 0; i < 2; i++ {
+		replay := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		replay.Header = header.Clone()
+		_, err = withoutStore.Parse(replay, RepoPostUpdateEvent)
+		assert.NoError(err)
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	assert := require.New(t)
+	now := time.Date(2024, 6, 3, 8, 44, 0, 0, time.UTC)
+	c := NewNonceCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	assert.True(c.Remember("a"))
+	assert.False(c.Remember("a"))
+	now = now.Add(30 * time.Second)
+	assert.True(c.Remember("b"))
+	assert.Equal(2, c.Len())
+
+	now = now.Add(40 * time.Second)
+	assert.True(c.Remember("a"))
+	assert.False(c.Remember("b"))
+	assert.Equal(2, c.Len())
+
+	now = now.Add(time.Hour)
+	assert.True(c.Remember("c"))
+	assert.Equal(1, c.Len())
+}
+
+func TestOptions(t *testing.T) {
+	assert := require.New(t)
+	_, err := New(Options.PublicKey("not base64!"))
+	assert.Error(err)
+	_, err = New(Options.PublicKey(base64.StdEncoding.EncodeToString([]byte("short"))))
+	assert.Error(err)
+
+	// without a public key deliveries are not verified
+	unverified, err := New()
+	assert.NoError(err)
+	_, err = unverified.Parse(whtest.SourceHut(JobCreateEvent, whtest.Fixture(t, "./testdata/job-create.json")).Unsigned(), JobCreateEvent)
+	assert.NoError(err)
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("~sircmpwn/git.sr.ht").Pusher("~emersion")
+	push := faker.PostUpdatePayload("master", 3)
+	assert.Len(push.Refs, 1)
+	assert.Equal("refs/heads/master", push.Refs[0].Name)
+	assert.Equal("~emersion", push.Pusher.CanonicalName)
+	assert.Equal(push, NewFaker("~sircmpwn/git.sr.ht").Pusher("~emersion").PostUpdatePayload("master", 3))
+
+	repo := faker.RepositoryPayload()
+	assert.Equal("git.sr.ht", repo.Name)
+	assert.Equal("~sircmpwn", repo.Owner.CanonicalName)
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: RepoPostUpdateEvent, payload: push},
+		{event: RepoUpdateEvent, payload: repo},
+		{event: JobCreateEvent, payload: faker.JobPayload("failed")},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.SourceHut(tc.event, tc.payload).Secret(secret).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{RepoCreateEvent, "./testdata/repo-create.json"},
+		{RepoPostUpdateEvent, "./testdata/repo-post-update.json"},
+		{JobCreateEvent, "./testdata/job-create.json"},
+		{TicketCreateEvent, "./testdata/ticket-create.json"},
+		{TicketUpdateEvent, "./testdata/ticket-update.json"},
+		{EventCreateEvent, "./testdata/event-create.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), "", payload)
+	}
+	f.Add(string(seeds[0].event), "zz", []byte("{}"))
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event, signature string, payload []byte) {
+		d := whtest.SourceHut(event, payload).Secret(secret)
+		if signature != "" {
+			d.Header("X-Payload-Signature", signature)
+		}
+
+		r := d.Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Memory is a Tracer keeping the ended spans in memory, e.g. to test the spans of a receiver.
+// Spans are carried by contexts with ContextWithSpan.
+type Memory struct {
+	mu    sync.Mutex
+	ids   uint64
+	spans []SpanData
+}
+
+// SpanData is a span ended by a Memory tracer.
+type SpanData struct {
+	Name       string
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Attributes map[string]string
+	// Err is the last error recorded.
+	Err   error
+	Start time.Time
+	End   time.Time
+}
+
+type memorySpan struct {
+	tracer *Memory
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// NewMemory returns an in-memory tracer.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Start implements Tracer.
+func (m *Memory) Start(ctx context.Context, name string) (context.Context, Span) {
+	m.mu.Lock()
+	m.ids++
+	id := fmt.Sprintf("%016x", m.ids)
+	m.mu.Unlock()
+
+	s := &memorySpan{tracer: m, data: SpanData{
+		Name:       name,
+		TraceID:    id,
+		SpanID:     id,
+		Attributes: map[string]string{},
+		Start:      time.Now(),
+	}}
+	if parent, ok := SpanFromContext(ctx); ok {
+		if p, ok := parent.(*memorySpan); ok && p.tracer == m {
+			s.data.TraceID, s.data.ParentID = p.data.TraceID, p.data.SpanID
+		}
+	}
+	return ContextWithSpan(ctx, s), s
+}
+
+// Spans returns the ended spans in the order they ended.
+func (m *Memory) Spans() []SpanData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SpanData(nil), m.spans...)
+}
+
+// Reset forgets the ended spans.
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spans = nil
+}
+
+// SetAttributes implements Span.
+func (s *memorySpan) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range attrs {
+		s.data.Attributes[attr.Key] = attr.Value
+	}
+}
+
+// RecordError implements Span.
+func (s *memorySpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Err = err
+}
+
+// End implements Span, only the first call has an effect.
+func (s *memorySpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Attributes = make(map[string]string, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, data)
+}
@@ -0,0 +1,68 @@
+// The `tracing` package traces the deliveries parsed by the providers and handled by the dispatcher.
+//
+// Every delivery gets a "webhook.delivery" span with the provider, event, action, repository,
+// delivery ID and verification result as attributes, and "webhook.verify", "webhook.decode"
+// and "webhook.handle" child spans. Tracer is implemented by Memory, which keeps the spans in memory for tests,
+// and is small enough to be adapted to OpenTelemetry or any other tracing library.
+package tracing
+
+import (
+	"context"
+)
+
+const (
+	// Span names.
+	DeliverySpan = "webhook.delivery"
+	VerifySpan   = "webhook.verify"
+	DecodeSpan   = "webhook.decode"
+	HandleSpan   = "webhook.handle"
+	// Attribute keys.
+	ProviderKey     = "webhook.provider"
+	EventKey        = "webhook.event"
+	ActionKey       = "webhook.action"
+	RepositoryKey   = "webhook.repository"
+	DeliveryIDKey   = "webhook.delivery_id"
+	VerificationKey = "webhook.verification"
+)
+
+// Tracer starts spans.
+type Tracer interface {
+	// Start starts a span named name, child of the span carried by ctx if any,
+	// and returns a copy of ctx carrying the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation of a delivery.
+type Span interface {
+	// SetAttributes sets attributes of the span, replacing those with the same key.
+	SetAttributes(attrs ...Attribute)
+	// RecordError records err and marks the span as failed.
+	RecordError(err error)
+	// End ends the span.
+	End()
+}
+
+// Attribute is a key value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+type spanKey struct{}
+
+// String returns an attribute.
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// ContextWithSpan returns a copy of ctx carrying span,
+// for Tracer implementations that do not have their own way to carry spans.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx with ContextWithSpan, e.g. by the context of a handler.
+func SpanFromContext(ctx context.Context) (Span, bool) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	return span, ok
+}
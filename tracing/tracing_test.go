@@ -0,0 +1,143 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pchchv/wh/dispatch"
+	"github.com/pchchv/wh/github"
+	"github.com/pchchv/wh/tracing"
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+
+func spans(m *tracing.Memory) map[string]tracing.SpanData {
+	byName := map[string]tracing.SpanData{}
+	for _, s := range m.Spans() {
+		byName[s.Name] = s
+	}
+	return byName
+}
+
+func TestMemory(t *testing.T) {
+	assert := require.New(t)
+	m := tracing.NewMemory()
+	ctx, parent := m.Start(context.Background(), "parent")
+	parent.SetAttributes(tracing.String("a", "1"), tracing.String("b", "2"))
+	parent.SetAttributes(tracing.String("a", "3"))
+
+	childCtx, child := m.Start(ctx, "child")
+	s, ok := tracing.SpanFromContext(childCtx)
+	assert.True(ok)
+	assert.Equal(child, s)
+	child.RecordError(errors.New("failed"))
+	child.End()
+	parent.End()
+	parent.End()
+
+	got := m.Spans()
+	assert.Len(got, 2)
+	assert.Equal("child", got[0].Name)
+	assert.Equal(got[1].SpanID, got[0].ParentID)
+	assert.Equal(got[1].TraceID, got[0].TraceID)
+	assert.EqualError(got[0].Err, "failed")
+	assert.Empty(got[1].ParentID)
+	assert.Equal(map[string]string{"a": "3", "b": "2"}, got[1].Attributes)
+	assert.False(got[1].End.Before(got[1].Start))
+
+	_, other := m.Start(context.Background(), "other")
+	other.End()
+	assert.NotEqual(got[1].TraceID, m.Spans()[2].TraceID)
+
+	m.Reset()
+	assert.Empty(m.Spans())
+}
+
+func TestProvider(t *testing.T) {
+	assert := require.New(t)
+	m := tracing.NewMemory()
+	hook, err := github.New(github.Options.Secret(secret), github.Options.Tracer(m))
+	assert.NoError(err)
+
+	d := whtest.GitHub(github.PullRequestEvent, whtest.Fixture(t, "../github/testdata/pull-request.json")).
+		Secret(secret).
+		ID("72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	_, err = hook.Parse(d.Request(), github.PullRequestEvent)
+	assert.NoError(err)
+
+	got := spans(m)
+	assert.Len(got, 3)
+	delivery := got[tracing.DeliverySpan]
+	assert.NoError(delivery.Err)
+	assert.Equal(map[string]string{
+		tracing.ProviderKey:     "github",
+		tracing.EventKey:        "pull_request",
+		tracing.ActionKey:       "opened",
+		tracing.RepositoryKey:   "baxterthehacker/public-repo",
+		tracing.DeliveryIDKey:   "72d3162e-cc78-11e3-81ab-4c9367dc0958",
+		tracing.VerificationKey: "ok",
+	}, delivery.Attributes)
+	assert.Equal(delivery.SpanID, got[tracing.VerifySpan].ParentID)
+	assert.Equal("ok", got[tracing.VerifySpan].Attributes[tracing.VerificationKey])
+	assert.Equal(delivery.SpanID, got[tracing.DecodeSpan].ParentID)
+
+	m.Reset()
+	_, err = hook.Parse(d.Tampered(), github.PullRequestEvent)
+	assert.Error(err)
+	got = spans(m)
+	assert.Len(got, 2)
+	assert.Error(got[tracing.DeliverySpan].Err)
+	assert.Error(got[tracing.VerifySpan].Err)
+	assert.Equal("failed", got[tracing.DeliverySpan].Attributes[tracing.VerificationKey])
+	assert.NotContains(got[tracing.DeliverySpan].Attributes, tracing.RepositoryKey)
+}
+
+func TestDispatcher(t *testing.T) {
+	assert := require.New(t)
+	m := tracing.NewMemory()
+	hook, err := github.New(github.Options.Secret(secret))
+	assert.NoError(err)
+
+	handled := make(chan tracing.Span, 1)
+	dispatcher, err := dispatch.New(func(r *http.Request) (interface{}, error) {
+		return hook.Parse(r, github.PushEvent)
+	}, func(ctx context.Context, payload interface{}) error {
+		span, _ := tracing.SpanFromContext(ctx)
+		handled <- span
+		return errors.New("downstream unavailable")
+	}, dispatch.Options.Tracer(m))
+	assert.NoError(err)
+
+	w := httptest.NewRecorder()
+	dispatcher.ServeHTTP(w, whtest.GitHub(github.PushEvent, whtest.Fixture(t, "../github/testdata/push.json")).Secret(secret).Request())
+	assert.Equal(http.StatusAccepted, w.Code)
+	assert.NotNil(<-handled)
+	assert.NoError(dispatcher.Shutdown(context.Background()))
+
+	// one span per delivery even though the provider has no tracer
+	got := spans(m)
+	assert.Len(got, 4)
+	delivery := got[tracing.DeliverySpan]
+	assert.Equal("github", delivery.Attributes[tracing.ProviderKey])
+	assert.Equal("push", delivery.Attributes[tracing.EventKey])
+	assert.Equal("binkkatal/sample_app", delivery.Attributes[tracing.RepositoryKey])
+	assert.EqualError(delivery.Err, "downstream unavailable")
+	for _, name := range []string{tracing.VerifySpan, tracing.DecodeSpan, tracing.HandleSpan} {
+		assert.Equal(delivery.SpanID, got[name].ParentID, name)
+	}
+	assert.EqualError(got[tracing.HandleSpan].Err, "downstream unavailable")
+
+	// rejected deliveries end the span right away
+	m.Reset()
+	w = httptest.NewRecorder()
+	dispatcher.ServeHTTP(w, whtest.GitHub(github.PushEvent, whtest.Fixture(t, "../github/testdata/push.json")).Secret(secret).Tampered())
+	assert.Equal(http.StatusBadRequest, w.Code)
+	got = spans(m)
+	assert.Len(got, 2)
+	assert.Error(got[tracing.DeliverySpan].Err)
+}
@@ -0,0 +1,521 @@
+// The `whtest` package builds signed webhook deliveries for testing receivers built with the wh packages.
+//
+//	r := whtest.GitHub(github.PushEvent, payload).Secret("secret").Request()
+//	pl, err := hook.Parse(r, github.PushEvent)
+//
+// The requests carry the headers each provider sends and pass the Parse of the matching package.
+// Unsigned and Tampered build the variants a receiver has to reject.
+package whtest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DefaultTarget is the request target of the built requests.
+const DefaultTarget = "/webhooks"
+
+// provider describes the headers a webhook provider sends with its deliveries.
+type provider struct {
+	name           string
+	eventHeader    string
+	deliveryHeader string
+	sign           func(h http.Header, body []byte, d *Delivery)
+	// tamper invalidates the credentials of providers whose signature does not cover the body
+	tamper func(h http.Header)
+}
+
+var (
+	github = provider{
+		name:           "github",
+		eventHeader:    "X-GitHub-Event",
+		deliveryHeader: "X-GitHub-Delivery",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			h.Set("X-Hub-Signature", "sha1="+hexMAC(sha1.New, d.secret, body))
+			h.Set("X-Hub-Signature-256", "sha256="+hexMAC(sha256.New, d.secret, body))
+		},
+	}
+	gitlab = provider{
+		name:           "gitlab",
+		eventHeader:    "X-Gitlab-Event",
+		deliveryHeader: "X-Gitlab-Event-UUID",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			h.Set("X-Gitlab-Token", d.secret)
+		},
+		tamper: func(h http.Header) {
+			h.Set("X-Gitlab-Token", h.Get("X-Gitlab-Token")+"-tampered")
+		},
+	}
+	gitee = provider{
+		name:        "gitee",
+		eventHeader: "X-Gitee-Event",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			h.Set("X-Gitee-Token", d.secret)
+		},
+		tamper: func(h http.Header) {
+			h.Set("X-Gitee-Token", h.Get("X-Gitee-Token")+"-tampered")
+		},
+	}
+	giteeSigned = provider{
+		name:        "gitee",
+		eventHeader: "X-Gitee-Event",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			mac := hmac.New(sha256.New, []byte(d.secret))
+			_, _ = io.WriteString(mac, timestamp+"\n"+d.secret)
+			h.Set("X-Gitee-Timestamp", timestamp)
+			h.Set("X-Gitee-Token", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		},
+		tamper: func(h http.Header) {
+			h.Set("X-Gitee-Token", h.Get("X-Gitee-Token")+"-tampered")
+		},
+	}
+	gitea = provider{
+		name:           "gitea",
+		eventHeader:    "X-Gitea-Event",
+		deliveryHeader: "X-Gitea-Delivery",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			h.Set("X-Gitea-Signature", hexMAC(sha256.New, d.secret, body))
+		},
+	}
+	forgejo = provider{
+		name:           "forgejo",
+		eventHeader:    "X-Forgejo-Event",
+		deliveryHeader: "X-Forgejo-Delivery",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			h.Set("X-Forgejo-Signature", hexMAC(sha256.New, d.secret, body))
+		},
+	}
+	gogs = provider{
+		name:           "gogs",
+		eventHeader:    "X-Gogs-Event",
+		deliveryHeader: "X-Gogs-Delivery",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			h.Set("X-Gogs-Signature", hexMAC(sha256.New, d.secret, body))
+		},
+	}
+	bitbucket = provider{
+		name:           "bitbucket",
+		eventHeader:    "X-Event-Key",
+		deliveryHeader: "X-Request-UUID",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			h.Set("X-Hook-UUID", d.secret)
+		},
+		tamper: func(h http.Header) {
+			h.Set("X-Hook-UUID", h.Get("X-Hook-UUID")+"-tampered")
+		},
+	}
+	bitbucketServer = provider{
+		name:           "bitbucket-server",
+		eventHeader:    "X-Event-Key",
+		deliveryHeader: "X-Request-Id",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			h.Set("X-Hub-Signature", "sha256="+hexMAC(sha256.New, d.secret, body))
+		},
+	}
+	sourcehut = provider{
+		name:           "sourcehut",
+		eventHeader:    "X-Webhook-Event",
+		deliveryHeader: "X-Webhook-Delivery",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			key, err := base64.StdEncoding.DecodeString(d.secret)
+			if err != nil || len(key) != ed25519.PrivateKeySize {
+				panic("whtest: invalid Ed25519 private key")
+			}
+
+			var b [16]byte
+			_, _ = rand.Read(b[:])
+			nonce := hex.EncodeToString(b[:])
+			sig := ed25519.Sign(ed25519.PrivateKey(key), append(bytes.Clone(body), nonce...))
+			h.Set("X-Payload-Nonce", nonce)
+			h.Set("X-Payload-Signature", base64.StdEncoding.EncodeToString(sig))
+		},
+	}
+	// SNS signs the message in the body, see the codecommit package
+	codecommit = provider{
+		name:           "codecommit",
+		eventHeader:    "X-Amz-Sns-Message-Type",
+		deliveryHeader: "X-Amz-Sns-Message-Id",
+		sign:           func(h http.Header, body []byte, d *Delivery) {},
+	}
+	azure = provider{
+		name:   "azure",
+		sign:   signBasicAuth,
+		tamper: tamperBasicAuth,
+	}
+	gerrit = provider{
+		name:   "gerrit",
+		sign:   signBasicAuth,
+		tamper: tamperBasicAuth,
+	}
+	harbor = provider{
+		name: "harbor",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			h.Set("Authorization", d.secret)
+		},
+		tamper: func(h http.Header) {
+			h.Set("Authorization", h.Get("Authorization")+"-tampered")
+		},
+	}
+	quay = provider{
+		name:   "quay",
+		sign:   signBasicAuth,
+		tamper: tamperBasicAuth,
+	}
+	jenkins = provider{
+		name:   "jenkins",
+		sign:   signBasicAuth,
+		tamper: tamperBasicAuth,
+	}
+	// the webhook plugin sends the token, or the credentials, in the Authorization header
+	woodpecker = provider{
+		name: "woodpecker",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			if d.username != "" {
+				signBasicAuth(h, body, d)
+				return
+			}
+			h.Set("Authorization", "Bearer "+d.secret)
+		},
+		tamper: func(h http.Header) {
+			h.Set("Authorization", h.Get("Authorization")+"-tampered")
+		},
+	}
+	// Drone signs the request target, date and digest of the body with HTTP Signatures
+	drone = provider{
+		name:        "drone",
+		eventHeader: "X-Drone-Event",
+		sign: func(h http.Header, body []byte, d *Delivery) {
+			digest := sha256.Sum256(body)
+			h.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+			h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+
+			target := d.target
+			if u, err := url.Parse(d.target); err == nil {
+				target = u.RequestURI()
+			}
+			signing := "(request-target): " + strings.ToLower(d.method) + " " + target +
+				"\ndate: " + h.Get("Date") +
+				"\ndigest: " + h.Get("Digest")
+			mac := hmac.New(sha256.New, []byte(d.secret))
+			_, _ = mac.Write([]byte(signing))
+			h.Set("Signature", fmt.Sprintf(`keyId="hmac-key",algorithm="hmac-sha256",headers="(request-target) date digest",signature="%s"`,
+				base64.StdEncoding.EncodeToString(mac.Sum(nil))))
+		},
+	}
+	docker = provider{
+		name: "docker",
+		sign: func(h http.Header, body []byte, d *Delivery) {},
+	}
+)
+
+// Delivery builds the requests of a provider delivery.
+type Delivery struct {
+	provider provider
+	event    string
+	payload  interface{}
+	secret   string
+	username string
+	signed   bool
+	id       string
+	method   string
+	target   string
+	header   http.Header
+}
+
+// GitHub returns a delivery of a GitHub event, see Secret.
+func GitHub[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(github, string(event), payload)
+}
+
+// GitLab returns a delivery of a GitLab event, Secret sets the X-Gitlab-Token.
+func GitLab[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(gitlab, string(event), payload)
+}
+
+// Gitee returns a delivery of a Gitee event, Secret sets the WebHook password sent in the X-Gitee-Token.
+func Gitee[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(gitee, string(event), payload)
+}
+
+// GiteeSigned returns a delivery of a Gitee event signed with a signing key,
+// Secret sets the key the X-Gitee-Token is computed with for the current X-Gitee-Timestamp.
+func GiteeSigned[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(giteeSigned, string(event), payload)
+}
+
+// Gitea returns a delivery of a Gitea event, see Secret.
+func Gitea[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(gitea, string(event), payload)
+}
+
+// Forgejo returns a delivery of a Forgejo event, see Secret.
+func Forgejo[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(forgejo, string(event), payload)
+}
+
+// Gogs returns a delivery of a Gogs event, see Secret.
+func Gogs[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(gogs, string(event), payload)
+}
+
+// Bitbucket returns a delivery of a Bitbucket Cloud event, Secret sets the X-Hook-UUID.
+func Bitbucket[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(bitbucket, string(event), payload)
+}
+
+// BitbucketServer returns a delivery of a Bitbucket Server event, see Secret.
+func BitbucketServer[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(bitbucketServer, string(event), payload)
+}
+
+// SourceHut returns a delivery of a SourceHut event, Secret sets the base64 encoded Ed25519 private key
+// signing it with a new nonce for every request.
+func SourceHut[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(sourcehut, string(event), payload)
+}
+
+// CodeCommit returns a delivery of an SNS message of a CodeCommit trigger, the event is the message type.
+// SNS signs the message itself rather than the delivery, so Secret has no effect.
+func CodeCommit[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(codecommit, string(event), payload)
+}
+
+// Azure returns a delivery of an Azure DevOps event, see BasicAuth.
+// Azure DevOps sends the event type in the payload, so the delivery has no event header.
+func Azure(payload interface{}) *Delivery {
+	return newDelivery(azure, "", payload)
+}
+
+// Gerrit returns a delivery of a Gerrit webhooks plugin event, see BasicAuth.
+// Gerrit sends the event type in the payload, so the delivery has no event header.
+func Gerrit(payload interface{}) *Delivery {
+	return newDelivery(gerrit, "", payload)
+}
+
+// Harbor returns a delivery of a Harbor event, the secret is the Auth Header of the webhook policy.
+// Harbor sends the event type in the payload, so the delivery has no event header.
+func Harbor(payload interface{}) *Delivery {
+	return newDelivery(harbor, "", payload)
+}
+
+// Quay returns a delivery of a Quay notification, see BasicAuth.
+// Quay sends no event header, the event is the event query parameter of the target.
+func Quay[E ~string](event E, payload interface{}) *Delivery {
+	d := newDelivery(quay, "", payload)
+	d.target = DefaultTarget + "?event=" + url.QueryEscape(string(event))
+	return d
+}
+
+// Jenkins returns a delivery of a Jenkins Notification plugin notification, see BasicAuth.
+// Jenkins sends the build phase in the payload, so the delivery has no event header.
+func Jenkins(payload interface{}) *Delivery {
+	return newDelivery(jenkins, "", payload)
+}
+
+// Woodpecker returns a delivery of the Woodpecker webhook plugin, the secret is its bearer token
+// unless basic auth credentials are set.
+// Woodpecker sends the pipeline status in the payload, so the delivery has no event header.
+func Woodpecker(payload interface{}) *Delivery {
+	return newDelivery(woodpecker, "", payload)
+}
+
+// Drone returns a delivery of a Drone global webhook, signed with HTTP Signatures.
+func Drone[E ~string](event E, payload interface{}) *Delivery {
+	return newDelivery(drone, string(event), payload)
+}
+
+// Docker returns a delivery of a Docker Hub build notice, Docker Hub does not sign its deliveries.
+func Docker(payload interface{}) *Delivery {
+	return newDelivery(docker, "build", payload)
+}
+
+func newDelivery(p provider, event string, payload interface{}) *Delivery {
+	return &Delivery{
+		provider: p,
+		event:    event,
+		payload:  payload,
+		id:       newUUID(),
+		method:   http.MethodPost,
+		target:   DefaultTarget,
+		header:   http.Header{},
+	}
+}
+
+// Secret signs the delivery with the webhook secret.
+// It is the token of GitLab, the password or signing key of Gitee, the hook UUID of Bitbucket Cloud,
+// the password of Azure DevOps, Gerrit, Quay or Jenkins, the Auth Header of Harbor, the bearer token
+// of Woodpecker, the HMAC secret of Drone, and the base64 encoded Ed25519 private key of SourceHut.
+func (d *Delivery) Secret(secret string) *Delivery {
+	d.secret = secret
+	d.signed = true
+	return d
+}
+
+// BasicAuth sets the basic auth credentials of an Azure DevOps, Gerrit, Quay, Jenkins or Woodpecker delivery.
+func (d *Delivery) BasicAuth(username, password string) *Delivery {
+	d.username = username
+	return d.Secret(password)
+}
+
+// ID sets the delivery ID header, a random UUID by default.
+func (d *Delivery) ID(id string) *Delivery {
+	d.id = id
+	return d
+}
+
+// Method sets the request method, POST by default.
+func (d *Delivery) Method(method string) *Delivery {
+	d.method = method
+	return d
+}
+
+// Target sets the request target, DefaultTarget by default.
+func (d *Delivery) Target(target string) *Delivery {
+	d.target = target
+	return d
+}
+
+// Header sets a request header after the delivery is signed, replacing the provider header of the same name.
+func (d *Delivery) Header(key, value string) *Delivery {
+	d.header.Set(key, value)
+	return d
+}
+
+// Body returns the payload as sent on the wire.
+// It panics if the payload cannot be read or encoded.
+func (d *Delivery) Body() []byte {
+	switch pl := d.payload.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return pl
+	case json.RawMessage:
+		return pl
+	case string:
+		return []byte(pl)
+	case io.Reader:
+		body, err := io.ReadAll(pl)
+		if err != nil {
+			panic("whtest: reading payload: " + err.Error())
+		}
+		// keep the body for the next requests
+		d.payload = body
+		return body
+	default:
+		body, err := json.Marshal(pl)
+		if err != nil {
+			panic("whtest: encoding payload: " + err.Error())
+		}
+		return body
+	}
+}
+
+// Request returns the delivery as the provider sends it, signed when a secret is set.
+// It panics if the payload cannot be read or encoded, like httptest.NewRequest.
+func (d *Delivery) Request() *http.Request {
+	body := d.Body()
+	return d.request(body, d.headers(body, d.signed))
+}
+
+// Unsigned returns the delivery without the signature headers, even when a secret is set.
+func (d *Delivery) Unsigned() *http.Request {
+	body := d.Body()
+	return d.request(body, d.headers(body, false))
+}
+
+// Tampered returns the signed delivery altered in transit.
+// Providers signing the body get a body changed after it was signed,
+// the others, whose token or credentials do not cover the body, get changed credentials.
+// Docker Hub deliveries carry no credentials and are only changed in the body.
+func (d *Delivery) Tampered() *http.Request {
+	body := d.Body()
+	h := d.headers(body, true)
+	if d.provider.tamper != nil {
+		d.provider.tamper(h)
+		return d.request(body, h)
+	}
+
+	// trailing whitespace keeps JSON payloads valid so only the signature check fails
+	return d.request(append(bytes.Clone(body), ' '), h)
+}
+
+func (d *Delivery) headers(body []byte, signed bool) http.Header {
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	if d.provider.eventHeader != "" {
+		h.Set(d.provider.eventHeader, d.event)
+	}
+
+	if d.provider.deliveryHeader != "" && d.id != "" {
+		h.Set(d.provider.deliveryHeader, d.id)
+	}
+
+	if signed {
+		d.provider.sign(h, body, d)
+	}
+
+	for k, v := range d.header {
+		h[k] = append([]string(nil), v...)
+	}
+	return h
+}
+
+func (d *Delivery) request(body []byte, h http.Header) *http.Request {
+	r := httptest.NewRequest(d.method, d.target, bytes.NewReader(body))
+	r.Header = h
+	return r
+}
+
+func signBasicAuth(h http.Header, body []byte, d *Delivery) {
+	r := http.Request{Header: h}
+	r.SetBasicAuth(d.username, d.secret)
+}
+
+func tamperBasicAuth(h http.Header) {
+	r := http.Request{Header: h}
+	username, password, _ := r.BasicAuth()
+	r.SetBasicAuth(username, password+"-tampered")
+}
+
+// Fixture reads a payload file, e.g. from testdata, failing the test if it cannot be read.
+func Fixture(tb testing.TB, path string) []byte {
+	tb.Helper()
+	body, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("whtest: reading fixture: %v", err)
+	}
+	return body
+}
+
+func hexMAC(fn func() hash.Hash, secret string, body []byte) string {
+	mac := hmac.New(fn, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
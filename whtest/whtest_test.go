@@ -0,0 +1,487 @@
+package whtest_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	client "github.com/gogits/go-gogs-client"
+	"github.com/pchchv/wh/azure"
+	"github.com/pchchv/wh/bitbucket"
+	bitbucketserver "github.com/pchchv/wh/bitbucket-server"
+	"github.com/pchchv/wh/codecommit"
+	"github.com/pchchv/wh/docker"
+	"github.com/pchchv/wh/drone"
+	"github.com/pchchv/wh/forgejo"
+	"github.com/pchchv/wh/gerrit"
+	"github.com/pchchv/wh/gitea"
+	"github.com/pchchv/wh/gitee"
+	"github.com/pchchv/wh/github"
+	"github.com/pchchv/wh/gitlab"
+	"github.com/pchchv/wh/gogs"
+	"github.com/pchchv/wh/harbor"
+	"github.com/pchchv/wh/jenkins"
+	"github.com/pchchv/wh/quay"
+	"github.com/pchchv/wh/sourcehut"
+	"github.com/pchchv/wh/whtest"
+	"github.com/pchchv/wh/woodpecker"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+
+var (
+	// sourcehutKey is the private key SourceHut signs deliveries with instead of a shared secret
+	sourcehutKey       = ed25519.NewKeyFromSeed([]byte(secret[:ed25519.SeedSize]))
+	sourcehutSecret    = base64.StdEncoding.EncodeToString(sourcehutKey)
+	sourcehutPublicKey = base64.StdEncoding.EncodeToString(sourcehutKey.Public().(ed25519.PublicKey))
+)
+
+func TestDeliveries(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery *whtest.Delivery
+		parse    func(r *http.Request) (interface{}, error)
+		typ      interface{}
+		// signed reports whether the provider rejects unsigned and tampered deliveries
+		signed bool
+	}{
+		{
+			name:     "GitHub",
+			delivery: whtest.GitHub(github.PullRequestEvent, whtest.Fixture(t, "../github/testdata/pull-request.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := github.New(github.Options.Secret(secret))
+				return hook.Parse(r, github.PullRequestEvent)
+			},
+			typ:    github.PullRequestPayload{},
+			signed: true,
+		},
+		{
+			name:     "GitLab",
+			delivery: whtest.GitLab(gitlab.MergeRequestEvents, whtest.Fixture(t, "../gitlab/testdata/merge-request-event.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := gitlab.New(gitlab.Options.Secret(secret))
+				return hook.Parse(r, gitlab.MergeRequestEvents)
+			},
+			typ:    gitlab.MergeRequestEventPayload{},
+			signed: true,
+		},
+		{
+			name:     "Gitee",
+			delivery: whtest.Gitee(gitee.PushEvents, whtest.Fixture(t, "../gitee/testdata/push-event.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := gitee.New(gitee.Options.Secret(secret))
+				return hook.Parse(r, gitee.PushEvents)
+			},
+			typ:    gitee.PushEventPayload{},
+			signed: true,
+		},
+		{
+			name:     "GiteeSigned",
+			delivery: whtest.GiteeSigned(gitee.PushEvents, whtest.Fixture(t, "../gitee/testdata/push-event.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := gitee.New(gitee.Options.SigningKey(secret))
+				return hook.Parse(r, gitee.PushEvents)
+			},
+			typ:    gitee.PushEventPayload{},
+			signed: true,
+		},
+		{
+			name:     "Gitea",
+			delivery: whtest.Gitea(gitea.PushEvent, whtest.Fixture(t, "../gitea/testdata/push-event.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := gitea.New(gitea.Options.Secret(secret))
+				return hook.Parse(r, gitea.PushEvent)
+			},
+			typ:    gitea.PushPayload{},
+			signed: true,
+		},
+		{
+			name:     "Forgejo",
+			delivery: whtest.Forgejo(forgejo.PushEvent, whtest.Fixture(t, "../forgejo/testdata/push-event.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := forgejo.New(forgejo.Options.Secret(secret))
+				return hook.Parse(r, forgejo.PushEvent)
+			},
+			typ:    forgejo.PushPayload{},
+			signed: true,
+		},
+		{
+			name:     "SourceHut",
+			delivery: whtest.SourceHut(sourcehut.RepoPostUpdateEvent, whtest.Fixture(t, "../sourcehut/testdata/repo-post-update.json")).Secret(sourcehutSecret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := sourcehut.New(sourcehut.Options.PublicKey(sourcehutPublicKey))
+				return hook.Parse(r, sourcehut.RepoPostUpdateEvent)
+			},
+			typ:    sourcehut.PostUpdatePayload{},
+			signed: true,
+		},
+		{
+			name:     "Gogs",
+			delivery: whtest.Gogs(gogs.PushEvent, whtest.Fixture(t, "../gogs/testdata/push-event.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := gogs.New(gogs.Options.Secret(secret))
+				return hook.Parse(r, gogs.PushEvent)
+			},
+			typ:    client.PushPayload{},
+			signed: true,
+		},
+		{
+			name:     "Bitbucket",
+			delivery: whtest.Bitbucket(bitbucket.RepoPushEvent, whtest.Fixture(t, "../bitbucket/testdata/repo-push.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := bitbucket.New(bitbucket.Options.UUID(secret))
+				return hook.Parse(r, bitbucket.RepoPushEvent)
+			},
+			typ:    bitbucket.RepoPushPayload{},
+			signed: true,
+		},
+		{
+			name:     "BitbucketServer",
+			delivery: whtest.BitbucketServer(bitbucketserver.PullRequestOpenedEvent, whtest.Fixture(t, "../bitbucket-server/testdata/pr-opened.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := bitbucketserver.New(bitbucketserver.Options.Secret(secret))
+				return hook.Parse(r, bitbucketserver.PullRequestOpenedEvent)
+			},
+			typ:    bitbucketserver.PullRequestOpenedPayload{},
+			signed: true,
+		},
+		{
+			name:     "Azure",
+			delivery: whtest.Azure(whtest.Fixture(t, "../azure/testdata/git.push.json")).BasicAuth("user", secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := azure.New(azure.Options.BasicAuth("user", secret))
+				return hook.Parse(r, azure.GitPushEventType)
+			},
+			typ:    azure.GitPushEvent{},
+			signed: true,
+		},
+		{
+			name:     "Gerrit",
+			delivery: whtest.Gerrit(whtest.Fixture(t, "../gerrit/testdata/patchset-created.json")).BasicAuth("user", secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := gerrit.New(gerrit.Options.BasicAuth("user", secret))
+				return hook.Parse(r, gerrit.PatchsetCreatedEvent)
+			},
+			typ:    gerrit.PatchsetCreatedPayload{},
+			signed: true,
+		},
+		{
+			name:     "CodeCommit",
+			delivery: whtest.CodeCommit(codecommit.NotificationEvent, whtest.Fixture(t, "../codecommit/testdata/notification.json")),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := codecommit.New()
+				return hook.Parse(r, codecommit.NotificationEvent)
+			},
+			typ: codecommit.NotificationPayload{},
+		},
+		{
+			name:     "Harbor",
+			delivery: whtest.Harbor(whtest.Fixture(t, "../harbor/testdata/push-artifact.json")).Secret("Bearer " + secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := harbor.New(harbor.Options.AuthHeader("Bearer " + secret))
+				return hook.Parse(r, harbor.PushArtifactEvent)
+			},
+			typ:    harbor.ArtifactPayload{},
+			signed: true,
+		},
+		{
+			name:     "Quay",
+			delivery: whtest.Quay(quay.BuildSuccessEvent, whtest.Fixture(t, "../quay/testdata/build-success.json")).BasicAuth("user", secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := quay.New(quay.Options.BasicAuth("user", secret))
+				return hook.Parse(r, quay.BuildSuccessEvent)
+			},
+			typ:    quay.BuildPayload{},
+			signed: true,
+		},
+		{
+			name:     "Jenkins",
+			delivery: whtest.Jenkins(whtest.Fixture(t, "../jenkins/testdata/completed.json")).BasicAuth("user", secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := jenkins.New(jenkins.Options.BasicAuth("user", secret))
+				return hook.Parse(r, jenkins.CompletedEvent)
+			},
+			typ:    jenkins.JobPayload{},
+			signed: true,
+		},
+		{
+			name:     "Woodpecker",
+			delivery: whtest.Woodpecker(whtest.Fixture(t, "../woodpecker/testdata/success.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := woodpecker.New(woodpecker.Options.Token(secret))
+				return hook.Parse(r, woodpecker.SuccessEvent)
+			},
+			typ:    woodpecker.PipelinePayload{},
+			signed: true,
+		},
+		{
+			name:     "Drone",
+			delivery: whtest.Drone(drone.BuildEvent, whtest.Fixture(t, "../drone/testdata/build-updated.json")).Secret(secret),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := drone.New(drone.Options.Secret(secret))
+				return hook.Parse(r, drone.BuildEvent)
+			},
+			typ:    drone.BuildPayload{},
+			signed: true,
+		},
+		{
+			name:     "Docker",
+			delivery: whtest.Docker(whtest.Fixture(t, "../docker/testdata/docker_hub_build_notice.json")),
+			parse: func(r *http.Request) (interface{}, error) {
+				hook, _ := docker.New()
+				return hook.Parse(r, docker.BuildEvent)
+			},
+			typ: docker.BuildPayload{},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+			pl, err := tc.parse(tc.delivery.Request())
+			assert.NoError(err)
+			assert.IsType(tc.typ, pl)
+
+			// a request can be built several times
+			_, err = tc.parse(tc.delivery.Request())
+			assert.NoError(err)
+
+			_, err = tc.parse(tc.delivery.Unsigned())
+			_, terr := tc.parse(tc.delivery.Tampered())
+			if tc.signed {
+				assert.Error(err)
+				assert.Error(terr)
+			} else {
+				assert.NoError(err)
+				assert.NoError(terr)
+			}
+		})
+	}
+}
+
+func TestDeliveryOptions(t *testing.T) {
+	assert := require.New(t)
+	type ping struct {
+		HookID int `json:"hook_id"`
+	}
+
+	d := whtest.GitHub(github.PingEvent, ping{HookID: 42}).
+		Secret(secret).
+		ID("72d3162e-cc78-11e3-81ab-4c9367dc0958").
+		Target("/hooks/github").
+		Header("User-Agent", "GitHub-Hookshot/044aadd")
+	r := d.Request()
+	assert.Equal(http.MethodPost, r.Method)
+	assert.Equal("/hooks/github", r.URL.Path)
+	assert.Equal("ping", r.Header.Get("X-GitHub-Event"))
+	assert.Equal("72d3162e-cc78-11e3-81ab-4c9367dc0958", r.Header.Get("X-GitHub-Delivery"))
+	assert.Equal("GitHub-Hookshot/044aadd", r.Header.Get("User-Agent"))
+	assert.True(strings.HasPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="))
+	assert.JSONEq(`{"hook_id":42}`, string(d.Body()))
+
+	hook, err := github.New(github.Options.Secret(secret))
+	assert.NoError(err)
+	pl, err := hook.Parse(r, github.PingEvent)
+	assert.NoError(err)
+	assert.Equal(42, pl.(github.PingPayload).HookID)
+
+	_, err = hook.Parse(d.Method(http.MethodGet).Request(), github.PingEvent)
+	assert.Error(err)
+
+	// readers are read once and reused
+	d = whtest.Gitea(gitea.PushEvent, strings.NewReader(`{"ref":"refs/heads/main"}`)).Secret(secret)
+	assert.Equal(d.Body(), d.Body())
+	assert.Empty(d.Unsigned().Header.Get("X-Gitea-Signature"))
+	assert.NotEmpty(d.Request().Header.Get("X-Gitea-Signature"))
+
+	assert.Panics(func() {
+		whtest.GitHub(github.PingEvent, func() {}).Request()
+	})
+}
+
+func TestLogger(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery *whtest.Delivery
+		parse    func(r *http.Request, logger *slog.Logger) (interface{}, error)
+	}{
+		{
+			name:     "GitHub",
+			delivery: whtest.GitHub(github.PullRequestEvent, whtest.Fixture(t, "../github/testdata/pull-request.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := github.New(github.Options.Secret(secret), github.Options.Logger(logger))
+				return hook.Parse(r, github.PullRequestEvent)
+			},
+		},
+		{
+			name:     "GitLab",
+			delivery: whtest.GitLab(gitlab.MergeRequestEvents, whtest.Fixture(t, "../gitlab/testdata/merge-request-event.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := gitlab.New(gitlab.Options.Secret(secret), gitlab.Options.Logger(logger))
+				return hook.Parse(r, gitlab.MergeRequestEvents)
+			},
+		},
+		{
+			name:     "Gitee",
+			delivery: whtest.Gitee(gitee.PushEvents, whtest.Fixture(t, "../gitee/testdata/push-event.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := gitee.New(gitee.Options.Secret(secret), gitee.Options.Logger(logger))
+				return hook.Parse(r, gitee.PushEvents)
+			},
+		},
+		{
+			name:     "Gitea",
+			delivery: whtest.Gitea(gitea.PushEvent, whtest.Fixture(t, "../gitea/testdata/push-event.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := gitea.New(gitea.Options.Secret(secret), gitea.Options.Logger(logger))
+				return hook.Parse(r, gitea.PushEvent)
+			},
+		},
+		{
+			name:     "Forgejo",
+			delivery: whtest.Forgejo(forgejo.PushEvent, whtest.Fixture(t, "../forgejo/testdata/push-event.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := forgejo.New(forgejo.Options.Secret(secret), forgejo.Options.Logger(logger))
+				return hook.Parse(r, forgejo.PushEvent)
+			},
+		},
+		{
+			name:     "SourceHut",
+			delivery: whtest.SourceHut(sourcehut.RepoPostUpdateEvent, whtest.Fixture(t, "../sourcehut/testdata/repo-post-update.json")).Secret(sourcehutSecret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := sourcehut.New(sourcehut.Options.PublicKey(sourcehutPublicKey), sourcehut.Options.Logger(logger))
+				return hook.Parse(r, sourcehut.RepoPostUpdateEvent)
+			},
+		},
+		{
+			name:     "Gogs",
+			delivery: whtest.Gogs(gogs.PushEvent, whtest.Fixture(t, "../gogs/testdata/push-event.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := gogs.New(gogs.Options.Secret(secret), gogs.Options.Logger(logger))
+				return hook.Parse(r, gogs.PushEvent)
+			},
+		},
+		{
+			name:     "Bitbucket",
+			delivery: whtest.Bitbucket(bitbucket.RepoPushEvent, whtest.Fixture(t, "../bitbucket/testdata/repo-push.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := bitbucket.New(bitbucket.Options.UUID(secret), bitbucket.Options.Logger(logger))
+				return hook.Parse(r, bitbucket.RepoPushEvent)
+			},
+		},
+		{
+			name:     "BitbucketServer",
+			delivery: whtest.BitbucketServer(bitbucketserver.PullRequestOpenedEvent, whtest.Fixture(t, "../bitbucket-server/testdata/pr-opened.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := bitbucketserver.New(bitbucketserver.Options.Secret(secret), bitbucketserver.Options.Logger(logger))
+				return hook.Parse(r, bitbucketserver.PullRequestOpenedEvent)
+			},
+		},
+		{
+			name:     "Azure",
+			delivery: whtest.Azure(whtest.Fixture(t, "../azure/testdata/git.push.json")).BasicAuth("user", secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := azure.New(azure.Options.BasicAuth("user", secret), azure.Options.Logger(logger))
+				return hook.Parse(r, azure.GitPushEventType)
+			},
+		},
+		{
+			name:     "Gerrit",
+			delivery: whtest.Gerrit(whtest.Fixture(t, "../gerrit/testdata/patchset-created.json")).BasicAuth("user", secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := gerrit.New(gerrit.Options.BasicAuth("user", secret), gerrit.Options.Logger(logger))
+				return hook.Parse(r, gerrit.PatchsetCreatedEvent)
+			},
+		},
+		{
+			name:     "CodeCommit",
+			delivery: whtest.CodeCommit(codecommit.NotificationEvent, whtest.Fixture(t, "../codecommit/testdata/notification.json")),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := codecommit.New(codecommit.Options.Logger(logger))
+				return hook.Parse(r, codecommit.NotificationEvent)
+			},
+		},
+		{
+			name:     "Harbor",
+			delivery: whtest.Harbor(whtest.Fixture(t, "../harbor/testdata/push-artifact.json")).Secret("Bearer " + secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := harbor.New(harbor.Options.AuthHeader("Bearer "+secret), harbor.Options.Logger(logger))
+				return hook.Parse(r, harbor.PushArtifactEvent)
+			},
+		},
+		{
+			name:     "Quay",
+			delivery: whtest.Quay(quay.BuildSuccessEvent, whtest.Fixture(t, "../quay/testdata/build-success.json")).BasicAuth("user", secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := quay.New(quay.Options.BasicAuth("user", secret), quay.Options.Logger(logger))
+				return hook.Parse(r, quay.BuildSuccessEvent)
+			},
+		},
+		{
+			name:     "Jenkins",
+			delivery: whtest.Jenkins(whtest.Fixture(t, "../jenkins/testdata/completed.json")).BasicAuth("user", secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := jenkins.New(jenkins.Options.BasicAuth("user", secret), jenkins.Options.Logger(logger))
+				return hook.Parse(r, jenkins.CompletedEvent)
+			},
+		},
+		{
+			name:     "Woodpecker",
+			delivery: whtest.Woodpecker(whtest.Fixture(t, "../woodpecker/testdata/success.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := woodpecker.New(woodpecker.Options.Token(secret), woodpecker.Options.Logger(logger))
+				return hook.Parse(r, woodpecker.SuccessEvent)
+			},
+		},
+		{
+			name:     "Drone",
+			delivery: whtest.Drone(drone.BuildEvent, whtest.Fixture(t, "../drone/testdata/build-updated.json")).Secret(secret),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := drone.New(drone.Options.Secret(secret), drone.Options.Logger(logger))
+				return hook.Parse(r, drone.BuildEvent)
+			},
+		},
+		{
+			name:     "Docker",
+			delivery: whtest.Docker(whtest.Fixture(t, "../docker/testdata/docker_hub_build_notice.json")),
+			parse: func(r *http.Request, logger *slog.Logger) (interface{}, error) {
+				hook, _ := docker.New(docker.Options.Logger(logger))
+				return hook.Parse(r, docker.BuildEvent)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			assert := require.New(t)
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			r := tc.delivery.Request()
+			_, err := tc.parse(r, logger)
+			assert.NoError(err)
+			assert.Contains(buf.String(), "level=DEBUG msg=\"webhook delivery received\"")
+			assert.Contains(buf.String(), "level=INFO msg=\"webhook delivery parsed\"")
+			assert.NotContains(buf.String(), secret)
+			for name, values := range r.Header {
+				if strings.Contains(name, "Signature") || strings.Contains(name, "Token") || name == "X-Hook-Uuid" {
+					assert.NotContains(buf.String(), values[0], name)
+				}
+			}
+
+			buf.Reset()
+			_, err = tc.parse(tc.delivery.Tampered(), logger)
+			if err != nil {
+				assert.Contains(buf.String(), "level=WARN msg=\"webhook delivery rejected\"")
+				assert.Contains(buf.String(), "verification=failed reason=verification")
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package woodpecker
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pchchv/wh/internal/fake"
+)
+
+// Faker builds realistic notifications of a repository to unit test handlers.
+// The notifications of a faker agree with each other on the repository, the commit author
+// and the previous pipeline of each branch, and encode to the JSON the Woodpecker webhook plugin sends,
+// e.g. to deliver them with the whtest package.
+// The same repository always yields the same notifications.
+type Faker struct {
+	repo     Repo
+	author   Author
+	number   int64
+	previous map[string]Pipeline
+	src      *fake.Source
+}
+
+// NewFaker returns a faker of the GitHub repository with the given full name, e.g. "example/api",
+// built by ci.example.com. Commits are authored by the user "John Doe".
+func NewFaker(fullName string) *Faker {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok {
+		owner, name = "example", fullName
+	}
+
+	f := &Faker{
+		repo: Repo{
+			ID:            fake.NameID(fullName),
+			Name:          name,
+			Owner:         owner,
+			RemoteID:      strconv.FormatInt(fake.NameID("github/"+fullName), 10),
+			ForgeURL:      "https://github.com/" + owner + "/" + name,
+			CloneURL:      "https://github.com/" + owner + "/" + name + ".git",
+			CloneSSHURL:   "git@github.com:" + owner + "/" + name + ".git",
+			DefaultBranch: "main",
+		},
+		previous: map[string]Pipeline{},
+		src:      fake.New("woodpecker/" + fullName),
+	}
+	return f.User("John Doe")
+}
+
+// User sets the name of the author of the commits built.
+func (f *Faker) User(name string) *Faker {
+	f.author = Author{
+		Name:   name,
+		Email:  strings.ToLower(strings.ReplaceAll(name, " ", ".")) + "@example.com",
+		Avatar: "https://avatars.githubusercontent.com/u/" + strconv.FormatInt(fake.NameID(name), 10),
+	}
+	return f
+}
+
+// PipelinePayload returns the notification of the next pipeline of a new commit on the branch, with the status,
+// sent from the last step of the pipeline. The previous pipeline is the last one of the branch.
+func (f *Faker) PipelinePayload(branch string, status Event) PipelinePayload {
+	f.number++
+	sha := f.src.SHA()
+	created := f.src.Time().Unix()
+	curr := Pipeline{
+		Number:   f.number,
+		Created:  created,
+		Started:  created + 2,
+		Status:   status,
+		Event:    "push",
+		ForgeURL: f.repo.ForgeURL + "/commit/" + sha,
+		Commit: Commit{
+			SHA:     sha,
+			Ref:     "refs/heads/" + branch,
+			Branch:  branch,
+			Message: "Update " + branch + "\n",
+			Author:  f.author,
+		},
+	}
+
+	prev := f.previous[branch]
+	finished := curr
+	finished.Finished = f.src.Time().Unix()
+	f.previous[branch] = finished
+
+	return PipelinePayload{
+		Repo:     f.repo,
+		Curr:     curr,
+		Prev:     prev,
+		Workflow: Workflow{Name: "build", Number: 1},
+		Step:     Step{Name: "notify", Number: 4},
+		Sys: System{
+			Name:     "woodpecker",
+			Host:     "ci.example.com",
+			Link:     "https://ci.example.com",
+			Platform: "linux/amd64",
+			Version:  "2.6.0",
+		},
+		Forge: Forge{Type: "github", URL: "https://github.com"},
+	}
+}
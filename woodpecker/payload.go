@@ -0,0 +1,107 @@
+package woodpecker
+
+import "time"
+
+// BasicEvent contains the status of the current pipeline, the event of a notification.
+type BasicEvent struct {
+	Curr struct {
+		Status Event `json:"status"`
+	} `json:"curr"`
+}
+
+// Repo contains the repository of a pipeline, ForgeURL is its page on the forge, e.g. on GitHub.
+type Repo struct {
+	ID            int64  `json:"id,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Owner         string `json:"owner,omitempty"`
+	RemoteID      string `json:"remote_id,omitempty"`
+	ForgeURL      string `json:"forge_url,omitempty"`
+	CloneURL      string `json:"clone_url,omitempty"`
+	CloneSSHURL   string `json:"clone_url_ssh,omitempty"`
+	Private       bool   `json:"private,omitempty"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+	Trusted       bool   `json:"trusted,omitempty"`
+}
+
+// Author contains the author of a commit.
+type Author struct {
+	Name   string `json:"name,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// Commit contains the commit a pipeline runs for, Labels are the labels of its pull request.
+type Commit struct {
+	SHA          string   `json:"sha,omitempty"`
+	Ref          string   `json:"ref,omitempty"`
+	Refspec      string   `json:"refspec,omitempty"`
+	Branch       string   `json:"branch,omitempty"`
+	Message      string   `json:"message,omitempty"`
+	Author       Author   `json:"author,omitempty"`
+	ChangedFiles []string `json:"changed_files,omitempty"`
+	Labels       []string `json:"labels,omitempty"`
+	IsPrerelease bool     `json:"is_prerelease,omitempty"`
+}
+
+// Pipeline contains a pipeline of a repository. Event is the event it runs for, e.g. "push", "pull_request",
+// "tag", "deployment", "cron" or "manual", and Target the environment of deployments.
+// Created, Started and Finished are the seconds since the epoch, Finished is zero while the pipeline runs.
+type Pipeline struct {
+	Number   int64  `json:"number,omitempty"`
+	Created  int64  `json:"created,omitempty"`
+	Started  int64  `json:"started,omitempty"`
+	Finished int64  `json:"finished,omitempty"`
+	Timeout  int64  `json:"timeout,omitempty"`
+	Status   Event  `json:"status,omitempty"`
+	Event    string `json:"event,omitempty"`
+	ForgeURL string `json:"forge_url,omitempty"`
+	Target   string `json:"target,omitempty"`
+	Commit   Commit `json:"commit,omitempty"`
+	Parent   int64  `json:"parent,omitempty"`
+	Cron     string `json:"cron,omitempty"`
+}
+
+// StartedAt returns the start of the pipeline.
+func (p Pipeline) StartedAt() time.Time {
+	return time.Unix(p.Started, 0).UTC()
+}
+
+// Workflow contains the workflow of the step sending a notification, Matrix its matrix axes.
+type Workflow struct {
+	Name   string            `json:"name,omitempty"`
+	Number int               `json:"number,omitempty"`
+	Matrix map[string]string `json:"matrix,omitempty"`
+}
+
+// Step contains the step sending a notification.
+type Step struct {
+	Name   string `json:"name,omitempty"`
+	Number int    `json:"number,omitempty"`
+}
+
+// System contains the Woodpecker server, Link is its URL.
+type System struct {
+	Name     string `json:"name,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Link     string `json:"link,omitempty"`
+	Platform string `json:"arch,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// Forge contains the forge of a repository, Type is e.g. "github", "gitlab", "gitea" or "forgejo".
+type Forge struct {
+	Type string `json:"type,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// PipelinePayload contains the information for the success and failure events, the metadata of the pipeline
+// the plugin runs in. Curr is the current pipeline and Prev the previous pipeline of the branch.
+type PipelinePayload struct {
+	Repo     Repo     `json:"repo,omitempty"`
+	Curr     Pipeline `json:"curr,omitempty"`
+	Prev     Pipeline `json:"prev,omitempty"`
+	Workflow Workflow `json:"workflow,omitempty"`
+	Step     Step     `json:"step,omitempty"`
+	Sys      System   `json:"sys,omitempty"`
+	Forge    Forge    `json:"forge,omitempty"`
+}
@@ -0,0 +1,299 @@
+// The `woodpecker` package accepts the pipeline notifications of the Woodpecker webhook plugin,
+// posted with its default JSON body, the metadata of the pipeline.
+//
+// The plugin runs as a step of a pipeline and sends no event header, the event is the status of the pipeline.
+// It authenticates itself with the token setting in the Authorization header as a bearer token,
+// or with the username and password settings as basic auth credentials.
+//
+// Deliveries signed by the Woodpecker server with HTTP Signatures are verified with its Ed25519 public key:
+// the Signature header must cover the Date header and the Digest header, the SHA-256 of the body,
+// and deliveries whose Date is further from the current time than the tolerance are rejected.
+package woodpecker
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pchchv/wh/internal/httpsig"
+	"github.com/pchchv/wh/internal/observe"
+	"github.com/pchchv/wh/metrics"
+	"github.com/pchchv/wh/tracing"
+)
+
+const (
+	// Woodpecker pipeline statuses, a notification step usually runs with success or failure.
+	SuccessEvent  Event = "success"
+	FailureEvent  Event = "failure"
+	KilledEvent   Event = "killed"
+	ErrorEvent    Event = "error"
+	PendingEvent  Event = "pending"
+	RunningEvent  Event = "running"
+	BlockedEvent  Event = "blocked"
+	DeclinedEvent Event = "declined"
+	SkippedEvent  Event = "skipped"
+)
+
+// DefaultTolerance is how far the Date of a signed delivery may be from the current time.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// Options is a namespace var for configuration options.
+	Options = WebhookOptions{}
+	// Parse errors.
+	ErrParsingPayload              = errors.New("error parsing payload")
+	ErrTokenVerificationFailed     = errors.New("bearer token verification failed")
+	ErrBasicAuthVerificationFailed = errors.New("basic auth verification failed")
+	ErrSignatureVerificationFailed = errors.New("signature verification failed")
+	// ErrTimestampExpired is returned when the Date of a signed delivery
+	// is further from the current time than the tolerance, e.g. of a replayed delivery.
+	ErrTimestampExpired = errors.New("Date out of tolerance")
+)
+
+// Event defines the status of a Woodpecker pipeline by the status field of the current pipeline.
+type Event string
+
+// provider describes the deliveries of Woodpecker to the instrumentation.
+var provider = &observe.Provider{
+	Name:          "woodpecker",
+	SecretHeaders: []string{"Signature"},
+}
+
+// Webhook instance contains all methods needed to process events.
+type Webhook struct {
+	tokenHash []byte
+	username  string
+	password  string
+	publicKey ed25519.PublicKey
+	tolerance time.Duration
+	now       func() time.Time
+	observer  observe.Observer
+}
+
+// Parse verifies and parses the events specified and returns the payload object or an error.
+// A panic while parsing, e.g. on hostile input, is recovered and returned as an error wrapping ErrParsingPayload.
+func (hook Webhook) Parse(r *http.Request, events ...Event) (payload interface{}, err error) {
+	d := hook.observer.Start(provider, r)
+	defer func() {
+		if p := recover(); p != nil {
+			payload, err = nil, fmt.Errorf("%w: %v", ErrParsingPayload, p)
+		}
+		err = d.Done(payload, err)
+	}()
+	return hook.parse(r, d, events...)
+}
+
+func (hook Webhook) parse(r *http.Request, d *observe.Delivery, events ...Event) (interface{}, error) {
+	defer func() {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}()
+
+	if len(events) == 0 {
+		return nil, errors.New("no Event specified to parse")
+	}
+
+	if r.Method != http.MethodPost {
+		return nil, errors.New("invalid HTTP Method")
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil || len(payload) == 0 {
+		return nil, ErrParsingPayload
+	}
+
+	err = d.Verify(len(hook.tokenHash) > 0 || hook.username != "" || hook.password != "" || hook.publicKey != nil, func() error {
+		return hook.verify(r, payload)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.Decoding()
+	var pl BasicEvent
+	if err = json.Unmarshal(payload, &pl); err != nil {
+		return nil, ErrParsingPayload
+	}
+
+	// Woodpecker sends the pipeline status in the payload
+	d.Event = string(pl.Curr.Status)
+
+	var found bool
+	for _, evt := range events {
+		if evt == pl.Curr.Status {
+			found = true
+			break
+		}
+	}
+
+	// event not defined to be parsed
+	if !found {
+		return nil, errors.New("event not defined to be parsed")
+	}
+
+	switch pl.Curr.Status {
+	case SuccessEvent, FailureEvent, KilledEvent, ErrorEvent, PendingEvent, RunningEvent, BlockedEvent, DeclinedEvent, SkippedEvent:
+		var fpl PipelinePayload
+		err = json.Unmarshal(payload, &fpl)
+		return fpl, err
+	default:
+		return nil, fmt.Errorf("unknown event %s", pl.Curr.Status)
+	}
+}
+
+// verify checks the token and the basic auth credentials configured, in constant time, and the signature.
+func (hook Webhook) verify(r *http.Request, payload []byte) error {
+	if len(hook.tokenHash) > 0 {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		tokenHash := sha512.Sum512([]byte(token))
+		if subtle.ConstantTimeCompare(tokenHash[:], hook.tokenHash) == 0 {
+			return ErrTokenVerificationFailed
+		}
+	}
+
+	if hook.username != "" || hook.password != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(hook.username)) == 0 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(hook.password)) == 0 {
+			return ErrBasicAuthVerificationFailed
+		}
+	}
+
+	if hook.publicKey != nil {
+		date, err := httpsig.Verify(r, payload, "ed25519", func(signing, signature string) error {
+			sig, err := base64.StdEncoding.DecodeString(signature)
+			if err != nil || !ed25519.Verify(hook.publicKey, []byte(signing), sig) {
+				return errors.New("Ed25519 verification failed")
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrSignatureVerificationFailed, err)
+		}
+
+		if httpsig.Expired(date, hook.now(), hook.tolerance) {
+			return ErrTimestampExpired
+		}
+	}
+	return nil
+}
+
+// Option is a configuration option for the webhook.
+type Option func(*Webhook) error
+
+// New creates and returns a WebHook instance.
+func New(options ...Option) (*Webhook, error) {
+	hook := &Webhook{tolerance: DefaultTolerance, now: time.Now}
+	for _, opt := range options {
+		if err := opt(hook); err != nil {
+			return nil, errors.New("Error applying Option")
+		}
+	}
+	return hook, nil
+}
+
+// WebhookOptions is a namespace for configuration option methods.
+type WebhookOptions struct{}
+
+// Token registers the token setting of the plugin, sent as a bearer token in the Authorization header.
+func (WebhookOptions) Token(token string) Option {
+	return func(hook *Webhook) error {
+		if token == "" {
+			return errors.New("token must not be empty")
+		}
+		// already convert here to prevent timing attack
+		// (conversion depends on secret)
+		hash := sha512.Sum512([]byte(token))
+		hook.tokenHash = hash[:]
+		return nil
+	}
+}
+
+// BasicAuth verifies payload using basic auth, i.e. the username and password settings of the plugin.
+func (WebhookOptions) BasicAuth(username, password string) Option {
+	return func(hook *Webhook) error {
+		hook.username = username
+		hook.password = password
+		return nil
+	}
+}
+
+// PublicKey registers the Ed25519 public key the Woodpecker server signs its deliveries with,
+// base64 encoded or the PEM block served at /api/signature/public-key.
+func (WebhookOptions) PublicKey(key string) Option {
+	return func(hook *Webhook) error {
+		if block, _ := pem.Decode([]byte(key)); block != nil {
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if k, ok := pub.(ed25519.PublicKey); err == nil && ok {
+				hook.publicKey = k
+				return nil
+			}
+			return errors.New("invalid Ed25519 public key")
+		}
+
+		b, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return errors.New("invalid Ed25519 public key")
+		}
+		hook.publicKey = ed25519.PublicKey(b)
+		return nil
+	}
+}
+
+// Tolerance sets how far the Date of a signed delivery may be from the current time, DefaultTolerance by default.
+func (WebhookOptions) Tolerance(tolerance time.Duration) Option {
+	return func(hook *Webhook) error {
+		if tolerance <= 0 {
+			return errors.New("tolerance must be positive")
+		}
+		hook.tolerance = tolerance
+		return nil
+	}
+}
+
+// Logger logs the deliveries with logger.
+func (WebhookOptions) Logger(logger *slog.Logger) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Logger = logger
+		return nil
+	}
+}
+
+// Metrics reports the deliveries to m, e.g. a metrics.Prometheus.
+func (WebhookOptions) Metrics(m metrics.Recorder) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Metrics = m
+		return nil
+	}
+}
+
+// Tracer traces the deliveries with tracer.
+func (WebhookOptions) Tracer(tracer tracing.Tracer) Option {
+	return func(hook *Webhook) error {
+		hook.observer.Tracer = tracer
+		return nil
+	}
+}
+
+// ReadTimeout aborts reading the body of a delivery taking longer than timeout.
+func (WebhookOptions) ReadTimeout(timeout time.Duration) Option {
+	return func(hook *Webhook) error {
+		if timeout < 0 {
+			return errors.New("read timeout must not be negative")
+		}
+		hook.observer.ReadTimeout = timeout
+		return nil
+	}
+}
@@ -0,0 +1,364 @@
+package woodpecker
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pchchv/wh/whtest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	path   = "/webhooks"
+	secret = "IsWishesWereHorsesWedAllBeEatingSteak!"
+)
+
+var hook *Webhook
+
+func TestMain(m *testing.M) {
+	// setup
+	var err error
+	hook, err = New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+	// teardown
+}
+
+func TestWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		typ      interface{}
+		filename string
+	}{
+		{
+			name:     "SuccessEvent",
+			event:    SuccessEvent,
+			typ:      PipelinePayload{},
+			filename: "./testdata/success.json",
+		},
+		{
+			name:     "FailureEvent",
+			event:    FailureEvent,
+			typ:      PipelinePayload{},
+			filename: "./testdata/failure.json",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert := require.New(t)
+			payload, err := os.Open(tc.filename)
+			assert.NoError(err)
+			defer func() {
+				_ = payload.Close()
+			}()
+
+			var parseError error
+			var results interface{}
+			server := newServer(func(w http.ResponseWriter, r *http.Request) {
+				results, parseError = hook.Parse(r, tc.event)
+			})
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodPost, server.URL+path, payload)
+			assert.NoError(err)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(err)
+			assert.Equal(http.StatusOK, resp.StatusCode)
+			assert.NoError(parseError)
+			assert.Equal(reflect.TypeOf(tc.typ), reflect.TypeOf(results))
+		})
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	assert := require.New(t)
+	parse := func(event Event, filename string) PipelinePayload {
+		pl, err := hook.Parse(whtest.Woodpecker(whtest.Fixture(t, filename)).Request(), event)
+		assert.NoError(err)
+		return pl.(PipelinePayload)
+	}
+
+	success := parse(SuccessEvent, "./testdata/success.json")
+	assert.Equal("example", success.Repo.Owner)
+	assert.Equal("api", success.Repo.Name)
+	assert.Equal(int64(318), success.Curr.Number)
+	assert.Equal(SuccessEvent, success.Curr.Status)
+	assert.Equal("push", success.Curr.Event)
+	assert.Equal("refs/heads/main", success.Curr.Commit.Ref)
+	assert.Equal("John Doe", success.Curr.Commit.Author.Name)
+	assert.Equal(time.Date(2024, 6, 3, 8, 43, 3, 0, time.UTC), success.Curr.StartedAt())
+	assert.Equal(FailureEvent, success.Prev.Status)
+	assert.Equal("notify", success.Step.Name)
+	assert.Equal("github", success.Forge.Type)
+
+	failure := parse(FailureEvent, "./testdata/failure.json")
+	assert.Equal("pull_request", failure.Curr.Event)
+	assert.Equal("feature-cache:main", failure.Curr.Commit.Refspec)
+	assert.Equal(success.Curr.Number, failure.Prev.Number)
+	assert.NotZero(failure.Prev.Finished)
+}
+
+func TestBadRequests(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/success.json")
+	tests := []struct {
+		name   string
+		events []Event
+		r      *http.Request
+	}{
+		{name: "NoEvents", r: whtest.Woodpecker(body).Request()},
+		{name: "UnsubscribedEvent", events: []Event{FailureEvent}, r: whtest.Woodpecker(body).Request()},
+		{name: "BadMethod", events: []Event{SuccessEvent}, r: whtest.Woodpecker(body).Method(http.MethodGet).Request()},
+		{name: "BadBody", events: []Event{SuccessEvent}, r: whtest.Woodpecker("").Request()},
+		{name: "BadJSON", events: []Event{SuccessEvent}, r: whtest.Woodpecker("{").Request()},
+		{name: "UnknownEvent", events: []Event{"canceled"}, r: whtest.Woodpecker(`{"curr":{"status":"canceled"}}`).Request()},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := hook.Parse(tc.r, tc.events...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestVerification(t *testing.T) {
+	body := whtest.Fixture(t, "./testdata/failure.json")
+	tests := []struct {
+		name    string
+		options []Option
+		r       *http.Request
+		wantErr error
+	}{
+		{
+			name:    "Token",
+			options: []Option{Options.Token(secret)},
+			r:       whtest.Woodpecker(body).Secret(secret).Request(),
+		},
+		{
+			name:    "WrongToken",
+			options: []Option{Options.Token(secret)},
+			r:       whtest.Woodpecker(body).Secret(secret).Tampered(),
+			wantErr: ErrTokenVerificationFailed,
+		},
+		{
+			name:    "MissingToken",
+			options: []Option{Options.Token(secret)},
+			r:       whtest.Woodpecker(body).Request(),
+			wantErr: ErrTokenVerificationFailed,
+		},
+		{
+			name:    "BasicAuth",
+			options: []Option{Options.BasicAuth("woodpecker", secret)},
+			r:       whtest.Woodpecker(body).BasicAuth("woodpecker", secret).Request(),
+		},
+		{
+			name:    "WrongPassword",
+			options: []Option{Options.BasicAuth("woodpecker", secret)},
+			r:       whtest.Woodpecker(body).BasicAuth("woodpecker", secret).Tampered(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+		{
+			name:    "TokenInsteadOfBasicAuth",
+			options: []Option{Options.BasicAuth("woodpecker", secret)},
+			r:       whtest.Woodpecker(body).Secret(secret).Request(),
+			wantErr: ErrBasicAuthVerificationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			h, err := New(tc.options...)
+			assert.NoError(err)
+			_, err = h.Parse(tc.r, FailureEvent)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+
+	_, err := New(Options.Token(""))
+	require.Error(t, err)
+}
+
+func TestSignature(t *testing.T) {
+	assert := require.New(t)
+	body := whtest.Fixture(t, "./testdata/failure.json")
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(err)
+
+	sum := sha256.Sum256(body)
+	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+	sign := func(params, signing string) string {
+		return params + `,signature="` + base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(signing))) + `"`
+	}
+
+	now := time.Date(2024, time.June, 3, 8, 44, 1, 0, time.UTC)
+	signed, err := New(Options.PublicKey(base64.StdEncoding.EncodeToString(pub)), Options.Tolerance(time.Minute))
+	assert.NoError(err)
+	signed.now = func() time.Time { return now }
+
+	date := now.Format(http.TimeFormat)
+	stale := now.Add(-2 * time.Minute).Format(http.TimeFormat)
+	params := `keyId="woodpecker-ci-plugins",algorithm="ed25519",headers="(request-target) date digest"`
+	tests := []struct {
+		name      string
+		body      string
+		date      string
+		signature string
+		wantErr   error
+	}{
+		{
+			name:      "Valid",
+			date:      date,
+			signature: sign(params, "(request-target): post /webhooks\ndate: "+date+"\ndigest: "+digest),
+		},
+		{
+			name:      "Tampered",
+			body:      " ",
+			date:      date,
+			signature: sign(params, "(request-target): post /webhooks\ndate: "+date+"\ndigest: "+digest),
+			wantErr:   ErrSignatureVerificationFailed,
+		},
+		{
+			name:      "OtherKey",
+			date:      date,
+			signature: params + `,signature="` + base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize)) + `"`,
+			wantErr:   ErrSignatureVerificationFailed,
+		},
+		{
+			name:      "Stale",
+			date:      stale,
+			signature: sign(params, "(request-target): post /webhooks\ndate: "+stale+"\ndigest: "+digest),
+			wantErr:   ErrTimestampExpired,
+		},
+		{
+			name:      "NotCoveringDigest",
+			date:      date,
+			signature: sign(`keyId="woodpecker-ci-plugins",algorithm="ed25519",headers="(request-target) date"`, "(request-target): post /webhooks\ndate: "+date),
+			wantErr:   ErrSignatureVerificationFailed,
+		},
+		{
+			name:      "NotCoveringDate",
+			date:      date,
+			signature: sign(`keyId="woodpecker-ci-plugins",algorithm="ed25519",headers="digest"`, "digest: "+digest),
+			wantErr:   ErrSignatureVerificationFailed,
+		},
+		{
+			name:    "Unsigned",
+			date:    date,
+			wantErr: ErrSignatureVerificationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+			r := whtest.Woodpecker(append(append([]byte(nil), body...), tc.body...)).
+				Header("Date", tc.date).Header("Digest", digest).Header("Signature", tc.signature).Request()
+			_, err := signed.Parse(r, FailureEvent)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	assert.NoError(err)
+	_, err = New(Options.PublicKey(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))))
+	assert.NoError(err)
+	_, err = New(Options.PublicKey("c2hvcnQ="))
+	assert.Error(err)
+	_, err = New(Options.Tolerance(0))
+	assert.Error(err)
+}
+
+func newServer(handler http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, handler)
+	return httptest.NewServer(mux)
+}
+
+func TestFaker(t *testing.T) {
+	assert := require.New(t)
+	faker := NewFaker("example/api").User("Jane Roe")
+	first := faker.PipelinePayload("main", FailureEvent)
+	assert.Equal("api", first.Repo.Name)
+	assert.Equal(int64(1), first.Curr.Number)
+	assert.Equal("Jane Roe", first.Curr.Commit.Author.Name)
+	assert.Zero(first.Prev.Number)
+
+	second := faker.PipelinePayload("main", SuccessEvent)
+	assert.Equal(int64(2), second.Curr.Number)
+	assert.Equal(first.Curr.Commit.SHA, second.Prev.Commit.SHA)
+	assert.Equal(FailureEvent, second.Prev.Status)
+	assert.NotZero(second.Prev.Finished)
+	assert.Zero(faker.PipelinePayload("develop", SuccessEvent).Prev.Number)
+	assert.Equal(first, NewFaker("example/api").User("Jane Roe").PipelinePayload("main", FailureEvent))
+
+	tests := []struct {
+		event   Event
+		payload interface{}
+	}{
+		{event: FailureEvent, payload: first},
+		{event: SuccessEvent, payload: second},
+		{event: KilledEvent, payload: faker.PipelinePayload("main", KilledEvent)},
+		{event: ErrorEvent, payload: faker.PipelinePayload("main", ErrorEvent)},
+	}
+	for _, tc := range tests {
+		pl, err := hook.Parse(whtest.Woodpecker(tc.payload).Request(), tc.event)
+		assert.NoError(err)
+		assert.Equal(tc.payload, pl)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []struct {
+		event    Event
+		filename string
+	}{
+		{SuccessEvent, "./testdata/success.json"},
+		{FailureEvent, "./testdata/failure.json"},
+	}
+	for _, s := range seeds {
+		payload, err := os.ReadFile(s.filename)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(s.event), payload)
+	}
+
+	// parse is fuzzed rather than Parse so panics are not hidden by its recovery
+	f.Fuzz(func(t *testing.T, event string, payload []byte) {
+		r := whtest.Woodpecker(payload).Request()
+		if pl, err := hook.parse(r, hook.observer.Start(provider, r), Event(event)); err == nil && pl == nil {
+			t.Fatal("no payload and no error")
+		}
+	})
+}